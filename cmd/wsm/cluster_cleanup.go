@@ -0,0 +1,680 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/operator/api/v2"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/localcluster"
+	"github.com/wandb/wsm/pkg/operator"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// The parts of a wsm deployment `cluster cleanup --only` selects.
+const (
+	cleanupScopeWandb        = "wandb"
+	cleanupScopeOperator     = "operator"
+	cleanupScopeCertManager  = "cert-manager"
+	cleanupScopeNginxGateway = "nginx-gateway"
+	cleanupScopeCRDs         = "crds"
+)
+
+var cleanupScopes = []string{cleanupScopeWandb, cleanupScopeOperator, cleanupScopeCertManager, cleanupScopeNginxGateway, cleanupScopeCRDs}
+
+// componentScopes maps deployment marker components, which are also the
+// inventory steps, to cleanup scopes.
+var componentScopes = map[string]string{
+	"wandb-cr":      cleanupScopeWandb,
+	"operator":      cleanupScopeOperator,
+	"cert-manager":  cleanupScopeCertManager,
+	"nginx-gateway": cleanupScopeNginxGateway,
+}
+
+// Cleanup runs in phases, each waiting for what it deleted to be gone before
+// the next starts: the W&B CRs go first, while the operator that runs their
+// finalizers is still installed, and the namespaces go last.
+const (
+	cleanupPhaseCRs = iota
+	cleanupPhaseObjects
+	cleanupPhaseCRDs
+	cleanupPhaseNamespaces
+)
+
+var wandbCREntry = kubectl.InventoryEntry{APIVersion: "apps.wandb.com/v2", Kind: "WeightsAndBiases"}
+
+// markedObject is an inventory entry with the namespace of the marker
+// recording it.
+type markedObject struct {
+	markerNamespace string
+	kubectl.InventoryEntry
+}
+
+// markedObjects flattens the markers' inventories. Objects of the local
+// cluster steps (kind-cluster...) are left out: they go with the cluster, not
+// with `cluster cleanup`.
+func markedObjects(markers []kubectl.Marker) []markedObject {
+	var objects []markedObject
+	for _, m := range markers {
+		for _, e := range m.Inventory {
+			if localcluster.IsMarkerComponent(e.Step) {
+				continue
+			}
+			objects = append(objects, markedObject{markerNamespace: m.Namespace, InventoryEntry: e})
+		}
+	}
+	return objects
+}
+
+// deletionOrder sorts objects for deletion: most recently created first, then
+// the CRDs, then the namespaces, so nothing goes before what lives inside it.
+func deletionOrder(objects []markedObject) []markedObject {
+	ordered := slices.Clone(objects)
+	slices.Reverse(ordered)
+	rank := func(o markedObject) int {
+		switch o.Kind {
+		case "CustomResourceDefinition":
+			return 1
+		case "Namespace":
+			return 2
+		}
+		return 0
+	}
+	slices.SortStableFunc(ordered, func(a, b markedObject) int { return rank(a) - rank(b) })
+	return ordered
+}
+
+// cleanupItem is one thing `cluster cleanup` deletes.
+type cleanupItem struct {
+	scope string
+	phase int
+	desc  string
+	// markerNamespace and component locate the marker component the item
+	// belongs to; entry is set when the item is in that marker's inventory.
+	markerNamespace string
+	component       string
+	entry           *kubectl.InventoryEntry
+	remove          func(context.Context) error
+	// remaining returns "" once the item is gone, or what keeps it.
+	remaining func(context.Context) (string, error)
+	// gone, if set, runs once the item is gone.
+	gone func(context.Context) error
+}
+
+// markerComponent is a component recorded in the marker in namespace.
+type markerComponent struct {
+	namespace string
+	component string
+}
+
+// key identifies what the component stands for: cert-manager and nginx-gateway
+// are one cluster-wide release, whichever markers list them.
+func (mc markerComponent) key() markerComponent {
+	switch componentScopes[mc.component] {
+	case cleanupScopeCertManager, cleanupScopeNginxGateway:
+		mc.namespace = ""
+	}
+	return mc
+}
+
+// cleanupPlan is what `cluster cleanup` will do, worked out up front so
+// --dry-run can show it.
+type cleanupPlan struct {
+	items []cleanupItem
+	// components are the in-scope marker components, dropped from their
+	// markers once none of their items remain.
+	components []markerComponent
+	// forget are inventory entries already gone or handed to another owner;
+	// they are dropped from the markers without deleting anything.
+	forget  map[string][]kubectl.InventoryEntry
+	skipped []string
+	// instances are the W&B instances the plan deletes, with what their
+	// retention policies delete or keep.
+	instances []cleanupInstance
+}
+
+// cleanupInstance is a W&B instance `cluster cleanup` deletes.
+type cleanupInstance struct {
+	name, namespace string
+	data            *instanceData
+}
+
+type cleanupOptions struct {
+	dryRun           bool
+	yes              bool
+	only             []string
+	namespaces       []string
+	deleteNamespaces bool
+	confirm          []string
+	timeout          time.Duration
+}
+
+func (o cleanupOptions) validate() error {
+	for _, scope := range o.only {
+		if !slices.Contains(cleanupScopes, scope) {
+			return fmt.Errorf("invalid --only value %q (expected: %s)", scope, strings.Join(cleanupScopes, ", "))
+		}
+	}
+	return nil
+}
+
+// inScope reports whether scope was selected; objects of no known scope are
+// only cleaned up when --only is not given.
+func (o cleanupOptions) inScope(scope string) bool {
+	return len(o.only) == 0 || slices.Contains(o.only, scope)
+}
+
+func (o cleanupOptions) inNamespace(ns string) bool {
+	return len(o.namespaces) == 0 || slices.Contains(o.namespaces, ns)
+}
+
+// planCleanup reads the deployment markers and works out what to delete.
+func planCleanup(ctx context.Context, opts cleanupOptions) (*cleanupPlan, error) {
+	client := kubectl.FromContext(ctx)
+	markers, err := kubectl.ListDeploymentMarkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Namespaces holding data a destroyed W&B instance was told to keep.
+	detached := map[string]bool{}
+	for _, m := range markers {
+		if len(m.Detached) > 0 {
+			detached[m.Namespace] = true
+		}
+	}
+	markers = slices.DeleteFunc(markers, func(m kubectl.Marker) bool { return !opts.inNamespace(m.Namespace) })
+
+	plan := &cleanupPlan{forget: map[string][]kubectl.InventoryEntry{}}
+	planned := map[string]int{} // W&B CR items by object
+	releases := map[string]bool{}
+	// Why a namespace must outlive the cleanup: data the retention policy of
+	// a W&B instance being deleted keeps.
+	retained := map[string]string{}
+
+	for _, m := range markers {
+		for _, component := range m.Components {
+			scope, ok := componentScopes[component]
+			if !ok || !opts.inScope(scope) {
+				continue
+			}
+			plan.components = append(plan.components, markerComponent{namespace: m.Namespace, component: component})
+
+			switch scope {
+			case cleanupScopeWandb:
+				crs, err := operator.ListCRs(ctx, client, m.Namespace)
+				if err != nil && !apierrors.IsNotFound(err) {
+					return nil, fmt.Errorf("failed to list W&B CRs in namespace %s: %w", m.Namespace, err)
+				}
+				for _, name := range crs {
+					entry := wandbCREntry
+					entry.Namespace, entry.Name = m.Namespace, name
+					cr, err := kubectl.GetInventoryObject(ctx, entry)
+					if err != nil {
+						return nil, err
+					}
+					item := objectCleanupItem(scope, cleanupPhaseCRs, m.Namespace, component, entry, false)
+					if cr != nil {
+						data, err := findInstanceData(ctx, cr)
+						if err != nil {
+							return nil, err
+						}
+						if data.policy != v2.PurgeOnDelete && len(data.objects) > 0 {
+							retained[m.Namespace] = fmt.Sprintf("holds %d PVC(s) and Secret(s) the %s retention policy of W&B instance %s keeps", len(data.objects), data.policy, name)
+						}
+						plan.instances = append(plan.instances, cleanupInstance{name: name, namespace: m.Namespace, data: data})
+						ns := m.Namespace
+						item.gone = func(ctx context.Context) error {
+							return recordLeftBehind(ctx, name, ns, data)
+						}
+					}
+					planned[entry.String()] = len(plan.items)
+					plan.items = append(plan.items, item)
+				}
+
+			case cleanupScopeOperator:
+				version, err := operator.GetOperatorChartVersion(ctx, client, m.Namespace)
+				if err != nil {
+					return nil, err
+				}
+				if version != "" {
+					ns := m.Namespace
+					plan.items = append(plan.items, releaseCleanupItem(scope, m.Namespace, component, "wandb-operator", ns,
+						func(ctx context.Context) error {
+							_, err := operator.DeleteOperator(ctx, client, ns)
+							return err
+						},
+						func(ctx context.Context) (bool, error) {
+							version, err := operator.GetOperatorChartVersion(ctx, client, ns)
+							return version != "", err
+						}))
+				}
+
+			case cleanupScopeCertManager, cleanupScopeNginxGateway:
+				// One cluster-wide release, however many markers list it.
+				if releases[scope] {
+					continue
+				}
+				releases[scope] = true
+				installed, remove := operator.CertManagerInstalled, operator.DeleteCertManager
+				if scope == cleanupScopeNginxGateway {
+					installed, remove = operator.NginxGatewayInstalled, operator.DeleteNginxGateway
+				}
+				exists, err := installed(ctx, client)
+				if err != nil {
+					return nil, err
+				}
+				if exists {
+					plan.items = append(plan.items, releaseCleanupItem(scope, m.Namespace, component, scope, scope,
+						func(ctx context.Context) error {
+							_, err := remove(ctx, client)
+							return err
+						},
+						func(ctx context.Context) (bool, error) {
+							return installed(ctx, client)
+						}))
+				}
+			}
+		}
+	}
+
+	for _, o := range deletionOrder(markedObjects(markers)) {
+		scope, phase := componentScopes[o.Step], cleanupPhaseObjects
+		switch o.Kind {
+		case "CustomResourceDefinition":
+			scope, phase = cleanupScopeCRDs, cleanupPhaseCRDs
+		case "Namespace":
+			phase = cleanupPhaseNamespaces
+		}
+		if !opts.inScope(scope) {
+			continue
+		}
+
+		entry := o.InventoryEntry
+		obj, err := kubectl.GetInventoryObject(ctx, entry)
+		switch {
+		case err != nil:
+			return nil, err
+		case obj == nil:
+			plan.forget[o.markerNamespace] = append(plan.forget[o.markerNamespace], entry)
+			continue
+		case !kubectl.IsManaged(obj):
+			plan.skipped = append(plan.skipped, fmt.Sprintf("%s: no longer carries the %s=%s label", entry, kubectl.ManagedByLabel, kubectl.ManagedByValue))
+			plan.forget[o.markerNamespace] = append(plan.forget[o.markerNamespace], entry)
+			continue
+		}
+		if entry.Kind == "Namespace" {
+			reason, err := namespaceKept(ctx, client, entry.Name, opts, detached, retained, planned)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				plan.skipped = append(plan.skipped, fmt.Sprintf("%s: %s", entry, reason))
+				continue
+			}
+		}
+		if i, ok := planned[entry.String()]; ok {
+			// A W&B CR already planned from its marker component.
+			plan.items[i].entry = &entry
+			continue
+		}
+		plan.items = append(plan.items, objectCleanupItem(scope, phase, o.markerNamespace, o.Step, entry, true))
+	}
+
+	slices.SortStableFunc(plan.items, func(a, b cleanupItem) int { return a.phase - b.phase })
+	return plan, nil
+}
+
+// namespaceKept returns why cleanup must leave namespace in place, or "" if
+// it can go: deleting a namespace deletes everything in it, so it only goes
+// with --delete-namespaces, and never while it holds W&B data.
+func namespaceKept(ctx context.Context, client *kubectl.Client, namespace string, opts cleanupOptions, detached map[string]bool, retained map[string]string, planned map[string]int) (string, error) {
+	if !opts.deleteNamespaces {
+		return "namespaces are only deleted with --delete-namespaces", nil
+	}
+	if detached[namespace] {
+		return "holds data detached from a destroyed W&B instance; remove it with `wsm deploy-v2 wandb orphans --delete` first", nil
+	}
+	if reason := retained[namespace]; reason != "" {
+		return reason, nil
+	}
+	crs, err := operator.ListCRs(ctx, client, namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to list W&B CRs in namespace %s: %w", namespace, err)
+	}
+	for _, name := range crs {
+		entry := wandbCREntry
+		entry.Namespace, entry.Name = namespace, name
+		if _, ok := planned[entry.String()]; !ok {
+			return fmt.Sprintf("holds W&B instance %s, which this cleanup does not delete", name), nil
+		}
+	}
+	return "", nil
+}
+
+// objectCleanupItem deletes a Kubernetes object and waits out its finalizers.
+func objectCleanupItem(scope string, phase int, markerNamespace, component string, entry kubectl.InventoryEntry, inventory bool) cleanupItem {
+	item := cleanupItem{
+		scope:           scope,
+		phase:           phase,
+		desc:            entry.String(),
+		markerNamespace: markerNamespace,
+		component:       component,
+		remove: func(ctx context.Context) error {
+			return kubectl.DeleteInventoryObject(ctx, entry)
+		},
+		remaining: func(ctx context.Context) (string, error) {
+			return objectRemaining(ctx, entry)
+		},
+	}
+	if inventory {
+		item.entry = &entry
+	}
+	return item
+}
+
+// objectRemaining returns "" once the object entry refers to is gone, or what
+// keeps it.
+func objectRemaining(ctx context.Context, entry kubectl.InventoryEntry) (string, error) {
+	obj, err := kubectl.GetInventoryObject(ctx, entry)
+	if err != nil || obj == nil {
+		return "", err
+	}
+	if obj.GetDeletionTimestamp() == nil {
+		return "still present", nil
+	}
+	if finalizers := obj.GetFinalizers(); len(finalizers) > 0 {
+		return "terminating, waiting on finalizers " + strings.Join(finalizers, ", "), nil
+	}
+	return "terminating", nil
+}
+
+// releaseCleanupItem uninstalls a Helm release.
+func releaseCleanupItem(scope, markerNamespace, component, release, releaseNamespace string, remove func(context.Context) error, installed func(context.Context) (bool, error)) cleanupItem {
+	return cleanupItem{
+		scope:           scope,
+		phase:           cleanupPhaseObjects,
+		desc:            fmt.Sprintf("Helm release %s/%s", releaseNamespace, release),
+		markerNamespace: markerNamespace,
+		component:       component,
+		remove:          remove,
+		remaining: func(ctx context.Context) (string, error) {
+			exists, err := installed(ctx)
+			if err != nil || !exists {
+				return "", err
+			}
+			return "still installed", nil
+		},
+	}
+}
+
+func (p *cleanupPlan) print() {
+	if len(p.items) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SCOPE\tDELETE")
+		for _, item := range p.items {
+			fmt.Fprintf(w, "%s\t%s\n", valueOr(item.scope, "-"), item.desc)
+		}
+		w.Flush()
+	}
+	for _, s := range p.skipped {
+		fmt.Printf("! Skipping %s\n", s)
+	}
+}
+
+// cleanupResult is the outcome of running a cleanup plan.
+type cleanupResult struct {
+	removed   int
+	remaining []string
+}
+
+// run deletes the plan's items phase by phase, waiting up to timeout overall
+// for each phase's items to be gone. A phase that does not finish stops the
+// ones after it, which are reported as not attempted.
+func (p *cleanupPlan) run(ctx context.Context, timeout time.Duration) cleanupResult {
+	var result cleanupResult
+	deadline := time.Now().Add(timeout)
+	gone := make([]bool, len(p.items))
+
+	for phase := cleanupPhaseCRs; phase <= cleanupPhaseNamespaces; phase++ {
+		var pending []int
+		for i, item := range p.items {
+			if item.phase != phase {
+				continue
+			}
+			fmt.Printf("→ Deleting %s...\n", item.desc)
+			if err := item.remove(ctx); err != nil {
+				fmt.Printf("  ✗ Failed to delete %s: %v\n", item.desc, err)
+				result.remaining = append(result.remaining, fmt.Sprintf("%s: %v", item.desc, err))
+				continue
+			}
+			pending = append(pending, i)
+		}
+
+		why := p.waitGone(ctx, pending, gone, deadline)
+		for _, i := range pending {
+			if gone[i] {
+				result.removed++
+				if item := p.items[i]; item.gone != nil {
+					if err := item.gone(ctx); err != nil {
+						fmt.Printf("  ✗ %s: %v\n", item.desc, err)
+						result.remaining = append(result.remaining, fmt.Sprintf("%s: %v", item.desc, err))
+					}
+				}
+			} else {
+				result.remaining = append(result.remaining, fmt.Sprintf("%s: %s", p.items[i].desc, why[i]))
+			}
+		}
+
+		if len(result.remaining) > 0 {
+			for _, item := range p.items {
+				if item.phase > phase {
+					result.remaining = append(result.remaining, item.desc+": not attempted")
+				}
+			}
+			break
+		}
+	}
+
+	p.updateMarkers(ctx, gone)
+	return result
+}
+
+// waitGone polls the pending items until they are gone or deadline passes,
+// marking them in gone. It returns why each one still remains.
+func (p *cleanupPlan) waitGone(ctx context.Context, pending []int, gone []bool, deadline time.Time) map[int]string {
+	why := map[int]string{}
+	announced := false
+	for {
+		left := 0
+		for _, i := range pending {
+			if gone[i] {
+				continue
+			}
+			reason, err := p.items[i].remaining(ctx)
+			switch {
+			case err != nil:
+				why[i] = err.Error()
+				left++
+			case reason == "":
+				gone[i] = true
+			default:
+				why[i] = reason
+				left++
+			}
+		}
+		if left == 0 || time.Now().After(deadline) {
+			return why
+		}
+		if !announced {
+			fmt.Printf("→ Waiting for %d object(s) to finish terminating...\n", left)
+			announced = true
+		}
+		select {
+		case <-ctx.Done():
+			return why
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// updateMarkers drops from the markers what cleanup removed: the components
+// with nothing left and the inventory entries that are gone.
+func (p *cleanupPlan) updateMarkers(ctx context.Context, gone []bool) {
+	left := map[markerComponent]bool{}
+	forget := map[string][]kubectl.InventoryEntry{}
+	for ns, entries := range p.forget {
+		forget[ns] = append(forget[ns], entries...)
+	}
+	for i, item := range p.items {
+		if !gone[i] {
+			left[markerComponent{namespace: item.markerNamespace, component: item.component}.key()] = true
+			continue
+		}
+		if item.entry != nil {
+			forget[item.markerNamespace] = append(forget[item.markerNamespace], *item.entry)
+		}
+	}
+
+	for _, mc := range p.components {
+		if left[mc.key()] {
+			continue
+		}
+		if err := kubectl.DeleteDeploymentMarker(ctx, mc.namespace, mc.component); err != nil {
+			fmt.Printf("  ✗ Failed to remove %s marker in %s: %v\n", mc.component, mc.namespace, err)
+		}
+	}
+	for ns, entries := range forget {
+		if err := kubectl.ForgetInventory(ctx, ns, entries); err != nil {
+			fmt.Printf("  ✗ Failed to update deployment marker in %s: %v\n", ns, err)
+		}
+	}
+}
+
+func performCleanup(ctx context.Context, opts cleanupOptions) error {
+	plan, err := planCleanup(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.items) == 0 {
+		plan.print()
+		if opts.dryRun {
+			fmt.Println("(dry-run) nothing to delete.")
+			return nil
+		}
+		plan.updateMarkers(ctx, nil)
+		fmt.Println("✓ Nothing to clean up.")
+		return nil
+	}
+
+	fmt.Println("Cleanup plan:")
+	plan.print()
+	for _, inst := range plan.instances {
+		fmt.Println()
+		inst.data.print(inst.name, inst.namespace)
+	}
+	if opts.dryRun {
+		fmt.Println("(dry-run) nothing deleted.")
+		return nil
+	}
+	if !opts.yes {
+		fmt.Printf("Delete %d item(s)? [y/N]: ", len(plan.items))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			fmt.Println("aborted.")
+			return nil
+		}
+	}
+	// Purging an instance needs its name typed, or passed with --confirm,
+	// as with `wsm deploy-v2 wandb destroy`; --yes alone is not enough.
+	for _, inst := range plan.instances {
+		if inst.data.policy != v2.PurgeOnDelete {
+			continue
+		}
+		confirm := ""
+		if slices.Contains(opts.confirm, inst.name) {
+			confirm = inst.name
+		}
+		ok, err := confirmDestroy(inst.name, inst.data.policy, destroyOptions{confirm: confirm})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("aborted.")
+			return nil
+		}
+	}
+
+	result := plan.run(ctx, opts.timeout)
+
+	fmt.Printf("\nCleanup summary: %d removed, %d skipped, %d remaining\n", result.removed, len(plan.skipped), len(result.remaining))
+	for _, r := range result.remaining {
+		fmt.Printf("  ✗ %s\n", r)
+	}
+	if len(result.remaining) > 0 {
+		return fmt.Errorf("%d item(s) remain", len(result.remaining))
+	}
+	fmt.Println("✓ Cleanup completed successfully")
+	return nil
+}
+
+func clusterCleanupCmd() *cobra.Command {
+	var opts cleanupOptions
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Cleanup wsm-managed resources",
+		Long: `Delete the resources wsm deployed, as recorded in its deployment markers:
+W&B CRs, the operator, cert-manager and nginx-gateway releases, and the
+objects in the markers' inventories (CA issuers, ingress and storage classes,
+Gateway API CRDs...).
+
+The namespaces wsm created are only deleted with --delete-namespaces, and
+never while they hold a W&B instance this cleanup leaves, PVCs or Secrets a
+W&B instance's retention policy keeps, or data a destroyed instance left
+behind.
+
+The plan is shown first, with what each W&B instance's retention policy
+deletes or keeps, and needs confirming; --dry-run only shows it. Purging an
+instance needs its name typed, or passed with --confirm, even with --yes.
+Data a detached instance leaves behind is recorded for
+'wsm deploy-v2 wandb orphans'. W&B CRs
+are deleted first and their finalizers waited for, while the operator is still
+there to run them; namespaces are deleted last. If anything is still there
+after --timeout, the rest is left alone and the command exits non-zero.`,
+		Example: `  wsm cluster cleanup --context kind-wandb --dry-run
+  wsm cluster cleanup --context prod --only wandb --namespace wandb --yes
+  wsm cluster cleanup --context prod --only cert-manager,nginx-gateway,crds`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireKubeContext(); err != nil {
+				return err
+			}
+			return opts.validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := performCleanup(cmd.Context(), opts); err != nil {
+				fmt.Printf("✗ Cleanup failed: %v\n", err)
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show what would be deleted without deleting anything")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Don't ask for confirmation (purging a W&B instance still needs --confirm)")
+	cmd.Flags().StringSliceVar(&opts.confirm, "confirm", nil, "Names of the W&B instances with the purge retention policy to delete without being asked to type them")
+	cmd.Flags().StringSliceVar(&opts.only, "only", nil, "Only clean up these parts: "+strings.Join(cleanupScopes, ", ")+" (default all)")
+	cmd.Flags().StringSliceVarP(&opts.namespaces, "namespace", "n", nil, "Only clean up what the deployment markers in these namespaces recorded (default all)")
+	cmd.Flags().BoolVar(&opts.deleteNamespaces, "delete-namespaces", false, "Also delete the namespaces wsm created, unless they still hold W&B data")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 10*time.Minute, "How long to wait for deleted objects' finalizers before giving up")
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kubectl"
+)
+
+func clusterStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show what wsm deployed to the cluster",
+		Long: `List the wsm deployment markers in the cluster and every object wsm recorded
+creating (outside the Helm releases it installs), with the step that created
+it, the wsm version that did, and whether it is still there.
+
+Markers written by wsm versions that predate the inventory list components
+only.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			markers, err := kubectl.ListDeploymentMarkers(ctx)
+			if err != nil {
+				return err
+			}
+			if len(markers) == 0 {
+				fmt.Println("! No wsm deployment markers found.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAMESPACE\tCOMPONENTS\tCLUSTER\tOBJECTS")
+			for _, m := range markers {
+				components := strings.Join(m.Components, ",")
+				if components == "" {
+					components = "-"
+				}
+				objects := "-"
+				if len(m.Inventory) > 0 {
+					objects = fmt.Sprint(len(m.Inventory))
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Namespace, components, valueOr(m.ClusterName, "-"), objects)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			var entries []kubectl.InventoryEntry
+			for _, m := range markers {
+				entries = append(entries, m.Inventory...)
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+
+			fmt.Println()
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "STEP\tKIND\tNAMESPACE\tNAME\tWSM VERSION\tSTATE")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					valueOr(e.Step, "-"), e.Kind, valueOr(e.Namespace, "-"), e.Name, valueOr(e.WSMVersion, "-"), inventoryState(ctx, e))
+			}
+			return w.Flush()
+		},
+	}
+
+	return cmd
+}
+
+// inventoryState describes whether the object e recorded is still in place.
+func inventoryState(ctx context.Context, e kubectl.InventoryEntry) string {
+	obj, err := kubectl.GetInventoryObject(ctx, e)
+	switch {
+	case err != nil:
+		return "unknown: " + err.Error()
+	case obj == nil:
+		return "missing"
+	case obj.GetDeletionTimestamp() != nil:
+		return "terminating"
+	case !kubectl.IsManaged(obj):
+		return "unmanaged"
+	}
+	return "present"
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	b64 "encoding/base64"
+	"fmt"
 	urlutil "net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
-	"time"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/wandb/wsm/pkg/kubectl"
@@ -35,27 +37,49 @@ func openBrowser(url string) error {
 
 func ConsoleCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use: "console",
-		Run: func(cmd *cobra.Command, args []string) {
-			pwd, err := kubectl.GetSecret("wandb-password", "default")
+		Use:   "console",
+		Short: "Port-forward to the W&B console and open it logged in",
+		Long:  `Port-forward to the wandb-console Service in the context's namespace (or --namespace) and open the console, logged in with the wandb-password Secret.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			client := kubectl.Default()
+			namespace := client.Namespace()
+
+			secret, err := client.GetSecretDataMap(ctx, "wandb-password", namespace)
+			if err != nil {
+				return err
+			}
+			pwd, ok := secret["password"]
+			if !ok {
+				return fmt.Errorf("secret %s/wandb-password does not contain key %q", namespace, "password")
+			}
+
+			session, err := client.PortForward(ctx, namespace, "wandb-console", 8082, 8082)
 			if err != nil {
-				panic(err)
+				return err
 			}
+			defer func() { _ = session.Close() }()
 
-			url := "http://localhost:8082/console/login?password=" + urlutil.QueryEscape(
-				b64.StdEncoding.EncodeToString([]byte(pwd)),
+			url := fmt.Sprintf("http://localhost:%d/console/login?password=", session.LocalPort) + urlutil.QueryEscape(
+				b64.StdEncoding.EncodeToString(pwd),
 			)
+			fmt.Printf("→ Forwarding %s/wandb-console to http://localhost:%d (Ctrl+C to stop)\n", namespace, session.LocalPort)
+			_ = openBrowser(url)
 
-			time.AfterFunc(500*time.Millisecond, func() {
-				_ = openBrowser(url)
-			})
-			portForward := exec.Command("kubectl", "port-forward", "service/wandb-console", "8082:8082")
-			portForward.Stderr = os.Stderr
-			portForward.Stdout = os.Stdout
-			portForward.Stdin = os.Stdin
-			_ = portForward.Run()
+			ctxSig, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			select {
+			case <-ctxSig.Done():
+				return nil
+			case err := <-session.Done():
+				if err != nil {
+					return fmt.Errorf("port-forward to %s/wandb-console ended unexpectedly: %w", namespace, err)
+				}
+				return nil
+			}
 		},
 	}
 
+	cmd.Flags().StringVarP(&kubeOptions.Namespace, "namespace", "n", "", "Namespace of the W&B instance (default: the context's namespace)")
 	return cmd
 }
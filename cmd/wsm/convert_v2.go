@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kubectl"
 	"github.com/wandb/wsm/pkg/operator"
 	sigsyaml "sigs.k8s.io/yaml"
 )
@@ -47,7 +48,7 @@ what the in-cluster webhook would emit after upgrade.`,
 
 			ctx := context.Background()
 
-			v1CR, v2CR, err := operator.ConvertV1CRToV2(ctx, wandbName, wandbNamespace)
+			v1CR, v2CR, err := operator.ConvertV1CRToV2(ctx, kubectl.Default(), wandbName, wandbNamespace)
 			if err != nil {
 				return err
 			}
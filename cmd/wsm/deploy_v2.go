@@ -11,14 +11,17 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/wandb/wsm/pkg/kind"
 	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/localcluster"
+	"github.com/wandb/wsm/pkg/mirror"
 	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	storagev1 "k8s.io/api/storage/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/ptr"
@@ -53,6 +56,10 @@ const (
 // defaultWandbSize is stamped into spec.size when --size is unset.
 const defaultWandbSize = v2.SizeDev
 
+// defaultOperatorChartVersion is the operator chart deploy-v2 installs and the
+// registry commands mirror, check and describe unless told otherwise.
+const defaultOperatorChartVersion = "2.0.0-beta.1"
+
 const (
 	certManagerInstallModeAuto  = "auto"
 	certManagerInstallModeTrue  = "true"
@@ -128,21 +135,14 @@ var (
 
 // DeployV2Cmd returns the deploy-v2 command with subcommands
 func DeployV2Cmd() *cobra.Command {
-	var kubeContext string
 	cmd := &cobra.Command{
 		Use:   "deploy-v2",
 		Short: "Deploy v2 operator and resources",
 		Long:  `Deploy the v2 operator, server manifest, and custom resources`,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			if kubeContext == "" {
-				return errors.New("context is required")
-			}
-
-			kubectl.SetContext(kubeContext)
-			return nil
+			return requireKubeContext()
 		},
 	}
-	cmd.PersistentFlags().StringVar(&kubeContext, "context", "", "name of the kubeconfig context to use (Required)")
 	// CR deployment
 	cmd.PersistentFlags().String("cr-file", "", "Path to WeightsAndBiases CR YAML (uses built-in default if not provided)")
 	cmd.PersistentFlags().Bool("create-ca", true, "Create a self-signed CA certificate for the W&B instance")
@@ -169,6 +169,10 @@ func DeployV2Cmd() *cobra.Command {
 	cmd.PersistentFlags().String("mirror-registry", "", "Install everything (charts, operator/infra images, and the W&B app/DB images) from this air-gapped mirror registry, e.g. harbor.corp:5443. Populate it first with 'wsm registry mirror --to <same-host>'.")
 	cmd.PersistentFlags().Bool("insecure-registry", false, "Use plain HTTP / skip TLS verification when talking to --mirror-registry")
 	cmd.PersistentFlags().String("registry-ca-file", "", "PEM CA bundle to trust for an HTTPS --mirror-registry with a self-signed / internal-CA cert")
+	// Same layout flags as `wsm registry mirror`, prefixed to sit beside --mirror-registry.
+	new(layoutFlags).register(cmd.PersistentFlags(), "mirror-")
+	// Same credential flags as `wsm registry mirror`, for Helm's chart pulls from the mirror.
+	new(credentialFlags).register(cmd.PersistentFlags(), "mirror-", false)
 	cmd.PersistentFlags().String("wandb-namespace", "wandb", "Namespace for CR")
 	cmd.PersistentFlags().String("oidc-client-id", "", "OIDC client ID as <secret-name>:<key> (spec.wandb.oidc.clientId; optional)")
 	cmd.PersistentFlags().String("oidc-client-secret", "", "OIDC client secret as <secret-name>:<key> (spec.wandb.oidc.clientSecret; optional)")
@@ -200,34 +204,12 @@ func wandbCmd() *cobra.Command {
 
 	cmd.AddCommand(wandbCreateCmd())
 	cmd.AddCommand(wandbDestroyCmd())
+	cmd.AddCommand(wandbOrphansCmd())
 	cmd.AddCommand(wandbGetCACertCmd())
 
 	return cmd
 }
 
-func wandbDestroyCmd() *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "destroy",
-		Short: "Destroy an instance of W&B",
-		Long:  `Destroy an instance of W&B`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			wandbNamespace, _ := cmd.Flags().GetString("wandb-namespace")
-			wandbName, _ := cmd.Flags().GetString("wandb-name")
-
-			ctx := context.Background()
-
-			err := destroyWandbCR(ctx, wandbName, wandbNamespace)
-			if err != nil {
-				return err
-			}
-
-			return nil
-		},
-	}
-
-	return cmd
-}
-
 func wandbGetCACertCmd() *cobra.Command {
 	var outputDir string
 
@@ -344,7 +326,7 @@ func wandbCreateCmd() *cobra.Command {
 			if wait {
 				fmt.Println("Waiting for W&B instance to be ready...")
 
-				if err := operator.WaitForCRReady(ctx, wandbCR.Namespace, wandbCR.Name, 30*time.Minute); err != nil {
+				if err := operator.WaitForCRReady(ctx, kubectl.Default(), wandbCR.Namespace, wandbCR.Name, 30*time.Minute); err != nil {
 					fmt.Println(" ✗")
 					return err
 				}
@@ -363,8 +345,9 @@ func operatorDeployCmd() *cobra.Command {
 	var installNginxGatewayMode string
 	var enableGatewayAPI bool
 	var clusterName string
+	var clusterProvider string
 	var workers int
-	var kindNodeImage string
+	var nodeImage string
 	var operatorChartVersion string
 	var operatorNamespace string
 	var includeCR bool
@@ -394,6 +377,16 @@ func operatorDeployCmd() *cobra.Command {
 			if err := normalizeMirrorManifestSource(&f, includeCR); err != nil {
 				return err
 			}
+			mirrorLayout, err := f.mirrorLayout.layout()
+			if err != nil {
+				return err
+			}
+			var mirrorCreds registryauth.Provider
+			if f.mirrorRegistry != "" {
+				if mirrorCreds, err = f.mirrorCreds.provider(f.mirrorRegistry); err != nil {
+					return err
+				}
+			}
 
 			if err := validateObservabilityMode(telemetry.Mode); err != nil {
 				return err
@@ -421,6 +414,10 @@ func operatorDeployCmd() *cobra.Command {
 			if err := processWandbCR(cmd, f); err != nil {
 				return err
 			}
+			provider, err := localcluster.Get(clusterProvider)
+			if err != nil {
+				return err
+			}
 
 			// Perform the deployment
 			deployStart := time.Now()
@@ -432,6 +429,7 @@ func operatorDeployCmd() *cobra.Command {
 				includeCR,
 				wait,
 				clusterName,
+				provider,
 				telemetry,
 				f.wandbNamespace,
 				workers,
@@ -441,8 +439,10 @@ func operatorDeployCmd() *cobra.Command {
 				createAwsStorageClass,
 				createAwsIngressClass,
 				f.ingressClass,
-				kindNodeImage,
+				nodeImage,
 				f.mirrorRegistry,
+				mirrorLayout,
+				mirrorCreds,
 				f.insecureRegistry,
 				f.registryCAFile,
 				gatewayCRDURL,
@@ -461,32 +461,34 @@ func operatorDeployCmd() *cobra.Command {
 			if includeCR {
 				fmt.Println("Access your W&B instance:")
 				if setupCluster {
-					fmt.Printf("  • Kubectl context: kind-%s\n", clusterName)
+					fmt.Printf("  • Kubectl context: %s\n", provider.KubeContext(clusterName))
 				}
 				fmt.Printf("  • Namespace: %s\n", f.wandbNamespace)
 				fmt.Printf("  • Status: kubectl get wandb -n %s\n", f.wandbNamespace)
 				fmt.Println()
 			} else {
-				kubeContext, _ := cmd.Flags().GetString("context")
-				fmt.Printf("Next: install the W&B instance with 'wsm deploy-v2 wandb deploy --context %s' (add --mirror-registry for an air-gapped install).\n", kubeContext)
+				fmt.Printf("Next: install the W&B instance with 'wsm deploy-v2 wandb deploy --context %s' (add --mirror-registry for an air-gapped install).\n", kubectl.Default().Context())
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVar(&setupCluster, "setup-k8s-cluster", false, "Setup a Kind cluster before deploying")
-	cmd.Flags().StringVar(&clusterName, "cluster-name", "kind", "Name of the Kind cluster (only used with --setup-k8s-cluster)")
+	cmd.Flags().BoolVar(&setupCluster, "setup-k8s-cluster", false, "Setup a local cluster before deploying")
+	cmd.Flags().StringVar(&clusterProvider, "provider", localcluster.DefaultProvider, "Local cluster provider: "+strings.Join(localcluster.Names(), ", ")+" (only used with --setup-k8s-cluster)")
+	cmd.Flags().StringVar(&clusterName, "cluster-name", "kind", "Name of the local cluster (only used with --setup-k8s-cluster)")
 	cmd.Flags().IntVar(&workers, "workers", 0, "Number of worker nodes (only used with --setup-k8s-cluster)")
-	cmd.Flags().StringVar(&kindNodeImage, "kind-node-image", "", "Kind node image to use, e.g. myreg.example.com/kindest/node:v1.35.1@sha256:... (defaults to the upstream pinned image; only used with --setup-k8s-cluster)")
+	cmd.Flags().StringVar(&nodeImage, "node-image", "", "Node image to use, e.g. myreg.example.com/kindest/node:v1.35.1@sha256:... (defaults to the provider's; for kind the pinned image, from --mirror-registry when it serves HTTPS; only used with --setup-k8s-cluster)")
+	cmd.Flags().StringVar(&nodeImage, "kind-node-image", "", "Kind node image to use")
+	_ = cmd.Flags().MarkDeprecated("kind-node-image", "use --node-image")
 
-	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", "2.0.0-beta.1", "Operator Chart version (e.g., v2.0.0)")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator Chart version (e.g., v2.0.0)")
 	cmd.Flags().StringVar(&operatorNamespace, "operator-namespace", "wandb-operators", "Namespace for operator")
 	cmd.Flags().StringVar(&installCertManagerMode, "install-cert-manager", certManagerInstallModeAuto, "Cert-manager install mode: auto (detect and reuse existing), true (force install flow), false (skip installation)")
 	cmd.Flags().StringVar(&installNginxGatewayMode, "install-nginx-gateway", nginxGatewayInstallModeAuto, "Nginx-gateway-fabric install mode: auto (detect and reuse existing), true (force install flow), false (skip installation)")
 
 	cmd.Flags().BoolVar(&enableGatewayAPI, "enable-gateway-api", true, "Enable Gateway API support for cert-manager")
 	cmd.Flags().BoolVar(&includeCR, "include-cr", false, "Also deploy the WeightsAndBiases CR in this run instead of leaving it to 'wsm deploy-v2 wandb deploy'")
-	cmd.Flags().StringVar(&gatewayCRDURL, "gateway-api-crd-url", "", "Fetch the Gateway API CRDs from this URL instead of the GitHub default (use a mirrored copy for air-gapped installs)")
+	cmd.Flags().StringVar(&gatewayCRDURL, "gateway-api-crd-url", "", "Fetch the Gateway API CRDs from this URL instead of the GitHub default, or the copy in --mirror-registry when set")
 	cmd.Flags().BoolVar(&skipGatewayCRDs, "skip-gateway-api-crds", false, "Assume the Gateway API CRDs are already installed; fail instead of fetching them from the internet")
 	cmd.Flags().BoolVar(&allowUnsupportedArch, "allow-unsupported-arch", false, "Deploy even if the cluster has non-amd64 nodes. The wandb-operator image is published amd64-only and will crash under emulation on arm64 (e.g. Kind on Apple Silicon); set this only if you know your operator image is multi-arch.")
 	cmd.Flags().BoolVar(&openshift, "openshift", false, "Enable OpenShift compatibility for the operator and bundled managed-service pods")
@@ -519,9 +521,10 @@ func operatorDestroyCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			operatorNamespace, _ := cmd.Flags().GetString("operator-namespace")
 			ctx := context.Background()
+			client := kubectl.Default()
 
 			fmt.Printf("→ Deleting W&B operator in namespace '%s'...\n", operatorNamespace)
-			removed, err := operator.DeleteOperator(ctx, operatorNamespace)
+			removed, err := operator.DeleteOperator(ctx, client, operatorNamespace)
 			if err != nil {
 				return err
 			}
@@ -537,7 +540,7 @@ func operatorDestroyCmd() *cobra.Command {
 			// All markers live in the operator namespace's ConfigMap.
 			if includeCertManager {
 				fmt.Println("→ Deleting cert-manager...")
-				cmRemoved, err := operator.DeleteCertManager(ctx)
+				cmRemoved, err := operator.DeleteCertManager(ctx, client)
 				if err != nil {
 					return err
 				}
@@ -553,7 +556,7 @@ func operatorDestroyCmd() *cobra.Command {
 
 			if includeNginxGateway {
 				fmt.Println("→ Deleting nginx-gateway...")
-				ngRemoved, err := operator.DeleteNginxGateway(ctx)
+				ngRemoved, err := operator.DeleteNginxGateway(ctx, client)
 				if err != nil {
 					return err
 				}
@@ -584,7 +587,7 @@ func operatorOpenShiftStatusCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			operatorNamespace, _ := cmd.Flags().GetString("operator-namespace")
 
-			cfg, err := operator.GetOperatorOpenShiftConfig(operatorNamespace)
+			cfg, err := operator.GetOperatorOpenShiftConfig(cmd.Context(), kubectl.Default(), operatorNamespace)
 			if err != nil {
 				return err
 			}
@@ -615,7 +618,7 @@ func operatorOpenShiftStatusCmd() *cobra.Command {
 // cluster" workflow is not blocked. A probe failure is non-fatal: if we can't
 // read the nodes we let the install proceed rather than guess.
 func checkOperatorArch(ctx context.Context, operatorImageTag string, allowUnsupported bool) error {
-	_, cs, err := kubectl.GetClientset()
+	cs, err := kubectl.FromContext(ctx).Clientset()
 	if err != nil {
 		return nil
 	}
@@ -659,6 +662,7 @@ func performDeploy(
 	includeCR bool,
 	wait bool,
 	clusterName string,
+	provider localcluster.Provider,
 	telemetry operator.TelemetryConfig,
 	wandbNamespace string,
 	workers int,
@@ -668,8 +672,10 @@ func performDeploy(
 	createAwsStorageClass bool,
 	createAwsIngressClass bool,
 	ingressClass string,
-	kindNodeImage string,
+	nodeImage string,
 	mirrorRegistry string,
+	mirrorLayout *mirror.Layout,
+	mirrorCreds registryauth.Provider,
 	insecureRegistry bool,
 	registryCAFile string,
 	gatewayCRDURL string,
@@ -685,9 +691,11 @@ func performDeploy(
 	var mirror *operator.MirrorConfig
 	if mirrorRegistry != "" {
 		mirror = &operator.MirrorConfig{
-			Host:     strings.TrimRight(mirrorRegistry, "/"),
-			Insecure: insecureRegistry,
-			CAFile:   registryCAFile,
+			Host:        strings.TrimRight(mirrorRegistry, "/"),
+			Insecure:    insecureRegistry,
+			CAFile:      registryCAFile,
+			Layout:      mirrorLayout,
+			Credentials: mirrorCreds,
 		}
 	}
 
@@ -706,15 +714,31 @@ func performDeploy(
 		fmt.Printf("[%d/%d] Setting up cluster (%d workers)...", currentStep, totalSteps, workers)
 		start := time.Now()
 
-		// When the user supplied --mirror-registry --insecure-registry on the
-		// combined --setup-k8s-cluster path, treat that host as insecure for
-		// the new Kind node's containerd too — otherwise kubelet would default
-		// to HTTPS and ImagePullBackOff on every chart pod.
-		insecureRegistryHost := ""
-		if mirror != nil && mirror.Insecure {
-			insecureRegistryHost = mirror.Host
+		// The new nodes' containerd pulls from --mirror-registry too: over
+		// plain HTTP with --insecure-registry (otherwise kubelet would default
+		// to HTTPS and ImagePullBackOff on every chart pod), or trusting
+		// --registry-ca-file.
+		var registry kind.Registry
+		if mirror != nil {
+			registry = kind.Registry{Host: mirror.Host, Insecure: mirror.Insecure, Layout: mirrorLayout}
+			if !mirror.Insecure {
+				registry.CAFile = mirror.CAFile
+			}
 		}
-		err := performCreateCluster(ctx, clusterName, workers, 8080, 8443, kindNodeImage, insecureRegistryHost)
+		// The node image is pulled by the host's container runtime before the
+		// cluster exists, so it comes from an HTTPS mirror too. The host's
+		// Docker refuses a plain-HTTP mirror, so that keeps the upstream image.
+		if nodeImage == "" && mirror != nil && !mirror.Insecure {
+			nodeImage = provider.MirroredNodeImage(mirror.Host, mirrorLayout)
+		}
+		err := performCreateCluster(ctx, provider, localcluster.CreateOptions{
+			Name:      clusterName,
+			Workers:   workers,
+			HTTPPort:  8080,
+			HTTPSPort: 8443,
+			NodeImage: nodeImage,
+			Registry:  registry,
+		})
 		if err != nil {
 			return err
 		}
@@ -725,25 +749,38 @@ func performDeploy(
 		_ = clusterName
 	}
 
+	// Objects wsm creates from here on (outside the Helm releases) are recorded
+	// in the operator namespace's deployment marker, under the step creating them.
+	ctx = kubectl.WithInventory(ctx, &kubectl.Inventory{})
+	client := kubectl.FromContext(ctx)
+
 	// Step: Ensure nginx-gateway-fabric
 	if installNginxGatewayMode != nginxGatewayInstallModeFalse {
 		fmt.Printf("[%d/%d] Ensuring nginx-gateway-fabric...", currentStep, totalSteps)
 		start := time.Now()
 
+		gatewayCtx := kubectl.WithStep(ctx, "nginx-gateway")
+		// A cluster from `wsm cluster create` maps its host ports to the
+		// gateway's node ports, whichever provider created it.
+		nodePorts, err := localcluster.Created(ctx, client)
+		if err != nil {
+			fmt.Println(" ✗")
+			return err
+		}
 		switch installNginxGatewayMode {
 		case nginxGatewayInstallModeAuto:
-			if err := operator.InstallNginxGateway(ctx, true, mirror, gatewayCRDURL, skipGatewayCRDs); err != nil {
+			if err := operator.InstallNginxGateway(gatewayCtx, client, true, nodePorts, mirror, gatewayCRDURL, skipGatewayCRDs); err != nil {
 				fmt.Println(" ✗")
 				return err
 			}
 		case nginxGatewayInstallModeTrue:
-			if err := operator.InstallNginxGateway(ctx, false, mirror, gatewayCRDURL, skipGatewayCRDs); err != nil {
+			if err := operator.InstallNginxGateway(gatewayCtx, client, false, nodePorts, mirror, gatewayCRDURL, skipGatewayCRDs); err != nil {
 				fmt.Println(" ✗")
 				return err
 			}
 		}
 
-		if err := operator.WaitForNginxGateway(ctx, 5*time.Minute); err != nil {
+		if err := operator.WaitForNginxGateway(ctx, client, 5*time.Minute); err != nil {
 			fmt.Println(" ✗")
 			return err
 		}
@@ -757,14 +794,15 @@ func performDeploy(
 		fmt.Printf("[%d/%d] Ensuring cert-manager...", currentStep, totalSteps)
 		start := time.Now()
 
+		certManagerCtx := kubectl.WithStep(ctx, "cert-manager")
 		switch installCertManagerMode {
 		case certManagerInstallModeAuto:
-			if err := operator.InstallCertManager(ctx, enableGatewayAPI, true, mirror); err != nil {
+			if err := operator.InstallCertManager(certManagerCtx, client, enableGatewayAPI, true, mirror); err != nil {
 				fmt.Println(" ✗")
 				return err
 			}
 		case certManagerInstallModeTrue:
-			if err := operator.InstallCertManager(ctx, enableGatewayAPI, false, mirror); err != nil {
+			if err := operator.InstallCertManager(certManagerCtx, client, enableGatewayAPI, false, mirror); err != nil {
 				fmt.Println(" ✗")
 				return err
 			}
@@ -775,7 +813,7 @@ func performDeploy(
 			return fmt.Errorf("invalid --install-cert-manager value %q (expected: auto, true, false)", installCertManagerMode)
 		}
 
-		if err := operator.WaitForCertManager(ctx, 5*time.Minute); err != nil {
+		if err := operator.WaitForCertManager(ctx, client, 5*time.Minute); err != nil {
 			fmt.Println(" ✗")
 			if installCertManagerMode == certManagerInstallModeFalse {
 				return fmt.Errorf("cert-manager is not ready and installation is disabled (--install-cert-manager=false): %w", err)
@@ -788,7 +826,8 @@ func performDeploy(
 	}
 
 	// Step: Create infra-operators wandbNamespace
-	if err := operator.CreateNamespace(ctx, operatorNamespace); err != nil {
+	ctx = kubectl.WithStep(ctx, "operator")
+	if err := operator.CreateNamespace(ctx, client, operatorNamespace); err != nil {
 		return err
 	}
 
@@ -802,7 +841,7 @@ func performDeploy(
 	fmt.Printf("[%d/%d] Deploying Required operators...", currentStep, totalSteps)
 	start := time.Now()
 
-	if err := operator.DeployOperator(ctx, operatorNamespace, operatorChartVersion, mirror, telemetry, wandbNamespace, openshift); err != nil {
+	if err := operator.DeployOperator(ctx, client, operatorNamespace, operatorChartVersion, mirror, telemetry, wandbNamespace, openshift); err != nil {
 		fmt.Println(" ✗")
 		return err
 	}
@@ -811,13 +850,13 @@ func performDeploy(
 	// operator so its in-cluster server-manifest fetch trusts the registry. Done
 	// before WaitForOperator so the wait observes the rolled (CA-trusting) pod.
 	if mirror != nil && mirror.CAFile != "" {
-		if err := operator.InjectRegistryCAIntoOperator(ctx, operatorNamespace, mirror.CAFile); err != nil {
+		if err := operator.InjectRegistryCAIntoOperator(ctx, client, operatorNamespace, mirror.CAFile); err != nil {
 			fmt.Println(" ✗")
 			return err
 		}
 	}
 
-	if err := operator.WaitForOperator(ctx, operatorNamespace, 5*time.Minute); err != nil {
+	if err := operator.WaitForOperator(ctx, client, operatorNamespace, 5*time.Minute); err != nil {
 		fmt.Println(" ✗")
 		return err
 	}
@@ -852,7 +891,7 @@ func performDeploy(
 			fmt.Printf("[%d/%d] Waiting for W&B instance to be ready...", currentStep, totalSteps)
 			start = time.Now()
 
-			if err := operator.WaitForCRReady(ctx, wandbCR.Namespace, wandbCR.Name, 30*time.Minute); err != nil {
+			if err := operator.WaitForCRReady(ctx, client, wandbCR.Namespace, wandbCR.Name, 30*time.Minute); err != nil {
 				fmt.Println(" ✗")
 				return err
 			}
@@ -864,29 +903,12 @@ func performDeploy(
 	return nil
 }
 
-func destroyWandbCR(ctx context.Context, name string, namespace string) error {
-	hasMarker, err := kubectl.HasDeploymentMarker(ctx, namespace, "wandb-cr")
-	if err != nil {
-		return err
-	}
-
-	if !hasMarker {
-		return errors.New("no wsm deployment marker found - W&B instance may not be managed by wsm")
-	}
-
-	if err := kubectl.DeleteCR(ctx, name, namespace); err != nil {
-		return fmt.Errorf("failed to delete W&B CR: %w", err)
-	}
-
-	if err := kubectl.DeleteDeploymentMarker(ctx, namespace, "wandb-cr"); err != nil {
-		return fmt.Errorf("failed to delete deployment marker: %w", err)
-	}
-
-	return nil
-}
-
 func deployWandbCR(ctx context.Context, createCA bool, createAwsStorageClass, createAwsIngressClass bool, ingressClass string, crOverrides []operator.CROverride) error {
-	if err := operator.CreateNamespace(ctx, wandbCR.Namespace); err != nil {
+	// Everything this step creates is recorded in the W&B namespace's marker.
+	ctx = kubectl.WithInventory(kubectl.WithStep(ctx, "wandb-cr"), &kubectl.Inventory{})
+	client := kubectl.FromContext(ctx)
+
+	if err := operator.CreateNamespace(ctx, client, wandbCR.Namespace); err != nil {
 		return err
 	}
 
@@ -911,7 +933,7 @@ func deployWandbCR(ctx context.Context, createCA bool, createAwsStorageClass, cr
 		}
 	}
 
-	if err := operator.ApplyCR(ctx, wandbCR, crOverrides); err != nil {
+	if err := operator.ApplyCR(ctx, client, wandbCR, crOverrides); err != nil {
 		fmt.Println(" ✗")
 		return err
 	}
@@ -923,27 +945,27 @@ func deployWandbCR(ctx context.Context, createCA bool, createAwsStorageClass, cr
 	return nil
 }
 
-func performCreateCluster(ctx context.Context, clusterName string, workers int, httpPort int32, httpsPort int32, nodeImage string, insecureRegistryHost string) error {
-	exists, err := kind.ClusterExists(ctx, clusterName)
+func performCreateCluster(ctx context.Context, provider localcluster.Provider, opts localcluster.CreateOptions) error {
+	exists, err := localcluster.Exists(ctx, provider, opts.Name)
 	if err != nil {
 		fmt.Println(" ✗")
 		return fmt.Errorf("failed to check if cluster exists: %w", err)
 	}
 
 	if !exists {
-		if err := kind.CreateCluster(ctx, clusterName, workers, httpPort, httpsPort, nodeImage, insecureRegistryHost); err != nil {
+		if err := provider.Create(ctx, opts); err != nil {
 			fmt.Println(" ✗")
 			return err
 		}
 
-		kind.SetKubectlContext(ctx, clusterName)
+		kubectl.SetContext(provider.KubeContext(opts.Name))
 
-		if err := kind.InstallMetricsServer(ctx); err != nil {
+		if err := provider.InstallMetricsServer(ctx, opts.Name); err != nil {
 			fmt.Println(" ✗")
 			return err
 		}
 
-		if err := kubectl.CreateDeploymentMarker(ctx, clusterName, "default", "kind-cluster"); err != nil {
+		if err := kubectl.CreateDeploymentMarker(ctx, opts.Name, "default", localcluster.MarkerComponent(provider.Name())); err != nil {
 			fmt.Println(" ✗")
 			return err
 		}
@@ -988,7 +1010,7 @@ func waitForAllClusterPodsReady(ctx context.Context, timeout time.Duration) erro
 }
 
 func clusterPodsReady(ctx context.Context) (bool, []string, error) {
-	_, cs, err := kubectl.GetClientset()
+	cs, err := kubectl.FromContext(ctx).Clientset()
 	if err != nil {
 		return false, nil, err
 	}
@@ -1208,6 +1230,8 @@ type wandbCRFlags struct {
 	// points the operator/subchart charts + images and the server manifest at the
 	// mirror (defaults manifestRepo). It does NOT set spec.global.imageRegistry.
 	mirrorRegistry         string
+	mirrorLayout           layoutFlags
+	mirrorCreds            credentialFlags
 	insecureRegistry       bool
 	registryCAFile         string
 	manifestRepo           string
@@ -1253,6 +1277,8 @@ func wandbCRFlagsFrom(cmd *cobra.Command) wandbCRFlags {
 		objectStoreCopies:      changedInt32(cmd, "objectstore-copies"),
 		bucketProxy:            changedBool(cmd, "bucket-proxy"),
 		mirrorRegistry:         str("mirror-registry"),
+		mirrorLayout:           layoutFlags{strategy: str("mirror-layout"), prefix: str("mirror-layout-prefix"), mapping: str("mirror-layout-mapping")},
+		mirrorCreds:            credentialFlags{namePrefix: "mirror-", username: str("mirror-registry-username"), passwordStdin: boolean("mirror-registry-password-stdin")},
 		insecureRegistry:       boolean("insecure-registry"),
 		registryCAFile:         str("registry-ca-file"),
 		manifestRepo:           str("manifest-repository"),
@@ -1341,7 +1367,8 @@ func validateSizeOverride(overrides []operator.CROverride) error {
 
 // normalizeMirrorManifestSource defaults the server-manifest source from the mirror
 // and rejects a source the operator can't consume. When installing from a mirror,
-// defaulting manifestRepo to oci://<mirror>/wandb/server-manifest makes the operator
+// defaulting manifestRepo to oci://<mirror>/wandb/server-manifest (placed by
+// --mirror-layout) makes the operator
 // pull the manifest (and every app image it references) offline — 'wsm registry mirror
 // --wandb-version' pushes it to exactly this path. willReconcile is true when the CR is
 // actually applied this run (always for `wandb deploy`; only with --include-cr for
@@ -1349,7 +1376,11 @@ func validateSizeOverride(overrides []operator.CROverride) error {
 // an unusable source is a warning, not a failure.
 func normalizeMirrorManifestSource(f *wandbCRFlags, willReconcile bool) error {
 	if f.manifestRepo == "" && f.mirrorRegistry != "" {
-		f.manifestRepo = "oci://" + strings.TrimRight(f.mirrorRegistry, "/") + "/wandb/server-manifest"
+		layout, err := f.mirrorLayout.layout()
+		if err != nil {
+			return err
+		}
+		f.manifestRepo = "oci://" + layout.Repository(f.mirrorRegistry, serverManifestUpstream)
 	}
 	// An oci:// manifest is fetched over HTTPS; a plain-HTTP mirror can't serve it. A
 	// file:// source is mounted onto the operator pod and needs no registry TLS.
@@ -1634,50 +1665,230 @@ func ClusterCmd() *cobra.Command {
 	cmd.AddCommand(clusterDestroyCmd())
 	cmd.AddCommand(clusterListCmd())
 	cmd.AddCommand(clusterCleanupCmd())
+	cmd.AddCommand(clusterStatusCmd())
 
 	return cmd
 }
 
+// kindConfigFlags are the `cluster create` flags customizing the generated
+// Kind config.
+type kindConfigFlags struct {
+	file          string
+	portMappings  []string
+	mounts        []string
+	featureGates  []string
+	apiServerPort int32
+	nodeLabels    []string
+	nodeTaints    []string
+}
+
+func (f *kindConfigFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.file, "config", "", "Kind config file (kind: Cluster, apiVersion: kind.x-k8s.io/v1alpha4) merged over the generated one: maps merge, scalars override, nodes merge by position, other lists are appended")
+	fs.StringArrayVar(&f.portMappings, "port-mapping", nil, "Extra control-plane port mapping HOST:CONTAINER[/PROTOCOL], e.g. 9000:30900; repeatable")
+	fs.StringArrayVar(&f.mounts, "mount", nil, "Host path mounted into every node as HOST_PATH:CONTAINER_PATH[:ro], e.g. ./data:/mnt/data for local object storage; repeatable")
+	fs.StringSliceVar(&f.featureGates, "feature-gates", nil, "Kubernetes feature gates NAME=true|false, comma-separated or repeated")
+	fs.Int32Var(&f.apiServerPort, "api-server-port", 0, "Host port of the API server (default a free port)")
+	fs.StringArrayVar(&f.nodeLabels, "node-label", nil, "Node label [NODES:]KEY=VALUE; NODES is all (default), control-plane, worker or worker-N; repeatable")
+	fs.StringArrayVar(&f.nodeTaints, "node-taint", nil, "Node taint [NODES:]KEY[=VALUE]:EFFECT, e.g. worker-1:dedicated=db:NoSchedule; repeatable")
+}
+
+func (f *kindConfigFlags) config() (kind.ClusterConfig, error) {
+	c := kind.ClusterConfig{File: f.file, APIServerPort: f.apiServerPort}
+	for _, v := range f.portMappings {
+		m, err := kind.ParsePortMapping(v)
+		if err != nil {
+			return c, err
+		}
+		c.ExtraPortMappings = append(c.ExtraPortMappings, m)
+	}
+	for _, v := range f.mounts {
+		m, err := kind.ParseMount(v)
+		if err != nil {
+			return c, err
+		}
+		c.ExtraMounts = append(c.ExtraMounts, m)
+	}
+	for _, v := range f.featureGates {
+		name, enabled, err := kind.ParseFeatureGate(v)
+		if err != nil {
+			return c, err
+		}
+		if c.FeatureGates == nil {
+			c.FeatureGates = map[string]bool{}
+		}
+		c.FeatureGates[name] = enabled
+	}
+	for _, v := range f.nodeLabels {
+		l, err := kind.ParseNodeLabel(v)
+		if err != nil {
+			return c, err
+		}
+		c.NodeLabels = append(c.NodeLabels, l)
+	}
+	for _, v := range f.nodeTaints {
+		t, err := kind.ParseNodeTaint(v)
+		if err != nil {
+			return c, err
+		}
+		c.NodeTaints = append(c.NodeTaints, t)
+	}
+	return c, nil
+}
+
+// nodeRegistryFlags are the `cluster create` flags choosing the registry the
+// nodes pull from.
+type nodeRegistryFlags struct {
+	insecureHost string
+	mirror       string
+	caFile       string
+	local        bool
+	localPort    int32
+	localDir     string
+}
+
+func (f *nodeRegistryFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.insecureHost, "insecure-registry-host", "", "Configure containerd to pull from this host over plain HTTP (e.g. host.docker.internal:5000). Pairs with 'wsm registry mirror --insecure' for local-laptop testing against a plain-HTTP registry:2.")
+	fs.StringVar(&f.mirror, "registry-mirror", "", "Configure containerd to pull from this HTTPS registry, for its own images and the upstream registries mirrored to it (with --local-registry, the host name image references use; default localhost:<port>)")
+	fs.StringVar(&f.caFile, "registry-ca-file", "", "PEM CA bundle the nodes trust for --registry-mirror")
+	fs.BoolVar(&f.local, "local-registry", false, "Start (or reuse) an HTTPS registry:2 container, "+kind.LocalRegistryName+", on the Kind network and pull from it (Kind only)")
+	fs.Int32Var(&f.localPort, "local-registry-port", 5443, "Localhost port the local registry is published on")
+	fs.StringVar(&f.localDir, "local-registry-dir", "wsm-registry", "Directory keeping the local registry's CA and certificate")
+}
+
+// registry returns the registry the nodes pull from, starting the local
+// registry when asked.
+func (f *nodeRegistryFlags) registry(ctx context.Context, provider localcluster.Provider, layout *mirror.Layout) (kind.Registry, error) {
+	switch {
+	case f.insecureHost != "" && (f.mirror != "" || f.local):
+		return kind.Registry{}, fmt.Errorf("--insecure-registry-host can't be combined with --registry-mirror or --local-registry")
+	case f.insecureHost != "":
+		return kind.Registry{Host: f.insecureHost, Insecure: true, Layout: layout}, nil
+	case f.local && f.caFile != "":
+		return kind.Registry{}, fmt.Errorf("--local-registry serves a certificate from its own CA; drop --registry-ca-file")
+	case f.local && provider.Name() != "kind":
+		return kind.Registry{}, fmt.Errorf("--local-registry needs the kind provider")
+	case f.local:
+		local, err := kind.StartLocalRegistry(ctx, f.localPort, f.localDir)
+		if err != nil {
+			return kind.Registry{}, err
+		}
+		host := f.mirror
+		if host == "" {
+			host = local.Host
+		}
+		return kind.Registry{Host: host, Endpoint: local.Endpoint, CAFile: local.CAFile, Layout: layout}, nil
+	case f.caFile != "" && f.mirror == "":
+		return kind.Registry{}, fmt.Errorf("--registry-ca-file needs --registry-mirror")
+	case f.mirror != "":
+		r := kind.Registry{Host: strings.TrimRight(f.mirror, "/"), CAFile: f.caFile, Layout: layout}
+		return r, r.Validate()
+	}
+	return kind.Registry{}, nil
+}
+
 func clusterCreateCmd() *cobra.Command {
-	var clusterName string
-	var workers int
-	var httpPort, httpsPort int32
-	var kindNodeImage string
-	var insecureRegistryHost string
+	var providerName string
+	var opts localcluster.CreateOptions
+	var layoutOpts layoutFlags
+	var kindOpts kindConfigFlags
+	var registryOpts nodeRegistryFlags
 	cmd := &cobra.Command{
 		Use:   "create",
-		Short: "Create a new kind cluster",
+		Short: "Create a new local cluster",
+		Long: `Create a local cluster with Kind (default), k3d or minikube (--provider).
+
+The host's --http-port and --https-port lead to the nginx gateway's node ports,
+and --insecure-registry-host makes every provider's containerd pull from a
+plain-HTTP registry, standing in for the upstream registries too. The k3d and
+minikube providers need their CLI installed; minikube uses its docker driver.
+
+--registry-mirror does the same for an HTTPS registry, trusting
+--registry-ca-file. With Kind, --local-registry starts a registry:2 container
+serving HTTPS with a certificate from a CA kept in --local-registry-dir, and
+attaches it to the Kind network, for rehearsing a TLS air-gapped install on one
+machine: mirror into localhost:<port>, and the nodes pull from it by name.
+
+With Kind the generated config can be customized with a Kind config file
+(--config) merged over it, then extra port mappings, host path mounts, feature
+gates, the API server port, and node labels and taints, which also apply to the
+nodes the file adds.`,
+		Example: `  wsm cluster create --cluster-name wandb --workers 2
+  wsm cluster create --workers 3 --node-label worker:pool=apps --node-taint worker-3:dedicated=db:NoSchedule
+  wsm cluster create --mount ./objects:/mnt/objects --port-mapping 9000:30900 --config kind.yaml
+  wsm cluster create --registry-mirror harbor.corp.internal --registry-ca-file ./corp-ca.pem
+  wsm cluster create --local-registry`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := performCreateCluster(context.Background(), clusterName, workers, httpPort, httpsPort, kindNodeImage, insecureRegistryHost); err != nil {
+			provider, err := localcluster.Get(providerName)
+			if err != nil {
+				return err
+			}
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			if opts.Kind, err = kindOpts.config(); err != nil {
+				return err
+			}
+			ctx := context.Background()
+			if opts.Registry, err = registryOpts.registry(ctx, provider, layout); err != nil {
+				return err
+			}
+			// The host's Docker pulls the node image before the nodes know the
+			// registry: it refuses a plain-HTTP one, and a fresh local registry
+			// doesn't hold the image yet.
+			if opts.NodeImage == "" && !opts.Registry.IsZero() && !opts.Registry.Insecure && !registryOpts.local {
+				opts.NodeImage = provider.MirroredNodeImage(opts.Registry.Host, layout)
+			}
+			if err := performCreateCluster(ctx, provider, opts); err != nil {
 				fmt.Printf("✗ Cluster Create failed: %v\n", err)
 				return err
 			}
-			fmt.Printf("✓ Kind cluster '%s' created successfully\n", clusterName)
+			if registryOpts.local {
+				if err := kind.ConnectLocalRegistry(ctx); err != nil {
+					fmt.Printf("✗ Cluster Create failed: %v\n", err)
+					return err
+				}
+			}
+			fmt.Printf("✓ %s cluster '%s' created successfully (context %s)\n", provider.Name(), opts.Name, provider.KubeContext(opts.Name))
+			if registryOpts.local {
+				fmt.Printf("  • Local registry: %s (nodes: %s)\n", opts.Registry.Host, opts.Registry.Endpoint)
+				fmt.Printf("  • CA certificate: %s\n", opts.Registry.CAFile)
+				fmt.Printf("  • Next: wsm registry mirror --to %s (trust the CA, or pass --insecure)\n", opts.Registry.Host)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&clusterName, "cluster-name", "kind", "Name of the Kind cluster")
-	cmd.Flags().IntVar(&workers, "workers", 0, "Number of worker nodes")
-	cmd.Flags().Int32Var(&httpPort, "http-port", 8080, "HTTP port for Kind cluster ingress")
-	cmd.Flags().Int32Var(&httpsPort, "https-port", 8443, "HTTPS port for Kind cluster ingress")
-	cmd.Flags().StringVar(&kindNodeImage, "kind-node-image", "", "Kind node image to use, e.g. myreg.example.com/kindest/node:v1.35.1@sha256:... (defaults to the upstream pinned image)")
-	cmd.Flags().StringVar(&insecureRegistryHost, "insecure-registry-host", "", "Configure containerd to pull from this host over plain HTTP (e.g. host.docker.internal:5000). Pairs with 'wsm registry mirror --insecure' for local-laptop testing against a plain-HTTP registry:2.")
+	cmd.Flags().StringVar(&providerName, "provider", localcluster.DefaultProvider, "Local cluster provider: "+strings.Join(localcluster.Names(), ", "))
+	cmd.Flags().StringVar(&opts.Name, "cluster-name", "kind", "Name of the cluster")
+	cmd.Flags().IntVar(&opts.Workers, "workers", 0, "Number of worker nodes")
+	cmd.Flags().Int32Var(&opts.HTTPPort, "http-port", 8080, "Host port for the cluster's HTTP ingress")
+	cmd.Flags().Int32Var(&opts.HTTPSPort, "https-port", 8443, "Host port for the cluster's HTTPS ingress")
+	cmd.Flags().StringVar(&opts.NodeImage, "node-image", "", "Node image to use: the kind node, k3s or minikube base image, e.g. myreg.example.com/kindest/node:v1.35.1@sha256:... (defaults to the provider's; for kind the pinned image, from --registry-mirror when set)")
+	cmd.Flags().StringVar(&opts.NodeImage, "kind-node-image", "", "Kind node image to use")
+	_ = cmd.Flags().MarkDeprecated("kind-node-image", "use --node-image")
+	registryOpts.register(cmd.Flags())
+	layoutOpts.register(cmd.Flags(), "mirror-")
+	kindOpts.register(cmd.Flags())
 
 	return cmd
 }
 
 func clusterDestroyCmd() *cobra.Command {
+	var providerName string
 	var clusterName string
 	cmd := &cobra.Command{
 		Use:   "destroy",
-		Short: "Destroy Kind cluster and cleanup",
-		Long:  `Delete the Kind cluster and cleanup resources`,
+		Short: "Destroy a local cluster and cleanup",
+		Long:  `Delete the local cluster and cleanup resources`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-			kubectl.SetContext(fmt.Sprintf("kind-%s", clusterName))
+			provider, err := localcluster.Get(providerName)
+			if err != nil {
+				return err
+			}
+			ctx := kubectl.WithClient(context.Background(), kubectl.NewClient(provider.KubeContext(clusterName)))
 
-			hasMarker, err := kubectl.HasDeploymentMarker(ctx, "default", "kind-cluster")
+			hasMarker, err := kubectl.HasDeploymentMarker(ctx, "default", localcluster.MarkerComponent(provider.Name()))
 			if err != nil {
 				return err
 			}
@@ -1686,8 +1897,8 @@ func clusterDestroyCmd() *cobra.Command {
 				return fmt.Errorf("no wsm deployment marker found - cluster may not be managed by wsm")
 			}
 
-			fmt.Printf("→ Deleting Kind cluster '%s'...\n", clusterName)
-			if err := kind.DeleteCluster(ctx, clusterName); err != nil {
+			fmt.Printf("→ Deleting %s cluster '%s'...\n", provider.Name(), clusterName)
+			if err := provider.Delete(ctx, clusterName); err != nil {
 				return err
 			}
 
@@ -1695,12 +1906,13 @@ func clusterDestroyCmd() *cobra.Command {
 				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 			}
 
-			fmt.Printf("✓ Kind cluster '%s' deleted successfully\n", clusterName)
+			fmt.Printf("✓ %s cluster '%s' deleted successfully\n", provider.Name(), clusterName)
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&clusterName, "cluster-name", "kind", "Name of the Kind cluster to delete")
+	cmd.Flags().StringVar(&providerName, "provider", localcluster.DefaultProvider, "Local cluster provider: "+strings.Join(localcluster.Names(), ", "))
+	cmd.Flags().StringVar(&clusterName, "cluster-name", "kind", "Name of the cluster to delete")
 
 	return cmd
 }
@@ -1708,24 +1920,24 @@ func clusterDestroyCmd() *cobra.Command {
 func clusterListCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list",
-		Short: "List wsm-managed Kind clusters",
-		Long:  `List local Kind clusters that contain the wsm deployment marker`,
+		Short: "List wsm-managed local clusters",
+		Long:  `List the local clusters of every installed provider that contain the wsm deployment marker`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
-			clusters, err := listWSMManagedKindClusters(ctx)
+			clusters, err := listWSMManagedClusters(ctx)
 			if err != nil {
 				return err
 			}
 
 			if len(clusters) == 0 {
-				fmt.Println("! No wsm-managed Kind clusters found.")
+				fmt.Println("! No wsm-managed local clusters found.")
 				return nil
 			}
 
-			fmt.Println("WSM-managed Kind clusters:")
-			for _, clusterName := range clusters {
-				fmt.Printf("  • %s\n", clusterName)
+			fmt.Println("WSM-managed local clusters:")
+			for _, c := range clusters {
+				fmt.Printf("  • %s (%s, context %s)\n", c.name, c.provider.Name(), c.provider.KubeContext(c.name))
 			}
 
 			return nil
@@ -1735,155 +1947,36 @@ func clusterListCmd() *cobra.Command {
 	return cmd
 }
 
-func listWSMManagedKindClusters(ctx context.Context) ([]string, error) {
-	kindClusters, err := kind.ListClusters()
-	if err != nil {
-		return nil, err
-	}
-
-	originalContext := kubectl.GetContext()
-	defer func() {
-		kubectl.SetContext(originalContext)
-		kubectl.ResetClients()
-	}()
-
-	clusters := make([]string, 0, len(kindClusters))
-	for _, clusterName := range kindClusters {
-		kubectl.SetContext(fmt.Sprintf("kind-%s", clusterName))
-		kubectl.ResetClients()
-
-		hasMarker, err := kubectl.HasDeploymentMarker(ctx, "default", "kind-cluster")
-		if err != nil {
-			return nil, fmt.Errorf("failed to check deployment marker for cluster %q: %w", clusterName, err)
-		}
-
-		if hasMarker {
-			clusters = append(clusters, clusterName)
-		}
-	}
-
-	return clusters, nil
+// managedCluster is a local cluster carrying wsm's deployment marker.
+type managedCluster struct {
+	provider localcluster.Provider
+	name     string
 }
 
-func performCleanup() error {
-	ctx := context.Background()
-
-	// 1. Delete W&B CRs in all namespaces that have the marker
-	wandbNamespaces, err := kubectl.FindNamespacesWithMarker(ctx, "wandb-cr")
-	if err != nil {
-		return err
-	}
-
-	if len(wandbNamespaces) == 0 {
-		fmt.Println("! No wsm-managed W&B deployments found.")
-	}
-
-	for _, ns := range wandbNamespaces {
-		crs, err := operator.ListCRs(ctx, ns)
-		if apierrors.IsNotFound(err) {
-			crs = nil
-		} else if err != nil {
-			fmt.Printf("✗ Failed to list W&B CRs in namespace %s: %v\n", ns, err)
+func listWSMManagedClusters(ctx context.Context) ([]managedCluster, error) {
+	var clusters []managedCluster
+	for _, provider := range localcluster.Providers() {
+		if !provider.Installed() {
 			continue
 		}
-
-		for _, cr := range crs {
-			fmt.Printf("→ Deleting W&B CR '%s' in namespace '%s'...\n", cr, ns)
-			if err := operator.DeleteCR(ctx, cr, ns); err != nil {
-				fmt.Printf("  ✗ Failed to delete W&B CR %s/%s: %v\n", ns, cr, err)
-			}
-		}
-
-		fmt.Printf("→ Removing deployment marker in namespace '%s'...\n", ns)
-		if err := kubectl.DeleteDeploymentMarker(ctx, ns, "wandb-cr"); err != nil {
-			fmt.Printf("  ✗ Failed to delete deployment marker in %s: %v\n", ns, err)
-		}
-	}
-
-	// 2. Delete W&B operators in all namespaces that have the marker
-	operatorNamespaces, err := kubectl.FindNamespacesWithMarker(ctx, "operator")
-	if err != nil {
-		return err
-	}
-
-	if len(operatorNamespaces) == 0 {
-		fmt.Println("! No wsm-managed W&B operators found.")
-	}
-
-	for _, ns := range operatorNamespaces {
-		fmt.Printf("→ Deleting W&B operator in namespace '%s'...\n", ns)
-		if _, err := operator.DeleteOperator(ctx, ns); err != nil {
-			fmt.Printf("  ✗ Failed to delete operator in %s: %v\n", ns, err)
-			return err
+		names, err := provider.List(ctx)
+		if err != nil {
+			return nil, err
 		}
 
-		fmt.Printf("→ Removing deployment marker in namespace '%s'...\n", ns)
-		if err := kubectl.DeleteDeploymentMarker(ctx, ns, "operator"); err != nil {
-			fmt.Printf("  ✗ Failed to delete deployment marker in %s: %v\n", ns, err)
-			return err
-		}
-	}
+		for _, clusterName := range names {
+			clusterCtx := kubectl.WithClient(ctx, kubectl.NewClient(provider.KubeContext(clusterName)))
 
-	// 3. Delete cert-manager
-	fmt.Println("→ Deleting cert-manager...")
-	if _, err := operator.DeleteCertManager(ctx); err != nil {
-		fmt.Printf("  ✗ Failed to delete cert-manager: %v\n", err)
-	}
-
-	cmNamespaces, err := kubectl.FindNamespacesWithMarker(ctx, "cert-manager")
-	if err == nil {
-		for _, ns := range cmNamespaces {
-			if err := kubectl.DeleteDeploymentMarker(ctx, ns, "cert-manager"); err != nil {
-				fmt.Printf("  ✗ Failed to remove cert-manager marker in %s: %v\n", ns, err)
+			hasMarker, err := kubectl.HasDeploymentMarker(clusterCtx, "default", localcluster.MarkerComponent(provider.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to check deployment marker for %s cluster %q: %w", provider.Name(), clusterName, err)
 			}
-		}
-	}
 
-	// 4. Delete nginx-gateway
-	fmt.Println("→ Deleting nginx-gateway...")
-	if _, err := operator.DeleteNginxGateway(ctx); err != nil {
-		fmt.Printf("  ✗ Failed to delete nginx-gateway: %v\n", err)
-	}
-
-	ngNamespaces, err := kubectl.FindNamespacesWithMarker(ctx, "nginx-gateway")
-	if err == nil {
-		for _, ns := range ngNamespaces {
-			if err := kubectl.DeleteDeploymentMarker(ctx, ns, "nginx-gateway"); err != nil {
-				fmt.Printf("  ✗ Failed to remove nginx-gateway marker in %s: %v\n", ns, err)
+			if hasMarker {
+				clusters = append(clusters, managedCluster{provider: provider, name: clusterName})
 			}
 		}
 	}
 
-	return nil
-}
-
-func clusterCleanupCmd() *cobra.Command {
-	var kubeContext string
-
-	cmd := &cobra.Command{
-		Use:   "cleanup",
-		Short: "Cleanup wsm-managed resources",
-		Long:  `Delete all resources deployed by wsm, including operator and CR`,
-		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if kubeContext == "" {
-				return errors.New("context is required")
-			}
-
-			kubectl.SetContext(kubeContext)
-			return nil
-		},
-		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := performCleanup(); err != nil {
-				fmt.Printf("✗ Cleanup failed: %v\n", err)
-				return err
-			}
-
-			fmt.Println("✓ Cleanup completed successfully")
-			return nil
-		},
-	}
-
-	cmd.Flags().StringVar(&kubeContext, "context", "", "name of the kubeconfig context to use (Required)")
-
-	return cmd
+	return clusters, nil
 }
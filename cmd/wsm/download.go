@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wandb/wsm/pkg/deployer"
 	"github.com/wandb/wsm/pkg/helm"
 	"github.com/wandb/wsm/pkg/images"
+	"github.com/wandb/wsm/pkg/registryauth"
 	"github.com/wandb/wsm/pkg/term/pkgm"
 	"github.com/wandb/wsm/pkg/utils"
 	"gopkg.in/yaml.v3"
@@ -46,11 +50,19 @@ func downloadChartImages(
 }
 
 func DownloadCmd() *cobra.Command {
-	var platform string
+	var (
+		platform  string
+		extraOpts extraFlags
+	)
 
 	cmd := &cobra.Command{
 		Use: "download",
 		Run: func(cmd *cobra.Command, args []string) {
+			extras, err := extraOpts.extras()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
 			_ = os.RemoveAll("bundle")
 			// Fetch the latest tag for the controller
 			operatorTag, err := getMostRecentTag("wandb/controller")
@@ -93,7 +105,7 @@ func DownloadCmd() *cobra.Command {
 				dlSpec.Values,
 			)
 
-			imgs := utils.RemoveDuplicates(append(wandbImgs, operatorImgs...))
+			imgs := utils.RemoveDuplicates(append(append(wandbImgs, operatorImgs...), extras.Images...))
 			if len(imgs) == 0 {
 				fmt.Println("No images to download.")
 				os.Exit(1)
@@ -122,10 +134,28 @@ func DownloadCmd() *cobra.Command {
 				fmt.Println("Error deploying:", err)
 				os.Exit(1)
 			}
+
+			// Extra OCI charts are kept as OCI layouts; 'wsm registry push'
+			// pushes them beside the images.
+			creds := registryauth.Default()
+			var failed []string
+			for _, chart := range extras.Charts {
+				fmt.Println("Downloading chart", chart)
+				dir := filepath.Join("bundle", ociChartsDir, filepath.FromSlash(chart))
+				if err := downloadOCIChart(context.Background(), chart, dir, creds); err != nil {
+					fmt.Printf("Error downloading chart %s: %v\n", chart, err)
+					failed = append(failed, chart)
+				}
+			}
+			if len(failed) > 0 {
+				fmt.Printf("The bundle is incomplete: %d extra chart(s) failed to download: %s\n", len(failed), strings.Join(failed, ", "))
+				os.Exit(1)
+			}
 		},
 	}
 
 	cmd.Flags().StringVarP(&platform, "platform", "p", "linux/amd64", "Platform to download images for")
+	extraOpts.register(cmd.Flags())
 
 	return cmd
 }
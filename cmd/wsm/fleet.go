@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/fleet"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/operator"
+)
+
+func init() {
+	rootCmd.AddCommand(FleetCmd())
+}
+
+// fleetFlags are the flags every fleet subcommand shares.
+type fleetFlags struct {
+	file        string
+	clusters    []string
+	maxParallel int
+}
+
+// targets loads the fleet file and resolves the selected clusters on top of
+// the defaults the single-cluster commands use.
+func (f *fleetFlags) targets() ([]fleet.Target, error) {
+	file, err := fleet.Load(f.file)
+	if err != nil {
+		return nil, err
+	}
+	base := fleet.Profile{
+		WandbName:            "wandb",
+		WandbNamespace:       "wandb",
+		OperatorNamespace:    "wandb-operators",
+		OperatorChartVersion: defaultOperatorChartVersion,
+		ObservabilityMode:    operator.TelemetryModeOff,
+	}
+	return file.Targets(base, f.clusters)
+}
+
+func FleetCmd() *cobra.Command {
+	var flags fleetFlags
+	cmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "Run wsm operations across every cluster in a fleet file",
+		Long: `Run an operation against many clusters at once. The fleet file lists the
+  kubeconfig contexts W&B runs in, with defaults, named profiles and per-cluster
+  overrides for the settings each one is deployed with:
+
+    defaults:
+      wandbVersion: 0.82.2
+      mirrorRegistry: harbor.corp:5443
+    profiles:
+      eu:
+        mirrorRegistry: harbor.eu.corp:5443
+        deployArgs: ["--size", "large"]
+    clusters:
+      - name: staging
+        context: staging-admin
+        canary: true
+      - name: prod-us
+        context: prod-us-admin
+      - name: prod-eu
+        context: prod-eu-admin
+        profile: eu
+
+  Settings: wandbVersion, wandbName, wandbNamespace, operatorNamespace,
+  operatorChartVersion, observabilityMode, mirrorRegistry, insecureRegistry,
+  skipManagedImages, and deployArgs (extra 'wsm deploy-v2 operator' flags).
+
+    wsm fleet status               Report each cluster's operator and W&B version.
+    wsm fleet set-version          Upgrade the W&B version, canary clusters first.
+    wsm fleet deploy               Run 'wsm deploy-v2 operator' on every cluster, canaries first.
+    wsm fleet registry check       Run 'wsm registry check' against each cluster's mirror.
+
+  Clusters run concurrently, at most --max-parallel at a time; each subcommand
+  prints one row per cluster and exits non-zero if any cluster failed.`,
+	}
+	cmd.PersistentFlags().StringVarP(&flags.file, "fleet-file", "f", "fleet.yaml", "Fleet file listing the clusters")
+	cmd.PersistentFlags().StringSliceVar(&flags.clusters, "cluster", nil, "Only run against these clusters (by name); repeatable")
+	cmd.PersistentFlags().IntVar(&flags.maxParallel, "max-parallel", 4, "Maximum number of clusters to run against at once (0 for all)")
+
+	cmd.AddCommand(fleetStatusCmd(&flags))
+	cmd.AddCommand(fleetSetVersionCmd(&flags))
+	cmd.AddCommand(fleetDeployCmd(&flags))
+	cmd.AddCommand(fleetRegistryCmd(&flags))
+	return cmd
+}
+
+// clusterContext returns ctx carrying a client for t's kubeconfig context.
+func clusterContext(ctx context.Context, t fleet.Target) context.Context {
+	return kubectl.WithClient(ctx, kubectl.NewClient(t.Context))
+}
+
+// printFleetResults prints one row per cluster under headers, the failures
+// below the table, and a summary line. It returns the number of clusters that
+// failed or were skipped.
+func printFleetResults(headers []string, results []fleet.Result) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tCONTEXT\t"+strings.Join(headers, "\t")+"\tRESULT\tTIME")
+	var ok, failed, skipped int
+	for _, r := range results {
+		cells := make([]string, len(headers))
+		for i := range cells {
+			cells[i] = "-"
+			if i < len(r.Cells) && r.Cells[i] != "" {
+				cells[i] = r.Cells[i]
+			}
+		}
+		result, elapsed := "ok", r.Elapsed.Round(time.Second).String()
+		switch {
+		case errors.Is(r.Err, fleet.ErrSkipped):
+			result, elapsed = "skipped", "-"
+			skipped++
+		case r.Err != nil:
+			result = "failed"
+			failed++
+		default:
+			ok++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Target.Name, r.Target.Context, strings.Join(cells, "\t"), result, elapsed)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Println()
+		for _, r := range results {
+			if r.Err != nil && !errors.Is(r.Err, fleet.ErrSkipped) {
+				fmt.Printf("✗ %s: %v\n", r.Target.Name, r.Err)
+			}
+		}
+	}
+	fmt.Printf("\n%d cluster(s) — %d ok, %d failed, %d skipped\n", len(results), ok, failed, skipped)
+	return failed + skipped
+}
+
+// ---------------- wsm fleet status ----------------
+
+func fleetStatusCmd(flags *fleetFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Report the operator and W&B version of every cluster",
+		Long: `Connect to every cluster and report its Kubernetes version, the installed
+  operator chart version, the W&B instance's version and readiness, and the
+  version the fleet file wants it at.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := flags.targets()
+			if err != nil {
+				return err
+			}
+
+			results := fleet.Run(cmd.Context(), targets, fleet.RunOptions{MaxParallel: flags.maxParallel}, func(ctx context.Context, t fleet.Target) ([]string, error) {
+				ctx = clusterContext(ctx, t)
+				client := kubectl.FromContext(ctx)
+				cs, err := client.Clientset()
+				if err != nil {
+					return nil, err
+				}
+				info, err := cs.Discovery().ServerVersion()
+				if err != nil {
+					return nil, fmt.Errorf("cluster unreachable: %w", err)
+				}
+				cells := []string{info.GitVersion, "not installed", "not deployed", "", t.Settings.WandbVersion}
+
+				chartVersion, err := operator.GetOperatorChartVersion(ctx, client, t.Settings.OperatorNamespace)
+				if err != nil {
+					return cells, err
+				}
+				if chartVersion != "" {
+					cells[1] = chartVersion
+				}
+
+				status, err := operator.GetCRStatus(ctx, client, t.Settings.WandbName, t.Settings.WandbNamespace)
+				if err != nil {
+					return cells, err
+				}
+				if status != nil {
+					cells[2] = status.Version
+					cells[3] = "no"
+					if status.Ready {
+						cells[3] = "yes"
+					}
+				}
+				return cells, nil
+			})
+
+			if printFleetResults([]string{"KUBERNETES", "OPERATOR", "WANDB", "READY", "WANT"}, results) > 0 {
+				return fmt.Errorf("could not read the status of every cluster")
+			}
+			return nil
+		},
+	}
+}
+
+// ---------------- wsm fleet set-version ----------------
+
+func fleetSetVersionCmd(flags *fleetFlags) *cobra.Command {
+	var (
+		wandbVersion string
+		canaryFirst  bool
+		wait         bool
+		timeout      time.Duration
+		force        bool
+		dryRun       bool
+		yes          bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set-version",
+		Short: "Upgrade the W&B version of every cluster, canaries first",
+		Long: `Patch spec.wandb.version on each cluster's wsm-managed W&B instance, as
+  'wsm set-version' does for one. The version is --wandb-version, or each
+  cluster's wandbVersion from the fleet file.
+
+  Every cluster is planned first, with the same marker, downgrade and minimum
+  version checks as 'wsm set-version'; if any cluster fails its checks nothing
+  is changed. After one confirmation, the canary clusters are upgraded and
+  waited on until ready, and only if they all come up are the rest upgraded.`,
+		Example: `  wsm fleet set-version --wandb-version 0.83.0 --dry-run
+  wsm fleet set-version --wandb-version 0.83.0 --max-parallel 2 --wait
+  wsm fleet set-version --cluster staging --cluster prod-us --yes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := flags.targets()
+			if err != nil {
+				return err
+			}
+
+			var mu sync.Mutex
+			upgrades := map[string]*versionUpgrade{}
+			fmt.Println("Planning...")
+			plan := fleet.Run(cmd.Context(), targets, fleet.RunOptions{MaxParallel: flags.maxParallel}, func(ctx context.Context, t fleet.Target) ([]string, error) {
+				version := t.Settings.WandbVersion
+				if wandbVersion != "" {
+					version = wandbVersion
+				}
+				if version == "" {
+					return nil, errors.New("no version: pass --wandb-version or set wandbVersion in the fleet file")
+				}
+				upgrade, err := planVersionUpgrade(clusterContext(ctx, t), t.Settings.WandbName, t.Settings.WandbNamespace, version, force)
+				if err != nil {
+					return nil, err
+				}
+				if upgrade == nil {
+					return []string{version, version, "up to date"}, nil
+				}
+				mu.Lock()
+				upgrades[t.Name] = upgrade
+				mu.Unlock()
+				return []string{upgrade.from, upgrade.to, "upgrade"}, nil
+			})
+			if printFleetResults([]string{"CURRENT", "TARGET", "ACTION"}, plan) > 0 {
+				return fmt.Errorf("not every cluster can be upgraded; nothing was changed")
+			}
+
+			var pending []fleet.Target
+			for _, t := range targets {
+				if upgrades[t.Name] != nil {
+					pending = append(pending, t)
+				}
+			}
+			if len(pending) == 0 {
+				fmt.Println("✓ Every cluster is already at its target version, nothing to do.")
+				return nil
+			}
+			if dryRun {
+				fmt.Println("(dry-run) no changes applied.")
+				return nil
+			}
+			if !yes {
+				fmt.Printf("Upgrade %d cluster(s)? [y/N]: ", len(pending))
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+					fmt.Println("aborted.")
+					return nil
+				}
+			}
+
+			opts := fleet.RunOptions{
+				MaxParallel: flags.maxParallel,
+				CanaryFirst: canaryFirst,
+				OnDone: func(r fleet.Result) {
+					if r.Err == nil {
+						fmt.Printf("  ✓ %s upgraded (%s)\n", r.Target.Name, r.Elapsed.Round(time.Second))
+					}
+				},
+			}
+			fmt.Println("\n→ Applying upgrades...")
+			results := fleet.Run(cmd.Context(), pending, opts, func(ctx context.Context, t fleet.Target) ([]string, error) {
+				ctx = clusterContext(ctx, t)
+				upgrade := upgrades[t.Name]
+				cells := []string{upgrade.from, upgrade.to}
+				if err := upgrade.apply(ctx); err != nil {
+					return cells, err
+				}
+				// A canary gates the rollout on coming up, so it is waited on
+				// even without --wait.
+				if wait || (canaryFirst && t.Canary) {
+					if err := operator.WaitForCRReady(ctx, kubectl.FromContext(ctx), t.Settings.WandbNamespace, t.Settings.WandbName, timeout); err != nil {
+						return cells, fmt.Errorf("instance did not become ready: %w", err)
+					}
+				}
+				return cells, nil
+			})
+			fmt.Println()
+			if printFleetResults([]string{"FROM", "TO"}, results) > 0 {
+				return fmt.Errorf("not every cluster was upgraded")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "Target server manifest version for every cluster (defaults to each cluster's wandbVersion in the fleet file)")
+	cmd.Flags().BoolVar(&canaryFirst, "canary-first", true, "Upgrade the canary clusters, and wait for them to be ready, before the rest")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for every W&B instance to be ready after applying, not just the canaries")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "Timeout for each instance to become ready")
+	cmd.Flags().BoolVar(&force, "force", false, "Allow downgrades and unparseable versions")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the plan without applying")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Don't ask for confirmation")
+	return cmd
+}
+
+// ---------------- wsm fleet deploy ----------------
+
+func fleetDeployCmd(flags *fleetFlags) *cobra.Command {
+	var (
+		canaryFirst bool
+		includeCR   bool
+		logDir      string
+		dryRun      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "Run 'wsm deploy-v2 operator' on every cluster, canaries first",
+		Long: `Deploy the operator stack, and the W&B instance unless --include-cr=false, to
+  every cluster by running 'wsm deploy-v2 operator' against each one with the
+  flags its fleet file settings translate to, followed by its deployArgs.
+
+  The canary clusters are deployed first; the rest are only deployed once every
+  canary succeeded. Each deploy's output goes to <log-dir>/<cluster>.log, or,
+  without --log-dir, the tail of a failed deploy's output is printed.`,
+		Example: `  wsm fleet deploy --dry-run
+  wsm fleet deploy --max-parallel 3 --log-dir fleet-logs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := flags.targets()
+			if err != nil {
+				return err
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate the wsm binary: %w", err)
+			}
+
+			if dryRun {
+				for _, t := range targets {
+					fmt.Printf("%s: wsm %s\n", t.Name, strings.Join(fleetDeployArgs(t, includeCR), " "))
+				}
+				fmt.Println("(dry-run) nothing deployed.")
+				return nil
+			}
+			if logDir != "" {
+				if err := os.MkdirAll(logDir, 0o755); err != nil {
+					return fmt.Errorf("failed to create log directory: %w", err)
+				}
+			}
+
+			opts := fleet.RunOptions{
+				MaxParallel: flags.maxParallel,
+				CanaryFirst: canaryFirst,
+				OnDone: func(r fleet.Result) {
+					switch {
+					case errors.Is(r.Err, fleet.ErrSkipped):
+					case r.Err != nil:
+						fmt.Printf("  ✗ %s failed (%s)\n", r.Target.Name, r.Elapsed.Round(time.Second))
+					default:
+						fmt.Printf("  ✓ %s deployed (%s)\n", r.Target.Name, r.Elapsed.Round(time.Second))
+					}
+				},
+			}
+			fmt.Printf("→ Deploying to %d cluster(s)...\n", len(targets))
+			results := fleet.Run(cmd.Context(), targets, opts, func(ctx context.Context, t fleet.Target) ([]string, error) {
+				var out bytes.Buffer
+				run := exec.CommandContext(ctx, exe, fleetDeployArgs(t, includeCR)...)
+				run.Stdout = &out
+				run.Stderr = &out
+				runErr := run.Run()
+
+				cells := []string{t.Settings.OperatorChartVersion, t.Settings.WandbVersion, "-"}
+				if logDir != "" {
+					path := filepath.Join(logDir, t.Name+".log")
+					if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+						return cells, fmt.Errorf("failed to write deploy log: %w", err)
+					}
+					cells[2] = path
+				}
+				if runErr != nil {
+					if logDir == "" {
+						return cells, fmt.Errorf("%w\n%s", runErr, outputTail(out.String(), 15))
+					}
+					return cells, runErr
+				}
+				return cells, nil
+			})
+			fmt.Println()
+			if printFleetResults([]string{"OPERATOR", "WANDB", "LOG"}, results) > 0 {
+				return fmt.Errorf("not every cluster was deployed")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&canaryFirst, "canary-first", true, "Deploy the canary clusters before the rest, and stop if any of them fails")
+	cmd.Flags().BoolVar(&includeCR, "include-cr", true, "Also deploy the W&B instance on each cluster")
+	cmd.Flags().StringVar(&logDir, "log-dir", "", "Write each cluster's deploy output to <log-dir>/<cluster>.log")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the deploy command for each cluster without running it")
+	return cmd
+}
+
+// fleetDeployArgs are the `wsm deploy-v2 operator` arguments for t.
+func fleetDeployArgs(t fleet.Target, includeCR bool) []string {
+	s := t.Settings
+	args := []string{
+		"deploy-v2", "operator",
+		"--context", t.Context,
+		"--wandb-name", s.WandbName,
+		"--wandb-namespace", s.WandbNamespace,
+		"--operator-namespace", s.OperatorNamespace,
+		"--operator-chart-version", s.OperatorChartVersion,
+		"--observability-mode", s.ObservabilityMode,
+	}
+	// The kubeconfig, impersonation and timeout flags wsm was run with.
+	opts := kubectl.DefaultOptions()
+	if opts.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", opts.Kubeconfig)
+	}
+	if opts.Impersonate != "" {
+		args = append(args, "--as", opts.Impersonate)
+	}
+	for _, group := range opts.ImpersonateGroups {
+		args = append(args, "--as-group", group)
+	}
+	if opts.RequestTimeout > 0 {
+		args = append(args, "--request-timeout", opts.RequestTimeout.String())
+	}
+	if opts.ForceConflicts {
+		args = append(args, "--force-conflicts")
+	}
+	if includeCR {
+		args = append(args, "--include-cr")
+	}
+	if s.WandbVersion != "" {
+		args = append(args, "--wandb-version", s.WandbVersion)
+	}
+	if s.MirrorRegistry != "" {
+		args = append(args, "--mirror-registry", s.MirrorRegistry)
+	}
+	if s.Insecure() {
+		args = append(args, "--insecure-registry")
+	}
+	return append(args, s.DeployArgs...)
+}
+
+// outputTail returns the last n lines of out.
+func outputTail(out string, n int) string {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return "    " + strings.Join(lines, "\n    ")
+}
+
+// ---------------- wsm fleet registry check ----------------
+
+func fleetRegistryCmd(flags *fleetFlags) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Check the mirror registry of every cluster",
+	}
+	cmd.AddCommand(fleetRegistryCheckCmd(flags))
+	return cmd
+}
+
+func fleetRegistryCheckCmd(flags *fleetFlags) *cobra.Command {
+	var layoutOpts layoutFlags
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Run 'wsm registry check' against each cluster's mirror registry",
+		Long: `Check that each cluster's mirrorRegistry holds everything 'wsm registry mirror'
+  pushes for the cluster's operatorChartVersion, wandbVersion,
+  observabilityMode and skipManagedImages settings. Clusters sharing a registry
+  and settings are checked once. Clusters without a mirrorRegistry are listed
+  but not checked.
+
+  Auth comes from the WSM_REGISTRY_USERNAME/WSM_REGISTRY_PASSWORD environment
+  variables, then your Docker config (~/.docker/config.json, including
+  credential helpers).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := flags.targets()
+			if err != nil {
+				return err
+			}
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+
+			type check struct {
+				once  sync.Once
+				cells []string
+				err   error
+			}
+			var mu sync.Mutex
+			checks := map[string]*check{}
+
+			results := fleet.Run(cmd.Context(), targets, fleet.RunOptions{MaxParallel: flags.maxParallel}, func(ctx context.Context, t fleet.Target) ([]string, error) {
+				s := t.Settings
+				if s.MirrorRegistry == "" {
+					return []string{"(none)"}, nil
+				}
+				registry := strings.TrimRight(s.MirrorRegistry, "/")
+				key := strings.Join([]string{registry, s.OperatorChartVersion, s.WandbVersion, s.ObservabilityMode, fmt.Sprint(s.SkipManaged(), s.Insecure())}, "|")
+				mu.Lock()
+				c, ok := checks[key]
+				if !ok {
+					c = &check{}
+					checks[key] = c
+				}
+				mu.Unlock()
+
+				c.once.Do(func() {
+					c.cells, c.err = fleetCheckRegistry(ctx, registry, s, layout)
+				})
+				return c.cells, c.err
+			})
+
+			if printFleetResults([]string{"REGISTRY", "PRESENT", "MISSING", "ERRORS"}, results) > 0 {
+				return fmt.Errorf("not every cluster's registry is complete")
+			}
+			return nil
+		},
+	}
+
+	layoutOpts.register(cmd.Flags(), "")
+	return cmd
+}
+
+// fleetCheckRegistry checks registry for the artifacts a cluster with
+// settings s needs, as 'wsm registry check' does.
+func fleetCheckRegistry(ctx context.Context, registry string, s fleet.Profile, layout *mirror.Layout) ([]string, error) {
+	creds, err := new(credentialFlags).provider(registry)
+	if err != nil {
+		return nil, err
+	}
+	telemetry, err := stackTelemetry(s.ObservabilityMode)
+	if err != nil {
+		return nil, err
+	}
+	stack := stackOptions{operatorChartVersion: s.OperatorChartVersion, telemetry: telemetry, skipManaged: s.SkipManaged()}
+	set := mirrorTargets(ctx, registry, s.WandbVersion, stack, mirror.Extras{}, layout, s.Insecure(), creds)
+
+	var present, missing, errs int
+	for _, tgt := range set.refs {
+		switch status, _ := checkOne(ctx, tgt, s.Insecure(), creds); status {
+		case "present":
+			present++
+		case "missing":
+			missing++
+		default:
+			errs++
+		}
+	}
+	cells := []string{registry, fmt.Sprint(present), fmt.Sprint(missing), fmt.Sprint(errs)}
+	switch {
+	case set.chartErr != nil:
+		return cells, set.chartErr
+	case set.manifestErr != nil:
+		return cells, set.manifestErr
+	case missing+errs > 0:
+		return cells, fmt.Errorf("%d artifact(s) not present in %s (run 'wsm registry check --registry %s' for the list)", missing+errs, registry, registry)
+	}
+	return cells, nil
+}
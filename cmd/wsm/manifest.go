@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	wmanifest "github.com/wandb/operator/pkg/wandb/manifest"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	rootCmd.AddCommand(ManifestCmd())
+}
+
+func ManifestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "manifest",
+		Short: "Inspect the W&B server manifest",
+		Long: `The server manifest is the OCI artifact the v2 operator reads to learn which
+application images and migrations make up a W&B version. These commands read
+it from upstream, or from a mirror with --registry.
+
+    wsm manifest show  Print the components, images and migrations of a version.
+    wsm manifest diff  Show what changes between two versions.`,
+	}
+	cmd.AddCommand(manifestShowCmd())
+	cmd.AddCommand(manifestDiffCmd())
+	return cmd
+}
+
+// manifestSourceFlags say where a server manifest is read from: upstream by
+// default, or the copy 'wsm registry mirror' pushed to --registry.
+type manifestSourceFlags struct {
+	registry string
+	insecure bool
+	layout   layoutFlags
+	creds    credentialFlags
+}
+
+func (f *manifestSourceFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.registry, "registry", "", "Read the manifest from this mirror instead of upstream")
+	fs.BoolVar(&f.insecure, "insecure", false, "Skip TLS verification (or use plain HTTP) when contacting --registry")
+	f.layout.register(fs, "")
+	f.creds.register(fs, "", false)
+}
+
+// repository returns the server-manifest repository to read from, and the
+// credentials to read it with.
+func (f *manifestSourceFlags) repository() (string, registryauth.Provider, error) {
+	creds, err := f.creds.provider(strings.TrimRight(f.registry, "/"))
+	if err != nil {
+		return "", nil, err
+	}
+	if f.registry == "" {
+		return serverManifestUpstream, creds, nil
+	}
+	layout, err := f.layout.layout()
+	if err != nil {
+		return "", nil, err
+	}
+	return layout.Repository(strings.TrimRight(f.registry, "/"), serverManifestUpstream), creds, nil
+}
+
+// load pulls and parses the manifest for version.
+func (f *manifestSourceFlags) load(ctx context.Context, version string) (*serverManifest, error) {
+	repo, creds, err := f.repository()
+	if err != nil {
+		return nil, err
+	}
+	insecure := f.insecure && f.registry != ""
+	files, err := pullManifestYAMLFrom(ctx, repo, version, insecure, creds)
+	if err != nil {
+		return nil, fmt.Errorf("read server manifest %s:%s: %w", repo, version, err)
+	}
+	m, err := parseServerManifest(files)
+	if err != nil {
+		return nil, fmt.Errorf("parse server manifest %s:%s: %w", repo, version, err)
+	}
+	m.Repository, m.Version = repo, version
+	return m, nil
+}
+
+// serverManifest is the merged content of a server-manifest artifact's YAML
+// files, keyed for display and comparison.
+type serverManifest struct {
+	Repository   string
+	Version      string
+	Applications map[string]wmanifest.Application
+	Migrations   map[string]wmanifest.ImageRef
+}
+
+// applicationImages returns every image app runs, keyed by the role it plays:
+// "" for the main container, "init:<name>" and "container:<name>" for the
+// others.
+func applicationImages(app wmanifest.Application) map[string]wmanifest.ImageRef {
+	out := map[string]wmanifest.ImageRef{}
+	if app.Image.Repository != "" {
+		out[""] = app.Image
+	}
+	for _, c := range app.InitContainers {
+		if c.Image.Repository != "" {
+			out["init:"+c.Name] = c.Image
+		}
+	}
+	for _, c := range app.Containers {
+		if c.Image.Repository != "" {
+			out["container:"+c.Name] = c.Image
+		}
+	}
+	return out
+}
+
+func parseServerManifest(files map[string][]byte) (*serverManifest, error) {
+	m := &serverManifest{
+		Applications: map[string]wmanifest.Application{},
+		Migrations:   map[string]wmanifest.ImageRef{},
+	}
+	for _, name := range sortedKeys(files) {
+		var doc wmanifest.Manifest
+		if err := yaml.Unmarshal(files[name], &doc); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		for _, app := range doc.Applications {
+			m.Applications[app.Name] = app
+		}
+		for _, mig := range doc.Migrations {
+			m.Migrations[mig.Name] = mig.Image
+		}
+	}
+	return m, nil
+}
+
+// imageSet returns every distinct image reference in the manifest.
+func (m *serverManifest) imageSet() map[string]bool {
+	set := map[string]bool{}
+	for _, app := range m.Applications {
+		for _, img := range applicationImages(app) {
+			set[img.GetImage("")] = true
+		}
+	}
+	for _, img := range m.Migrations {
+		set[img.GetImage("")] = true
+	}
+	return set
+}
+
+// ---------------- wsm manifest show ----------------
+
+func manifestShowCmd() *cobra.Command {
+	var (
+		wandbVersion string
+		raw          bool
+		source       manifestSourceFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "show [version]",
+		Short: "Print the components, images and migrations of a W&B version",
+		Example: `  wsm manifest show --wandb-version 0.82.2
+  wsm manifest show 0.82.2 --registry localhost:5000 --insecure
+  wsm manifest show 0.82.2 --raw`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				if wandbVersion != "" && wandbVersion != args[0] {
+					return fmt.Errorf("version given twice: %s and --wandb-version %s", args[0], wandbVersion)
+				}
+				wandbVersion = args[0]
+			}
+			if wandbVersion == "" {
+				return fmt.Errorf("--wandb-version is required")
+			}
+			ctx := context.Background()
+
+			if raw {
+				repo, creds, err := source.repository()
+				if err != nil {
+					return err
+				}
+				files, err := pullManifestYAMLFrom(ctx, repo, wandbVersion, source.insecure && source.registry != "", creds)
+				if err != nil {
+					return fmt.Errorf("read server manifest %s:%s: %w", repo, wandbVersion, err)
+				}
+				for i, name := range sortedKeys(files) {
+					if i > 0 {
+						fmt.Println("---")
+					}
+					fmt.Printf("# %s\n%s", name, files[name])
+				}
+				return nil
+			}
+
+			m, err := source.load(ctx, wandbVersion)
+			if err != nil {
+				return err
+			}
+			printManifest(m)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "W&B server version to show (or pass it as the argument)")
+	cmd.Flags().BoolVar(&raw, "raw", false, "Print the manifest's YAML files as they are")
+	source.register(cmd.Flags())
+	return cmd
+}
+
+func printManifest(m *serverManifest) {
+	fmt.Printf("Server manifest %s:%s\n", m.Repository, m.Version)
+	fmt.Printf("  %d components, %d migrations, %d distinct images\n\n",
+		len(m.Applications), len(m.Migrations), len(m.imageSet()))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tCONTAINER\tIMAGE")
+	for _, name := range sortedKeys(m.Applications) {
+		images := applicationImages(m.Applications[name])
+		for i, role := range sortedKeys(images) {
+			component := name
+			if i > 0 {
+				component = ""
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", component, roleLabel(role), images[role].GetImage(""))
+		}
+	}
+	w.Flush()
+
+	if len(m.Migrations) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MIGRATION\tIMAGE")
+		for _, name := range sortedKeys(m.Migrations) {
+			fmt.Fprintf(w, "%s\t%s\n", name, m.Migrations[name].GetImage(""))
+		}
+		w.Flush()
+	}
+}
+
+// roleLabel is how an application image role is shown: "main" for the
+// application's own image.
+func roleLabel(role string) string {
+	if role == "" {
+		return "main"
+	}
+	return role
+}
+
+// ---------------- wsm manifest diff ----------------
+
+func manifestDiffCmd() *cobra.Command {
+	var source manifestSourceFlags
+	cmd := &cobra.Command{
+		Use:   "diff <from-version> <to-version>",
+		Short: "Show which components, images and migrations change between two W&B versions",
+		Long: `Compare the server manifests of two W&B versions: components and migrations
+added or removed, and every image whose repository, tag or digest changes.
+Useful before an upgrade, to see what the mirror will have to carry.`,
+		Example: `  wsm manifest diff 0.81.0 0.82.2
+  wsm manifest diff 0.81.0 0.82.2 --registry localhost:5000 --insecure`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			from, err := source.load(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			to, err := source.load(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			printManifestDiff(from, to)
+			return nil
+		},
+	}
+	source.register(cmd.Flags())
+	return cmd
+}
+
+func printManifestDiff(from, to *serverManifest) {
+	fmt.Printf("Server manifest %s → %s (%s)\n\n", from.Version, to.Version, from.Repository)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var changes int
+
+	fmt.Fprintln(w, "Components:")
+	before := changes
+	for _, name := range unionKeys(from.Applications, to.Applications) {
+		a, inFrom := from.Applications[name]
+		b, inTo := to.Applications[name]
+		switch {
+		case !inFrom:
+			changes++
+			fmt.Fprintf(w, "  + %s\t%s\n", name, b.Image.GetImage(""))
+		case !inTo:
+			changes++
+			fmt.Fprintf(w, "  - %s\t%s\n", name, a.Image.GetImage(""))
+		default:
+			ai, bi := applicationImages(a), applicationImages(b)
+			for _, role := range unionKeys(ai, bi) {
+				x, inA := ai[role]
+				y, inB := bi[role]
+				label := name
+				if role != "" {
+					label += " (" + role + ")"
+				}
+				switch {
+				case !inA:
+					changes++
+					fmt.Fprintf(w, "  + %s\t%s\n", label, y.GetImage(""))
+				case !inB:
+					changes++
+					fmt.Fprintf(w, "  - %s\t%s\n", label, x.GetImage(""))
+				case x != y:
+					changes++
+					fmt.Fprintf(w, "  ~ %s\t%s\n", label, describeImageChange(x, y))
+				}
+			}
+		}
+	}
+
+	if changes == before {
+		fmt.Fprintln(w, "  (unchanged)")
+	}
+
+	fmt.Fprintln(w, "\nMigrations:")
+	before = changes
+	for _, name := range unionKeys(from.Migrations, to.Migrations) {
+		x, inA := from.Migrations[name]
+		y, inB := to.Migrations[name]
+		switch {
+		case !inA:
+			changes++
+			fmt.Fprintf(w, "  + %s\t%s\n", name, y.GetImage(""))
+		case !inB:
+			changes++
+			fmt.Fprintf(w, "  - %s\t%s\n", name, x.GetImage(""))
+		case x != y:
+			changes++
+			fmt.Fprintf(w, "  ~ %s\t%s\n", name, describeImageChange(x, y))
+		}
+	}
+
+	if changes == before {
+		fmt.Fprintln(w, "  (unchanged)")
+	}
+	w.Flush()
+
+	fromImages, toImages := from.imageSet(), to.imageSet()
+	var added, removed int
+	for img := range toImages {
+		if !fromImages[img] {
+			added++
+		}
+	}
+	for img := range fromImages {
+		if !toImages[img] {
+			removed++
+		}
+	}
+	if changes == 0 {
+		fmt.Println("\nNo differences.")
+		return
+	}
+	fmt.Printf("\n%d change(s); %d image(s) new in %s, %d no longer used.\n",
+		changes, added, to.Version, removed)
+}
+
+// describeImageChange renders an image update compactly: just the tag or
+// digest when only that moved, the full references otherwise.
+func describeImageChange(a, b wmanifest.ImageRef) string {
+	if a.Repository != b.Repository {
+		return a.GetImage("") + " → " + b.GetImage("")
+	}
+	var parts []string
+	if a.Tag != b.Tag {
+		parts = append(parts, fmt.Sprintf("tag %s → %s", orNone(a.Tag), orNone(b.Tag)))
+	}
+	if a.Digest != b.Digest {
+		parts = append(parts, fmt.Sprintf("digest %s → %s", shortDigest(a.Digest), shortDigest(b.Digest)))
+	}
+	return a.Repository + ": " + strings.Join(parts, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+// shortDigest trims a digest to its algorithm and first 12 hex characters.
+func shortDigest(d string) string {
+	if d == "" {
+		return "(none)"
+	}
+	if alg, hex, ok := strings.Cut(d, ":"); ok && len(hex) > 12 {
+		return alg + ":" + hex[:12]
+	}
+	return d
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	set := map[string]bool{}
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+	return sortedKeys(set)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// ociChartsDir is where 'wsm download' stores extra OCI charts inside the
+// bundle, one OCI image layout per chart at <bundle>/oci-charts/<reference>.
+// 'wsm registry push' walks it the same way it walks <bundle>/images.
+const ociChartsDir = "oci-charts"
+
+// referenceTag returns the tag or digest oras resolves ref by, preferring the
+// digest when both are present.
+func referenceTag(ref string) (repo, tag string) {
+	repo, suffix := mirror.SplitReference(ref)
+	if _, digest, ok := strings.Cut(suffix, "@"); ok {
+		return repo, digest
+	}
+	return repo, strings.TrimPrefix(suffix, ":")
+}
+
+// downloadOCIChart copies an OCI chart (or any OCI artifact) into an OCI image
+// layout at dir, keeping its manifest byte-for-byte so the pushed copy has the
+// same digest.
+func downloadOCIChart(ctx context.Context, ref, dir string, creds registryauth.Provider) error {
+	repoRef, tag := referenceTag(ref)
+	src, err := remote.NewRepository(repoRef)
+	if err != nil {
+		return fmt.Errorf("init source repo: %w", err)
+	}
+	src.Client = registryAuthClient(false, creds)
+
+	store, err := oci.New(dir)
+	if err != nil {
+		return fmt.Errorf("init OCI layout %s: %w", dir, err)
+	}
+	if _, err := oras.Copy(ctx, src, tag, store, tag, oras.DefaultCopyOptions); err != nil {
+		return fmt.Errorf("copy %s: %w", ref, err)
+	}
+	return nil
+}
+
+// pushOCIChart copies the chart stored at dir by downloadOCIChart to target.
+func pushOCIChart(ctx context.Context, dir, source, target string, insecure bool, creds registryauth.Provider) error {
+	_, srcTag := referenceTag(source)
+	dstRepo, dstTag := referenceTag(target)
+
+	store, err := oci.New(dir)
+	if err != nil {
+		return fmt.Errorf("open OCI layout %s: %w", dir, err)
+	}
+	dst, err := remote.NewRepository(dstRepo)
+	if err != nil {
+		return fmt.Errorf("init target repo: %w", err)
+	}
+	dst.Client = registryAuthClient(insecure, creds)
+	dst.PlainHTTP = insecure && isPlainHTTPRegistry(ctx, dst)
+
+	if _, err := oras.Copy(ctx, store, srcTag, dst, dstTag, oras.DefaultCopyOptions); err != nil {
+		return fmt.Errorf("copy to mirror: %w", err)
+	}
+	return nil
+}
+
+// isPlainHTTPRegistry reports whether repo's registry only answers plain HTTP,
+// matching how the image push paths treat --insecure (plain HTTP or an
+// untrusted certificate).
+func isPlainHTTPRegistry(ctx context.Context, repo *remote.Repository) bool {
+	reg, err := remote.NewRegistry(repo.Reference.Registry)
+	if err != nil {
+		return false
+	}
+	reg.Client = repo.Client
+	if reg.Ping(ctx) == nil {
+		return false
+	}
+	reg.PlainHTTP = true
+	return reg.Ping(ctx) == nil
+}
+
+// findOCICharts returns every chart layout under dir, keyed back to its
+// original reference by its path relative to dir.
+func findOCICharts(dir string) ([]tarball, error) {
+	var out []tarball
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "oci-layout" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		ref := strings.ReplaceAll(rel, string(os.PathSeparator), "/")
+		out = append(out, tarball{path: filepath.Dir(path), source: ref})
+		return filepath.SkipDir
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return out, err
+}
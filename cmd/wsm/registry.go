@@ -3,22 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/types"
 	"github.com/spf13/cobra"
-	"github.com/wandb/wsm/pkg/deployer"
-	"github.com/wandb/wsm/pkg/helm"
+	"github.com/spf13/pflag"
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
 	"github.com/wandb/wsm/pkg/utils"
-	"gopkg.in/yaml.v3"
 )
 
-var registryKeepCache bool
-
 func init() {
 	rootCmd.AddCommand(RegistryCmd())
 }
@@ -32,63 +30,92 @@ func RegistryCmd() *cobra.Command {
     wsm registry mirror  Push every chart and image a v2 install needs to your registry.
     wsm registry check   Verify each artifact 'wsm registry mirror' pushes is present.
     wsm registry push    Push a pre-downloaded bundle to your registry.
-    wsm registry values  Emit a values.yaml fragment that points the chart at
-                         your registry instead of the public sources.`,
+    wsm registry prune   Delete old W&B versions and images only they use.
+    wsm registry serve   Run a local OCI registry to mirror into.
+    wsm registry doctor  Probe a registry and explain what an install would trip on.
+    wsm registry scan    Generate SBOMs for mirrored images and match them against
+                         an offline vulnerability database.
+    wsm registry sbom    Write one merged SBOM covering everything a W&B version mirrors.
+    wsm registry values  Emit the Helm values and CR fragment wsm uses to point
+                         each release at your registry, for GitOps installs.`,
 	}
 
-	cmd.PersistentFlags().BoolVar(
-		&registryKeepCache,
-		"keep-cache",
-		false,
-		"Don't delete ./bundle/charts after running (speeds up repeated invocations)",
-	)
+	// --keep-cache kept ./bundle/charts between 'registry values' runs, which
+	// no longer downloads any charts.
+	cmd.PersistentFlags().Bool("keep-cache", false, "No effect")
+	_ = cmd.PersistentFlags().MarkDeprecated("keep-cache", "registry values no longer downloads charts")
 	cmd.AddCommand(registryCheckCmd())
 	cmd.AddCommand(registryValuesCmd())
 	cmd.AddCommand(registryPushCmd())
 	cmd.AddCommand(registryMirrorCmd())
+	cmd.AddCommand(registryPruneCmd())
+	cmd.AddCommand(registryServeCmd())
+	cmd.AddCommand(registryDoctorCmd())
+	cmd.AddCommand(registryScanCmd())
+	cmd.AddCommand(registrySBOMCmd())
 	return cmd
 }
 
-func discoverImages() ([]string, error) {
-	operatorTag, err := getMostRecentTag("wandb/controller")
-	if err != nil {
-		return nil, fmt.Errorf("get latest operator tag: %w", err)
-	}
+// layoutFlags are the --layout / --layout-prefix / --layout-mapping flags
+// shared by every registry subcommand that computes mirror references, so
+// mirror, check, push and values always agree on where each artifact lives.
+type layoutFlags struct {
+	strategy string
+	prefix   string
+	mapping  string
+}
 
-	operatorImgs, err := downloadChartImages(
-		helm.WandbHelmRepoURL,
-		helm.WandbOperatorChart,
-		"",
-		map[string]interface{}{
-			"image": map[string]interface{}{"tag": operatorTag},
-		},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("operator images: %w", err)
-	}
+// register adds the layout flags to fs. namePrefix is prepended to each flag
+// name (deploy-v2 and cluster create use "mirror-" to sit beside --mirror-registry).
+func (f *layoutFlags) register(fs *pflag.FlagSet, namePrefix string) {
+	fs.StringVar(&f.strategy, namePrefix+"layout", mirror.LayoutPreservePath, "How upstream repositories map to paths in the mirror: "+strings.Join(mirror.LayoutNames, ", "))
+	fs.StringVar(&f.prefix, namePrefix+"layout-prefix", "", "Project every repository is placed under with --"+namePrefix+"layout=flatten, e.g. wandb-mirror")
+	fs.StringVar(&f.mapping, namePrefix+"layout-mapping", "", "YAML file of source → target repository prefix rules for --"+namePrefix+"layout=mapping")
+}
 
-	spec, err := deployer.GetChannelSpec("")
-	if err != nil {
-		return nil, fmt.Errorf("channel spec: %w", err)
-	}
-	if wt, ok := spec.Values["weave-trace"]; ok {
-		wt.(map[string]interface{})["install"] = true
-	}
-	wandbImgs, err := downloadChartImages(spec.Chart.URL, spec.Chart.Name, spec.Chart.Version, spec.Values)
-	if err != nil {
-		return nil, fmt.Errorf("wandb images: %w", err)
-	}
+func (f *layoutFlags) layout() (*mirror.Layout, error) {
+	return mirror.ParseLayout(f.strategy, f.prefix, f.mapping)
+}
+
+// extraFlags are the --extra-images / --extra-charts / --config flags naming
+// user-specified artifacts. mirror, check and download share them so the same
+// extras are copied, checked and bundled.
+type extraFlags struct {
+	images string
+	charts string
+	config string
+}
 
-	return utils.RemoveDuplicates(append(operatorImgs, wandbImgs...)), nil
+func (f *extraFlags) register(fs *pflag.FlagSet) {
+	fs.StringVar(&f.images, "extra-images", "", "File listing additional images to include, one reference per line ('#' comments allowed)")
+	fs.StringVar(&f.charts, "extra-charts", "", "File listing additional OCI Helm charts to include (oci://host/path:version), one per line")
+	fs.StringVar(&f.config, "config", "", "Mirror config file; its extra.images / extra.charts are added to --extra-images / --extra-charts")
 }
 
-func translate(image, registry string) string {
-	registry = strings.TrimRight(registry, "/")
-	parts := strings.SplitN(image, "/", 2)
-	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
-		return registry + "/" + parts[1]
+func (f *extraFlags) extras() (mirror.Extras, error) {
+	var extras mirror.Extras
+	if f.config != "" {
+		cfg, err := mirror.LoadConfig(f.config)
+		if err != nil {
+			return extras, err
+		}
+		extras.Merge(cfg.Extra)
 	}
-	return registry + "/" + image
+	if f.images != "" {
+		images, err := mirror.LoadList(f.images)
+		if err != nil {
+			return extras, err
+		}
+		extras.Merge(mirror.Extras{Images: images})
+	}
+	if f.charts != "" {
+		charts, err := mirror.LoadList(f.charts)
+		if err != nil {
+			return extras, err
+		}
+		extras.Merge(mirror.Extras{Charts: charts})
+	}
+	return extras, extras.Normalize()
 }
 
 // ---------------- wsm registry check ----------------
@@ -101,6 +128,10 @@ func registryCheckCmd() *cobra.Command {
 		operatorChartVersion string
 		wandbVersion         string
 		skipManaged          bool
+		observabilityMode    string
+		layoutOpts           layoutFlags
+		credOpts             credentialFlags
+		extraOpts            extraFlags
 	)
 
 	cmd := &cobra.Command{
@@ -113,13 +144,20 @@ func registryCheckCmd() *cobra.Command {
   application image it references — and does a manifest check for each against
   --registry.
 
-  Pass the SAME --operator-chart-version / --wandb-version / --skip-managed-images
-  you mirrored with, so check and mirror agree on the expected set.
+  Pass the SAME --operator-chart-version / --wandb-version / --skip-managed-images,
+  --observability-mode, --layout and --extra-images / --extra-charts / --config
+  flags you mirrored with, so check and mirror agree on the expected set.
 
-  The server manifest and its application images are read back out of the mirror
-  itself, so this works from an air-gapped host with access only to the registry.
+  The charts are read back out of the mirror and rendered with the values
+  'wsm deploy-v2 --mirror-registry' installs them with, so the chart images
+  checked are the ones the install pulls; any the rendered charts still
+  reference upstream are listed as a warning. The server manifest and its
+  application images are read back out of the mirror too, so this works from an
+  air-gapped host with access only to the registry.
 
-  Auth is read from your Docker config (~/.docker/config.json) by default.
+  Auth comes from --registry-username/--registry-password-stdin, then the
+  WSM_REGISTRY_USERNAME/WSM_REGISTRY_PASSWORD environment variables, then your
+  Docker config (~/.docker/config.json, including credential helpers).
   Use --insecure for self-signed registries.`,
 		Example: `  wsm registry check --registry myreg.example.com --wandb-version 0.81.0
     wsm registry check --registry myreg.example.com --insecure
@@ -129,52 +167,34 @@ func registryCheckCmd() *cobra.Command {
 				return fmt.Errorf("--registry is required")
 			}
 			registry = strings.TrimRight(registry, "/")
-			ctx := context.Background()
-
-			// Build the same destination set 'wsm registry mirror' pushes, so
-			// check and mirror always agree. (The old path discovered a different,
-			// v1-derived image set under different names, so it reported every
-			// freshly-mirrored image as "missing".)
-			var targets []string
-			for _, it := range buildMirrorPlan(registry, operatorChartVersion) {
-				targets = append(targets, it.dst)
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
 			}
-			if !skipManaged {
-				for _, it := range buildManagedImagePlan(registry) {
-					targets = append(targets, it.dst)
-				}
+			creds, err := credOpts.provider(registry)
+			if err != nil {
+				return err
 			}
-
-			// The application images are listed inside the mirrored server
-			// manifest. Read it back FROM THE MIRROR (refs already rewritten to
-			// point at the registry) so we validate exactly what the operator
-			// will pull, using only registry access.
-			var manifestWarn string
-			if wandbVersion != "" {
-				manifestRepo := registry + "/wandb/server-manifest"
-				targets = append(targets, manifestRepo+":"+wandbVersion)
-
-				files, err := pullManifestYAMLFrom(ctx, manifestRepo, wandbVersion, insecure)
-				if err != nil {
-					manifestWarn = fmt.Sprintf("could not read server manifest %s:%s — application images not checked (%v)", manifestRepo, wandbVersion, err)
-				} else if refs, err := collectManifestImages(files); err != nil {
-					manifestWarn = fmt.Sprintf("could not parse server manifest %s:%s — application images not checked (%v)", manifestRepo, wandbVersion, err)
-				} else {
-					for _, r := range refs {
-						targets = append(targets, r.GetImage(""))
-					}
-				}
+			extras, err := extraOpts.extras()
+			if err != nil {
+				return err
 			}
+			ctx := context.Background()
 
-			targets = utils.RemoveDuplicates(targets)
-			sort.Strings(targets)
+			telemetry, err := stackTelemetry(observabilityMode)
+			if err != nil {
+				return err
+			}
+			stack := stackOptions{operatorChartVersion: operatorChartVersion, telemetry: telemetry, skipManaged: skipManaged}
+			set := mirrorTargets(ctx, registry, wandbVersion, stack, extras, layout, insecure, creds)
+			targets := set.refs
 
 			fmt.Printf("Checking %d artifacts against %s\n\n", len(targets), registry)
 			fmt.Printf("%-12s  %s\n", "STATUS", "REFERENCE")
 
 			var present, missing, unauth, errs int
 			for _, tgt := range targets {
-				status, msg := checkOne(ctx, tgt, insecure)
+				status, msg := checkOne(ctx, tgt, insecure, creds)
 				switch status {
 				case "present":
 					present++
@@ -193,8 +213,17 @@ func registryCheckCmd() *cobra.Command {
 
 			fmt.Printf("\n%d total — %d present, %d missing, %d auth issues, %d errors\n",
 				len(targets), present, missing, unauth, errs)
-			if manifestWarn != "" {
-				fmt.Printf("⚠ %s\n", manifestWarn)
+			if set.chartErr != nil {
+				fmt.Printf("⚠ %v — chart images not checked\n", set.chartErr)
+			}
+			if set.manifestErr != nil {
+				fmt.Printf("⚠ %v — application images not checked\n", set.manifestErr)
+			}
+			if len(set.upstream) > 0 {
+				fmt.Printf("⚠ %d image(s) stay upstream with the install's mirror values; the install pulls them from there unless the nodes' registry mirrors redirect them:\n", len(set.upstream))
+				for _, ref := range set.upstream {
+					fmt.Printf("    %s\n", ref)
+				}
 			}
 			if wandbVersion == "" {
 				fmt.Println("Note: pass --wandb-version to also check the server manifest and W&B application images.")
@@ -210,19 +239,133 @@ func registryCheckCmd() *cobra.Command {
 	cmd.Flags().StringVar(&registry, "registry", "", "Target registry to check against, e.g. myreg.example.com (required)")
 	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when contacting the registry")
 	cmd.Flags().BoolVar(&failOnMissing, "fail-on-missing", false, "Exit non-zero if any artifact is missing")
-	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", "2.0.0-beta.1", "Operator chart version that was mirrored (must match 'wsm registry mirror')")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version that was mirrored (must match 'wsm registry mirror')")
 	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "W&B server version that was mirrored; when set, also check the server manifest and every application image it references")
 	cmd.Flags().BoolVar(&skipManaged, "skip-managed-images", false, "Don't check the managed-service operator + data-plane images (match the flag you mirrored with)")
+	cmd.Flags().StringVar(&observabilityMode, "observability-mode", operator.TelemetryModeOff, "Telemetry mode the operator is deployed with (match the flag you mirrored with)")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", false)
+	extraOpts.register(cmd.Flags())
 	return cmd
 }
 
-func checkOne(ctx context.Context, image string, insecure bool) (status, errMsg string) {
+// mirrorTargetSet is what mirrorTargets expects in a mirror.
+type mirrorTargetSet struct {
+	refs []string // sorted and de-duplicated
+	// upstream lists images the charts still reference upstream once rendered
+	// with the install's mirror values: the install pulls them from there
+	// unless the nodes' registry mirrors redirect them.
+	upstream []string
+	// chartErr and manifestErr are set when the charts or the server manifest
+	// could not be read from the mirror; the images they reference are missing
+	// from refs, the rest of the set is complete.
+	chartErr, manifestErr error
+}
+
+// mirrorTargets returns the destination references 'wsm registry mirror'
+// pushes for these flags, so every command that inspects a mirror agrees on
+// the set. (An older check discovered a different, v1-derived image set under
+// different names, so it reported every freshly-mirrored image as "missing".)
+//
+// The charts are read back out of the mirror and rendered with the values
+// deploy-v2 --mirror-registry installs them with, so the chart images are
+// exactly what the install will pull. With wandbVersion, the application
+// images are likewise read out of the server manifest stored IN THE MIRROR
+// (refs already rewritten to point at the registry). Both need only registry
+// access.
+func mirrorTargets(
+	ctx context.Context,
+	registry, wandbVersion string,
+	stack stackOptions,
+	extras mirror.Extras,
+	layout *mirror.Layout,
+	insecure bool,
+	creds registryauth.Provider,
+) mirrorTargetSet {
+	var set mirrorTargetSet
+	var targets []string
+	for _, chart := range operator.StackCharts(stack.operatorChartVersion) {
+		targets = append(targets, layout.Image(registry, chart))
+	}
+	target := &operator.MirrorConfig{Host: registry, Insecure: insecure, Layout: layout, Credentials: creds}
+	images, err := operator.StackImages(ctx, stack.options(target))
+	if err != nil {
+		set.chartErr = fmt.Errorf("could not render the charts from %s (%w)", registry, err)
+	}
+	for _, img := range images {
+		if stack.skipManaged && img.Managed() {
+			continue
+		}
+		if strings.HasPrefix(img.Reference, registry+"/") {
+			targets = append(targets, img.Reference)
+			continue
+		}
+		targets = append(targets, layout.Image(registry, img.Reference))
+		if img.Rendered {
+			set.upstream = append(set.upstream, img.Reference)
+		}
+	}
+	if !stack.skipManaged {
+		for _, it := range planFor(dataPlaneImageSources(), registry, layout) {
+			targets = append(targets, it.dst)
+		}
+	}
+	targets = append(targets, layout.Image(registry, kind.DefaultNodeImage))
+	for _, it := range planFor(extras.Sources(), registry, layout) {
+		targets = append(targets, it.dst)
+	}
+	targets = append(targets, gatewayAPICRDItem(registry, mirrorSource{}, layout).dst)
+
+	if wandbVersion != "" {
+		manifestRepo := layout.Repository(registry, serverManifestUpstream)
+		targets = append(targets, manifestRepo+":"+wandbVersion)
+
+		files, err := pullManifestYAMLFrom(ctx, manifestRepo, wandbVersion, insecure, creds)
+		if err != nil {
+			set.manifestErr = fmt.Errorf("could not read server manifest %s:%s (%w)", manifestRepo, wandbVersion, err)
+		} else if refs, err := collectManifestImages(files); err != nil {
+			set.manifestErr = fmt.Errorf("could not parse server manifest %s:%s (%w)", manifestRepo, wandbVersion, err)
+		} else {
+			for _, r := range refs {
+				targets = append(targets, r.GetImage(""))
+			}
+		}
+	}
+
+	targets = utils.RemoveDuplicates(targets)
+	sort.Strings(targets)
+	set.refs = targets
+	set.upstream = utils.RemoveDuplicates(set.upstream)
+	sort.Strings(set.upstream)
+	return set
+}
+
+// stackTelemetry is the telemetry config the operator chart's images are
+// listed with. Forward mode needs an endpoint to render, but no image depends
+// on it, so a placeholder stands in.
+func stackTelemetry(mode string) (operator.TelemetryConfig, error) {
+	if err := validateObservabilityMode(mode); err != nil {
+		return operator.TelemetryConfig{}, err
+	}
+	telemetry := operator.TelemetryConfig{Mode: mode}
+	if mode == operator.TelemetryModeForward {
+		telemetry.ForwardEndpoint = "otlp.invalid:4317"
+	}
+	return telemetry, nil
+}
+
+func checkOne(ctx context.Context, image string, insecure bool, creds registryauth.Provider) (status, errMsg string) {
 	sysCtx := &types.SystemContext{}
 	if insecure {
 		sysCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
 	}
+	sysCtx, err := registryauth.SystemContext(ctx, sysCtx, creds, registryauth.Host(image))
+	if err != nil {
+		return "error", err.Error()
+	}
 
-	ref, err := docker.ParseReference("//" + image)
+	byDigest, _ := splitPinned(image)
+	ref, err := docker.ParseReference("//" + byDigest)
 	if err != nil {
 		return "error", err.Error()
 	}
@@ -252,74 +395,3 @@ func classify(err error) (status, errMsg string) {
 	}
 	return "error", msg
 }
-
-// ---------------- wsm registry values ----------------
-
-func registryValuesCmd() *cobra.Command {
-	var (
-		registry string
-		outFile  string
-	)
-
-	cmd := &cobra.Command{
-		Use:   "values",
-		Short: "Emit a values.yaml fragment that re-points images at your registry",
-		Long: `Generate a values.yaml fragment that overrides each image reference to use
-  your mirrored registry instead of the upstream source. Pipe to a file or use
-  --output to write directly.`,
-		Example: `  wsm registry values --registry myreg.example.com
-    wsm registry values --registry myreg.example.com -o overrides.yaml`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			if !registryKeepCache {
-				defer cleanupChartCache()
-			}
-			if registry == "" {
-				return fmt.Errorf("--registry is required")
-			}
-
-			fmt.Fprintln(os.Stderr, "Discovering required images…")
-			images, err := discoverImages()
-			if err != nil {
-				return err
-			}
-
-			overrides := make([]map[string]string, 0, len(images))
-			for _, src := range images {
-				overrides = append(overrides, map[string]string{
-					"source": src,
-					"target": translate(src, registry),
-				})
-			}
-
-			doc := map[string]interface{}{
-				"global": map[string]interface{}{
-					"imageRegistry": strings.TrimRight(registry, "/"),
-				},
-				"_imageOverrides": overrides,
-			}
-			y, err := yaml.Marshal(doc)
-			if err != nil {
-				return err
-			}
-
-			header := fmt.Sprintf("# Generated by wsm registry values\n# Registry: %s\n# Date: %s\n\n",
-				registry, time.Now().UTC().Format(time.RFC3339))
-			content := append([]byte(header), y...)
-
-			if outFile == "" || outFile == "-" {
-				_, err = os.Stdout.Write(content)
-				return err
-			}
-			return os.WriteFile(outFile, content, 0644)
-		},
-	}
-
-	cmd.Flags().StringVar(&registry, "registry", "", "Target registry, e.g. myreg.example.com (required)")
-	cmd.Flags().StringVarP(&outFile, "output", "o", "-", "Output path (default: stdout)")
-	return cmd
-}
-
-func cleanupChartCache() {
-	_ = os.RemoveAll("bundle/charts")
-	_ = os.Remove("bundle")
-}
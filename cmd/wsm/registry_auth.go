@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/wandb/wsm/pkg/registryauth"
+)
+
+// Environment variables read for registry credentials when the flags are not
+// given. WSM_SOURCE_REGISTRY_HOST names the registry the source pair is for.
+const (
+	registryEnvPrefix       = "WSM_REGISTRY_"
+	sourceRegistryEnvPrefix = "WSM_SOURCE_REGISTRY_"
+)
+
+// credentialFlags are the --registry-username / --registry-password-stdin
+// flags (and, for commands that pull from an upstream, their --source-*
+// counterparts) shared by every command that talks to a registry. They resolve
+// into one registryauth.Provider: explicit flags first, then WSM_REGISTRY_* /
+// WSM_SOURCE_REGISTRY_* environment variables, then the Docker config and its
+// credential helpers.
+type credentialFlags struct {
+	namePrefix          string
+	username            string
+	passwordStdin       bool
+	sourceHost          string
+	sourceUsername      string
+	sourcePasswordStdin bool
+}
+
+// register adds the destination credential flags to fs, and the source ones
+// when withSource is set. namePrefix is prepended to each flag name (deploy-v2
+// uses "mirror-" to sit beside --mirror-registry).
+func (f *credentialFlags) register(fs *pflag.FlagSet, namePrefix string, withSource bool) {
+	f.namePrefix = namePrefix
+	fs.StringVar(&f.username, namePrefix+"registry-username", "", "Username for the registry (default: $"+registryEnvPrefix+"USERNAME, then your Docker config)")
+	fs.BoolVar(&f.passwordStdin, namePrefix+"registry-password-stdin", false, "Read the registry password or token from stdin (default: $"+registryEnvPrefix+"PASSWORD)")
+	if !withSource {
+		return
+	}
+	fs.StringVar(&f.sourceHost, "source-registry-host", "", "Upstream registry the --source-registry-* credentials are for, e.g. us-docker.pkg.dev")
+	fs.StringVar(&f.sourceUsername, "source-registry-username", "", "Username for --source-registry-host (default: $"+sourceRegistryEnvPrefix+"USERNAME)")
+	fs.BoolVar(&f.sourcePasswordStdin, "source-registry-password-stdin", false, "Read the --source-registry-host password or token from stdin (default: $"+sourceRegistryEnvPrefix+"PASSWORD)")
+}
+
+// provider returns the credential provider for a command whose destination
+// registry is host.
+func (f *credentialFlags) provider(host string) (registryauth.Provider, error) {
+	if f.passwordStdin && f.sourcePasswordStdin {
+		return nil, fmt.Errorf("only one of --%sregistry-password-stdin and --source-registry-password-stdin can read stdin", f.namePrefix)
+	}
+
+	chain := registryauth.Chain{}
+	if f.username != "" || f.passwordStdin {
+		password, err := resolvePassword(f.passwordStdin, registryEnvPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if f.username == "" || password == "" {
+			return nil, fmt.Errorf("--%sregistry-username needs a password: pass --%sregistry-password-stdin or set %sPASSWORD", f.namePrefix, f.namePrefix, registryEnvPrefix)
+		}
+		chain = append(chain, registryauth.Static{Host: host, Username: f.username, Password: password})
+	}
+	if f.sourceUsername != "" || f.sourcePasswordStdin {
+		sourceHost := f.sourceHost
+		if sourceHost == "" {
+			sourceHost = os.Getenv(sourceRegistryEnvPrefix + "HOST")
+		}
+		if sourceHost == "" {
+			return nil, fmt.Errorf("--source-registry-username needs --source-registry-host (or %sHOST)", sourceRegistryEnvPrefix)
+		}
+		password, err := resolvePassword(f.sourcePasswordStdin, sourceRegistryEnvPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if f.sourceUsername == "" || password == "" {
+			return nil, fmt.Errorf("--source-registry-username needs a password: pass --source-registry-password-stdin or set %sPASSWORD", sourceRegistryEnvPrefix)
+		}
+		chain = append(chain, registryauth.Static{Host: sourceHost, Username: f.sourceUsername, Password: password})
+	}
+	chain = append(chain,
+		registryauth.FromEnv(registryEnvPrefix, host),
+		registryauth.FromEnv(sourceRegistryEnvPrefix, f.sourceHost),
+		registryauth.Default(),
+	)
+	return chain, nil
+}
+
+// resolvePassword reads the password from stdin (trimming the trailing
+// newline, like `docker login --password-stdin`) or from <envPrefix>PASSWORD.
+func resolvePassword(fromStdin bool, envPrefix string) (string, error) {
+	if !fromStdin {
+		return os.Getenv(envPrefix + "PASSWORD"), nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("read password from stdin: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
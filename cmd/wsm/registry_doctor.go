@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// doctorScratchUpstream is the repository the write probes use, placed by
+// --layout like every other artifact so it lands in the same project/namespace
+// the mirrored images do.
+const doctorScratchUpstream = "wandb/wsm-doctor"
+
+// doctorArtifactType marks the probe artifacts, so they're recognisable if a
+// run is interrupted before cleanup.
+const doctorArtifactType = "application/vnd.wandb.wsm-doctor"
+
+// doctorResult is the outcome of one check.
+type doctorResult struct {
+	name   string
+	status string // ok, warn, fail, skip
+	detail string
+	fix    string
+}
+
+// registryDoctor runs the checks in order, carrying what earlier checks
+// learned (plain HTTP or HTTPS, the TLS config to use) into later ones.
+type registryDoctor struct {
+	host      string // hostname[:port] as given
+	hostname  string
+	port      string
+	caFile    string
+	insecure  bool
+	creds     registryauth.Provider
+	scratch   string
+	plainHTTP bool
+	tlsConfig *tls.Config
+	results   []doctorResult
+}
+
+func registryDoctorCmd() *cobra.Command {
+	var (
+		registry   string
+		caFile     string
+		insecure   bool
+		scratch    string
+		skipWrite  bool
+		layoutOpts layoutFlags
+		credOpts   credentialFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Probe a registry for everything a --mirror-registry install needs",
+		Long: `Check a registry step by step and say how to fix what's wrong, before an
+install fails deep inside Helm or as ImagePullBackOff:
+
+    DNS         the hostname resolves
+    connect     the port accepts TCP connections
+    TLS         HTTPS with a certificate trusted by the system or --registry-ca-file
+                (plain HTTP is reported, since the operator can't use it)
+    API         /v2/ answers like an OCI distribution registry
+    auth        credentials (flags, WSM_REGISTRY_*, Docker config) are accepted
+    push        a small OCI artifact with an artifactType, like the server
+                manifest 'wsm registry mirror' pushes, can be written
+    pull        it reads back byte-for-byte
+    referrers   the OCI referrers API is served
+    delete      manifests can be deleted (needed by 'wsm registry prune')
+
+The write checks use a scratch repository (wandb/wsm-doctor, placed by --layout)
+and delete what they push, including the sha256-<digest> tag index clients
+write in place of the referrers API. Anything that can't be deleted is listed
+in the delete check. Use --skip-write with a read-only account.
+
+Exits non-zero when any check fails.`,
+		Example: `  wsm registry doctor --registry harbor.mycorp.internal --registry-ca-file ./corp-ca.pem
+
+  # Against 'wsm registry serve' on this machine.
+  wsm registry doctor --registry localhost:5000`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if registry == "" {
+				return fmt.Errorf("--registry is required")
+			}
+			host := strings.TrimRight(registry, "/")
+			host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(host)
+			if err != nil {
+				return err
+			}
+			if scratch == "" {
+				scratch = layout.Repository(host, doctorScratchUpstream)
+			}
+
+			d := &registryDoctor{host: host, caFile: caFile, insecure: insecure, creds: creds, scratch: scratch}
+			d.hostname, d.port = splitRegistryHost(host)
+			d.run(context.Background(), skipWrite)
+
+			failed := d.print()
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&registry, "registry", "", "Registry to probe, e.g. harbor.mycorp.internal[:port] (required)")
+	cmd.Flags().StringVar(&caFile, "registry-ca-file", "", "PEM CA bundle to trust in addition to the system roots (as passed to deploy-v2)")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Continue past TLS verification failures, as the registry commands do with --insecure")
+	cmd.Flags().StringVar(&scratch, "scratch-repository", "", "Repository for the write probes (default: wandb/wsm-doctor placed by --layout)")
+	cmd.Flags().BoolVar(&skipWrite, "skip-write", false, "Skip the push/pull/referrers/delete probes")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", false)
+	return cmd
+}
+
+// splitRegistryHost splits host[:port], defaulting the port to 443.
+func splitRegistryHost(host string) (hostname, port string) {
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		return h, p
+	}
+	return host, "443"
+}
+
+func (d *registryDoctor) add(name, status, detail, fix string) {
+	d.results = append(d.results, doctorResult{name: name, status: status, detail: detail, fix: fix})
+}
+
+// run executes the checks, stopping at the first one later checks depend on.
+func (d *registryDoctor) run(ctx context.Context, skipWrite bool) {
+	if !d.checkDNS(ctx) || !d.checkConnect() || !d.checkTLS() {
+		return
+	}
+	anonymous, ok := d.checkAPI()
+	if !ok || !d.checkAuth(ctx, anonymous) {
+		return
+	}
+	if skipWrite {
+		d.add("push", "skip", "--skip-write", "")
+		return
+	}
+	d.checkArtifact(ctx)
+}
+
+func (d *registryDoctor) checkDNS(ctx context.Context) bool {
+	if net.ParseIP(d.hostname) != nil {
+		d.add("DNS", "skip", d.hostname+" is an IP address", "")
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.hostname)
+	if err != nil {
+		d.add("DNS", "fail", err.Error(),
+			fmt.Sprintf("Check the spelling of %s and your DNS or /etc/hosts. Cluster nodes resolve it separately, so check from a node too.", d.hostname))
+		return false
+	}
+	d.add("DNS", "ok", d.hostname+" → "+strings.Join(addrs, ", "), "")
+	return true
+}
+
+func (d *registryDoctor) checkConnect() bool {
+	addr := net.JoinHostPort(d.hostname, d.port)
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		d.add("connect", "fail", err.Error(),
+			fmt.Sprintf("Nothing accepts connections on %s. Check the port, that the registry is running, and any firewall or proxy in between.", addr))
+		return false
+	}
+	conn.Close()
+	d.add("connect", "ok", addr, "")
+	return true
+}
+
+func (d *registryDoctor) checkTLS() bool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if d.caFile != "" {
+		pem, err := os.ReadFile(d.caFile)
+		if err != nil {
+			d.add("TLS", "fail", err.Error(), "Point --registry-ca-file at a readable PEM file.")
+			return false
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			d.add("TLS", "fail", d.caFile+" holds no PEM certificates", "Pass the CA certificate in PEM form (-----BEGIN CERTIFICATE-----).")
+			return false
+		}
+	}
+	d.tlsConfig = &tls.Config{ServerName: d.hostname, RootCAs: pool}
+
+	addr := net.JoinHostPort(d.hostname, d.port)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, d.tlsConfig)
+	if err == nil {
+		state := conn.ConnectionState()
+		conn.Close()
+		leaf := state.PeerCertificates[0]
+		detail := fmt.Sprintf("%s, issued by %q, valid until %s", tls.VersionName(state.Version), leaf.Issuer.CommonName, leaf.NotAfter.Format("2006-01-02"))
+		if time.Until(leaf.NotAfter) < 30*24*time.Hour {
+			d.add("TLS", "warn", detail, "The certificate expires within 30 days; renew it before pulls start failing.")
+		} else {
+			d.add("TLS", "ok", detail, "")
+		}
+		return true
+	}
+
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		d.plainHTTP = true
+		d.tlsConfig = nil
+		d.add("TLS", "warn", "the registry speaks plain HTTP",
+			"Pushes work with --insecure and kind nodes can pull with --insecure-registry-host, but the operator fetches the oci:// server manifest over HTTPS only. Serve HTTPS (e.g. 'wsm registry serve --tls') for a full --mirror-registry install.")
+		return true
+	}
+
+	var unknownCA x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var detail, fix string
+	switch {
+	case errors.As(err, &unknownCA):
+		detail = "certificate signed by an unknown authority"
+		fix = "Pass the registry's CA with --registry-ca-file here and to 'deploy-v2 --registry-ca-file', so Helm and the cluster trust it too."
+	case errors.As(err, &hostErr):
+		detail = err.Error()
+		fix = fmt.Sprintf("Reissue the certificate with a subjectAltName for %s, or use a name it already covers.", d.hostname)
+	case errors.As(err, &invalid):
+		detail = err.Error()
+		fix = "Renew the registry certificate (or fix this machine's clock)."
+	default:
+		d.add("TLS", "fail", err.Error(), "The TLS handshake failed; check that the port serves a registry and not another service.")
+		return false
+	}
+	if d.insecure {
+		d.tlsConfig.InsecureSkipVerify = true //nolint:gosec // user explicitly opted into --insecure
+		d.add("TLS", "warn", detail+" (continuing: --insecure)", fix)
+		return true
+	}
+	d.add("TLS", "fail", detail, fix+" Or rerun with --insecure to continue past this check.")
+	return false
+}
+
+func (d *registryDoctor) scheme() string {
+	if d.plainHTTP {
+		return "http"
+	}
+	return "https"
+}
+
+func (d *registryDoctor) httpClient() *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = d.tlsConfig
+	return &http.Client{Transport: t, Timeout: 30 * time.Second}
+}
+
+func (d *registryDoctor) authClient() *auth.Client {
+	return &auth.Client{
+		Client:     d.httpClient(),
+		Cache:      auth.NewCache(),
+		Credential: registryauth.CredentialFunc(d.creds),
+	}
+}
+
+// checkAPI probes /v2/ anonymously. anonymous reports whether the registry
+// answered 200 without credentials.
+func (d *registryDoctor) checkAPI() (anonymous, ok bool) {
+	url := fmt.Sprintf("%s://%s/v2/", d.scheme(), d.host)
+	resp, err := d.httpClient().Get(url)
+	if err != nil {
+		d.add("API", "fail", err.Error(), "The registry accepted a connection but not an HTTP request; check for a proxy or load balancer in between.")
+		return false, false
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		d.add("API", "ok", "GET "+url+" → 200 (anonymous access allowed)", "")
+		return true, true
+	case http.StatusUnauthorized:
+		challenge := resp.Header.Get("WWW-Authenticate")
+		scheme, _, _ := strings.Cut(challenge, " ")
+		d.add("API", "ok", fmt.Sprintf("GET %s → 401 (%s auth)", url, scheme), "")
+		return false, true
+	case http.StatusNotFound:
+		d.add("API", "fail", "GET "+url+" → 404",
+			"No OCI registry API at /v2/. Registries must be served at the host root; a path prefix (host/registry) isn't supported by containerd or Helm.")
+	default:
+		d.add("API", "fail", fmt.Sprintf("GET %s → %d", url, resp.StatusCode), "The endpoint doesn't behave like an OCI distribution registry.")
+	}
+	return false, false
+}
+
+func (d *registryDoctor) checkAuth(ctx context.Context, anonymous bool) bool {
+	cred, err := d.creds.Credential(ctx, d.host)
+	if err != nil {
+		d.add("auth", "fail", err.Error(), "Fix the Docker credential helper, or pass --registry-username/--registry-password-stdin.")
+		return false
+	}
+	if cred.Empty() {
+		if anonymous {
+			d.add("auth", "ok", "no credentials configured; anonymous access", "")
+			return true
+		}
+		d.add("auth", "fail", "the registry requires credentials and none are configured",
+			fmt.Sprintf("Pass --registry-username/--registry-password-stdin, set %sUSERNAME/PASSWORD, or 'docker login %s'.", registryEnvPrefix, d.host))
+		return false
+	}
+
+	reg, err := remote.NewRegistry(d.host)
+	if err != nil {
+		d.add("auth", "fail", err.Error(), "")
+		return false
+	}
+	reg.Client = d.authClient()
+	reg.PlainHTTP = d.plainHTTP
+	if err := reg.Ping(ctx); err != nil {
+		d.add("auth", "fail", "credentials for "+cred.Username+" were rejected: "+err.Error(),
+			"Check the username and password (or token). Robot/service accounts often need the full name, e.g. robot$project+name on Harbor.")
+		return false
+	}
+	who := cred.Username
+	if who == "" {
+		who = "identity token"
+	}
+	d.add("auth", "ok", "authenticated as "+who, "")
+	return true
+}
+
+// checkArtifact pushes a small artifact shaped like the server manifest,
+// reads it back, attaches a referrer, and deletes both.
+func (d *registryDoctor) checkArtifact(ctx context.Context) {
+	repo, err := remote.NewRepository(d.scratch)
+	if err != nil {
+		d.add("push", "fail", err.Error(), "Pass a valid --scratch-repository.")
+		return
+	}
+	repo.Client = d.authClient()
+	repo.PlainHTTP = d.plainHTTP
+
+	tag := fmt.Sprintf("probe-%d", time.Now().Unix())
+	layerData := []byte("wsm registry doctor probe\n")
+	layer := content.NewDescriptorFromBytes(ocispec.MediaTypeImageLayer, layerData)
+	var probe ocispec.Descriptor
+	err = func() error {
+		if err := repo.Push(ctx, layer, bytes.NewReader(layerData)); err != nil {
+			return err
+		}
+		desc, err := oras.PackManifest(ctx, repo, oras.PackManifestVersion1_1, doctorArtifactType, oras.PackManifestOptions{
+			Layers: []ocispec.Descriptor{layer},
+		})
+		if err != nil {
+			return err
+		}
+		probe = desc
+		return repo.Tag(ctx, desc, tag)
+	}()
+	if err != nil {
+		d.add("push", "fail", err.Error(), pushFix(err, d.scratch))
+		return
+	}
+	d.add("push", "ok", fmt.Sprintf("%s:%s (OCI artifact, artifactType %s)", d.scratch, tag, doctorArtifactType), "")
+
+	// Cleanup runs whatever happens below. Without the referrers API, oras
+	// records the referrer in a sha256-<digest> tag index next to the probe;
+	// deleting the referrer through oras drops that index again, and anything
+	// that survives is reported so it can be removed by hand.
+	var referrer *ocispec.Descriptor
+	fallback := false
+	defer func() {
+		var leftover []string
+		if referrer != nil {
+			if err := repo.Delete(ctx, *referrer); err != nil {
+				leftover = append(leftover, d.scratch+"@"+referrer.Digest.String())
+			}
+		}
+		if fallback {
+			indexTag := strings.Replace(probe.Digest.String(), ":", "-", 1)
+			if index, err := repo.Resolve(ctx, indexTag); err == nil {
+				if err := repo.Delete(ctx, index); err != nil {
+					leftover = append(leftover, d.scratch+":"+indexTag+" (referrers tag index)")
+				}
+			}
+		}
+		err := repo.Delete(ctx, probe)
+		if err != nil {
+			leftover = append(leftover, d.scratch+":"+tag)
+		}
+		left := "; left " + strings.Join(leftover, ", ") + " behind"
+		switch {
+		case len(leftover) == 0:
+			d.add("delete", "ok", "probe artifacts removed", "")
+		case err != nil && (isStatus(err, http.StatusMethodNotAllowed, http.StatusBadRequest) || isCode(err, "UNSUPPORTED")):
+			d.add("delete", "warn", "manifest deletes are disabled"+left,
+				"'wsm registry prune' needs deletes. On registry:2 set REGISTRY_STORAGE_DELETE_ENABLED=true; elsewhere, grant the account delete rights.")
+		case err != nil:
+			d.add("delete", "warn", err.Error()+left,
+				"Grant the account delete rights if you plan to use 'wsm registry prune'.")
+		default:
+			d.add("delete", "warn", "could not delete "+strings.Join(leftover, ", "), "Delete the leftover probe manifests by hand.")
+		}
+	}()
+
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		d.add("pull", "fail", fmt.Sprintf("resolve %s:%s: %v", d.scratch, tag, err), pullFix(err))
+		return
+	}
+	manifest, err := content.FetchAll(ctx, repo, desc)
+	if err != nil {
+		d.add("pull", "fail", fmt.Sprintf("fetch manifest %s: %v", desc.Digest, err), pullFix(err))
+		return
+	}
+	if got := digest.FromBytes(manifest); got != probe.Digest {
+		d.add("pull", "fail", fmt.Sprintf("manifest read back as %s, pushed %s", got, probe.Digest),
+			"The registry rewrote the artifact manifest. The operator verifies the server manifest by digest, so use a registry that stores OCI manifests as-is.")
+		return
+	}
+	blob, err := content.FetchAll(ctx, repo, layer)
+	if err != nil {
+		d.add("pull", "fail", fmt.Sprintf("fetch layer %s: %v", layer.Digest, err), pullFix(err))
+		return
+	}
+	if !bytes.Equal(blob, layerData) {
+		d.add("pull", "fail", fmt.Sprintf("layer %s read back with different content", layer.Digest), "Check the registry's storage backend.")
+		return
+	}
+	d.add("pull", "ok", "manifest and layer read back unchanged", "")
+
+	desc, err = oras.PackManifest(ctx, repo, oras.PackManifestVersion1_1, doctorArtifactType+".referrer", oras.PackManifestOptions{
+		Subject: &probe,
+	})
+	if err != nil {
+		d.add("referrers", "fail", err.Error(), "The registry rejects manifests with a subject; upgrade it to an OCI 1.1 compliant version.")
+		return
+	}
+	referrer = &desc
+	fallback = !d.checkReferrersAPI(ctx, repo, probe)
+}
+
+// checkReferrersAPI asks the registry's referrers endpoint directly, since
+// oras quietly falls back to the tag schema when it is missing. It reports
+// whether the API is served.
+func (d *registryDoctor) checkReferrersAPI(ctx context.Context, repo *remote.Repository, subject ocispec.Descriptor) bool {
+	url := fmt.Sprintf("%s://%s/v2/%s/referrers/%s", d.scheme(), repo.Reference.Registry, repo.Reference.Repository, subject.Digest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		d.add("referrers", "fail", err.Error(), "")
+		return false
+	}
+	resp, err := repo.Client.Do(req)
+	if err != nil {
+		d.add("referrers", "fail", err.Error(), "")
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		d.add("referrers", "ok", "OCI referrers API supported", "")
+		return true
+	}
+	d.add("referrers", "warn", fmt.Sprintf("referrers API answered %d; clients fall back to the sha256-<digest> tag schema", resp.StatusCode),
+		"Artifacts still push and pull, but signatures and SBOMs attached to mirrored images are slower to discover. Upgrade to a registry with OCI 1.1 referrers support if you rely on them.")
+	return false
+}
+
+// pullFix explains a failed probe read.
+func pullFix(err error) string {
+	if isStatus(err, http.StatusUnauthorized, http.StatusForbidden) {
+		return "The artifact was accepted but can't be read back; check the account's pull rights."
+	}
+	return "The artifact was accepted but can't be read back; check the registry's storage backend and logs."
+}
+
+// pushFix explains a failed probe push.
+func pushFix(err error, repo string) string {
+	switch {
+	case isStatus(err, http.StatusUnauthorized, http.StatusForbidden) || isCode(err, "DENIED", "UNAUTHORIZED"):
+		return fmt.Sprintf("The account can't push to %s. Grant it push rights on that project/namespace.", repo)
+	case isStatus(err, http.StatusNotFound) || isCode(err, "NAME_UNKNOWN"):
+		return fmt.Sprintf("%s doesn't exist and the registry doesn't create repositories on push. Create it, or run 'wsm registry mirror --create-repositories'.", repo)
+	case isCode(err, "MANIFEST_INVALID", "UNSUPPORTED") || isStatus(err, http.StatusUnsupportedMediaType):
+		return "The registry rejects OCI artifact manifests (artifactType), so the server manifest push will fail. Enable OCI artifacts or upgrade the registry."
+	}
+	return "Pushing a small OCI artifact failed; the server manifest push would fail the same way."
+}
+
+func isStatus(err error, codes ...int) bool {
+	var resp *errcode.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	for _, c := range codes {
+		if resp.StatusCode == c {
+			return true
+		}
+	}
+	return false
+}
+
+func isCode(err error, codes ...string) bool {
+	var resp *errcode.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	for _, e := range resp.Errors {
+		for _, c := range codes {
+			if e.Code == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// print writes one line per check plus its remediation, and returns the
+// number of failed checks.
+func (d *registryDoctor) print() int {
+	fmt.Printf("Registry doctor: %s\n\n", d.host)
+	icons := map[string]string{"ok": "✓", "warn": "⚠", "fail": "✗", "skip": "-"}
+	var failed, warned int
+	for _, r := range d.results {
+		fmt.Printf("  %s %-10s %s\n", icons[r.status], r.name, r.detail)
+		if r.fix != "" && r.status != "ok" {
+			fmt.Printf("    → %s\n", r.fix)
+		}
+		switch r.status {
+		case "fail":
+			failed++
+		case "warn":
+			warned++
+		}
+	}
+	fmt.Println()
+	switch {
+	case failed > 0:
+		fmt.Printf("%d failed, %d warning(s)\n", failed, warned)
+	case warned > 0:
+		fmt.Printf("All checks passed with %d warning(s)\n", warned)
+	default:
+		fmt.Println("All checks passed")
+	}
+	return failed
+}
@@ -17,46 +17,49 @@ import (
 
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
-	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1remote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	wmanifest "github.com/wandb/operator/pkg/wandb/manifest"
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/registryauth"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
-	"oras.land/oras-go/v2/registry/remote/credentials"
 	"oras.land/oras-go/v2/registry/remote/retry"
 	"sigs.k8s.io/yaml"
 )
 
 // serverManifestUpstream is the public OCI repository the operator pulls the
 // server manifest from by default. wsm mirrors it (with rewritten image refs)
-// to <mirror>/wandb/server-manifest so an air-gapped install can pull it and
-// every W&B application image (weave-trace, weave-python, local, …) from the
-// mirror.
+// to <mirror>/wandb/server-manifest (under the default layout) so an air-gapped
+// install can pull it and every W&B application image (weave-trace,
+// weave-python, local, …) from the mirror.
 const serverManifestUpstream = "us-docker.pkg.dev/wandb-production/public/wandb/server-manifest"
 
-// wandbPublicPrefix is stripped from W&B image references so they land under
-// <mirror>/wandb/* — matching how `--mirror-registry` retargets the operator
-// image and how buildMirrorPlan maps the operator chart.
-const wandbPublicPrefix = "us-docker.pkg.dev/wandb-production/public/"
-
 // mirrorServerManifest pulls the server-manifest OCI artifact for version,
 // mirrors every application/migration image it references, rewrites the image
 // references inside the manifest to point at target, and pushes the rewritten
-// manifest to <target>/wandb/server-manifest:<version>. After this runs,
+// manifest to <target>/wandb/server-manifest:<version> (both placed by layout).
+// With a --from mirror as source, the manifest and images are read from there
+// instead, and the manifest's mirror references are rewritten for target.
+// After this runs,
 // `wsm deploy-v2 operator --mirror-registry <target> --wandb-version <version>`
 // brings the whole app up with no public-registry access.
 func mirrorServerManifest(
 	ctx context.Context,
-	target, version, manifestSource string,
+	target string,
+	layout *mirror.Layout,
+	version, manifestSource string,
+	from mirrorSource,
 	insecure, dryRun bool,
 	srcCtx, dstCtx *types.SystemContext,
 	policyCtx *signature.PolicyContext,
+	repos mirror.RepositoryCreator,
+	creds registryauth.Provider,
 ) error {
 	// manifestSource is a hidden dev/testing override (--manifest-source): pull
 	// the manifest from a non-upstream OCI repo (e.g. a local Tilt registry
@@ -66,14 +69,20 @@ func mirrorServerManifest(
 	if manifestSource != "" {
 		source = manifestSource
 	}
+	if from.registry != "" {
+		source = layout.Repository(from.registry, serverManifestUpstream)
+	}
 	fmt.Printf("\nServer manifest %s:%s\n", source, version)
 
 	var files map[string][]byte
 	var err error
-	if manifestSource != "" {
-		files, err = pullManifestYAMLFrom(ctx, manifestSource, version, insecure)
-	} else {
-		files, err = pullManifestYAML(ctx, version)
+	switch {
+	case from.registry != "":
+		files, err = pullManifestYAMLFrom(ctx, source, version, from.insecure, creds)
+	case manifestSource != "":
+		files, err = pullManifestYAMLFrom(ctx, manifestSource, version, insecure, creds)
+	default:
+		files, err = pullManifestYAML(ctx, version, creds)
 	}
 	if err != nil {
 		return fmt.Errorf("pull server manifest: %w", err)
@@ -87,20 +96,26 @@ func mirrorServerManifest(
 		return fmt.Errorf("server manifest %s:%s referenced no images", source, version)
 	}
 
-	// Map each unique source repository to its mirror location once; the same
-	// mapping drives both image copying and the in-manifest rewrite.
+	// Map each unique repository named in the manifest to where it is copied
+	// from and to once; the same mapping drives both image copying and the
+	// in-manifest rewrite.
+	repoSource := map[string]string{}
 	repoRewrite := map[string]string{}
 	for _, ref := range refs {
-		repoRewrite[ref.Repository] = rewriteRepoForMirror(target, ref.Repository)
+		repoSource[ref.Repository], repoRewrite[ref.Repository] = from.manifestRepository(ref.Repository, target, layout)
+	}
+	imageRef := func(ref wmanifest.ImageRef, repos map[string]string) string {
+		return wmanifest.ImageRef{Repository: repos[ref.Repository], Tag: ref.Tag, Digest: ref.Digest}.GetImage("")
 	}
 
 	fmt.Printf("  %d application image(s) referenced:\n", len(refs))
 	for _, ref := range refs {
-		src := ref.GetImage("")
-		dst := mirrorImageRef(target, ref)
+		src := imageRef(ref, repoSource)
+		dst := imageRef(ref, repoRewrite)
 		fmt.Printf("    %s\n      → %s\n", src, dst)
 	}
-	manifestDst := target + "/wandb/server-manifest:" + version
+	manifestRepo := layout.Repository(target, serverManifestUpstream)
+	manifestDst := manifestRepo + ":" + version
 	fmt.Printf("  manifest → %s (image refs rewritten)\n", manifestDst)
 
 	if dryRun {
@@ -113,10 +128,15 @@ func mirrorServerManifest(
 	// report them after the manifest is pushed.
 	var failedImages []string
 	for _, ref := range refs {
-		src := ref.GetImage("")
-		dst := mirrorImageRef(target, ref)
+		src := imageRef(ref, repoSource)
+		dst := imageRef(ref, repoRewrite)
 		fmt.Printf("→ %s\n  → %s ... ", src, dst)
-		if err := copyImage(ctx, src, dst, insecure, srcCtx, dstCtx, policyCtx); err != nil {
+		if err := ensureRepository(ctx, repos, dst); err != nil {
+			fmt.Printf("✗ %v\n", err)
+			failedImages = append(failedImages, src)
+			continue
+		}
+		if err := copyImage(ctx, src, dst, insecure, srcCtx, dstCtx, policyCtx, creds); err != nil {
 			fmt.Printf("✗ %v\n", err)
 			failedImages = append(failedImages, src)
 			continue
@@ -135,7 +155,11 @@ func mirrorServerManifest(
 	}
 
 	fmt.Printf("→ pushing rewritten manifest to %s ... ", manifestDst)
-	if err := pushManifestArtifact(ctx, target, version, rewritten, insecure); err != nil {
+	if err := ensureRepository(ctx, repos, manifestDst); err != nil {
+		fmt.Println("✗")
+		return err
+	}
+	if err := pushManifestArtifact(ctx, manifestRepo, version, rewritten, insecure, creds); err != nil {
 		fmt.Println("✗")
 		return fmt.Errorf("push rewritten manifest: %w", err)
 	}
@@ -157,24 +181,47 @@ func copyImage(
 	dstInsecure bool,
 	srcCtx, dstCtx *types.SystemContext,
 	policyCtx *signature.PolicyContext,
+	creds registryauth.Provider,
 ) error {
-	err := mirrorOne(ctx, src, dst, srcCtx, dstCtx, policyCtx)
+	// A tag pinned to a digest (the Kind node image) is read by digest and
+	// written under its tag.
+	src, _ = splitPinned(src)
+	_, dst = splitPinned(dst)
+	err := mirrorOne(ctx, src, dst, srcCtx, dstCtx, policyCtx, creds)
 	if err == nil {
 		return nil
 	}
-	if cerr := craneCopyImage(ctx, src, dst, dstInsecure); cerr != nil {
+	srcInsecure := srcCtx != nil && srcCtx.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue
+	if cerr := craneCopyImage(ctx, src, dst, srcInsecure, dstInsecure, creds); cerr != nil {
 		return fmt.Errorf("%w (crane fallback also failed: %v)", err, cerr)
 	}
 	return nil
 }
 
+// splitPinned returns the by-digest and by-tag forms of a reference that
+// carries both a tag and a digest, which containers/image can't parse. Any
+// other reference is returned unchanged as both.
+func splitPinned(ref string) (byDigest, byTag string) {
+	repo, suffix := mirror.SplitReference(ref)
+	tag, dgst, ok := strings.Cut(suffix, "@")
+	if !ok || tag == "" {
+		return ref, ref
+	}
+	return repo + "@" + dgst, repo + tag
+}
+
 // craneCopyImage copies an image (or multi-arch index) from src to dst using
-// go-containerregistry. The source is always pulled over verified TLS; the
-// destination honours dstInsecure, which (matching the containers/image path)
-// means "don't be strict about the mirror's TLS": it tolerates a plain-HTTP
-// registry AND an HTTPS registry with a self-signed / untrusted cert.
-func craneCopyImage(ctx context.Context, src, dst string, dstInsecure bool) error {
-	srcRef, err := name.ParseReference(src)
+// go-containerregistry. Each side honours its own insecure flag, which
+// (matching the containers/image path) means "don't be strict about that
+// registry's TLS": it tolerates a plain-HTTP registry AND an HTTPS registry
+// with a self-signed / untrusted cert. The source is only insecure when it is
+// a --from mirror; upstream registries are always verified.
+func craneCopyImage(ctx context.Context, src, dst string, srcInsecure, dstInsecure bool, creds registryauth.Provider) error {
+	var srcOpts []name.Option
+	if srcInsecure {
+		srcOpts = append(srcOpts, name.Insecure)
+	}
+	srcRef, err := name.ParseReference(src, srcOpts...)
 	if err != nil {
 		return fmt.Errorf("parse source %q: %w", src, err)
 	}
@@ -189,14 +236,18 @@ func craneCopyImage(ctx context.Context, src, dst string, dstInsecure bool) erro
 		return fmt.Errorf("parse target %q: %w", dst, err)
 	}
 
+	keychain := registryauth.Keychain(creds)
 	pullOpts := []v1remote.Option{
-		v1remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		v1remote.WithAuthFromKeychain(keychain),
 		v1remote.WithContext(ctx),
 	}
 	pushOpts := []v1remote.Option{
-		v1remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		v1remote.WithAuthFromKeychain(keychain),
 		v1remote.WithContext(ctx),
 	}
+	if srcInsecure {
+		pullOpts = append(pullOpts, v1remote.WithTransport(insecureHTTPTransport()))
+	}
 	if dstInsecure {
 		pushOpts = append(pushOpts, v1remote.WithTransport(insecureHTTPTransport()))
 	}
@@ -233,9 +284,9 @@ func insecureHTTPTransport() *http.Transport {
 // path inside the artifact's layers. It mirrors the operator's own extraction
 // logic (pkg/wandb/manifest) so the bytes we rewrite are exactly what the
 // operator would have read.
-func pullManifestYAML(ctx context.Context, version string) (map[string][]byte, error) {
+func pullManifestYAML(ctx context.Context, version string, creds registryauth.Provider) (map[string][]byte, error) {
 	// Upstream is real TLS — always verify.
-	return pullManifestYAMLFrom(ctx, serverManifestUpstream, version, false)
+	return pullManifestYAMLFrom(ctx, serverManifestUpstream, version, false, creds)
 }
 
 // pullManifestYAMLFrom is pullManifestYAML against an arbitrary server-manifest
@@ -245,12 +296,12 @@ func pullManifestYAML(ctx context.Context, version string) (map[string][]byte, e
 // TLS verification for a self-signed mirror; like the push side it does not fall
 // back to plain HTTP, since the operator could never consume a plain-HTTP
 // manifest anyway.
-func pullManifestYAMLFrom(ctx context.Context, repoRef, version string, insecure bool) (map[string][]byte, error) {
+func pullManifestYAMLFrom(ctx context.Context, repoRef, version string, insecure bool, creds registryauth.Provider) (map[string][]byte, error) {
 	src, err := remote.NewRepository(repoRef)
 	if err != nil {
 		return nil, fmt.Errorf("init source repo: %w", err)
 	}
-	src.Client = dockerAuthClient(insecure)
+	src.Client = registryAuthClient(insecure, creds)
 
 	store := memory.New()
 	desc, err := oras.Copy(ctx, src, version, store, version, oras.DefaultCopyOptions)
@@ -376,35 +427,6 @@ func collectManifestImages(files map[string][]byte) ([]wmanifest.ImageRef, error
 	return refs, nil
 }
 
-// rewriteRepoForMirror maps an upstream image repository to its location in the
-// mirror. W&B images drop the wandb-production/public project path so they land
-// under <mirror>/wandb/*; everything else just loses its registry host. This
-// matches the mapping buildMirrorPlan uses for the charts and operator image.
-func rewriteRepoForMirror(target, repo string) string {
-	target = strings.TrimRight(target, "/")
-	if strings.HasPrefix(repo, wandbPublicPrefix) {
-		return target + "/" + strings.TrimPrefix(repo, wandbPublicPrefix)
-	}
-	if i := strings.IndexByte(repo, '/'); i > 0 {
-		host := repo[:i]
-		if strings.ContainsAny(host, ".:") || host == "localhost" {
-			return target + "/" + repo[i+1:]
-		}
-	}
-	return target + "/" + repo
-}
-
-// mirrorImageRef returns the full mirror reference (repo + tag/digest) for an
-// upstream image reference.
-func mirrorImageRef(target string, ref wmanifest.ImageRef) string {
-	mirrored := wmanifest.ImageRef{
-		Repository: rewriteRepoForMirror(target, ref.Repository),
-		Tag:        ref.Tag,
-		Digest:     ref.Digest,
-	}
-	return mirrored.GetImage("")
-}
-
 // replaceRepo replaces every standalone occurrence of oldRepo with newRepo in
 // data. A match is "standalone" only when neither the preceding nor following
 // byte continues a repository path, so replacing "…/weave-trace" never touches
@@ -447,10 +469,10 @@ func isRepoPathByte(b byte) bool {
 }
 
 // pushManifestArtifact packs the rewritten YAML files into a single gzipped tar
-// layer and pushes a fresh OCI image manifest to <target>/wandb/server-manifest
-// tagged with version. The layer uses MediaTypeImageLayerGzip so the operator's
+// layer and pushes a fresh OCI image manifest to repo (the mirror's
+// server-manifest repository) tagged with version. The layer uses MediaTypeImageLayerGzip so the operator's
 // extractor decompresses it.
-func pushManifestArtifact(ctx context.Context, target, version string, files map[string][]byte, insecure bool) error {
+func pushManifestArtifact(ctx context.Context, repo, version string, files map[string][]byte, insecure bool, creds registryauth.Provider) error {
 	layerData, err := buildLayerTarGz(files)
 	if err != nil {
 		return fmt.Errorf("build layer: %w", err)
@@ -477,7 +499,7 @@ func pushManifestArtifact(ctx context.Context, target, version string, files map
 		return fmt.Errorf("tag manifest: %w", err)
 	}
 
-	dst, err := remote.NewRepository(strings.TrimRight(target, "/") + "/wandb/server-manifest")
+	dst, err := remote.NewRepository(repo)
 	if err != nil {
 		return fmt.Errorf("init target repo: %w", err)
 	}
@@ -485,7 +507,7 @@ func pushManifestArtifact(ctx context.Context, target, version string, files map
 	// HTTPS from inside the cluster). --insecure skips TLS verification for a
 	// self-signed cert; it does NOT fall back to plain HTTP, since a plain-HTTP
 	// manifest could never be consumed anyway.
-	dst.Client = dockerAuthClient(insecure)
+	dst.Client = registryAuthClient(insecure, creds)
 
 	if _, err := oras.Copy(ctx, store, version, dst, version, oras.DefaultCopyOptions); err != nil {
 		return fmt.Errorf("copy to mirror: %w", err)
@@ -521,26 +543,24 @@ func buildLayerTarGz(files map[string][]byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// dockerAuthClient returns an oras auth client backed by the user's Docker
-// config (~/.docker/config.json), falling back to anonymous access if no
-// config is present. When insecure, TLS verification is skipped (for a mirror
-// with a self-signed / untrusted cert).
-func dockerAuthClient(insecure bool) *auth.Client {
+// registryAuthClient returns an oras auth client that resolves credentials
+// through creds (flags, environment, then the user's Docker config), falling
+// back to anonymous access when none match. When insecure, TLS verification is
+// skipped (for a mirror with a self-signed / untrusted cert).
+func registryAuthClient(insecure bool, creds registryauth.Provider) *auth.Client {
 	httpClient := retry.DefaultClient
 	if insecure {
 		httpClient = &http.Client{Transport: retry.NewTransport(insecureHTTPTransport())}
 	}
 	client := &auth.Client{
-		Client: httpClient,
-		Cache:  auth.NewCache(),
-	}
-	if store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{}); err == nil {
-		client.Credential = credentials.Credential(store)
+		Client:     httpClient,
+		Cache:      auth.NewCache(),
+		Credential: registryauth.CredentialFunc(creds),
 	}
 	return client
 }
 
-func sortedKeys(m map[string][]byte) []string {
+func sortedKeys[V any](m map[string]V) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
@@ -9,8 +9,13 @@ import (
 	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1remote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/mirror"
 	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
 )
 
 // registryMirrorCmd pulls every artifact wsm needs for a v2 install from its
@@ -21,11 +26,18 @@ func registryMirrorCmd() *cobra.Command {
 	var (
 		targetRegistry       string
 		insecure             bool
+		from                 mirrorSource
 		dryRun               bool
 		operatorChartVersion string
 		wandbVersion         string
 		skipManaged          bool
+		observabilityMode    string
 		manifestSource       string
+		layoutOpts           layoutFlags
+		createRepos          bool
+		registryType         string
+		credOpts             credentialFlags
+		extraOpts            extraFlags
 	)
 
 	cmd := &cobra.Command{
@@ -35,18 +47,60 @@ func registryMirrorCmd() *cobra.Command {
 its upstream source and push a copy to your mirror. After this runs, the install
 side can fetch everything from --mirror-registry instead of the public sources.
 
-Auth is read from your Docker config (~/.docker/config.json) by default. Use
+Auth for the mirror comes from --registry-username/--registry-password-stdin,
+then the WSM_REGISTRY_USERNAME/WSM_REGISTRY_PASSWORD environment variables, then
+your Docker config (~/.docker/config.json, including credential helpers). An
+upstream that needs its own login takes --source-registry-host with
+--source-registry-username/--source-registry-password-stdin (or the
+WSM_SOURCE_REGISTRY_* variables), so CI can mirror without a Docker config. Use
 --insecure for a plain-HTTP / self-signed mirror (e.g. a local registry:2).
 
-Mirrors, across three tiers: (1) the operator OCI chart + binary image,
-cert-manager and its 5 component images, and nginx-gateway-fabric with its 2
-images; (2) the managed-service operator images (moco/altinity/opstree/
+--from promotes between environments: it treats an existing wsm mirror as the
+source instead of the public registries, reading every artifact from where
+'wsm registry mirror' placed it there (the same --layout on both sides). With
+--wandb-version, the server manifest is read back from the source mirror and
+its image references are rewritten again for --to. Credentials for the source
+come from --source-registry-username/--source-registry-password-stdin (the host
+defaults to --from) or WSM_SOURCE_REGISTRY_*; --from-insecure skips TLS
+verification against it.
+
+Mirrors, across three tiers: (1) the operator, cert-manager and
+nginx-gateway-fabric OCI charts and the images they run, the Gateway API CRDs
+(packaged from their GitHub release as an OCI artifact) and the pinned Kind node
+image; (2) the managed-service operator images (moco/altinity/opstree/
 seaweedfs); (3) the managed data-plane images (ClickHouse/Kafka/MySQL/Redis/
-SeaweedFS servers). With --wandb-version it also mirrors the server manifest and
-every W&B application image it references (weave, megabinary, frontend, …),
-rewriting the manifest's image refs to point at the mirror. Pass
---skip-managed-images to omit tiers 2 and 3 (e.g. when running W&B against
-external databases).`,
+SeaweedFS servers). Tiers 1 and 2 are read from the charts themselves: each one
+is pulled at the version wsm installs and rendered with the values
+'wsm deploy-v2 operator' passes (--observability-mode adds the telemetry
+subcharts), and every image in the manifests, hooks and chart values is
+mirrored, so a subchart bump needs no new wsm. With --wandb-version it also
+mirrors the server manifest and every W&B application image it references
+(weave, megabinary, frontend, …), rewriting the manifest's image refs to point
+at the mirror. Pass --skip-managed-images to omit tiers 2 and 3 (e.g. when
+running W&B against external databases). Anything else your install needs
+(sidecars, custom CA init images, your own OCI Helm charts) can be added with
+--extra-images / --extra-charts, one reference per line, or the extra section of
+a --config file; extras follow the same --layout.
+
+--layout controls where each artifact lands in the mirror. The default,
+preserve-path, drops only the upstream host (quay.io/jetstack/x →
+<mirror>/jetstack/x). flatten places every repository directly under one
+project (--layout-prefix), for registries like a single-project Harbor or ECR.
+mapping rewrites upstream prefixes from a YAML file (--layout-mapping). Pass the
+same layout to 'wsm registry check' and 'wsm deploy-v2 --mirror-layout'.
+
+Registries that don't create repositories on push (ECR, Harbor projects,
+Artifactory repositories, Artifact Registry repositories) need
+--create-repositories, which creates each missing repository or project through
+the registry's API before copying into it. The flavour is detected from the
+hostname or by probing the Harbor/Artifactory APIs; override it with
+--registry-type. ECR uses the AWS SDK's default credentials (environment,
+profile, SSO, instance or pod role); Artifact Registry needs --to
+<location>-docker.pkg.dev/<project>/<repository> and uses
+GOOGLE_OAUTH_ACCESS_TOKEN or gcloud; Harbor and Artifactory use the same
+registry credentials as the push. --dry-run still pulls and renders the charts
+to build the plan, but never contacts the --to mirror: the registry type isn't
+probed and nothing is created or pushed.`,
 		Example: `  # Mirror everything to a local registry:2 on localhost:5000.
   wsm registry mirror --to localhost:5000 --insecure
 
@@ -54,27 +108,89 @@ external databases).`,
   wsm registry mirror --to harbor.mycorp.internal
 
   # Preview without pushing.
-  wsm registry mirror --to harbor.mycorp.internal --dry-run`,
+  wsm registry mirror --to harbor.mycorp.internal --dry-run
+
+  # Everything under a single Harbor project.
+  wsm registry mirror --to harbor.mycorp.internal --layout flatten --layout-prefix wandb-mirror
+
+  # ECR, creating every repository first.
+  wsm registry mirror --to 123456789012.dkr.ecr.us-east-1.amazonaws.com --create-repositories
+
+  # Also mirror in-house images and charts.
+  wsm registry mirror --to harbor.mycorp.internal --extra-images images.txt --extra-charts charts.txt
+
+  # Promote from a DMZ Harbor to a site registry.
+  wsm registry mirror --from harbor.dmz.mycorp.internal --to harbor.site1.mycorp.internal --wandb-version 0.82.2
+
+  # From CI, with no Docker config.
+  echo "$HARBOR_TOKEN" | wsm registry mirror --to harbor.mycorp.internal \
+    --registry-username ci-bot --registry-password-stdin`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if targetRegistry == "" {
 				return fmt.Errorf("--to is required (the hostname of your mirror, e.g. harbor.example.com)")
 			}
 			targetRegistry = strings.TrimRight(targetRegistry, "/")
+			from.registry = strings.TrimRight(from.registry, "/")
+			if from.registry != "" {
+				if from.registry == targetRegistry {
+					return fmt.Errorf("--from and --to are the same registry")
+				}
+				if manifestSource != "" {
+					return fmt.Errorf("--manifest-source can't be combined with --from")
+				}
+				if credOpts.sourceHost == "" {
+					credOpts.sourceHost = from.registry
+				}
+			}
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(targetRegistry)
+			if err != nil {
+				return err
+			}
+			extras, err := extraOpts.extras()
+			if err != nil {
+				return err
+			}
 
-			items := buildMirrorPlan(targetRegistry, operatorChartVersion)
-			if !skipManaged {
-				// Managed MySQL/Redis/Kafka/ClickHouse/object-store services. These
-				// pull from docker.io/quay.io/ghcr.io and are pushed to provided registry mirror
-				// At install they're retargeted to the mirror by:
-				// Helm image values set from --mirror-registry using
-				// spec.global.imageRegistry on the CR, which the operator host-replaces
-				// (requires an operator version that declares the field). On a plain-HTTP local
-				// install without that field, the node's containerd registry mirrors
-				// (wsm cluster create --insecure-registry-host) redirect them instead.
-				items = append(items, buildManagedImagePlan(targetRegistry)...)
+			ctx := context.Background()
+			telemetry, err := stackTelemetry(observabilityMode)
+			if err != nil {
+				return err
+			}
+			var charts *operator.MirrorConfig
+			if from.registry != "" {
+				charts = &operator.MirrorConfig{Host: from.registry, Insecure: from.insecure, Layout: layout, Credentials: creds}
+			}
+			// The images come from the charts rendered the way deploy-v2
+			// installs them, so the plan follows every subchart bump. Managed
+			// MySQL/Redis/Kafka/ClickHouse/object-store images pull from
+			// docker.io/quay.io/ghcr.io; at install they're retargeted to the
+			// mirror by the subcharts' Helm image values set from
+			// --mirror-registry, and the data-plane ones by
+			// spec.global.imageRegistry on the CR, which the operator
+			// host-replaces (requires an operator version that declares the
+			// field). On a plain-HTTP local install without that field, the
+			// node's containerd registry mirrors (wsm cluster create
+			// --insecure-registry-host) redirect them instead.
+			stack := stackOptions{operatorChartVersion: operatorChartVersion, telemetry: telemetry, skipManaged: skipManaged}
+			items, err := buildMirrorPlan(ctx, targetRegistry, stack, charts, layout)
+			if err != nil {
+				return err
+			}
+			items = append(items, planFor(extras.Sources(), targetRegistry, layout)...)
+			for i := range items {
+				items[i].src = from.image(items[i].src, layout)
 			}
+			items = append(items, gatewayAPICRDItem(targetRegistry, from, layout))
 
-			fmt.Printf("Mirroring %d artifacts to %s\n\n", len(items), targetRegistry)
+			if from.registry != "" {
+				fmt.Printf("Promoting %d artifacts from %s to %s (layout: %s)\n\n", len(items), from.registry, targetRegistry, layout)
+			} else {
+				fmt.Printf("Mirroring %d artifacts to %s (layout: %s)\n\n", len(items), targetRegistry, layout)
+			}
 
 			policyCtx, err := newAcceptAllPolicy()
 			if err != nil {
@@ -83,13 +199,26 @@ external databases).`,
 			defer func() { _ = policyCtx.Destroy() }()
 
 			srcCtx := &types.SystemContext{}
+			if from.insecure {
+				srcCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+				srcCtx.OCIInsecureSkipTLSVerify = true
+			}
 			dstCtx := &types.SystemContext{}
 			if insecure {
 				dstCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
 				dstCtx.OCIInsecureSkipTLSVerify = true
 			}
 
-			ctx := context.Background()
+			// A dry run never contacts the mirror, so the registry type
+			// isn't probed and nothing is created.
+			var repos mirror.RepositoryCreator
+			if createRepos && !dryRun {
+				repos, err = newRepositoryCreator(ctx, targetRegistry, registryType, insecure, creds)
+				if err != nil {
+					return err
+				}
+			}
+
 			var pushed, failed int
 			for _, item := range items {
 				if dryRun {
@@ -97,7 +226,17 @@ external databases).`,
 					continue
 				}
 				fmt.Printf("→ %s\n  → %s ... ", item.src, item.dst)
-				if err := copyImage(ctx, item.src, item.dst, insecure, srcCtx, dstCtx, policyCtx); err != nil {
+				if err := ensureRepository(ctx, repos, item.dst); err != nil {
+					fmt.Printf("✗ %v\n", err)
+					failed++
+					continue
+				}
+				if item.gatewayCRDs {
+					err = pushGatewayAPICRDs(ctx, item, from, insecure, creds)
+				} else {
+					err = copyImage(ctx, item.src, item.dst, insecure, srcCtx, dstCtx, policyCtx, creds)
+				}
+				if err != nil {
 					fmt.Printf("✗ %v\n", err)
 					failed++
 					continue
@@ -117,7 +256,7 @@ external databases).`,
 			// (weave-trace, weave-python, local, console, migrations, …) are only
 			// mirrored when a version is given, since they're version-specific.
 			if wandbVersion != "" {
-				if err := mirrorServerManifest(ctx, targetRegistry, wandbVersion, manifestSource, insecure, dryRun, srcCtx, dstCtx, policyCtx); err != nil {
+				if err := mirrorServerManifest(ctx, targetRegistry, layout, wandbVersion, manifestSource, from, insecure, dryRun, srcCtx, dstCtx, policyCtx, repos, creds); err != nil {
 					return err
 				}
 			} else {
@@ -129,86 +268,204 @@ external databases).`,
 	}
 
 	cmd.Flags().StringVar(&targetRegistry, "to", "", "Hostname of your mirror, e.g. harbor.example.com (required)")
+	cmd.Flags().StringVar(&from.registry, "from", "", "Copy from this existing wsm mirror (same --layout) instead of the upstream registries")
+	cmd.Flags().BoolVar(&from.insecure, "from-insecure", false, "Skip TLS verification when pulling from --from")
 	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when pushing to the mirror (use for plain-HTTP registries like local registry:2)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the source → target mirroring plan without pushing")
-	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", "2.0.0-beta.1", "Operator chart version; also used as the tag for the operator binary image")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version; also used as the tag for the operator binary image")
 	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "W&B server version (e.g. 0.81.0); when set, also mirror the server manifest and every application image it references, rewriting them to point at the mirror")
 	cmd.Flags().BoolVar(&skipManaged, "skip-managed-images", false, "Don't mirror the managed-service operator + data-plane images (ClickHouse/Kafka/MySQL/Redis/object-store). Use when you run W&B against external databases.")
+	cmd.Flags().StringVar(&observabilityMode, "observability-mode", operator.TelemetryModeOff, "Telemetry mode you'll deploy the operator with (off, full, forward); full and forward add the telemetry stack's images")
 	// TESTING ONLY, hidden from --help: pull the server manifest from a non-upstream
 	// OCI repo (e.g. a local Tilt registry serving unreleased wandb/core manifest
 	// changes) instead of us-docker.pkg.dev. Not a supported customer workflow.
 	cmd.Flags().StringVar(&manifestSource, "manifest-source", "", "TESTING ONLY: pull the server manifest from this OCI repo (host/path, no tag) instead of the public upstream; --wandb-version supplies the tag. Reuses --insecure for TLS skip.")
 	_ = cmd.Flags().MarkHidden("manifest-source")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", true)
+	extraOpts.register(cmd.Flags())
+	cmd.Flags().BoolVar(&createRepos, "create-repositories", false, "Create missing repositories/projects in the mirror through its API before copying (ECR, Harbor, Artifactory, Artifact Registry)")
+	cmd.Flags().StringVar(&registryType, "registry-type", "", "Mirror flavour for --create-repositories: "+strings.Join(mirror.RegistryTypes, ", ")+" (detected when unset)")
 	return cmd
 }
 
+// newRepositoryCreator resolves --registry-type, detecting it from target when
+// unset, and returns the creator --create-repositories uses.
+func newRepositoryCreator(ctx context.Context, target, registryType string, insecure bool, creds registryauth.Provider) (mirror.RepositoryCreator, error) {
+	opts := mirror.CreatorOptions{Insecure: insecure, Credential: registryauth.CredentialFunc(creds), Root: target}
+	if registryType == "" {
+		registryType = mirror.DetectRegistryType(ctx, target, opts)
+		fmt.Printf("Detected registry type: %s\n", registryType)
+	}
+	return mirror.NewRepositoryCreator(registryType, opts)
+}
+
+// ensureRepository creates the repository of ref in the mirror when
+// --create-repositories is set (repos is nil otherwise).
+func ensureRepository(ctx context.Context, repos mirror.RepositoryCreator, ref string) error {
+	if repos == nil {
+		return nil
+	}
+	repo, _ := mirror.SplitReference(ref)
+	if err := repos.EnsureRepository(ctx, repo); err != nil {
+		return fmt.Errorf("create repository: %w", err)
+	}
+	return nil
+}
+
+// mirrorSource is where registry mirror copies from: the upstream registries,
+// or with --from an existing wsm mirror laid out with the same layout.
+type mirrorSource struct {
+	registry string // empty means upstream
+	insecure bool
+}
+
+// image returns where upstreamImage is read from.
+func (s mirrorSource) image(upstreamImage string, layout *mirror.Layout) string {
+	if s.registry == "" {
+		return upstreamImage
+	}
+	return layout.Image(s.registry, upstreamImage)
+}
+
+// manifestRepository maps a repository named in a server manifest to where it
+// is copied from and to. From upstream the manifest holds upstream
+// repositories. From a mirror it holds that mirror's rewritten repositories,
+// and since both mirrors share the layout, promoting one only swaps the host;
+// a repository the source mirror didn't rewrite is read from where the layout
+// put it there.
+func (s mirrorSource) manifestRepository(repo, target string, layout *mirror.Layout) (src, dst string) {
+	if s.registry == "" {
+		return repo, layout.Repository(target, repo)
+	}
+	if rest, ok := strings.CutPrefix(repo, s.registry+"/"); ok {
+		return repo, target + "/" + rest
+	}
+	return layout.Repository(s.registry, repo), layout.Repository(target, repo)
+}
+
 type mirrorItem struct {
-	src string // full upstream OCI reference, e.g. quay.io/jetstack/cert-manager-controller:v1.20.2
+	src string // full source reference, e.g. quay.io/jetstack/cert-manager-controller:v1.20.2 (or its copy in the --from mirror)
 	dst string // full target reference,  e.g. localhost:5000/jetstack/cert-manager-controller:v1.20.2
+	// gatewayCRDs marks the Gateway API CRD artifact, which wsm packages from
+	// the release URL in src rather than copying (unless src is a --from mirror).
+	gatewayCRDs bool
 }
 
-// buildMirrorPlan returns the static set of artifacts Iteration 1 mirrors.
-// cert-manager and nginx-gateway versions come from pkg/operator so the
-// install side and mirror side stay in lockstep automatically.
-func buildMirrorPlan(target, operatorChartVersion string) []mirrorItem {
-	certManagerVersion := operator.CertManagerVersion
-	nginxGatewayVersion := operator.NginxGatewayVersion
-
-	plan := []mirrorItem{
-		// Operator OCI chart + binary image
-		{
-			src: "us-docker.pkg.dev/wandb-production/public/wandb/charts/operator:" + operatorChartVersion,
-			dst: target + "/wandb/charts/operator:" + operatorChartVersion,
-		},
-		{
-			src: "us-docker.pkg.dev/wandb-production/public/wandb/operator:" + operatorChartVersion,
-			dst: target + "/wandb/operator:" + operatorChartVersion,
-		},
+// buildMirrorPlan returns the operator stack wsm installs, from stackSources,
+// paired with its destinations under layout, the same one the install side
+// resolves through operator.MirrorConfig.
+func buildMirrorPlan(ctx context.Context, target string, stack stackOptions, charts *operator.MirrorConfig, layout *mirror.Layout) ([]mirrorItem, error) {
+	sources, err := stackSources(ctx, stack, charts)
+	if err != nil {
+		return nil, err
+	}
+	return planFor(sources, target, layout), nil
+}
 
-		// cert-manager OCI chart
-		{
-			src: "quay.io/jetstack/charts/cert-manager:" + certManagerVersion,
-			dst: target + "/jetstack/charts/cert-manager:" + certManagerVersion,
-		},
+// stackOptions are the flags that decide which images the operator stack
+// needs.
+type stackOptions struct {
+	operatorChartVersion string
+	telemetry            operator.TelemetryConfig
+	skipManaged          bool
+}
+
+// options returns the render options that list the stack's images as the
+// install pulls them from target (upstream when nil).
+func (o stackOptions) options(target *operator.MirrorConfig) operator.StackRenderOptions {
+	return operator.StackRenderOptions{
+		OperatorChartVersion: o.operatorChartVersion,
+		Telemetry:            o.telemetry,
+		Mirror:               target,
 	}
+}
 
-	for _, comp := range []string{"controller", "webhook", "cainjector", "acmesolver", "startupapicheck"} {
-		plan = append(plan, mirrorItem{
-			src: "quay.io/jetstack/cert-manager-" + comp + ":" + certManagerVersion,
-			dst: target + "/jetstack/cert-manager-" + comp + ":" + certManagerVersion,
-		})
+// stackSources lists the upstream references of the operator stack: the
+// cert-manager, nginx-gateway-fabric and operator charts, every image they
+// reference once rendered with the install's values (without the
+// managed-service subcharts' when skipManaged), the managed data-plane
+// images and the Kind node image. The charts are read from charts, a mirror
+// laid out like the target, or from upstream when it is nil.
+func stackSources(ctx context.Context, stack stackOptions, charts *operator.MirrorConfig) ([]string, error) {
+	opts := stack.options(nil)
+	opts.ChartSource = charts
+	images, err := operator.StackImages(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sources := operator.StackCharts(stack.operatorChartVersion)
+	for _, img := range images {
+		if stack.skipManaged && img.Managed() {
+			continue
+		}
+		sources = append(sources, img.Reference)
+	}
+	if !stack.skipManaged {
+		sources = append(sources, dataPlaneImageSources()...)
 	}
 
-	// nginx-gateway-fabric chart + 2 images (control plane + data plane)
-	plan = append(plan,
-		mirrorItem{
-			src: "ghcr.io/nginx/charts/nginx-gateway-fabric:" + nginxGatewayVersion,
-			dst: target + "/nginx/charts/nginx-gateway-fabric:" + nginxGatewayVersion,
-		},
-		mirrorItem{
-			src: "ghcr.io/nginx/nginx-gateway-fabric:" + nginxGatewayVersion,
-			dst: target + "/nginx/nginx-gateway-fabric:" + nginxGatewayVersion,
-		},
-		mirrorItem{
-			src: "ghcr.io/nginx/nginx-gateway-fabric/nginx:" + nginxGatewayVersion,
-			dst: target + "/nginx/nginx-gateway-fabric/nginx:" + nginxGatewayVersion,
-		},
-	)
+	// Kind node image, so `cluster create` and --setup-k8s-cluster can build
+	// the cluster itself from the mirror.
+	sources = append(sources, kind.DefaultNodeImage)
+	return sources, nil
+}
 
-	return plan
+// gatewayAPICRDItem is the Gateway API CRD artifact's place in the plan: its
+// GitHub release manifest, or the copy in the --from mirror, packaged at the
+// layout's place for operator.GatewayAPICRDReference.
+func gatewayAPICRDItem(target string, from mirrorSource, layout *mirror.Layout) mirrorItem {
+	return mirrorItem{
+		src:         from.gatewayAPICRDs(layout),
+		dst:         layout.Image(target, operator.GatewayAPICRDReference()),
+		gatewayCRDs: true,
+	}
+}
+
+// gatewayAPICRDs returns where the Gateway API CRDs are read from.
+func (s mirrorSource) gatewayAPICRDs(layout *mirror.Layout) string {
+	if s.registry == "" {
+		return operator.GatewayAPICRDURL
+	}
+	return layout.Image(s.registry, operator.GatewayAPICRDReference())
+}
+
+// pushGatewayAPICRDs downloads the Gateway API CRD manifest and pushes it to
+// item.dst as an OCI artifact, or copies the artifact from a --from mirror.
+func pushGatewayAPICRDs(ctx context.Context, item mirrorItem, from mirrorSource, insecure bool, creds registryauth.Provider) error {
+	if from.registry != "" {
+		return craneCopyImage(ctx, item.src, item.dst, from.insecure, insecure, creds)
+	}
+	crds, err := operator.FetchGatewayAPICRDs(ctx, item.src)
+	if err != nil {
+		return err
+	}
+	img, err := operator.GatewayAPICRDImage(crds, item.src)
+	if err != nil {
+		return fmt.Errorf("package CRDs: %w", err)
+	}
+	var nameOpts []name.Option
+	opts := []v1remote.Option{
+		v1remote.WithAuthFromKeychain(registryauth.Keychain(creds)),
+		v1remote.WithContext(ctx),
+	}
+	if insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+		opts = append(opts, v1remote.WithTransport(insecureHTTPTransport()))
+	}
+	ref, err := name.ParseReference(item.dst, nameOpts...)
+	if err != nil {
+		return fmt.Errorf("parse target %q: %w", item.dst, err)
+	}
+	return v1remote.Write(ref, img, opts...)
 }
 
-func buildManagedImagePlan(target string) []mirrorItem {
-	images := []string{
-		// Tier 2 — subchart operator images (default-enabled subcharts).
-		"alpine/k8s:1.35.4", // altinity-clickhouse-operator.crdHook
-		"altinity/clickhouse-operator:0.26.3",
-		"altinity/metrics-exporter:0.26.3",
-		"chrislusf/seaweedfs-operator:1.0.21",
-		"ghcr.io/cybozu-go/moco:0.36.0",
-		"quay.io/opstree/redis-operator:v0.22.2",
-
-		// Tier 3 — data-plane server images.
+// dataPlaneImageSources lists the managed data-plane images (ClickHouse,
+// Kafka, MySQL, Redis and SeaweedFS servers and their sidecars). The operator binary picks these
+// when it reconciles a WeightsAndBiases CR, so no chart renders them; keep
+// them in step with the operator's defaults.
+func dataPlaneImageSources() []string {
+	return []string{
 		"altinity/clickhouse-server:25.8.16.10002.altinitystable",
 		"altinity/clickhouse-keeper:25.8.16.10002.altinitystable",
 		// Kafka (Bufstream): broker + etcd + aws-cli bucket-ensure init image.
@@ -225,10 +482,13 @@ func buildManagedImagePlan(target string) []mirrorItem {
 		"quay.io/opstree/redis-exporter:v1.44.0",
 		"chrislusf/seaweedfs:4.35",
 	}
+}
 
-	plan := make([]mirrorItem, 0, len(images))
-	for _, img := range images {
-		plan = append(plan, mirrorItem{src: img, dst: translate(img, target)})
+// planFor pairs each upstream reference with its destination under layout.
+func planFor(sources []string, target string, layout *mirror.Layout) []mirrorItem {
+	plan := make([]mirrorItem, 0, len(sources))
+	for _, src := range sources {
+		plan = append(plan, mirrorItem{src: src, dst: layout.Image(target, src)})
 	}
 	return plan
 }
@@ -238,7 +498,17 @@ func mirrorOne(
 	source, target string,
 	srcCtx, dstCtx *types.SystemContext,
 	policyCtx *signature.PolicyContext,
+	creds registryauth.Provider,
 ) error {
+	// Credentials are resolved per host, so each side of the copy gets its own.
+	srcCtx, err := registryauth.SystemContext(ctx, srcCtx, creds, registryauth.Host(source))
+	if err != nil {
+		return err
+	}
+	dstCtx, err = registryauth.SystemContext(ctx, dstCtx, creds, registryauth.Host(target))
+	if err != nil {
+		return err
+	}
 	srcRef, err := docker.ParseReference("//" + source)
 	if err != nil {
 		return fmt.Errorf("parse source %q: %w", source, err)
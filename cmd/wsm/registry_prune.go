@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// registryPruneCmd deletes W&B versions that are no longer wanted from a
+// mirror: the server-manifest tag of every version beyond --keep, plus the
+// application images only those versions reference. Images that a kept
+// version or the rest of the mirror (the operator stack, the managed-service
+// images, extras) still references, by tag or by digest, are never touched.
+func registryPruneCmd() *cobra.Command {
+	var (
+		registry             string
+		keep                 int
+		dryRun               bool
+		insecure             bool
+		operatorChartVersion string
+		skipManaged          bool
+		observabilityMode    string
+		layoutOpts           layoutFlags
+		credOpts             credentialFlags
+		extraOpts            extraFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old W&B versions and the images only they reference from your mirror",
+		Long: `Every 'wsm registry mirror --wandb-version' adds a server-manifest tag and a
+full set of application images. prune lists the mirrored server-manifest tags,
+keeps the --keep newest versions, and deletes the other tags together with every
+application image referenced only by the versions being dropped. An image that
+shares its digest with anything else 'wsm registry mirror' placed in the mirror
+(the operator stack, the managed-service images, extras) is kept too; pass the
+flags you mirrored with so that set matches.
+
+With --context, the WeightsAndBiases CRs in that cluster are read first and any
+version they run is kept regardless of --keep.
+
+Deleting requires a registry that allows manifest deletes (registry:2 needs
+REGISTRY_STORAGE_DELETE_ENABLED=true). Blobs are reclaimed by the registry's own
+garbage collection afterwards.`,
+		Example: `  # Preview what keeping the 3 newest versions would delete.
+  wsm registry prune --registry harbor.mycorp.internal --keep 3 --dry-run
+
+  # Prune, but never drop a version the production cluster runs.
+  wsm registry prune --registry harbor.mycorp.internal --keep 3 --context prod`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if registry == "" {
+				return fmt.Errorf("--registry is required")
+			}
+			if keep < 1 {
+				return fmt.Errorf("--keep must be at least 1")
+			}
+			registry = strings.TrimRight(registry, "/")
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(registry)
+			if err != nil {
+				return err
+			}
+			extras, err := extraOpts.extras()
+			if err != nil {
+				return err
+			}
+			telemetry, err := stackTelemetry(observabilityMode)
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+
+			protected := map[string][]string{}
+			if kubeContext := kubectl.DefaultOptions().Context; kubeContext != "" {
+				running, err := operator.ListWandbVersions(ctx, kubectl.Default())
+				if err != nil {
+					return fmt.Errorf("read running versions from context %s: %w", kubeContext, err)
+				}
+				for ref, version := range running {
+					key := versionKey(version)
+					protected[key] = append(protected[key], ref.Namespace+"/"+ref.Name)
+				}
+			}
+
+			manifestRepo := layout.Repository(registry, serverManifestUpstream)
+			repo, err := remote.NewRepository(manifestRepo)
+			if err != nil {
+				return fmt.Errorf("init %s: %w", manifestRepo, err)
+			}
+			repo.Client = registryAuthClient(insecure, creds)
+
+			versions, skipped, err := listManifestVersions(ctx, repo)
+			if err != nil {
+				return err
+			}
+			for _, tag := range skipped {
+				fmt.Printf("Ignoring non-version tag %s:%s\n", manifestRepo, tag)
+			}
+
+			var kept, dropped []string
+			for i, v := range versions {
+				switch {
+				case i < keep:
+					kept = append(kept, v)
+				case len(protected[versionKey(v)]) > 0:
+					fmt.Printf("Keeping %s: running in %s\n", v, strings.Join(protected[versionKey(v)], ", "))
+					kept = append(kept, v)
+				default:
+					dropped = append(dropped, v)
+				}
+			}
+			fmt.Printf("%s: %d version(s), keeping %s\n", manifestRepo, len(versions), strings.Join(kept, ", "))
+			if len(dropped) == 0 {
+				fmt.Println("Nothing to prune.")
+				return nil
+			}
+
+			// Everything else the mirror holds for the stack is protected
+			// like a kept version's images.
+			stack := stackOptions{operatorChartVersion: operatorChartVersion, telemetry: telemetry, skipManaged: skipManaged}
+			set := mirrorTargets(ctx, registry, "", stack, extras, layout, insecure, creds)
+			if set.chartErr != nil {
+				return fmt.Errorf("%w; needed to protect the operator stack's images", set.chartErr)
+			}
+
+			plan, err := buildPrunePlan(ctx, manifestRepo, kept, dropped, set.refs, insecure, creds)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("\nDropping %d version(s): %s\n", len(plan.versions), strings.Join(plan.versions, ", "))
+			fmt.Printf("%d image(s) referenced only by them:\n", len(plan.images))
+			for _, img := range plan.images {
+				fmt.Printf("  %s\n", img)
+			}
+			if dryRun {
+				return nil
+			}
+
+			fmt.Println()
+			var deleted, failed int
+			deleteAll := func(refs []string) {
+				for _, ref := range refs {
+					fmt.Printf("→ delete %s ... ", ref)
+					if err := deleteManifest(ctx, ref, insecure, creds); err != nil {
+						fmt.Printf("✗ %v\n", err)
+						failed++
+						continue
+					}
+					fmt.Println("✓")
+					deleted++
+				}
+			}
+			// Images first: if a delete fails, the version's manifest stays and a
+			// re-run can still find what it referenced.
+			deleteAll(plan.images)
+			if failed > 0 {
+				return fmt.Errorf("%d image(s) failed to delete; server manifests left in place so a re-run can retry", failed)
+			}
+			var manifests []string
+			for _, v := range plan.versions {
+				manifests = append(manifests, manifestRepo+":"+v)
+			}
+			deleteAll(manifests)
+
+			fmt.Printf("\n%d deleted, %d failed\n", deleted, failed)
+			if failed > 0 {
+				return fmt.Errorf("%d artifact(s) failed to delete", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&registry, "registry", "", "Mirror registry to prune, e.g. harbor.mycorp.internal (required)")
+	cmd.Flags().IntVar(&keep, "keep", 3, "Number of newest W&B versions to keep")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be deleted without deleting")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when contacting the registry")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version that was mirrored (must match 'wsm registry mirror')")
+	cmd.Flags().BoolVar(&skipManaged, "skip-managed-images", false, "The managed-service images were not mirrored (match the flag you mirrored with)")
+	cmd.Flags().StringVar(&observabilityMode, "observability-mode", operator.TelemetryModeOff, "Telemetry mode the operator is deployed with (match the flag you mirrored with)")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", false)
+	extraOpts.register(cmd.Flags())
+	return cmd
+}
+
+// listManifestVersions returns the semver tags of the server-manifest
+// repository, newest first, and the tags that aren't versions.
+func listManifestVersions(ctx context.Context, repo *remote.Repository) (versions, skipped []string, err error) {
+	var parsed []*semver.Version
+	byVersion := map[*semver.Version]string{}
+	err = repo.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			v, err := semver.NewVersion(tag)
+			if err != nil {
+				skipped = append(skipped, tag)
+				continue
+			}
+			parsed = append(parsed, v)
+			byVersion[v] = tag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("list tags of %s: %w", repo.Reference, err)
+	}
+	sort.Sort(sort.Reverse(semver.Collection(parsed)))
+	for _, v := range parsed {
+		versions = append(versions, byVersion[v])
+	}
+	return versions, skipped, nil
+}
+
+// versionKey normalizes a W&B version so a CR's spec.wandb.version matches
+// its server-manifest tag however either spells it (v1.2.3 vs 1.2.3).
+// Anything that isn't a version is its own key.
+func versionKey(version string) string {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return version
+	}
+	return v.String()
+}
+
+type prunePlan struct {
+	versions []string // server-manifest tags to delete
+	images   []string // repo@digest of images only those versions reference
+}
+
+// buildPrunePlan reads every version's server manifest back out of the mirror
+// and works out which images are safe to delete. Images of the kept versions
+// and the other references in protect are pinned to digests first, since
+// deleting a dropped image by digest removes every tag on that digest. A kept
+// manifest or reference that can't be read aborts the prune, since its images
+// can't be protected; a dropped version that can't be read in full is skipped
+// (tag and images kept) with a warning.
+func buildPrunePlan(ctx context.Context, manifestRepo string, kept, dropped, protect []string, insecure bool, creds registryauth.Provider) (*prunePlan, error) {
+	keptRefs := map[string]bool{}
+	keptDigests := map[string]bool{} // repo@digest
+	keepRef := func(ref string) error {
+		keptRefs[ref] = true
+		pinned, err := resolveDigest(ctx, ref, insecure, creds)
+		switch {
+		case errors.Is(err, errdef.ErrNotFound):
+			// Not in the mirror, so no digest to share.
+			return nil
+		case err != nil:
+			return fmt.Errorf("resolve %s (needed to protect it): %w", ref, err)
+		}
+		keptDigests[pinned] = true
+		return nil
+	}
+	for _, v := range kept {
+		refs, err := manifestImageRefs(ctx, manifestRepo, v, insecure, creds)
+		if err != nil {
+			return nil, fmt.Errorf("read kept version %s (needed to protect its images): %w", v, err)
+		}
+		for _, ref := range refs {
+			if err := keepRef(ref); err != nil {
+				return nil, fmt.Errorf("kept version %s: %w", v, err)
+			}
+		}
+	}
+	for _, ref := range protect {
+		if err := keepRef(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &prunePlan{}
+	seen := map[string]bool{}
+	for _, v := range dropped {
+		images, err := droppedImages(ctx, manifestRepo, v, keptRefs, keptDigests, insecure, creds)
+		if err != nil {
+			fmt.Printf("⚠ skipping %s: %v\n", v, err)
+			continue
+		}
+		plan.versions = append(plan.versions, v)
+		for _, pinned := range images {
+			if !seen[pinned] {
+				seen[pinned] = true
+				plan.images = append(plan.images, pinned)
+			}
+		}
+	}
+	sort.Strings(plan.images)
+	return plan, nil
+}
+
+// droppedImages returns the pinned images of dropped version v that nothing
+// kept references. Images already gone from the mirror are left out; any
+// other failure to resolve one fails the version.
+func droppedImages(ctx context.Context, manifestRepo, v string, keptRefs, keptDigests map[string]bool, insecure bool, creds registryauth.Provider) ([]string, error) {
+	refs, err := manifestImageRefs(ctx, manifestRepo, v, insecure, creds)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, ref := range refs {
+		if keptRefs[ref] {
+			continue
+		}
+		pinned, err := resolveDigest(ctx, ref, insecure, creds)
+		switch {
+		case errors.Is(err, errdef.ErrNotFound):
+			// Already gone (or never mirrored); nothing to delete.
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("resolve %s: %w", ref, err)
+		}
+		if !keptDigests[pinned] {
+			images = append(images, pinned)
+		}
+	}
+	return images, nil
+}
+
+// manifestImageRefs returns the (already mirror-rewritten) image references in
+// the mirrored server manifest for version.
+func manifestImageRefs(ctx context.Context, manifestRepo, version string, insecure bool, creds registryauth.Provider) ([]string, error) {
+	files, err := pullManifestYAMLFrom(ctx, manifestRepo, version, insecure, creds)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := collectManifestImages(files)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(refs))
+	for _, r := range refs {
+		out = append(out, r.GetImage(""))
+	}
+	return out, nil
+}
+
+// resolveDigest pins ref to repo@digest.
+func resolveDigest(ctx context.Context, ref string, insecure bool, creds registryauth.Provider) (string, error) {
+	repoRef, tag := referenceTag(ref)
+	repo, err := remote.NewRepository(repoRef)
+	if err != nil {
+		return "", err
+	}
+	repo.Client = registryAuthClient(insecure, creds)
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return "", err
+	}
+	return repoRef + "@" + desc.Digest.String(), nil
+}
+
+// deleteManifest deletes the manifest ref resolves to. Deleting by digest
+// removes every tag pointing at it.
+func deleteManifest(ctx context.Context, ref string, insecure bool, creds registryauth.Provider) error {
+	repoRef, tag := referenceTag(ref)
+	repo, err := remote.NewRepository(repoRef)
+	if err != nil {
+		return err
+	}
+	repo.Client = registryAuthClient(insecure, creds)
+	desc, err := repo.Resolve(ctx, tag)
+	if err != nil {
+		return err
+	}
+	return repo.Delete(ctx, desc)
+}
@@ -1,42 +1,58 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/containers/image/v5/copy"
-	"github.com/containers/image/v5/docker"
-	dockerarchive "github.com/containers/image/v5/docker/archive"
 	"github.com/containers/image/v5/signature"
-	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1remote "github.com/google/go-containerregistry/pkg/v1/remote"
+	v1tarball "github.com/google/go-containerregistry/pkg/v1/tarball"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry/remote"
 )
 
 func registryPushCmd() *cobra.Command {
 	var (
-		registry  string
-		bundleDir string
-		insecure  bool
-		dryRun    bool
+		registry   string
+		bundleDir  string
+		insecure   bool
+		dryRun     bool
+		layoutOpts layoutFlags
+		credOpts   credentialFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   "push",
 		Short: "Push images from a bundle directory into your mirrored registry",
-		Long: `Walk a bundle produced by 'wsm download' and push every saved image into your
-mirrored registry. Each source image is re-tagged using the same translation
-rule as 'wsm registry check' / 'wsm registry values'.
+		Long: `Walk a bundle produced by 'wsm download' and push every saved image (and any
+extra OCI chart it was downloaded with) into your mirrored registry. Each
+image.tgz may be a docker-archive (docker save, optionally gzipped) or an OCI
+archive. Layers are streamed straight from the tarball, and blobs the registry
+already has are skipped, so re-running after a partial push only uploads
+what's missing. Each source image is re-tagged using the same translation
+rule (--layout) as 'wsm registry check' / 'wsm registry values'.
 
 The --registry flag is YOUR private container registry — the destination
 you're mirroring W&B's images into. It's the same hostname you'd pass to
 'wsm registry check' to verify the mirror afterwards. wsm does not discover
 or provision it; you supply it.
 
-Auth is read from your Docker config (~/.docker/config.json) by default.
+Auth comes from --registry-username/--registry-password-stdin, then the
+WSM_REGISTRY_USERNAME/WSM_REGISTRY_PASSWORD environment variables, then your
+Docker config (~/.docker/config.json, including credential helpers).
 Use --insecure for plain-HTTP or self-signed registries.`,
 		Example: `  # Push everything from ./bundle to a private Harbor.
   wsm registry push --registry harbor.mycorp.internal
@@ -50,6 +66,14 @@ Use --insecure for plain-HTTP or self-signed registries.`,
 			if registry == "" {
 				return fmt.Errorf("--registry is required (the hostname of your mirror, e.g. harbor.example.com)")
 			}
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(registry)
+			if err != nil {
+				return err
+			}
 
 			imagesDir := filepath.Join(bundleDir, "images")
 			info, err := os.Stat(imagesDir)
@@ -68,63 +92,64 @@ Use --insecure for plain-HTTP or self-signed registries.`,
 				return fmt.Errorf("no image.tgz files found under %q", imagesDir)
 			}
 
+			charts, err := findOCICharts(filepath.Join(bundleDir, ociChartsDir))
+			if err != nil {
+				return err
+			}
+
 			fmt.Printf("Found %d images in %s\n", len(tarballs), imagesDir)
+			if len(charts) > 0 {
+				fmt.Printf("Found %d extra charts in %s\n", len(charts), filepath.Join(bundleDir, ociChartsDir))
+			}
 			if dryRun {
 				fmt.Println("(dry-run) source → target")
 			} else {
 				fmt.Printf("Pushing to %s\n\n", registry)
 			}
 
-			policyCtx, err := newAcceptAllPolicy()
-			if err != nil {
-				return fmt.Errorf("failed to init signature policy: %w", err)
-			}
-			defer func() { _ = policyCtx.Destroy() }()
-
-			sysCtx := &types.SystemContext{}
-			if insecure {
-				sysCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
-			}
-
 			ctx := context.Background()
 			var pushed, failed int
-			var tooLarge []tarball // images that need a manual docker push
 			for _, t := range tarballs {
-				target := translate(t.source, registry)
+				target := layout.Image(registry, t.source)
 				if dryRun {
 					fmt.Printf("  %s → %s\n", t.source, target)
 					continue
 				}
 
 				fmt.Printf("→ %s\n  → %s ... ", t.source, target)
-				err := pushTarball(ctx, t.path, target, sysCtx, policyCtx)
-				if err == nil {
-					fmt.Println("✓")
-					pushed++
+				if err := pushTarball(ctx, t.path, t.source, target, insecure, creds); err != nil {
+					fmt.Printf("✗ %v\n", err)
+					failed++
 					continue
 				}
+				fmt.Println("✓")
+				pushed++
+			}
 
-				failed++
-				if isManifestTooLargeErr(err) {
-					fmt.Println("✗ image manifest exceeds containers/image 4 MiB cap (deferred to manual push)")
-					tooLarge = append(tooLarge, t)
+			for _, c := range charts {
+				target := layout.Image(registry, c.source)
+				if dryRun {
+					fmt.Printf("  %s → %s\n", c.source, target)
+					continue
+				}
+				fmt.Printf("→ %s\n  → %s ... ", c.source, target)
+				if err := pushOCIChart(ctx, c.path, c.source, target, insecure, creds); err != nil {
+					fmt.Printf("✗ %v\n", err)
+					failed++
 					continue
 				}
-				fmt.Printf("✗ %v\n", err)
+				fmt.Println("✓")
+				pushed++
 			}
 
 			if dryRun {
 				return nil
 			}
 
-			fmt.Printf("\n%d total — %d pushed, %d failed\n", len(tarballs), pushed, failed)
-
-			if len(tooLarge) > 0 {
-				printManualPushInstructions(tooLarge, registry)
-			}
+			fmt.Printf("\n%d total — %d pushed, %d failed\n", len(tarballs)+len(charts), pushed, failed)
 
 			if failed > 0 {
-				return fmt.Errorf("%d image(s) failed to push", failed)
+				return fmt.Errorf("%d artifact(s) failed to push", failed)
 			}
 			return nil
 		},
@@ -134,6 +159,8 @@ Use --insecure for plain-HTTP or self-signed registries.`,
 	cmd.Flags().StringVar(&bundleDir, "bundle", "./bundle", "Path to the bundle directory produced by 'wsm download'")
 	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when contacting the registry")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print source → target translations without pushing")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", false)
 	return cmd
 }
 
@@ -163,107 +190,190 @@ func findImageTarballs(imagesDir string) ([]tarball, error) {
 	return out, err
 }
 
-// isManifestTooLargeErr matches the hardcoded 4 MiB cap that containers/image
-// applies when sniffing manifest MIME type. The cap isn't configurable via
-// SystemContext; we have to fall back to a manual docker push for these images.
-func isManifestTooLargeErr(err error) bool {
-	if err == nil {
-		return false
+func newAcceptAllPolicy() (*signature.PolicyContext, error) {
+	policy := &signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
 	}
-	msg := err.Error()
-	return strings.Contains(msg, "exceeded maximum allowed size") ||
-		strings.Contains(msg, "MaxManifestBodySize")
+	return signature.NewPolicyContext(policy)
 }
 
-func printManualPushInstructions(images []tarball, registry string) {
-	plural := "image"
-	if len(images) > 1 {
-		plural = "images"
+// pushTarball pushes one saved image to targetImage. A docker-archive goes
+// through go-containerregistry, which has no manifest or config size cap
+// (W&B's megabinary config is over 4 MiB); an OCI archive goes through oras.
+// Both read layers from the tarball on demand rather than extracting it, and
+// both skip blobs the registry already has.
+func pushTarball(ctx context.Context, tarballPath, source, targetImage string, insecure bool, creds registryauth.Provider) error {
+	format, err := sniffTarball(tarballPath)
+	if err != nil {
+		return err
 	}
-
-	fmt.Println()
-	fmt.Printf("⚠  %d %s could not be pushed via wsm because their manifest exceeds\n", len(images), plural)
-	fmt.Println("   the 4 MiB sniff-cap baked into the containers/image library.")
-	fmt.Println("   Push them by hand using docker — same registry, same tag:")
-	fmt.Println()
-	for _, t := range images {
-		target := translate(t.source, registry)
-		fmt.Printf("     # %s\n", t.source)
-		fmt.Printf("     docker load -i %s\n", t.path)
-		fmt.Printf("     docker tag %s %s\n", t.source, target)
-		fmt.Printf("     docker push %s\n", target)
-		fmt.Println()
+	switch format {
+	case tarballDockerArchive:
+		return pushDockerArchive(ctx, tarballPath, source, targetImage, insecure, creds)
+	case tarballOCIArchive:
+		return pushOCIArchive(ctx, tarballPath, source, targetImage, insecure, creds)
+	default:
+		return fmt.Errorf("%s is neither a docker-archive (manifest.json) nor an OCI archive (oci-layout)", tarballPath)
 	}
-	fmt.Println("   Then re-run 'wsm registry check' to confirm everything landed.")
-	fmt.Println()
 }
 
-func colonFreeAlias(tarballPath string) (string, func(), error) {
-	absSrc, err := filepath.Abs(tarballPath)
+type tarballFormat int
+
+const (
+	tarballUnknown tarballFormat = iota
+	tarballDockerArchive
+	tarballOCIArchive
+)
+
+// sniffTarball reads the tar headers (only) to tell a docker-archive from an
+// OCI archive. Archives from Docker 25+ carry both files; manifest.json wins
+// because the docker-archive path also handles gzipped tarballs.
+func sniffTarball(path string) (tarballFormat, error) {
+	rc, err := openTarball(path)()
 	if err != nil {
-		return "", func() {}, err
+		return tarballUnknown, err
 	}
-	tmpDir, err := os.MkdirTemp("", "wsm-push-")
-	if err != nil {
-		return "", func() {}, err
+	defer func() { _ = rc.Close() }()
+
+	format := tarballUnknown
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return format, nil
+		}
+		if err != nil {
+			return tarballUnknown, fmt.Errorf("read %s: %w", path, err)
+		}
+		switch strings.TrimPrefix(hdr.Name, "./") {
+		case "manifest.json":
+			return tarballDockerArchive, nil
+		case ocispec.ImageLayoutFile:
+			format = tarballOCIArchive
+		}
 	}
-	cleanup := func() { _ = os.RemoveAll(tmpDir) }
+}
 
-	if strings.Contains(tmpDir, ":") {
-		cleanup()
-		return "", func() {}, fmt.Errorf("temp dir %q contains ':' which the docker-archive transport rejects", tmpDir)
+// openTarball returns an opener for the uncompressed tar stream of path,
+// transparently gunzipping a gzipped file. go-containerregistry re-opens the
+// tarball for every layer it reads, so nothing is held in memory or on disk.
+func openTarball(path string) v1tarball.Opener {
+	return func() (io.ReadCloser, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		br := bufio.NewReader(f)
+		magic, err := br.Peek(2)
+		if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			return readCloser{Reader: gz, close: func() error { _ = gz.Close(); return f.Close() }}, nil
+		}
+		return readCloser{Reader: br, close: f.Close}, nil
 	}
+}
 
-	linkPath := filepath.Join(tmpDir, "image.tgz")
-	if err := os.Symlink(absSrc, linkPath); err != nil {
-		cleanup()
-		return "", func() {}, fmt.Errorf("create symlink: %w", err)
-	}
-	return linkPath, cleanup, nil
+type readCloser struct {
+	io.Reader
+	close func() error
 }
 
-func newAcceptAllPolicy() (*signature.PolicyContext, error) {
-	policy := &signature.Policy{
-		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+func (r readCloser) Close() error { return r.close() }
+
+func pushDockerArchive(ctx context.Context, path, source, targetImage string, insecure bool, creds registryauth.Provider) error {
+	opener := openTarball(path)
+	manifest, err := v1tarball.LoadManifest(opener)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	// A single-image archive needs no tag lookup (and docker save of a
+	// digest reference records no RepoTags); otherwise pick source's entry.
+	var tag *name.Tag
+	if len(manifest) > 1 {
+		t, err := name.NewTag(source)
+		if err != nil {
+			return fmt.Errorf("%s holds %d images and %q is not a tag to pick one by", path, len(manifest), source)
+		}
+		tag = &t
+	}
+	img, err := v1tarball.Image(opener, tag)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", path, err)
 	}
-	return signature.NewPolicyContext(policy)
-}
 
-// pushTarball copies a docker-archive tarball into the destination registry
-// reference using containers/image — no shell-out to docker required.
-func pushTarball(
-	ctx context.Context,
-	tarballPath string,
-	targetImage string,
-	sysCtx *types.SystemContext,
-	policyCtx *signature.PolicyContext,
-) error {
-	// containers/image rejects any docker-archive path containing ':', even
-	// via NewReader → List() (the internal newReference validates the path
-	// regardless of caller). Our bundle layout embeds image tags in the path
-	// (wandb/controller:1.22.0/image.tgz), so we symlink to a colon-free
-	// temp path and hand the library that instead.
-	linkPath, cleanup, err := colonFreeAlias(tarballPath)
+	var nameOpts []name.Option
+	if insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	dstRef, err := name.ParseReference(targetImage, nameOpts...)
 	if err != nil {
-		return fmt.Errorf("alias tarball: %w", err)
+		return fmt.Errorf("parse target reference %q: %w", targetImage, err)
+	}
+	opts := []v1remote.Option{
+		v1remote.WithAuthFromKeychain(registryauth.Keychain(creds)),
+		v1remote.WithContext(ctx),
 	}
-	defer cleanup()
+	if insecure {
+		opts = append(opts, v1remote.WithTransport(insecureHTTPTransport()))
+	}
+	return v1remote.Write(dstRef, img, opts...)
+}
 
-	srcRef, err := dockerarchive.NewReference(linkPath, nil)
+func pushOCIArchive(ctx context.Context, path, source, targetImage string, insecure bool, creds registryauth.Provider) error {
+	store, err := oci.NewFromTar(ctx, path)
 	if err != nil {
-		return fmt.Errorf("build archive reference: %w", err)
+		return fmt.Errorf("open OCI archive %s (gzipped OCI archives must be decompressed first): %w", path, err)
+	}
+	srcRef, err := ociArchiveRef(ctx, store, source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
 	}
 
-	dstRef, err := docker.ParseReference("//" + targetImage)
+	dstRepo, dstTag := referenceTag(targetImage)
+	dst, err := remote.NewRepository(dstRepo)
 	if err != nil {
-		return fmt.Errorf("parse target reference %q: %w", targetImage, err)
+		return fmt.Errorf("init target repo: %w", err)
 	}
+	dst.Client = registryAuthClient(insecure, creds)
+	dst.PlainHTTP = insecure && isPlainHTTPRegistry(ctx, dst)
 
-	if _, err := copy.Image(ctx, policyCtx, dstRef, srcRef, &copy.Options{
-		SourceCtx:      sysCtx,
-		DestinationCtx: sysCtx,
-	}); err != nil {
-		return err
+	if _, err := oras.Copy(ctx, store, srcRef, dst, dstTag, oras.DefaultCopyOptions); err != nil {
+		return fmt.Errorf("copy to mirror: %w", err)
 	}
 	return nil
 }
+
+// ociArchiveRef picks the manifest to push from an OCI archive: source's tag
+// when the archive carries it, otherwise the archive's only manifest.
+func ociArchiveRef(ctx context.Context, store *oci.ReadOnlyStore, source string) (string, error) {
+	if _, tag := referenceTag(source); tag != "" {
+		if _, err := store.Resolve(ctx, tag); err == nil {
+			return tag, nil
+		}
+	}
+	digests := map[string]bool{}
+	err := store.Tags(ctx, "", func(tags []string) error {
+		for _, t := range tags {
+			desc, err := store.Resolve(ctx, t)
+			if err != nil {
+				return err
+			}
+			digests[desc.Digest.String()] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(digests) != 1 {
+		return "", fmt.Errorf("OCI archive holds %d manifests and none is tagged %q", len(digests), source)
+	}
+	for d := range digests {
+		return d, nil
+	}
+	return "", nil
+}
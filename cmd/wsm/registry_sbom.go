@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	v1remote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"github.com/wandb/wsm/pkg/sbom"
+	"github.com/wandb/wsm/pkg/utils"
+)
+
+// maxAttachedSBOMSize bounds how much of an attached SBOM blob is read.
+const maxAttachedSBOMSize = 256 << 20
+
+// BuildKit stores SBOM attestations as extra manifests in the image index,
+// annotated with the platform manifest they describe.
+const (
+	attestationTypeAnnotation   = "vnd.docker.reference.type"
+	attestationDigestAnnotation = "vnd.docker.reference.digest"
+	attestationManifestType     = "attestation-manifest"
+	inTotoPredicateAnnotation   = "in-toto.io/predicate-type"
+)
+
+// ---------------- wsm registry sbom ----------------
+
+func registrySBOMCmd() *cobra.Command {
+	var (
+		registry             string
+		insecure             bool
+		operatorChartVersion string
+		wandbVersion         string
+		skipManaged          bool
+		observabilityMode    string
+		format               string
+		output               string
+		platform             string
+		ignoreAttached       bool
+		layoutOpts           layoutFlags
+		credOpts             credentialFlags
+		extraOpts            extraFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Write one merged SBOM covering every artifact a W&B version mirrors",
+		Long: `Produce the component inventory of a W&B release: one CycloneDX document
+  (or SPDX with --format spdx) listing every artifact 'wsm registry mirror'
+  copies for --wandb-version — the operator stack, the managed-service images,
+  every application image the server manifest references, and your extras —
+  each with its digest and the packages and licenses inside it.
+
+  For each image, an SBOM already attached to it is used when there is one: an
+  OCI referrer with a CycloneDX or SPDX artifact type, or a BuildKit SBOM
+  attestation. Otherwise the image's filesystem is read and cataloged, as
+  'wsm registry scan' does. Charts and the server manifest are listed with their
+  digest only.
+
+  By default everything is read from upstream, so the inventory can go to
+  procurement before anything is mirrored. With --registry it is read from your
+  mirror instead, using the same flags as 'wsm registry check'.`,
+		Example: `  wsm registry sbom --wandb-version 0.82.2 -o wandb-0.82.2.cdx.json
+  wsm registry sbom --wandb-version 0.82.2 --registry myreg.example.com --format spdx -o wandb-0.82.2.spdx.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if wandbVersion == "" {
+				return fmt.Errorf("--wandb-version is required")
+			}
+			if format != sbom.FormatCycloneDX && format != sbom.FormatSPDX {
+				return fmt.Errorf("--format: want one of %s, got %q", strings.Join(sbom.Formats, ", "), format)
+			}
+			if output == "" {
+				output = "wandb-" + wandbVersion + sbom.FileExtension(format)
+			}
+			plat, err := v1.ParsePlatform(platform)
+			if err != nil {
+				return fmt.Errorf("--platform: %w", err)
+			}
+			registry = strings.TrimRight(registry, "/")
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(registry)
+			if err != nil {
+				return err
+			}
+			extras, err := extraOpts.extras()
+			if err != nil {
+				return err
+			}
+			telemetry, err := stackTelemetry(observabilityMode)
+			if err != nil {
+				return err
+			}
+			stack := stackOptions{operatorChartVersion: operatorChartVersion, telemetry: telemetry, skipManaged: skipManaged}
+			ctx := context.Background()
+
+			var refs []string
+			if registry != "" {
+				set := mirrorTargets(ctx, registry, wandbVersion, stack, extras, layout, insecure, creds)
+				if set.chartErr != nil {
+					return set.chartErr
+				}
+				if set.manifestErr != nil {
+					return set.manifestErr
+				}
+				refs = set.refs
+			} else {
+				if refs, err = mirrorSources(ctx, wandbVersion, stack, extras, creds); err != nil {
+					return err
+				}
+				insecure = false
+			}
+
+			fmt.Printf("Inventorying %d artifacts for W&B %s\n\n", len(refs), wandbVersion)
+			fmt.Printf("%-10s  %8s  %s\n", "SBOM", "PACKAGES", "REFERENCE")
+			doc := sbom.Document{Name: "wandb " + wandbVersion, ToolVersion: version}
+			var failed int
+			for _, ref := range refs {
+				inv, err := inventory(ctx, ref, plat, insecure, creds, ignoreAttached)
+				if err != nil {
+					failed++
+					fmt.Printf("%-10s  %8s  %s\n", "error", "-", ref)
+					fmt.Printf("            └─ %v\n", err)
+					continue
+				}
+				source := inv.Origin
+				switch {
+				case inv.Artifact != "":
+					source = "artifact"
+				case strings.HasPrefix(source, "attached"):
+					source = "attached"
+				}
+				fmt.Printf("%-10s  %8d  %s\n", source, len(inv.Packages), ref)
+				doc.Images = append(doc.Images, inv)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d artifact(s) could not be inventoried; no SBOM written", failed)
+			}
+
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			if err := sbom.Write(f, format, doc); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+
+			printLicenseSummary(doc.Images)
+			fmt.Printf("\nSBOM for %d artifacts written to %s\n", len(doc.Images), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "W&B server version to inventory (required)")
+	cmd.Flags().StringVar(&registry, "registry", "", "Read everything from this mirror instead of upstream")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when contacting --registry")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version to include (must match 'wsm registry mirror')")
+	cmd.Flags().BoolVar(&skipManaged, "skip-managed-images", false, "Leave out the managed-service operator + data-plane images")
+	cmd.Flags().StringVar(&observabilityMode, "observability-mode", operator.TelemetryModeOff, "Telemetry mode to include the images of (off, full, forward)")
+	cmd.Flags().StringVar(&format, "format", sbom.FormatCycloneDX, "SBOM format: "+strings.Join(sbom.Formats, ", "))
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write the SBOM to (default wandb-<version>.cdx.json, or .spdx.json)")
+	cmd.Flags().StringVar(&platform, "platform", "linux/amd64", "Platform to inventory in multi-arch images")
+	cmd.Flags().BoolVar(&ignoreAttached, "ignore-attached", false, "Catalog every image's filesystem even when an SBOM is attached to it")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", true)
+	extraOpts.register(cmd.Flags())
+	return cmd
+}
+
+// mirrorSources returns the upstream references 'wsm registry mirror' copies
+// for these flags, sorted and de-duplicated: the source side of the set
+// mirrorTargets returns.
+func mirrorSources(ctx context.Context, wandbVersion string, stack stackOptions, extras mirror.Extras, creds registryauth.Provider) ([]string, error) {
+	refs, err := stackSources(ctx, stack, nil)
+	if err != nil {
+		return nil, err
+	}
+	refs = append(refs, extras.Sources()...)
+	if wandbVersion != "" {
+		refs = append(refs, serverManifestUpstream+":"+wandbVersion)
+		files, err := pullManifestYAML(ctx, wandbVersion, creds)
+		if err != nil {
+			return nil, fmt.Errorf("read server manifest %s:%s: %w", serverManifestUpstream, wandbVersion, err)
+		}
+		images, err := collectManifestImages(files)
+		if err != nil {
+			return nil, fmt.Errorf("parse server manifest %s:%s: %w", serverManifestUpstream, wandbVersion, err)
+		}
+		for _, r := range images {
+			refs = append(refs, r.GetImage(""))
+		}
+	}
+	refs = utils.RemoveDuplicates(refs)
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// remoteImage is one reference resolved to the manifest for a platform.
+type remoteImage struct {
+	ref    string
+	repo   name.Repository
+	opts   []v1remote.Option
+	digest v1.Hash // platform image manifest
+	index  *v1.IndexManifest
+	// indexDigest is set when ref named a multi-arch index.
+	indexDigest *v1.Hash
+	img         v1.Image
+	// artifact is the config media type of a non-image artifact; img is nil.
+	artifact string
+}
+
+// resolveImage reads ref's manifest, picking plat out of a multi-arch index.
+func resolveImage(ctx context.Context, ref string, plat *v1.Platform, insecure bool, creds registryauth.Provider) (*remoteImage, error) {
+	var nameOpts []name.Option
+	if insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	r, err := name.ParseReference(ref, nameOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", ref, err)
+	}
+	opts := []v1remote.Option{
+		v1remote.WithAuthFromKeychain(registryauth.Keychain(creds)),
+		v1remote.WithContext(ctx),
+		v1remote.WithPlatform(*plat),
+	}
+	if insecure {
+		opts = append(opts, v1remote.WithTransport(insecureHTTPTransport()))
+	}
+
+	desc, err := v1remote.Get(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out := &remoteImage{ref: ref, repo: r.Context(), opts: opts, digest: desc.Digest}
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		if out.index, err = idx.IndexManifest(); err != nil {
+			return nil, err
+		}
+		d := desc.Digest
+		out.indexDigest = &d
+	} else {
+		m, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+		if err != nil {
+			return nil, err
+		}
+		if !m.Config.MediaType.IsConfig() {
+			out.artifact = string(m.Config.MediaType)
+			return out, nil
+		}
+	}
+	if out.img, err = desc.Image(); err != nil {
+		return nil, err
+	}
+	if out.digest, err = out.img.Digest(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// inventory returns ref's packages: from an SBOM attached to it when there
+// is one (unless ignoreAttached), otherwise by cataloging its filesystem.
+// Non-image artifacts come back with no packages.
+func inventory(ctx context.Context, ref string, plat *v1.Platform, insecure bool, creds registryauth.Provider, ignoreAttached bool) (*sbom.Inventory, error) {
+	ri, err := resolveImage(ctx, ref, plat, insecure, creds)
+	if err != nil {
+		return nil, err
+	}
+	if ri.artifact != "" {
+		return &sbom.Inventory{Image: ref, Digest: ri.digest.String(), Artifact: ri.artifact}, nil
+	}
+	var inv *sbom.Inventory
+	if !ignoreAttached {
+		if inv, err = ri.attachedSBOM(); err != nil {
+			return nil, err
+		}
+	}
+	if inv == nil {
+		if inv, err = sbom.Catalog(ri.img); err != nil {
+			return nil, err
+		}
+	}
+	inv.Image, inv.Digest = ref, ri.digest.String()
+	return inv, nil
+}
+
+// attachedSBOM looks for an SBOM attached to the image, first as an OCI
+// referrer of the platform manifest or its index, then as a BuildKit
+// attestation in the index. It returns nil when there is none.
+func (ri *remoteImage) attachedSBOM() (*sbom.Inventory, error) {
+	subjects := []v1.Hash{ri.digest}
+	if ri.indexDigest != nil {
+		subjects = append(subjects, *ri.indexDigest)
+	}
+	for _, subject := range subjects {
+		idx, err := v1remote.Referrers(ri.repo.Digest(subject.String()), ri.opts...)
+		if err != nil {
+			// Registries without the referrers API or tag fallback: treat
+			// as nothing attached.
+			continue
+		}
+		im, err := idx.IndexManifest()
+		if err != nil {
+			continue
+		}
+		for _, d := range im.Manifests {
+			if !sbom.IsSBOMMediaType(d.ArtifactType) {
+				continue
+			}
+			art, err := v1remote.Image(ri.repo.Digest(d.Digest.String()), ri.opts...)
+			if err != nil {
+				return nil, fmt.Errorf("fetch attached SBOM %s: %w", d.Digest, err)
+			}
+			inv, err := firstSBOMLayer(art, func(v1.Descriptor) bool { return true })
+			if err != nil {
+				return nil, fmt.Errorf("read attached SBOM %s: %w", d.Digest, err)
+			}
+			if inv != nil {
+				inv.Origin = fmt.Sprintf("attached %s %s", d.ArtifactType, d.Digest)
+				return inv, nil
+			}
+		}
+	}
+
+	if ri.index == nil {
+		return nil, nil
+	}
+	for _, d := range ri.index.Manifests {
+		if d.Annotations[attestationTypeAnnotation] != attestationManifestType ||
+			d.Annotations[attestationDigestAnnotation] != ri.digest.String() {
+			continue
+		}
+		att, err := v1remote.Image(ri.repo.Digest(d.Digest.String()), ri.opts...)
+		if err != nil {
+			return nil, fmt.Errorf("fetch attestation %s: %w", d.Digest, err)
+		}
+		inv, err := firstSBOMLayer(att, func(l v1.Descriptor) bool {
+			return sbom.IsSBOMMediaType(l.Annotations[inTotoPredicateAnnotation])
+		})
+		if err != nil {
+			return nil, fmt.Errorf("read attestation %s: %w", d.Digest, err)
+		}
+		if inv != nil {
+			inv.Origin = fmt.Sprintf("attached attestation %s", d.Digest)
+			return inv, nil
+		}
+	}
+	return nil, nil
+}
+
+// firstSBOMLayer parses the first layer of img that match accepts.
+func firstSBOMLayer(img v1.Image, match func(v1.Descriptor) bool) (*sbom.Inventory, error) {
+	m, err := img.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range m.Layers {
+		if !match(l) {
+			continue
+		}
+		layer, err := img.LayerByDigest(l.Digest)
+		if err != nil {
+			return nil, err
+		}
+		// The blob is the document itself; Compressed returns it unaltered.
+		rc, err := layer.Compressed()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxAttachedSBOMSize))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		return sbom.Parse(data)
+	}
+	return nil, nil
+}
+
+// printLicenseSummary prints how many packages carry each license across all
+// images, most common first.
+func printLicenseSummary(images []*sbom.Inventory) {
+	counts := map[string]int{}
+	var total int
+	for _, inv := range images {
+		for _, p := range inv.Packages {
+			total++
+			if len(p.Licenses) == 0 {
+				counts["(none declared)"]++
+				continue
+			}
+			counts[strings.Join(p.Licenses, " AND ")]++
+		}
+	}
+	if total == 0 {
+		return
+	}
+	licenses := make([]string, 0, len(counts))
+	for l := range counts {
+		licenses = append(licenses, l)
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		if counts[licenses[i]] != counts[licenses[j]] {
+			return counts[licenses[i]] > counts[licenses[j]]
+		}
+		return licenses[i] < licenses[j]
+	})
+	fmt.Printf("\n%8s  %s\n", "PACKAGES", "LICENSE")
+	for _, l := range licenses {
+		fmt.Printf("%8d  %s\n", counts[l], l)
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/operator"
+	"github.com/wandb/wsm/pkg/registryauth"
+	"github.com/wandb/wsm/pkg/sbom"
+	"github.com/wandb/wsm/pkg/vulndb"
+)
+
+// scanReport is the --output JSON document.
+type scanReport struct {
+	Generated string            `json:"generated"`
+	Registry  string            `json:"registry"`
+	Database  scanDatabase      `json:"database"`
+	FailOn    string            `json:"failOn,omitempty"`
+	Summary   map[string]int    `json:"summary"`
+	Images    []scanImageResult `json:"images"`
+}
+
+type scanDatabase struct {
+	Path       string `json:"path"`
+	Advisories int    `json:"advisories"`
+}
+
+type scanImageResult struct {
+	Reference string        `json:"reference"`
+	Digest    string        `json:"digest,omitempty"`
+	Distro    string        `json:"distro,omitempty"`
+	Packages  int           `json:"packages"`
+	SBOM      string        `json:"sbom,omitempty"`
+	Skipped   string        `json:"skipped,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Findings  []scanFinding `json:"findings"`
+	// Unevaluated lists advisories for the image's packages that could not
+	// be matched either way.
+	Unevaluated []scanUnevaluated `json:"unevaluated,omitempty"`
+}
+
+type scanUnevaluated struct {
+	ID      string `json:"id"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+type scanFinding struct {
+	ID           string   `json:"id"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Severity     string   `json:"severity"`
+	Package      string   `json:"package"`
+	Type         string   `json:"type"`
+	Version      string   `json:"version"`
+	FixedVersion string   `json:"fixedVersion,omitempty"`
+	Location     string   `json:"location,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+}
+
+// ---------------- wsm registry scan ----------------
+
+func registryScanCmd() *cobra.Command {
+	var (
+		registry             string
+		insecure             bool
+		operatorChartVersion string
+		wandbVersion         string
+		skipManaged          bool
+		observabilityMode    string
+		vulnDB               string
+		sbomDir              string
+		sbomFormat           string
+		failOn               string
+		ignoreUnfixed        bool
+		platform             string
+		output               string
+		layoutOpts           layoutFlags
+		credOpts             credentialFlags
+		extraOpts            extraFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scan",
+		Short: "Generate SBOMs for every mirrored image and match them against an offline vulnerability database",
+		Long: `Scan a mirror before an air-gapped install. scan walks the same set of
+  references 'wsm registry check' does — including every application image the
+  mirrored server manifest lists when --wandb-version is set — reads each image
+  from --registry, and catalogs what it contains without running it: dpkg and
+  apk packages, Go modules embedded in binaries, Python distributions and npm
+  packages. Charts and other non-image artifacts are skipped.
+
+  The packages are matched against --vuln-db, an offline copy of OSV advisories:
+  a JSON file, a .zip (such as the per-ecosystem all.zip archives published at
+  https://osv-vulnerabilities.storage.googleapis.com/<ecosystem>/all.zip), or a
+  directory of either. Nothing is fetched apart from the images themselves.
+
+  With --sbom-dir, one SBOM per image is written there in --sbom-format
+  (CycloneDX 1.5 or SPDX 2.3 JSON). --output writes the consolidated report,
+  every finding included, as JSON.
+
+  --fail-on makes the command exit non-zero when any finding is at or above
+  that severity, or when an image couldn't be scanned, for gating CI.`,
+		Example: `  # Fetch the advisories on a connected host and carry them over with the bundle.
+  for eco in Debian Ubuntu Alpine Go PyPI npm; do
+    curl -fsSLo osv/$eco.zip https://osv-vulnerabilities.storage.googleapis.com/$eco/all.zip
+  done
+
+  wsm registry scan --registry myreg.example.com --wandb-version 0.82.2 --vuln-db ./osv
+  wsm registry scan --registry myreg.example.com --wandb-version 0.82.2 --vuln-db ./osv \
+      --fail-on high --ignore-unfixed --sbom-dir ./sbom --output scan.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if registry == "" {
+				return fmt.Errorf("--registry is required")
+			}
+			if vulnDB == "" {
+				return fmt.Errorf("--vuln-db is required")
+			}
+			registry = strings.TrimRight(registry, "/")
+			threshold := vulndb.Unknown
+			if failOn != "" {
+				sev, err := vulndb.ParseSeverity(failOn)
+				if err != nil || sev == vulndb.Unknown {
+					return fmt.Errorf("--fail-on: want low, medium, high or critical, got %q", failOn)
+				}
+				threshold = sev
+			}
+			if sbomFormat != sbom.FormatCycloneDX && sbomFormat != sbom.FormatSPDX {
+				return fmt.Errorf("--sbom-format: want one of %s, got %q", strings.Join(sbom.Formats, ", "), sbomFormat)
+			}
+			plat, err := v1.ParsePlatform(platform)
+			if err != nil {
+				return fmt.Errorf("--platform: %w", err)
+			}
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			creds, err := credOpts.provider(registry)
+			if err != nil {
+				return err
+			}
+			extras, err := extraOpts.extras()
+			if err != nil {
+				return err
+			}
+			if sbomDir != "" {
+				if err := os.MkdirAll(sbomDir, 0755); err != nil {
+					return err
+				}
+			}
+
+			db, err := vulndb.Load(vulnDB)
+			if err != nil {
+				return err
+			}
+			if db.Len() == 0 {
+				return fmt.Errorf("no advisories found in %s", vulnDB)
+			}
+			ctx := context.Background()
+
+			telemetry, err := stackTelemetry(observabilityMode)
+			if err != nil {
+				return err
+			}
+			stack := stackOptions{operatorChartVersion: operatorChartVersion, telemetry: telemetry, skipManaged: skipManaged}
+			set := mirrorTargets(ctx, registry, wandbVersion, stack, extras, layout, insecure, creds)
+			targets := set.refs
+
+			fmt.Printf("Scanning %d artifacts in %s against %d advisories\n\n", len(targets), registry, db.Len())
+			fmt.Printf("%-8s  %4s %4s %4s %4s %4s  %s\n", "STATUS", "CRIT", "HIGH", "MED", "LOW", "UNK", "REFERENCE")
+
+			report := scanReport{
+				Generated: time.Now().UTC().Format(time.RFC3339),
+				Registry:  registry,
+				Database:  scanDatabase{Path: vulnDB, Advisories: db.Len()},
+				FailOn:    failOn,
+				Summary:   map[string]int{},
+			}
+			var scanned, skipped, errs, failing, totalUnevaluated int
+			var shown []scanFinding
+			var shownRefs []string
+			for _, tgt := range targets {
+				res := scanImageResult{Reference: tgt, Findings: []scanFinding{}}
+				inv, err := catalogImage(ctx, tgt, plat, insecure, creds)
+				switch {
+				case errors.Is(err, errNotAnImage):
+					res.Skipped = err.Error()
+					skipped++
+					fmt.Printf("%-8s  %4s %4s %4s %4s %4s  %s\n", "skipped", "-", "-", "-", "-", "-", tgt)
+					report.Images = append(report.Images, res)
+					continue
+				case err != nil:
+					res.Error = err.Error()
+					errs++
+					fmt.Printf("%-8s  %4s %4s %4s %4s %4s  %s\n", "error", "-", "-", "-", "-", "-", tgt)
+					fmt.Printf("          └─ %v\n", err)
+					report.Images = append(report.Images, res)
+					continue
+				}
+				scanned++
+				res.Digest = inv.Digest
+				res.Packages = len(inv.Packages)
+				if inv.Distro.ID != "" {
+					res.Distro = inv.Distro.ID + " " + inv.Distro.Version
+				}
+
+				if sbomDir != "" {
+					path := filepath.Join(sbomDir, sbomFileName(tgt)+sbom.FileExtension(sbomFormat))
+					if err := writeSBOM(path, sbomFormat, sbom.Document{Name: tgt, ToolVersion: version, Images: []*sbom.Inventory{inv}}); err != nil {
+						return err
+					}
+					res.SBOM = path
+				}
+
+				counts := map[vulndb.Severity]int{}
+				matched, unevaluated := db.Match(inv)
+				for _, u := range unevaluated {
+					res.Unevaluated = append(res.Unevaluated, scanUnevaluated{ID: u.ID, Package: u.Package.Name, Version: u.Package.Version, Reason: u.Reason})
+				}
+				totalUnevaluated += len(unevaluated)
+				for _, f := range matched {
+					if ignoreUnfixed && f.FixedVersion == "" {
+						continue
+					}
+					counts[f.Severity]++
+					report.Summary[f.Severity.String()]++
+					sf := scanFinding{
+						ID:           f.ID,
+						Aliases:      f.Aliases,
+						Severity:     f.Severity.String(),
+						Package:      f.Package.Name,
+						Type:         f.Package.Type,
+						Version:      f.Package.Version,
+						FixedVersion: f.FixedVersion,
+						Location:     f.Package.Location,
+						Summary:      f.Summary,
+					}
+					res.Findings = append(res.Findings, sf)
+					if threshold != vulndb.Unknown && f.Severity >= threshold {
+						failing++
+					}
+					if f.Severity >= displayThreshold(threshold) {
+						shown = append(shown, sf)
+						shownRefs = append(shownRefs, tgt)
+					}
+				}
+				report.Images = append(report.Images, res)
+				fmt.Printf("%-8s  %4d %4d %4d %4d %4d  %s\n", "scanned",
+					counts[vulndb.Critical], counts[vulndb.High], counts[vulndb.Medium], counts[vulndb.Low], counts[vulndb.Unknown], tgt)
+			}
+
+			if len(shown) > 0 {
+				fmt.Printf("\nFindings at %s or above:\n\n", displayThreshold(threshold))
+				fmt.Printf("%-8s  %-20s  %-28s  %-22s  %-22s  %s\n", "SEVERITY", "ID", "PACKAGE", "INSTALLED", "FIXED", "IMAGE")
+				for i, f := range shown {
+					fixed := f.FixedVersion
+					if fixed == "" {
+						fixed = "(no fix)"
+					}
+					fmt.Printf("%-8s  %-20s  %-28s  %-22s  %-22s  %s\n", f.Severity, f.ID, f.Package, f.Version, fixed, shownRefs[i])
+				}
+			}
+
+			fmt.Printf("\n%d total — %d scanned, %d skipped (not images), %d errors\n", len(targets), scanned, skipped, errs)
+			var parts []string
+			for i := len(vulndb.Severities) - 1; i >= 0; i-- {
+				s := vulndb.Severities[i]
+				parts = append(parts, fmt.Sprintf("%d %s", report.Summary[s.String()], strings.ToLower(s.String())))
+			}
+			fmt.Printf("Findings: %s\n", strings.Join(parts, ", "))
+			if totalUnevaluated > 0 {
+				fmt.Printf("⚠ %d advisories could not be evaluated (GIT-only ranges or unsupported ecosystems); the --output report lists them\n", totalUnevaluated)
+			}
+			if set.chartErr != nil {
+				fmt.Printf("⚠ %v — chart images not scanned\n", set.chartErr)
+			}
+			if set.manifestErr != nil {
+				fmt.Printf("⚠ %v — application images not scanned\n", set.manifestErr)
+			}
+			if wandbVersion == "" {
+				fmt.Println("Note: pass --wandb-version to also scan the W&B application images.")
+			}
+			if sbomDir != "" {
+				fmt.Printf("SBOMs written to %s\n", sbomDir)
+			}
+
+			if output != "" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(output, append(data, '\n'), 0644); err != nil {
+					return err
+				}
+				fmt.Printf("Report written to %s\n", output)
+			}
+
+			if threshold != vulndb.Unknown {
+				if failing > 0 {
+					return fmt.Errorf("%d finding(s) at %s or above", failing, threshold)
+				}
+				if errs > 0 || set.chartErr != nil || set.manifestErr != nil {
+					return fmt.Errorf("not every image could be scanned")
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&registry, "registry", "", "Registry to scan, e.g. myreg.example.com (required)")
+	cmd.Flags().BoolVar(&insecure, "insecure", false, "Skip TLS verification when contacting the registry")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version that was mirrored (must match 'wsm registry mirror')")
+	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", "W&B server version that was mirrored; when set, also scan every application image its server manifest references")
+	cmd.Flags().BoolVar(&skipManaged, "skip-managed-images", false, "Don't scan the managed-service operator + data-plane images (match the flag you mirrored with)")
+	cmd.Flags().StringVar(&observabilityMode, "observability-mode", operator.TelemetryModeOff, "Telemetry mode the operator is deployed with (match the flag you mirrored with)")
+	cmd.Flags().StringVar(&vulnDB, "vuln-db", "", "OSV advisories to match against: a JSON file, a .zip, or a directory of either (required)")
+	cmd.Flags().StringVar(&sbomDir, "sbom-dir", "", "Write one SBOM per image into this directory")
+	cmd.Flags().StringVar(&sbomFormat, "sbom-format", sbom.FormatCycloneDX, "SBOM format for --sbom-dir: "+strings.Join(sbom.Formats, ", "))
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "Exit non-zero if any finding is at or above this severity (low, medium, high, critical), or an image can't be scanned")
+	cmd.Flags().BoolVar(&ignoreUnfixed, "ignore-unfixed", false, "Leave out findings with no fixed version released")
+	cmd.Flags().StringVar(&platform, "platform", "linux/amd64", "Platform to scan in multi-arch images")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the full report as JSON to this file")
+	layoutOpts.register(cmd.Flags(), "")
+	credOpts.register(cmd.Flags(), "", false)
+	extraOpts.register(cmd.Flags())
+	return cmd
+}
+
+// displayThreshold is the lowest severity listed individually on the
+// terminal: the --fail-on level, or HIGH without one. --output has them all.
+func displayThreshold(failOn vulndb.Severity) vulndb.Severity {
+	if failOn == vulndb.Unknown {
+		return vulndb.High
+	}
+	return failOn
+}
+
+// errNotAnImage marks artifacts (charts, the server manifest) that have no
+// filesystem to catalog.
+var errNotAnImage = errors.New("not a container image")
+
+// catalogImage reads ref (the plat variant of a multi-arch image) and
+// catalogs its packages.
+func catalogImage(ctx context.Context, ref string, plat *v1.Platform, insecure bool, creds registryauth.Provider) (*sbom.Inventory, error) {
+	ri, err := resolveImage(ctx, ref, plat, insecure, creds)
+	if err != nil {
+		return nil, err
+	}
+	if ri.artifact != "" {
+		return nil, errNotAnImage
+	}
+	inv, err := sbom.Catalog(ri.img)
+	if err != nil {
+		return nil, err
+	}
+	inv.Image, inv.Digest = ref, ri.digest.String()
+	return inv, nil
+}
+
+// writeSBOM encodes doc to path.
+func writeSBOM(path, format string, doc sbom.Document) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := sbom.Write(f, format, doc); err != nil {
+		f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// sbomFileName turns an image reference into a flat file name, e.g.
+// "myreg:5000/wandb/app:0.82.2" → "myreg_5000_wandb_app_0.82.2".
+func sbomFileName(ref string) string {
+	return strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/registryserver"
+)
+
+// registryServeCmd runs an OCI registry in-process, so the whole air-gapped
+// flow can be tried on one machine without Docker.
+func registryServeCmd() *cobra.Command {
+	var (
+		addr          string
+		storage       string
+		generateTLS   bool
+		tlsHosts      []string
+		tlsCert       string
+		tlsKey        string
+		username      string
+		passwordStdin bool
+		verbose       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a local OCI registry to mirror into",
+		Long: `Run an OCI distribution registry in the foreground, storing everything under
+--storage. Content survives restarts, so a mirror populated once can be reused.
+
+Without TLS flags it speaks plain HTTP: use --insecure on the registry commands
+and --insecure-registry-host on 'wsm cluster create'. With --tls it creates a
+CA in <storage>/tls on first use and issues a certificate for --tls-host on
+every start; trust <storage>/tls/ca.crt (e.g. 'deploy-v2 --registry-ca-file').
+
+With --username, every request needs basic auth. The password is read from
+stdin (--password-stdin) or WSM_REGISTRY_PASSWORD; the same WSM_REGISTRY_*
+variables are what the registry commands read, so exporting them once
+configures both sides.
+
+Uploads are buffered in memory until they complete, so pushing a very large
+layer needs as much free memory as the layer is big.`,
+		Example: `  # Plain HTTP on :5000, mirror into it, and point a kind cluster at it.
+  wsm registry serve --addr :5000 --storage ./mirror &
+  wsm registry mirror --to localhost:5000 --insecure --wandb-version 0.82.2
+  wsm cluster create --insecure-registry-host host.docker.internal:5000
+
+  # HTTPS with a generated CA and basic auth.
+  export WSM_REGISTRY_USERNAME=wsm WSM_REGISTRY_PASSWORD=s3cret
+  wsm registry serve --addr :5443 --storage ./mirror --tls --tls-host host.docker.internal`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (tlsCert == "") != (tlsKey == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be given together")
+			}
+			if generateTLS && tlsCert != "" {
+				return fmt.Errorf("--tls generates a certificate; don't combine it with --tls-cert")
+			}
+			if username == "" {
+				username = os.Getenv(registryEnvPrefix + "USERNAME")
+			}
+			var password string
+			if username != "" {
+				p, err := resolvePassword(passwordStdin, registryEnvPrefix)
+				if err != nil {
+					return err
+				}
+				if p == "" {
+					return fmt.Errorf("--username needs a password: use --password-stdin or set %sPASSWORD", registryEnvPrefix)
+				}
+				password = p
+			}
+
+			opts := registryserver.Options{Storage: storage, Username: username, Password: password}
+			if verbose {
+				opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+			}
+			srv, err := registryserver.New(opts)
+			if err != nil {
+				return err
+			}
+
+			var tlsConfig *tls.Config
+			var caFile string
+			switch {
+			case tlsCert != "":
+				if tlsConfig, err = registryserver.FileTLS(tlsCert, tlsKey); err != nil {
+					return err
+				}
+			case generateTLS:
+				if tlsConfig, caFile, err = registryserver.GeneratedTLS(filepath.Join(storage, "tls"), tlsHosts); err != nil {
+					return err
+				}
+			}
+
+			scheme := "http"
+			if tlsConfig != nil {
+				scheme = "https"
+			}
+			host := localRegistryHost(addr)
+			fmt.Printf("Serving OCI registry on %s://%s (storage: %s)\n", scheme, host, storage)
+			if username != "" {
+				fmt.Printf("  • Basic auth required (user %s)\n", username)
+			}
+			if caFile != "" {
+				fmt.Printf("  • CA certificate: %s\n", caFile)
+				fmt.Printf("  • Next: wsm registry mirror --to %s (trust the CA, or pass --insecure)\n", host)
+				fmt.Printf("          wsm deploy-v2 operator --mirror-registry <host>%s --registry-ca-file %s\n", portSuffix(addr), caFile)
+			} else if tlsConfig == nil {
+				fmt.Printf("  • Next: wsm registry mirror --to %s --insecure\n", host)
+				fmt.Printf("          wsm cluster create --insecure-registry-host host.docker.internal%s\n", portSuffix(addr))
+			}
+			fmt.Println("Press Ctrl+C to stop.")
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return srv.Serve(ctx, addr, tlsConfig)
+		},
+	}
+
+	hostname, _ := os.Hostname()
+	defaultHosts := []string{"localhost", "127.0.0.1", "host.docker.internal"}
+	if hostname != "" {
+		defaultHosts = append(defaultHosts, hostname)
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":5000", "Address to listen on")
+	cmd.Flags().StringVar(&storage, "storage", "./mirror", "Directory to keep blobs, manifests and generated TLS material in")
+	cmd.Flags().BoolVar(&generateTLS, "tls", false, "Serve HTTPS with a certificate issued by a CA kept in <storage>/tls")
+	cmd.Flags().StringSliceVar(&tlsHosts, "tls-host", defaultHosts, "DNS names and IPs the generated certificate is valid for")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "PEM certificate to serve HTTPS with instead of a generated one")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM private key for --tls-cert")
+	cmd.Flags().StringVar(&username, "username", "", "Require basic auth with this user (default $"+registryEnvPrefix+"USERNAME)")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "Read the basic auth password from stdin (default $"+registryEnvPrefix+"PASSWORD)")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Log every request")
+	return cmd
+}
+
+// localRegistryHost turns a listen address into the host:port clients on this
+// machine use, e.g. ":5000" → "localhost:5000".
+func localRegistryHost(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// portSuffix returns ":<port>" of a listen address, or "" if it has none.
+func portSuffix(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil && port != "" {
+		return ":" + port
+	}
+	return ""
+}
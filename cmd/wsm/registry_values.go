@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/operator"
+	"gopkg.in/yaml.v3"
+)
+
+// crFragmentFile is the file name registry values gives the WeightsAndBiases
+// CR fragment in --output-dir.
+const crFragmentFile = "wandb-cr.yaml"
+
+// registryValuesCmd prints, for every Helm release wsm installs, the chart
+// reference and values deploy-v2 would pass for the same mirror, plus the CR
+// fragment that points the operator at the mirrored server manifest. The
+// values come from the same builders InstallCertManager, InstallNginxGateway
+// and DeployOperator use, so a GitOps install retargets exactly what wsm does.
+func registryValuesCmd() *cobra.Command {
+	var (
+		registry             string
+		outputDir            string
+		outFile              string
+		operatorChartVersion string
+		operatorNamespace    string
+		wandbNamespace       string
+		imageRegistry        string
+		enableGatewayAPI     bool
+		kindNodePorts        bool
+		openshift            bool
+		layoutOpts           layoutFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "values",
+		Short: "Emit the Helm values and CR fragment that point each release at your registry",
+		Long: `Generate ready-to-use values files for each Helm release wsm installs
+(cert-manager, nginx-gateway-fabric and the W&B operator), holding exactly the
+overrides 'wsm deploy-v2 --mirror-registry' sets, plus a WeightsAndBiases CR
+fragment pointing spec.wandb.manifestRepository at the mirrored server manifest.
+
+Each document starts with a comment naming its release, namespace, chart and
+version. Use --output-dir to write one file per release:
+
+    cert-manager.values.yaml
+    nginx-gateway-fabric.values.yaml
+    wandb-operator.values.yaml
+    wandb-cr.yaml
+
+Without it, all documents go to stdout separated by '---'.
+
+spec.global.imageRegistry is left unset unless --image-registry is given: the
+operator prepends it to the full upstream path, which double-prefixes the
+mirror-rewritten images in the server manifest.`,
+		Example: `  # One file per release, for Argo CD or Flux.
+  wsm registry values --registry harbor.mycorp.internal --output-dir ./wsm-values
+
+  # Flattened layout, printed to stdout.
+  wsm registry values --registry harbor.mycorp.internal --layout flatten --layout-prefix wandb`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if registry == "" {
+				return fmt.Errorf("--registry is required")
+			}
+			registry = strings.TrimRight(registry, "/")
+			layout, err := layoutOpts.layout()
+			if err != nil {
+				return err
+			}
+			telemetry := telemetryConfigFrom(cmd)
+			if telemetry.Mode == operator.TelemetryModeForward && telemetry.ForwardEndpoint == "" {
+				return fmt.Errorf("--observability-mode=forward requires --observability-forward-endpoint")
+			}
+
+			mirror := &operator.MirrorConfig{Host: registry, Layout: layout}
+			releases := []struct {
+				file string
+				spec operator.ReleaseSpec
+			}{
+				{"cert-manager.values.yaml", operator.CertManagerRelease(enableGatewayAPI, mirror)},
+				{"nginx-gateway-fabric.values.yaml", operator.NginxGatewayRelease(kindNodePorts, mirror)},
+				{"wandb-operator.values.yaml", operator.OperatorRelease(operatorNamespace, operatorChartVersion, mirror, telemetry, wandbNamespace, openshift)},
+			}
+
+			var docs []string
+			var files []string
+			for _, r := range releases {
+				doc, err := releaseValuesDoc(r.spec)
+				if err != nil {
+					return err
+				}
+				docs = append(docs, doc)
+				files = append(files, r.file)
+			}
+			doc, err := crFragmentDoc(registry, layout.Repository(registry, serverManifestUpstream), wandbNamespace, imageRegistry)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			files = append(files, crFragmentFile)
+
+			if outputDir != "" {
+				if err := os.MkdirAll(outputDir, 0755); err != nil {
+					return fmt.Errorf("create %s: %w", outputDir, err)
+				}
+				for i, doc := range docs {
+					path := filepath.Join(outputDir, files[i])
+					if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+						return fmt.Errorf("write %s: %w", path, err)
+					}
+					fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+				}
+				return nil
+			}
+
+			content := []byte(strings.Join(docs, "---\n"))
+			if outFile == "" || outFile == "-" {
+				_, err = os.Stdout.Write(content)
+				return err
+			}
+			return os.WriteFile(outFile, content, 0644)
+		},
+	}
+
+	cmd.Flags().StringVar(&registry, "registry", "", "Target registry, e.g. myreg.example.com (required)")
+	cmd.Flags().StringVar(&outputDir, "output-dir", "", "Write one file per release into this directory instead of stdout")
+	cmd.Flags().StringVarP(&outFile, "output", "o", "-", "Write all documents to this file (default: stdout)")
+	cmd.Flags().StringVar(&operatorChartVersion, "operator-chart-version", defaultOperatorChartVersion, "Operator chart version (must match 'wsm registry mirror')")
+	cmd.Flags().StringVar(&operatorNamespace, "operator-namespace", "wandb-operators", "Namespace the operator release is installed into")
+	cmd.Flags().StringVar(&wandbNamespace, "wandb-namespace", "wandb", "Namespace of the WeightsAndBiases CR")
+	cmd.Flags().StringVar(&imageRegistry, "image-registry", "", "Set spec.global.imageRegistry in the CR fragment (only for a registry that preserves full upstream paths)")
+	cmd.Flags().BoolVar(&enableGatewayAPI, "enable-gateway-api", true, "Enable Gateway API support for cert-manager")
+	cmd.Flags().BoolVar(&kindNodePorts, "kind-node-ports", false, "Expose nginx-gateway-fabric on the NodePorts 'wsm cluster create' maps to the host (any provider)")
+	cmd.Flags().BoolVar(&openshift, "openshift", false, "Enable OpenShift compatibility for the operator and bundled managed-service pods")
+	cmd.Flags().String("observability-mode", "off", "Operator telemetry mode (off, full, forward)")
+	cmd.Flags().String("observability-forward-endpoint", "", "OTLP endpoint to forward telemetry to (required when --observability-mode=forward)")
+	layoutOpts.register(cmd.Flags(), "")
+	return cmd
+}
+
+// releaseValuesDoc renders a release's values with a header naming where the
+// chart comes from.
+func releaseValuesDoc(r operator.ReleaseSpec) (string, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Generated by wsm registry values\n")
+	fmt.Fprintf(&b, "# Release:   %s\n", r.Name)
+	fmt.Fprintf(&b, "# Namespace: %s\n", r.Namespace)
+	fmt.Fprintf(&b, "# Chart:     %s\n", r.Chart)
+	fmt.Fprintf(&b, "# Version:   %s\n", r.Version)
+	if err := encodeYAML(&b, r.Values); err != nil {
+		return "", fmt.Errorf("marshal %s values: %w", r.Name, err)
+	}
+	return b.String(), nil
+}
+
+// crFragmentDoc renders the WeightsAndBiases CR fields deploy-v2 sets for a
+// mirror, to be merged into the CR the GitOps repository already holds.
+func crFragmentDoc(registry, manifestRepo, namespace, imageRegistry string) (string, error) {
+	spec := map[string]interface{}{
+		"wandb": map[string]interface{}{
+			"manifestRepository": "oci://" + manifestRepo,
+		},
+	}
+	if imageRegistry != "" {
+		spec["global"] = map[string]interface{}{"imageRegistry": imageRegistry}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Generated by wsm registry values\n")
+	fmt.Fprintf(&b, "# WeightsAndBiases CR fragment for mirror %s; merge into your CR.\n", registry)
+	if imageRegistry == "" {
+		fmt.Fprintf(&b, "# spec.global.imageRegistry is deliberately unset: the images in the\n")
+		fmt.Fprintf(&b, "# mirrored server manifest already point at the mirror.\n")
+	}
+	err := encodeYAML(&b, map[string]interface{}{
+		"apiVersion": "apps.wandb.com/v2",
+		"kind":       "WeightsAndBiases",
+		"metadata":   map[string]interface{}{"namespace": namespace},
+		"spec":       spec,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal CR fragment: %w", err)
+	}
+	return b.String(), nil
+}
+
+// encodeYAML writes v with the two-space indent Helm values files use.
+func encodeYAML(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
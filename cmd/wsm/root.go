@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kubectl"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -41,7 +43,34 @@ func Execute() {
 	}
 }
 
-func init() {}
+// kubeOptions are the kubectl-style flags every command reaches clusters
+// with. --namespace is only registered by the commands that honour it.
+var kubeOptions kubectl.ConfigOptions
+
+// requireKubeContext fails unless --context names the cluster to act on; the
+// commands that change a cluster don't fall back to the current context.
+func requireKubeContext() error {
+	if kubectl.DefaultOptions().Context == "" {
+		return errors.New("--context is required")
+	}
+	return nil
+}
+
+func init() {
+	fs := rootCmd.PersistentFlags()
+	fs.StringVar(&kubeOptions.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default $KUBECONFIG, then ~/.kube/config)")
+	fs.StringVar(&kubeOptions.Context, "context", "", "Name of the kubeconfig context to use")
+	fs.StringVar(&kubeOptions.Impersonate, "as", "", "Username to impersonate for the operation")
+	fs.StringArrayVar(&kubeOptions.ImpersonateGroups, "as-group", nil, "Group to impersonate for the operation; repeatable")
+	fs.DurationVar(&kubeOptions.RequestTimeout, "request-timeout", 0, "How long to wait for each Kubernetes API request, e.g. 30s (0 waits indefinitely)")
+	fs.BoolVar(&kubeOptions.ForceConflicts, "force-conflicts", false, "Take over fields other field managers own when applying, instead of failing with a conflict report")
+
+	// Runs after flag parsing and before any command's pre-run hooks, so the
+	// clients the commands build pick the options up.
+	cobra.OnInitialize(func() {
+		kubectl.SetDefaultOptions(kubeOptions)
+	})
+}
 
 func main() {
 	Execute()
@@ -11,6 +11,7 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
+	"github.com/wandb/operator/api/v2"
 	"github.com/wandb/wsm/pkg/kubectl"
 	"github.com/wandb/wsm/pkg/operator"
 )
@@ -21,7 +22,6 @@ func init() {
 
 func SetVersionCmd() *cobra.Command {
 	var (
-		kubeContext    string
 		wandbName      string
 		wandbNamespace string
 		wandbVersion   string
@@ -36,54 +36,28 @@ func SetVersionCmd() *cobra.Command {
 		Short: "Set version of wsm-managed W&B instance",
 		Long:  `Patch spec.wandb.version on a specific WeightsAndBiases CR`,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			if kubeContext == "" {
-				return errors.New("--context is required")
+			if err := requireKubeContext(); err != nil {
+				return err
 			}
 			if wandbVersion == "" {
 				return errors.New("--wandb-version is required")
 			}
-			kubectl.SetContext(kubeContext)
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := context.Background()
 
-			hasMarker, err := kubectl.HasDeploymentMarker(ctx, wandbNamespace, "wandb-cr")
+			upgrade, err := planVersionUpgrade(ctx, wandbName, wandbNamespace, wandbVersion, force)
 			if err != nil {
 				return err
 			}
-			if !hasMarker {
-				return fmt.Errorf("no wsm deployment marker found in namespace %q — refusing to upgrade an install wsm did not deploy", wandbNamespace)
-			}
-
-			currentCR, err := operator.GetCR(ctx, wandbName, wandbNamespace)
-			currentCR.ManagedFields = nil
-			currentCR.ResourceVersion = ""
-			if err != nil {
-				return fmt.Errorf("failed to read current CR: %w", err)
-			}
-
-			currentVersion := currentCR.Spec.Wandb.Version
-			if currentVersion == wandbVersion {
+			if upgrade == nil {
 				fmt.Printf("✓ %s/%s is already at version %s, nothing to do.\n", wandbNamespace, wandbName, wandbVersion)
 				return nil
 			}
 
-			if !force {
-				if err := validateWandbVersion(wandbVersion); err != nil {
-					return fmt.Errorf("%w (pass --force to override)", err)
-				}
-				down, cmpErr := isDowngrade(currentVersion, wandbVersion)
-				if cmpErr != nil {
-					return fmt.Errorf("%w (pass --force to proceed anyway)", cmpErr)
-				}
-				if down {
-					return fmt.Errorf("refusing to downgrade %s → %s (pass --force to override)", currentVersion, wandbVersion)
-				}
-			}
-
 			fmt.Printf("Upgrade plan for %s/%s:\n", wandbNamespace, wandbName)
-			fmt.Printf("  spec.wandb.version: %s → %s\n", currentVersion, wandbVersion)
+			fmt.Printf("  spec.wandb.version: %s → %s\n", upgrade.from, upgrade.to)
 
 			if dryRun {
 				fmt.Println("(dry-run) no changes applied.")
@@ -98,18 +72,16 @@ func SetVersionCmd() *cobra.Command {
 				}
 			}
 
-			currentCR.Spec.Wandb.Version = wandbVersion
-
 			start := time.Now()
 			fmt.Print("→ Applying upgrade...")
-			if err := operator.ApplyCR(ctx, currentCR, nil); err != nil {
-				return fmt.Errorf("failed to apply upgrade: %w", err)
+			if err := upgrade.apply(ctx); err != nil {
+				return err
 			}
 			fmt.Printf(" (%s)\n", time.Since(start).Round(time.Second))
 
 			if wait {
 				fmt.Printf("→ Waiting for %s/%s to be ready (timeout %s)...\n", wandbNamespace, wandbName, timeout)
-				if err := operator.WaitForCRReady(ctx, wandbNamespace, wandbName, timeout); err != nil {
+				if err := operator.WaitForCRReady(ctx, kubectl.Default(), wandbNamespace, wandbName, timeout); err != nil {
 					return fmt.Errorf("instance did not become ready: %w", err)
 				}
 				fmt.Println("Upgrade complete.")
@@ -121,7 +93,6 @@ func SetVersionCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&kubeContext, "context", "", "name of the kubeconfig context to use (required)")
 	cmd.Flags().StringVar(&wandbName, "wandb-name", "wandb", "Name of the W&B instance")
 	cmd.Flags().StringVar(&wandbNamespace, "wandb-namespace", "wandb", "Namespace of the W&B instance")
 	cmd.Flags().StringVar(&wandbVersion, "wandb-version", "", fmt.Sprintf("Target server manifest version (required; must be >= %s unless --force)", minWandbVersion))
@@ -144,3 +115,57 @@ func isDowngrade(current, target string) (bool, error) {
 	}
 	return tgt.LessThan(cur), nil
 }
+
+// versionUpgrade is a pending spec.wandb.version change on a wsm-managed CR.
+type versionUpgrade struct {
+	cr       *v2.WeightsAndBiases
+	from, to string
+}
+
+// planVersionUpgrade checks that the CR name/namespace is wsm-managed and may
+// move to version, refusing downgrades and unparseable versions unless force.
+// It returns nil when the CR is already at version.
+func planVersionUpgrade(ctx context.Context, name, namespace, version string, force bool) (*versionUpgrade, error) {
+	hasMarker, err := kubectl.HasDeploymentMarker(ctx, namespace, "wandb-cr")
+	if err != nil {
+		return nil, err
+	}
+	if !hasMarker {
+		return nil, fmt.Errorf("no wsm deployment marker found in namespace %q — refusing to upgrade an install wsm did not deploy", namespace)
+	}
+
+	currentCR, err := operator.GetCR(ctx, kubectl.FromContext(ctx), name, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current CR: %w", err)
+	}
+	currentCR.ManagedFields = nil
+	currentCR.ResourceVersion = ""
+
+	currentVersion := currentCR.Spec.Wandb.Version
+	if currentVersion == version {
+		return nil, nil
+	}
+
+	if !force {
+		if err := validateWandbVersion(version); err != nil {
+			return nil, fmt.Errorf("%w (pass --force to override)", err)
+		}
+		down, cmpErr := isDowngrade(currentVersion, version)
+		if cmpErr != nil {
+			return nil, fmt.Errorf("%w (pass --force to proceed anyway)", cmpErr)
+		}
+		if down {
+			return nil, fmt.Errorf("refusing to downgrade %s → %s (pass --force to override)", currentVersion, version)
+		}
+	}
+	return &versionUpgrade{cr: currentCR, from: currentVersion, to: version}, nil
+}
+
+// apply patches the CR to the new version.
+func (u *versionUpgrade) apply(ctx context.Context) error {
+	u.cr.Spec.Wandb.Version = u.to
+	if err := operator.ApplyCR(ctx, kubectl.FromContext(ctx), u.cr, nil); err != nil {
+		return fmt.Errorf("failed to apply upgrade: %w", err)
+	}
+	return nil
+}
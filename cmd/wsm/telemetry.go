@@ -31,14 +31,8 @@ The telemetry stack is deployed when the operator is installed with
 --observability-mode=full (Grafana + Victoria stack) or forward (Victoria
 stack only). Its services are ClusterIP-only, so these subcommands port-forward
 to a service and open it in a browser; press Ctrl+C to stop.`,
-		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			kubeContext, _ := cmd.Flags().GetString("context")
-			kubectl.SetContext(kubeContext)
-			return nil
-		},
 	}
 
-	cmd.PersistentFlags().String("context", "", "name of the kubeconfig context to use")
 	cmd.PersistentFlags().String("wandb-namespace", "wandb", "Namespace where the telemetry stack is deployed")
 	cmd.PersistentFlags().String("operator-namespace", "wandb-operators", "Namespace of the operator Helm release (used to read the installed telemetry mode)")
 
@@ -69,7 +63,7 @@ func telemetryUICmd(ui telemetry.UI) *cobra.Command {
 			}
 
 			// Report the installed mode and guard obvious mismatches before forwarding.
-			tc, err := operator.GetOperatorTelemetryConfig(ctx, operatorNamespace)
+			tc, err := operator.GetOperatorTelemetryConfig(ctx, kubectl.Default(), operatorNamespace)
 			if err != nil {
 				return fmt.Errorf("failed to read telemetry config from operator namespace %q (set --operator-namespace if the operator is installed elsewhere): %w", operatorNamespace, err)
 			}
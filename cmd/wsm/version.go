@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/wandb/wsm/pkg/kubectl"
 )
 
 // These are populated at build time via -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
@@ -16,6 +17,7 @@ var (
 
 func init() {
 	rootCmd.Version = version
+	kubectl.WSMVersion = version
 	rootCmd.AddCommand(VersionCmd())
 }
 
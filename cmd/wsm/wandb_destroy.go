@@ -0,0 +1,689 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wandb/operator/api/v2"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/operator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// instanceLabel is the standard label naming the W&B instance an object
+// belongs to.
+const instanceLabel = "app.kubernetes.io/instance"
+
+// dataObject is a PVC or Secret holding a W&B instance's data.
+type dataObject struct {
+	kubectl.InventoryEntry
+	// detail is the PVC's capacity or the Secret's type.
+	detail string
+}
+
+// instanceData is what a W&B instance's retention policy applies to.
+type instanceData struct {
+	policy  v2.OnDeletePolicy
+	objects []dataObject
+	// objectStores describes the instance's object stores.
+	objectStores []string
+}
+
+// findInstanceData works out the PVCs and Secrets of the W&B instance cr and
+// its object stores. An object belongs to the instance when its owner
+// references lead to the CR, or when its instance label names it (see
+// labelledInstance). Objects wsm created itself are left to `cluster cleanup`.
+func findInstanceData(ctx context.Context, cr *unstructured.Unstructured) (*instanceData, error) {
+	name, namespace := cr.GetName(), cr.GetNamespace()
+	policy, _, _ := unstructured.NestedString(cr.Object, "spec", "retentionPolicy", "onDelete")
+	if policy == "" {
+		policy = string(v2.DetachOnDelete)
+	}
+	data := &instanceData{policy: v2.OnDeletePolicy(policy)}
+
+	instances, err := operator.ListCRs(ctx, kubectl.FromContext(ctx), namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := kubectl.FromContext(ctx).Clientset()
+	if err != nil {
+		return nil, err
+	}
+	pvcs, err := cs.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVCs in %s: %w", namespace, err)
+	}
+	secrets, err := cs.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Secrets in %s: %w", namespace, err)
+	}
+	statefulSets, err := cs.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StatefulSets in %s: %w", namespace, err)
+	}
+	deployments, err := cs.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Deployments in %s: %w", namespace, err)
+	}
+
+	var all []metav1.Object
+	for i := range statefulSets.Items {
+		all = append(all, &statefulSets.Items[i])
+	}
+	for i := range deployments.Items {
+		all = append(all, &deployments.Items[i])
+	}
+	for i := range pvcs.Items {
+		all = append(all, &pvcs.Items[i])
+	}
+	for i := range secrets.Items {
+		all = append(all, &secrets.Items[i])
+	}
+	owned := ownedBy(cr.GetUID(), all)
+
+	// StatefulSet PVCs carry no owner reference; they are named
+	// <template>-<statefulset>-<ordinal>.
+	var ownedStatefulSets []string
+	for _, sts := range statefulSets.Items {
+		if owned[sts.UID] {
+			ownedStatefulSets = append(ownedStatefulSets, sts.Name)
+		}
+	}
+
+	belongs := func(obj metav1.Object) bool {
+		if kubectl.IsManaged(obj) {
+			return false
+		}
+		if owned[obj.GetUID()] {
+			return true
+		}
+		return labelledInstance(obj.GetLabels()[instanceLabel], instances) == name
+	}
+
+	for _, pvc := range pvcs.Items {
+		if !belongs(&pvc) && !slices.ContainsFunc(ownedStatefulSets, func(sts string) bool { return isStatefulSetClaim(pvc.Name, sts) }) {
+			continue
+		}
+		o := dataObject{InventoryEntry: kubectl.InventoryEntry{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: namespace, Name: pvc.Name}}
+		if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+			o.detail = capacity.String()
+		}
+		data.objects = append(data.objects, o)
+	}
+	for _, secret := range secrets.Items {
+		switch secret.Type {
+		case corev1.SecretTypeServiceAccountToken, "helm.sh/release.v1":
+			continue
+		}
+		if !belongs(&secret) {
+			continue
+		}
+		data.objects = append(data.objects, dataObject{
+			InventoryEntry: kubectl.InventoryEntry{APIVersion: "v1", Kind: "Secret", Namespace: namespace, Name: secret.Name},
+			detail:         string(secret.Type),
+		})
+	}
+
+	stores, _, _ := unstructured.NestedMap(cr.Object, "spec", "objectStore")
+	for _, key := range slices.Sorted(maps.Keys(stores)) {
+		store, _ := stores[key].(map[string]any)
+		if _, ok := store["managedObjectStore"]; ok {
+			data.objectStores = append(data.objectStores, fmt.Sprintf("%s: managed in-cluster, its buckets live on the PVCs above", key))
+			continue
+		}
+		desc := fmt.Sprintf("%s: external, never deleted by the operator", key)
+		if bucket := findString(store, "bucket"); bucket != "" {
+			desc = fmt.Sprintf("%s: external bucket %s, never deleted by the operator", key, bucket)
+		}
+		data.objectStores = append(data.objectStores, desc)
+	}
+	return data, nil
+}
+
+// labelledInstance returns which of instances the instance label value names:
+// the instance itself, or one of its components (<instance>-mysql...). The
+// longest match wins, so wandb-2-mysql is wandb-2's, not wandb's.
+func labelledInstance(label string, instances []string) string {
+	var match string
+	for _, name := range instances {
+		if (label == name || strings.HasPrefix(label, name+"-")) && len(name) > len(match) {
+			match = name
+		}
+	}
+	return match
+}
+
+// ownedBy returns the UIDs of the objects whose owner references lead,
+// directly or through other objects, to root.
+func ownedBy(root types.UID, objects []metav1.Object) map[types.UID]bool {
+	owned := map[types.UID]bool{root: true}
+	for changed := true; changed; {
+		changed = false
+		for _, obj := range objects {
+			if owned[obj.GetUID()] {
+				continue
+			}
+			for _, ref := range obj.GetOwnerReferences() {
+				if owned[ref.UID] {
+					owned[obj.GetUID()] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return owned
+}
+
+// isStatefulSetClaim reports whether pvc is named like a claim of the
+// StatefulSet sts: <template>-<sts>-<ordinal>.
+func isStatefulSetClaim(pvc, sts string) bool {
+	i := strings.LastIndex(pvc, "-")
+	if i < 0 || strings.Trim(pvc[i+1:], "0123456789") != "" || i == len(pvc)-1 {
+		return false
+	}
+	return strings.HasSuffix(pvc[:i], "-"+sts)
+}
+
+// findString returns the first string field named field in m or the maps
+// nested in it.
+func findString(m map[string]any, field string) string {
+	if s, ok := m[field].(string); ok {
+		return s
+	}
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		if nested, ok := m[key].(map[string]any); ok {
+			if s := findString(nested, field); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// print shows what destroying the instance will delete or leave behind.
+func (d *instanceData) print(name, namespace string) {
+	fmt.Printf("W&B instance %s/%s has retention policy %q.\n", namespace, name, d.policy)
+	if d.policy == v2.PurgeOnDelete {
+		fmt.Println("The operator will PERMANENTLY DELETE its managed infrastructure and data:")
+	} else {
+		fmt.Println("The operator will leave its managed infrastructure running and keep its data:")
+	}
+	if len(d.objects) == 0 && len(d.objectStores) == 0 {
+		fmt.Println("  (no PVCs, Secrets or object stores found)")
+		return
+	}
+	if len(d.objects) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  KIND\tNAME\tDETAIL")
+		for _, o := range d.objects {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", o.Kind, o.Name, valueOr(o.detail, "-"))
+		}
+		w.Flush()
+	}
+	if len(d.objectStores) > 0 {
+		fmt.Println("  Object stores:")
+		for _, s := range d.objectStores {
+			fmt.Printf("    %s\n", s)
+		}
+	}
+}
+
+type destroyOptions struct {
+	dryRun  bool
+	yes     bool
+	confirm string
+	timeout time.Duration
+}
+
+// confirmDestroy asks before destroying the instance name. Purging needs the
+// instance name typed, or passed with --confirm; --yes alone is not enough.
+func confirmDestroy(name string, policy v2.OnDeletePolicy, opts destroyOptions) (bool, error) {
+	if policy == v2.PurgeOnDelete {
+		if opts.confirm != "" {
+			if opts.confirm != name {
+				return false, fmt.Errorf("--confirm %q does not match the instance name %q", opts.confirm, name)
+			}
+			return true, nil
+		}
+		fmt.Printf("Type the instance name (%s) to purge it and its data: ", name)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		return strings.TrimSpace(answer) == name, nil
+	}
+	if opts.yes || opts.confirm == name {
+		return true, nil
+	}
+	fmt.Printf("Destroy W&B instance %s? [y/N]: ", name)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(answer)) == "y", nil
+}
+
+// waitObjectsGone polls entries until they are gone or deadline passes. It
+// returns why each remaining one is still there, keyed by entry.
+func waitObjectsGone(ctx context.Context, entries []kubectl.InventoryEntry, deadline time.Time) map[string]string {
+	why := map[string]string{}
+	pending := slices.Clone(entries)
+	for {
+		var left []kubectl.InventoryEntry
+		for _, e := range pending {
+			reason, err := objectRemaining(ctx, e)
+			switch {
+			case err != nil:
+				why[e.String()] = err.Error()
+				left = append(left, e)
+			case reason == "":
+				delete(why, e.String())
+			default:
+				why[e.String()] = reason
+				left = append(left, e)
+			}
+		}
+		pending = left
+		if len(pending) == 0 || time.Now().After(deadline) {
+			return why
+		}
+		select {
+		case <-ctx.Done():
+			return why
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// destroyWandbCR deletes the W&B instance name after showing what its
+// retention policy deletes or leaves behind, and waits for the operator's
+// finalizers. Data a detached instance leaves behind is recorded in the
+// namespace's deployment marker for `wsm deploy-v2 wandb orphans`.
+func destroyWandbCR(ctx context.Context, name string, namespace string, opts destroyOptions) error {
+	hasMarker, err := kubectl.HasDeploymentMarker(ctx, namespace, "wandb-cr")
+	if err != nil {
+		return err
+	}
+
+	if !hasMarker {
+		return errors.New("no wsm deployment marker found - W&B instance may not be managed by wsm")
+	}
+
+	entry := wandbCREntry
+	entry.Namespace, entry.Name = namespace, name
+	cr, err := kubectl.GetInventoryObject(ctx, entry)
+	if err != nil {
+		return err
+	}
+	if cr == nil {
+		fmt.Printf("! W&B instance %s/%s is already gone\n", namespace, name)
+		if opts.dryRun {
+			return nil
+		}
+		if err := kubectl.DeleteDeploymentMarker(ctx, namespace, "wandb-cr"); err != nil {
+			return fmt.Errorf("failed to delete deployment marker: %w", err)
+		}
+		return nil
+	}
+
+	data, err := findInstanceData(ctx, cr)
+	if err != nil {
+		return err
+	}
+	data.print(name, namespace)
+	if opts.dryRun {
+		fmt.Println("(dry-run) nothing deleted.")
+		return nil
+	}
+	ok, err := confirmDestroy(name, data.policy, opts)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("aborted.")
+		return nil
+	}
+
+	fmt.Printf("→ Deleting %s...\n", entry)
+	if err := kubectl.DeleteCR(ctx, name, namespace); err != nil {
+		return fmt.Errorf("failed to delete W&B CR: %w", err)
+	}
+	fmt.Println("→ Waiting for the operator's finalizers...")
+	if why := waitObjectsGone(ctx, []kubectl.InventoryEntry{entry}, time.Now().Add(opts.timeout)); len(why) > 0 {
+		return fmt.Errorf("%s still there after %s: %s; run destroy again to keep waiting", entry, opts.timeout, why[entry.String()])
+	}
+	fmt.Printf("✓ Deleted %s\n", entry)
+
+	if err := recordLeftBehind(ctx, name, namespace, data); err != nil {
+		return err
+	}
+
+	if err := kubectl.DeleteDeploymentMarker(ctx, namespace, "wandb-cr"); err != nil {
+		return fmt.Errorf("failed to delete deployment marker: %w", err)
+	}
+
+	return nil
+}
+
+// recordLeftBehind checks data once the W&B instance name is gone. What a
+// detached instance left behind is recorded in the namespace's deployment
+// marker for `wsm deploy-v2 wandb orphans`; what a purge missed is reported.
+func recordLeftBehind(ctx context.Context, name, namespace string, data *instanceData) error {
+	var left []dataObject
+	for _, o := range data.objects {
+		obj, err := kubectl.GetInventoryObject(ctx, o.InventoryEntry)
+		if err != nil {
+			return err
+		}
+		if obj != nil && obj.GetDeletionTimestamp() == nil {
+			left = append(left, o)
+		}
+	}
+
+	if data.policy == v2.PurgeOnDelete {
+		for _, o := range left {
+			fmt.Printf("! %s was not purged by the operator\n", o.InventoryEntry)
+		}
+		return nil
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	detached := make([]kubectl.DetachedObject, 0, len(left))
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, o := range left {
+		detached = append(detached, kubectl.DetachedObject{InventoryEntry: o.InventoryEntry, Instance: name, DetachedAt: now})
+	}
+	if err := kubectl.RecordDetached(ctx, namespace, detached); err != nil {
+		return fmt.Errorf("failed to record detached objects: %w", err)
+	}
+	fmt.Printf("! %d PVC(s) and Secret(s) of %s/%s left behind; list or delete them with 'wsm deploy-v2 wandb orphans'\n", len(left), namespace, name)
+	return nil
+}
+
+func wandbDestroyCmd() *cobra.Command {
+	var opts destroyOptions
+
+	cmd := &cobra.Command{
+		Use:   "destroy",
+		Short: "Destroy an instance of W&B",
+		Long: `Destroy an instance of W&B.
+
+What happens to the instance's data depends on the retention policy it was
+deployed with (--retention-policy): detach leaves its managed infrastructure
+running and keeps its PVCs and Secrets, purge deletes them. The PVCs, Secrets
+and object stores concerned are shown first.
+
+Destroying needs confirming; purging needs the instance name typed, or passed
+with --confirm. The command then waits up to --timeout for the operator's
+finalizers. What a detached instance leaves behind is recorded in the
+namespace's deployment marker and listed by 'wsm deploy-v2 wandb orphans'.`,
+		Example: `  wsm deploy-v2 wandb destroy --context kind-wandb --dry-run
+  wsm deploy-v2 wandb destroy --context prod --wandb-name wandb --yes
+  wsm deploy-v2 wandb destroy --context dev --confirm wandb`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			wandbNamespace, _ := cmd.Flags().GetString("wandb-namespace")
+			wandbName, _ := cmd.Flags().GetString("wandb-name")
+
+			ctx := context.Background()
+
+			err := destroyWandbCR(ctx, wandbName, wandbNamespace, opts)
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show what the retention policy will delete or leave behind without deleting anything")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Don't ask for confirmation (a purge still needs --confirm)")
+	cmd.Flags().StringVar(&opts.confirm, "confirm", "", "The instance name, to destroy without being asked; required to purge non-interactively")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 10*time.Minute, "How long to wait for the operator's finalizers")
+
+	return cmd
+}
+
+// orphan is a PVC or Secret a destroyed W&B instance left behind.
+type orphan struct {
+	kubectl.DetachedObject
+	// recorded is false for objects found in a namespace W&B was destroyed
+	// in but missing from its marker, e.g. left by an older wsm.
+	recorded bool
+	state    string
+}
+
+// findOrphans lists the objects recorded as detached in the deployment
+// markers, plus the unrecorded PVCs and Secrets in namespaces wsm deployed
+// W&B to that no longer hold a W&B instance.
+func findOrphans(ctx context.Context, instance string) ([]orphan, error) {
+	markers, err := kubectl.ListDeploymentMarkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubectl.FromContext(ctx).Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []orphan
+	for _, m := range markers {
+		recorded := map[string]bool{}
+		for _, o := range m.Detached {
+			recorded[o.String()] = true
+			if instance != "" && o.Instance != instance {
+				continue
+			}
+			obj, err := kubectl.GetInventoryObject(ctx, o.InventoryEntry)
+			if err != nil {
+				return nil, err
+			}
+			state := "present"
+			switch {
+			case obj == nil:
+				state = "gone"
+			case obj.GetDeletionTimestamp() != nil:
+				state = "terminating"
+			}
+			orphans = append(orphans, orphan{DetachedObject: o, recorded: true, state: state})
+		}
+
+		// Unrecorded leftovers: only where W&B was deployed and is gone.
+		if instance != "" || !hadWandb(m) {
+			continue
+		}
+		crs, err := operator.ListCRs(ctx, kubectl.FromContext(ctx), m.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if len(crs) > 0 {
+			continue
+		}
+		pvcs, err := cs.CoreV1().PersistentVolumeClaims(m.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PVCs in %s: %w", m.Namespace, err)
+		}
+		secrets, err := cs.CoreV1().Secrets(m.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Secrets in %s: %w", m.Namespace, err)
+		}
+		var found []kubectl.InventoryEntry
+		for _, pvc := range pvcs.Items {
+			if !kubectl.IsManaged(&pvc) {
+				found = append(found, kubectl.InventoryEntry{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: m.Namespace, Name: pvc.Name})
+			}
+		}
+		for _, secret := range secrets.Items {
+			switch secret.Type {
+			case corev1.SecretTypeServiceAccountToken, "helm.sh/release.v1":
+				continue
+			}
+			if !kubectl.IsManaged(&secret) {
+				found = append(found, kubectl.InventoryEntry{APIVersion: "v1", Kind: "Secret", Namespace: m.Namespace, Name: secret.Name})
+			}
+		}
+		for _, e := range found {
+			if !recorded[e.String()] {
+				orphans = append(orphans, orphan{DetachedObject: kubectl.DetachedObject{InventoryEntry: e}, state: "present"})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// hadWandb reports whether wsm deployed a W&B instance to m's namespace.
+func hadWandb(m kubectl.Marker) bool {
+	if slices.Contains(m.Components, "wandb-cr") || len(m.Detached) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(m.Inventory, func(e kubectl.InventoryEntry) bool { return e.Step == "wandb-cr" })
+}
+
+func printOrphans(orphans []orphan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tKIND\tNAME\tINSTANCE\tDETACHED\tSTATE")
+	for _, o := range orphans {
+		instance, detached := o.Instance, "-"
+		if !o.recorded {
+			instance = "(unrecorded)"
+		} else if !o.DetachedAt.IsZero() {
+			detached = o.DetachedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", o.Namespace, o.Kind, o.Name, valueOr(instance, "-"), detached, o.state)
+	}
+	w.Flush()
+}
+
+type orphansOptions struct {
+	instance string
+	delete   bool
+	yes      bool
+	timeout  time.Duration
+}
+
+// cleanOrphans lists the leftovers of destroyed W&B instances and, with
+// --delete, deletes them and drops them from the markers.
+func cleanOrphans(ctx context.Context, opts orphansOptions) error {
+	orphans, err := findOrphans(ctx, opts.instance)
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		fmt.Println("No data left behind by destroyed W&B instances.")
+		return nil
+	}
+	printOrphans(orphans)
+	if !opts.delete {
+		return nil
+	}
+
+	var targets, unrecorded []kubectl.InventoryEntry
+	forget := map[string][]kubectl.InventoryEntry{}
+	for _, o := range orphans {
+		switch {
+		case o.state == "gone":
+			forget[o.Namespace] = append(forget[o.Namespace], o.InventoryEntry)
+		case o.recorded:
+			targets = append(targets, o.InventoryEntry)
+		default:
+			unrecorded = append(unrecorded, o.InventoryEntry)
+		}
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	if len(targets) > 0 && !opts.yes {
+		fmt.Printf("Type 'delete' to permanently delete %d recorded object(s) and the data they hold: ", len(targets))
+		answer, _ := stdin.ReadString('\n')
+		if strings.TrimSpace(answer) != "delete" {
+			fmt.Println("aborted.")
+			return nil
+		}
+	}
+	// Unrecorded objects are only guessed to be a destroyed instance's, so
+	// they need their own typed confirmation; --yes is not enough.
+	if len(unrecorded) > 0 {
+		if opts.yes {
+			fmt.Printf("! Keeping %d unrecorded object(s); run without --yes to confirm deleting them\n", len(unrecorded))
+		} else {
+			fmt.Printf("Type 'delete unrecorded' to also delete %d unrecorded object(s) no marker ties to a destroyed instance (anything else keeps them): ", len(unrecorded))
+			answer, _ := stdin.ReadString('\n')
+			if strings.TrimSpace(answer) == "delete unrecorded" {
+				targets = append(targets, unrecorded...)
+			} else {
+				fmt.Printf("! Keeping %d unrecorded object(s)\n", len(unrecorded))
+			}
+		}
+	}
+	if len(targets) == 0 && len(forget) == 0 {
+		return nil
+	}
+
+	for _, e := range targets {
+		fmt.Printf("→ Deleting %s...\n", e)
+		if err := kubectl.DeleteInventoryObject(ctx, e); err != nil {
+			fmt.Printf("  ✗ %v\n", err)
+		}
+	}
+	why := waitObjectsGone(ctx, targets, time.Now().Add(opts.timeout))
+	for _, e := range targets {
+		if _, ok := why[e.String()]; !ok {
+			forget[e.Namespace] = append(forget[e.Namespace], e)
+		}
+	}
+	for ns, entries := range forget {
+		if err := kubectl.ForgetDetached(ctx, ns, entries); err != nil {
+			fmt.Printf("  ✗ Failed to update deployment marker in %s: %v\n", ns, err)
+		}
+	}
+
+	if len(why) > 0 {
+		for _, e := range targets {
+			if reason, ok := why[e.String()]; ok {
+				fmt.Printf("  ✗ %s: %s\n", e, reason)
+			}
+		}
+		return fmt.Errorf("%d object(s) remain", len(why))
+	}
+	fmt.Printf("✓ Deleted %d object(s)\n", len(targets))
+	return nil
+}
+
+func wandbOrphansCmd() *cobra.Command {
+	var opts orphansOptions
+
+	cmd := &cobra.Command{
+		Use:   "orphans",
+		Short: "List or delete data left behind by destroyed W&B instances",
+		Long: `List the PVCs and Secrets that W&B instances destroyed with the detach
+retention policy left behind, as recorded in the deployment markers, plus the
+unrecorded ones in namespaces wsm deployed W&B to that no longer hold an
+instance.
+
+With --delete the recorded ones are deleted, after typing 'delete' to confirm,
+and dropped from the markers. The unrecorded ones are only guessed to be a
+destroyed instance's, so they are deleted only after typing 'delete
+unrecorded', which --yes does not skip. A PVC stays terminating while a pod
+still mounts it: stop the detached infrastructure using it first.`,
+		Example: `  wsm deploy-v2 wandb orphans --context prod
+  wsm deploy-v2 wandb orphans --context prod --instance wandb --delete`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cleanOrphans(context.Background(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.instance, "instance", "", "Only the leftovers of this W&B instance (default all, including unrecorded ones)")
+	cmd.Flags().BoolVar(&opts.delete, "delete", false, "Delete the leftovers")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Don't ask for confirmation (unrecorded leftovers are then kept)")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 2*time.Minute, "How long to wait for deleted objects to be gone")
+
+	return cmd
+}
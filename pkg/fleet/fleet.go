@@ -0,0 +1,179 @@
+// Package fleet reads the fleet file `wsm fleet` works from: the clusters
+// (kubeconfig contexts) a set of W&B installs runs in, and the settings each
+// one is deployed, upgraded and checked with.
+package fleet
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// File is the fleet file read by --fleet-file:
+//
+//	defaults:
+//	  wandbVersion: 0.82.2
+//	  mirrorRegistry: harbor.corp:5443
+//	profiles:
+//	  eu:
+//	    mirrorRegistry: harbor.eu.corp:5443
+//	clusters:
+//	  - name: staging
+//	    context: staging-admin
+//	    canary: true
+//	  - name: prod-eu
+//	    context: prod-eu-admin
+//	    profile: eu
+//	    wandbVersion: 0.81.3
+//
+// A cluster's settings are the defaults, overridden by its profile, overridden
+// by the fields set on the cluster itself.
+type File struct {
+	Defaults Profile            `json:"defaults"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	Clusters []Cluster          `json:"clusters"`
+}
+
+// Profile is a set of install settings. Unset fields inherit.
+type Profile struct {
+	WandbVersion         string `json:"wandbVersion,omitempty"`
+	WandbName            string `json:"wandbName,omitempty"`
+	WandbNamespace       string `json:"wandbNamespace,omitempty"`
+	OperatorNamespace    string `json:"operatorNamespace,omitempty"`
+	OperatorChartVersion string `json:"operatorChartVersion,omitempty"`
+	ObservabilityMode    string `json:"observabilityMode,omitempty"`
+	MirrorRegistry       string `json:"mirrorRegistry,omitempty"`
+	InsecureRegistry     *bool  `json:"insecureRegistry,omitempty"`
+	SkipManagedImages    *bool  `json:"skipManagedImages,omitempty"`
+	// DeployArgs are extra `wsm deploy-v2 operator` flags. They accumulate
+	// rather than override: the defaults' come first, then the profile's, then
+	// the cluster's, so a later one wins for a flag given twice.
+	DeployArgs []string `json:"deployArgs,omitempty"`
+}
+
+// Cluster is one entry of the fleet file.
+type Cluster struct {
+	Name string `json:"name"`
+	// Context is the kubeconfig context; defaults to Name.
+	Context string `json:"context,omitempty"`
+	// Canary clusters are upgraded, and must come up, before the rest.
+	Canary      bool   `json:"canary,omitempty"`
+	ProfileName string `json:"profile,omitempty"`
+	Profile     `json:",inline"`
+}
+
+// Target is a cluster with its settings resolved.
+type Target struct {
+	Name     string
+	Context  string
+	Canary   bool
+	Settings Profile
+}
+
+// Load reads and validates a fleet file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fleet file: %w", err)
+	}
+	var f File
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse fleet file %s: %w", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("fleet file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	if len(f.Clusters) == 0 {
+		return fmt.Errorf("no clusters listed")
+	}
+	seen := map[string]bool{}
+	for i, c := range f.Clusters {
+		if c.Name == "" {
+			return fmt.Errorf("cluster %d has no name", i+1)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("cluster %q listed twice", c.Name)
+		}
+		seen[c.Name] = true
+		if c.ProfileName != "" {
+			if _, ok := f.Profiles[c.ProfileName]; !ok {
+				return fmt.Errorf("cluster %q uses unknown profile %q", c.Name, c.ProfileName)
+			}
+		}
+	}
+	return nil
+}
+
+// Targets resolves the clusters, in file order, on top of base (wsm's own
+// defaults). only, when non-empty, selects clusters by name; an unknown name
+// is an error.
+func (f *File) Targets(base Profile, only []string) ([]Target, error) {
+	selected := map[string]bool{}
+	for _, name := range only {
+		selected[name] = false
+	}
+	var targets []Target
+	for _, c := range f.Clusters {
+		if len(only) > 0 {
+			if _, ok := selected[c.Name]; !ok {
+				continue
+			}
+			selected[c.Name] = true
+		}
+		settings := base.merge(f.Defaults)
+		if c.ProfileName != "" {
+			settings = settings.merge(f.Profiles[c.ProfileName])
+		}
+		settings = settings.merge(c.Profile)
+		kubeContext := c.Context
+		if kubeContext == "" {
+			kubeContext = c.Name
+		}
+		targets = append(targets, Target{Name: c.Name, Context: kubeContext, Canary: c.Canary, Settings: settings})
+	}
+	for name, found := range selected {
+		if !found {
+			return nil, fmt.Errorf("cluster %q is not in the fleet file", name)
+		}
+	}
+	return targets, nil
+}
+
+// merge returns p with the fields o sets overriding it.
+func (p Profile) merge(o Profile) Profile {
+	str := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	str(&p.WandbVersion, o.WandbVersion)
+	str(&p.WandbName, o.WandbName)
+	str(&p.WandbNamespace, o.WandbNamespace)
+	str(&p.OperatorNamespace, o.OperatorNamespace)
+	str(&p.OperatorChartVersion, o.OperatorChartVersion)
+	str(&p.ObservabilityMode, o.ObservabilityMode)
+	str(&p.MirrorRegistry, o.MirrorRegistry)
+	if o.InsecureRegistry != nil {
+		p.InsecureRegistry = o.InsecureRegistry
+	}
+	if o.SkipManagedImages != nil {
+		p.SkipManagedImages = o.SkipManagedImages
+	}
+	p.DeployArgs = append(append([]string(nil), p.DeployArgs...), o.DeployArgs...)
+	return p
+}
+
+// Insecure reports whether the mirror registry is plain HTTP or untrusted TLS.
+func (p Profile) Insecure() bool {
+	return p.InsecureRegistry != nil && *p.InsecureRegistry
+}
+
+// SkipManaged reports whether the managed-service images are left out.
+func (p Profile) SkipManaged() bool {
+	return p.SkipManagedImages != nil && *p.SkipManagedImages
+}
@@ -0,0 +1,93 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSkipped is the Result.Err of a cluster a failed canary kept from running.
+var ErrSkipped = errors.New("skipped: a canary cluster failed")
+
+// Result is the outcome of an operation on one cluster.
+type Result struct {
+	Target Target
+	// Cells are the operation's table columns for the cluster.
+	Cells   []string
+	Err     error
+	Elapsed time.Duration
+}
+
+// RunOptions controls how Run schedules an operation.
+type RunOptions struct {
+	// MaxParallel caps how many clusters run at once; 0 or less means all.
+	MaxParallel int
+	// CanaryFirst runs the canary clusters as a first wave and the rest only
+	// once every canary succeeded.
+	CanaryFirst bool
+	// OnDone, if set, is called as each cluster finishes, serially.
+	OnDone func(Result)
+}
+
+// Run calls fn for every target concurrently and returns the results in
+// target order.
+func Run(ctx context.Context, targets []Target, opts RunOptions, fn func(context.Context, Target) ([]string, error)) []Result {
+	results := make([]Result, len(targets))
+	var canaries, rest []int
+	for i, t := range targets {
+		results[i].Target = t
+		if opts.CanaryFirst && t.Canary {
+			canaries = append(canaries, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+
+	var mu sync.Mutex
+	wave := func(indexes []int) bool {
+		limit := opts.MaxParallel
+		if limit <= 0 || limit > len(indexes) {
+			limit = len(indexes)
+		}
+		sem := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+		ok := true
+		for _, i := range indexes {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				start := time.Now()
+				cells, err := fn(ctx, targets[i])
+
+				mu.Lock()
+				defer mu.Unlock()
+				results[i].Cells = cells
+				results[i].Err = err
+				results[i].Elapsed = time.Since(start)
+				if err != nil {
+					ok = false
+				}
+				if opts.OnDone != nil {
+					opts.OnDone(results[i])
+				}
+			}(i)
+		}
+		wg.Wait()
+		return ok
+	}
+
+	if len(canaries) > 0 && !wave(canaries) {
+		for _, i := range rest {
+			results[i].Err = ErrSkipped
+			if opts.OnDone != nil {
+				opts.OnDone(results[i])
+			}
+		}
+		return results
+	}
+	wave(rest)
+	return results
+}
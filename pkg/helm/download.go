@@ -1,6 +1,13 @@
 package helm
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
@@ -10,12 +17,8 @@ import (
 	"helm.sh/helm/v3/pkg/repo"
 	"k8s.io/kubectl/pkg/scheme"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-
-	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
-	batchv1beta1 "k8s.io/api/batch/v1beta1"
-	v1 "k8s.io/api/core/v1"
 )
 
 func DownloadChart(url string, name string, version string, dest string) (string, error) {
@@ -107,6 +110,7 @@ func GetRuntimeObjects(chartPath string, vals map[string]interface{}) ([]runtime
 	return runtimeObejcts, nil
 }
 
+// ExtractImages returns the images objs run, see ExtractImage.
 func ExtractImages(obj []runtime.Object) []string {
 	images := []string{}
 	for _, o := range obj {
@@ -115,41 +119,270 @@ func ExtractImages(obj []runtime.Object) []string {
 	return images
 }
 
+// ExtractImage returns every image obj runs: the containers, init containers
+// and ephemeral containers of every pod template whatever the kind, so custom
+// resources count too, and the images operators are handed through --*image*=
+// flags or *IMAGE* environment variables.
 func ExtractImage(obj runtime.Object) []string {
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = u.UnstructuredContent()
+	} else {
+		c, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil
+		}
+		content = c
+	}
 	var images []string
-	switch typedObj := obj.(type) {
-	case *v1.Pod:
-		for _, container := range typedObj.Spec.Containers {
-			images = append(images, container.Image)
+	walkContainers(content, func(ref string) {
+		images = append(images, ref)
+	})
+	return images
+}
+
+// ChartImage is an image reference found in a chart, with the chart or
+// subchart it comes from, e.g. "operator" or "operator/charts/moco".
+type ChartImage struct {
+	Reference string
+	Chart     string
+	// Rendered is set for images the rendered manifests reference, as opposed
+	// to ones only found in the chart's values (a component the values leave
+	// disabled, or an image an operator runs at runtime).
+	Rendered bool
+}
+
+// imageFlag matches image references passed to a controller on its command
+// line, e.g. moco's --agent-image=ghcr.io/cybozu-go/moco-agent:0.16.0.
+var imageFlag = regexp.MustCompile(`^--?[A-Za-z0-9-]*image[A-Za-z0-9-]*=(.+)$`)
+
+// ManifestImages returns the images of every document of a rendered
+// manifest, as ExtractImage finds them. The chart of each image is read from
+// the "# Source:" comment Helm writes above every document.
+func ManifestImages(manifest string) ([]ChartImage, error) {
+	var images []ChartImage
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, fmt.Errorf("parse rendered manifest: %w", err)
+		}
+		chart := sourceChart(doc)
+		for _, ref := range ExtractImage(&unstructured.Unstructured{Object: obj}) {
+			images = append(images, ChartImage{Reference: ref, Chart: chart, Rendered: true})
+		}
+	}
+	return dedupeImages(images), nil
+}
+
+// sourceChart returns the chart path of a rendered document's
+// "# Source: <chart>/templates/<file>" comment.
+func sourceChart(doc string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		src, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source: ")
+		if !ok {
+			continue
 		}
-	case *v1.ReplicationController:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+		if chart, _, ok := strings.Cut(src, "/templates/"); ok {
+			return chart
 		}
-	case *appsv1.ReplicaSet:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+		return src
+	}
+	return ""
+}
+
+// walkContainers calls fn with every image referenced by a container list
+// anywhere under obj, in a stable order.
+func walkContainers(obj interface{}, fn func(ref string)) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(o))
+		for key := range o {
+			keys = append(keys, key)
 		}
-	case *appsv1.Deployment:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+		sort.Strings(keys)
+		for _, key := range keys {
+			v := o[key]
+			switch key {
+			case "containers", "initContainers", "ephemeralContainers":
+				if list, ok := v.([]interface{}); ok {
+					for _, c := range list {
+						if c, ok := c.(map[string]interface{}); ok {
+							containerImages(c, fn)
+						}
+					}
+					continue
+				}
+			}
+			walkContainers(v, fn)
 		}
-	case *appsv1.StatefulSet:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+	case []interface{}:
+		for _, v := range o {
+			walkContainers(v, fn)
 		}
-	case *appsv1.DaemonSet:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+	}
+}
+
+// containerImages reports a container's own image and the images its
+// arguments and environment name. The container's image may be untagged;
+// flag and environment values only count when they carry a tag or digest.
+func containerImages(c map[string]interface{}, fn func(ref string)) {
+	if image, ok := c["image"].(string); ok && isContainerImage(image) {
+		fn(image)
+	}
+	for _, key := range []string{"command", "args"} {
+		list, _ := c[key].([]interface{})
+		for _, arg := range list {
+			s, _ := arg.(string)
+			if m := imageFlag.FindStringSubmatch(s); m != nil && isImageReference(m[1]) {
+				fn(m[1])
+			}
 		}
-	case *batchv1.Job:
-		for _, container := range typedObj.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+	}
+	env, _ := c["env"].([]interface{})
+	for _, e := range env {
+		e, _ := e.(map[string]interface{})
+		envName, _ := e["name"].(string)
+		value, _ := e["value"].(string)
+		if strings.Contains(strings.ToUpper(envName), "IMAGE") && isImageReference(value) {
+			fn(value)
 		}
-	case *batchv1beta1.CronJob:
-		for _, container := range typedObj.Spec.JobTemplate.Spec.Template.Spec.Containers {
-			images = append(images, container.Image)
+	}
+}
+
+// ValuesImages returns the images a chart's (coalesced) values name, for the
+// ones the rendered manifests don't show: components the values leave
+// disabled and images a controller only runs later. It recognizes
+// {registry, repository, tag|digest} maps, {imageName, imageTag} maps, and
+// full references under "image" or "*Image" keys. subcharts maps each
+// dependency of the chart to whether it is enabled; a disabled one's values
+// are skipped.
+func ValuesImages(chart string, values map[string]interface{}, subcharts map[string]bool) []ChartImage {
+	var images []ChartImage
+	for key, v := range values {
+		if key == "global" {
+			continue
 		}
+		if enabled, ok := subcharts[key]; ok {
+			if sub, isMap := v.(map[string]interface{}); enabled && isMap {
+				images = append(images, ValuesImages(chart+"/charts/"+key, sub, nil)...)
+			}
+			continue
+		}
+		walkValues(key, v, func(ref string) {
+			images = append(images, ChartImage{Reference: ref, Chart: chart})
+		})
 	}
-	return images
+	return dedupeImages(images)
+}
+
+// walkValues calls fn with every image reference under the value at key.
+func walkValues(key string, v interface{}, fn func(ref string)) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := valuesImage(o); ok {
+			fn(ref)
+		}
+		for k, child := range o {
+			walkValues(k, child, fn)
+		}
+	case []interface{}:
+		for _, child := range o {
+			walkValues(key, child, fn)
+		}
+	case string:
+		if (key == "image" || strings.HasSuffix(key, "Image")) && isImageReference(o) {
+			fn(o)
+		}
+	}
+}
+
+// valuesImage composes the reference an image map of a chart's values
+// describes. An empty tag is skipped: charts default it to their appVersion
+// in the template, so the rendered manifest has the real reference.
+func valuesImage(m map[string]interface{}) (string, bool) {
+	str := func(key string) string {
+		switch v := m[key].(type) {
+		case string:
+			return v
+		case nil, map[string]interface{}, []interface{}, bool:
+			return ""
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	var ref string
+	switch {
+	case str("repository") != "":
+		ref = str("repository")
+		if registry := str("registry"); registry != "" {
+			ref = strings.TrimRight(registry, "/") + "/" + ref
+		}
+		if tag := str("tag"); tag != "" {
+			ref += ":" + tag
+		}
+		if digest := str("digest"); digest != "" {
+			ref += "@" + digest
+		}
+	case str("imageName") != "" && str("imageTag") != "":
+		ref = str("imageName") + ":" + str("imageTag")
+	default:
+		return "", false
+	}
+	return ref, isImageReference(ref)
+}
+
+// isContainerImage reports whether s, a container's image field, is a
+// reference rather than empty or an unrendered template.
+func isContainerImage(s string) bool {
+	if s == "" || strings.Contains(s, "{{") {
+		return false
+	}
+	_, err := name.ParseReference(s)
+	return err == nil
+}
+
+// isImageReference reports whether s is a literal, pinned image reference
+// (a tag or a digest), not a template or a bare repository.
+func isImageReference(s string) bool {
+	if s == "" || strings.Contains(s, "{{") || strings.ContainsAny(s, " \t\n") {
+		return false
+	}
+	if _, err := name.ParseReference(s); err != nil {
+		return false
+	}
+	// ParseReference defaults a missing tag to latest; a chart that names an
+	// image without one leaves the tag to its template.
+	last := s[strings.LastIndex(s, "/")+1:]
+	return strings.Contains(last, ":") || strings.Contains(last, "@")
+}
+
+// dedupeImages sorts images by reference and drops repeats, keeping the
+// first chart by name and marking the image rendered if any occurrence was.
+func dedupeImages(images []ChartImage) []ChartImage {
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Reference != images[j].Reference {
+			return images[i].Reference < images[j].Reference
+		}
+		return images[i].Chart < images[j].Chart
+	})
+	seen := map[string]int{}
+	var out []ChartImage
+	for _, img := range images {
+		if i, ok := seen[img.Reference]; ok {
+			out[i].Rendered = out[i].Rendered || img.Rendered
+			continue
+		}
+		seen[img.Reference] = len(out)
+		out = append(out, img)
+	}
+	return out
+}
+
+// MergeImages combines image lists, as dedupeImages does.
+func MergeImages(lists ...[]ChartImage) []ChartImage {
+	var all []ChartImage
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return dedupeImages(all)
 }
@@ -1,8 +1,10 @@
 package helm
 
 import (
+	"github.com/wandb/wsm/pkg/kubectl"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/cli"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
 const (
@@ -13,9 +15,31 @@ var (
 	noopLogger = func(_ string, _ ...interface{}) {}
 )
 
+// InitConfig returns Helm settings and an action config for namespace that
+// reach the cluster with the default kubectl options (kubeconfig, context,
+// impersonation and request timeout).
 func InitConfig(namespace string) (*cli.EnvSettings, *action.Configuration, error) {
+	opts := kubectl.DefaultOptions()
 	settings := cli.New()
 	settings.SetNamespace(namespace)
+	if opts.Kubeconfig != "" {
+		settings.KubeConfig = opts.Kubeconfig
+	}
+	if opts.Context != "" {
+		settings.KubeContext = opts.Context
+	}
+	if opts.Impersonate != "" {
+		settings.KubeAsUser = opts.Impersonate
+	}
+	if len(opts.ImpersonateGroups) > 0 {
+		settings.KubeAsGroups = opts.ImpersonateGroups
+	}
+	if opts.RequestTimeout > 0 {
+		if flags, ok := settings.RESTClientGetter().(*genericclioptions.ConfigFlags); ok {
+			timeout := opts.RequestTimeout.String()
+			flags.Timeout = &timeout
+		}
+	}
 	config := new(action.Configuration)
 	err := config.Init(
 		settings.RESTClientGetter(),
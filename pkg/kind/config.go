@@ -0,0 +1,360 @@
+package kind
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	config "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterConfig customizes the Kind config generateClusterConfig produces.
+type ClusterConfig struct {
+	// File is a Kind config (kind: Cluster, apiVersion:
+	// kind.x-k8s.io/v1alpha4) merged over the generated one, see
+	// mergeClusterConfig.
+	File string
+	// ExtraPortMappings are added to the control-plane node, beside the
+	// HTTP and HTTPS ingress mappings.
+	ExtraPortMappings []config.PortMapping
+	// ExtraMounts are added to every node, e.g. a host directory for local
+	// object storage.
+	ExtraMounts  []config.Mount
+	FeatureGates map[string]bool
+	// APIServerPort is the host port of the API server; 0 picks a free one.
+	APIServerPort int32
+	NodeLabels    []NodeLabel
+	NodeTaints    []NodeTaint
+}
+
+// IsZero reports whether c customizes nothing.
+func (c ClusterConfig) IsZero() bool {
+	return c.File == "" && len(c.ExtraPortMappings) == 0 && len(c.ExtraMounts) == 0 && len(c.FeatureGates) == 0 &&
+		c.APIServerPort == 0 && len(c.NodeLabels) == 0 && len(c.NodeTaints) == 0
+}
+
+// NodeLabel is a Kubernetes label set on the nodes Nodes selects.
+type NodeLabel struct {
+	Nodes NodeSelector
+	Key   string
+	Value string
+}
+
+// NodeTaint is a taint set on the nodes Nodes selects.
+type NodeTaint struct {
+	Nodes  NodeSelector
+	Key    string
+	Value  string
+	Effect string
+}
+
+// NodeSelector picks nodes of the cluster: all, control-plane (every
+// control-plane node), worker (every worker) or worker-N (the Nth worker,
+// from 1).
+type NodeSelector string
+
+var nodeSelectorPattern = regexp.MustCompile(`^(all|control-plane|worker|worker-[1-9][0-9]*)$`)
+
+// matches reports whether s selects node, worker being its position among
+// the workers, from 1 (0 for a control-plane node).
+func (s NodeSelector) matches(node config.Node, worker int) bool {
+	switch s {
+	case "", "all":
+		return true
+	case "control-plane":
+		return node.Role == config.ControlPlaneRole
+	case "worker":
+		return node.Role == config.WorkerRole
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(string(s), "worker-"))
+	return node.Role == config.WorkerRole && worker == n
+}
+
+// splitNodeSelector splits an optional NODES: prefix off a flag value.
+func splitNodeSelector(value string) (NodeSelector, string) {
+	if prefix, rest, ok := strings.Cut(value, ":"); ok && nodeSelectorPattern.MatchString(prefix) {
+		return NodeSelector(prefix), rest
+	}
+	return "all", value
+}
+
+// ParsePortMapping parses HOST:CONTAINER[/PROTOCOL], e.g. 9000:30900/tcp.
+func ParsePortMapping(value string) (config.PortMapping, error) {
+	ports, protocol, _ := strings.Cut(value, "/")
+	host, container, ok := strings.Cut(ports, ":")
+	if !ok {
+		return config.PortMapping{}, fmt.Errorf("invalid port mapping %q (expected HOST:CONTAINER[/PROTOCOL])", value)
+	}
+	hostPort, err := strconv.ParseInt(host, 10, 32)
+	if err != nil {
+		return config.PortMapping{}, fmt.Errorf("invalid port mapping %q: bad host port: %w", value, err)
+	}
+	containerPort, err := strconv.ParseInt(container, 10, 32)
+	if err != nil {
+		return config.PortMapping{}, fmt.Errorf("invalid port mapping %q: bad container port: %w", value, err)
+	}
+	m := config.PortMapping{HostPort: int32(hostPort), ContainerPort: int32(containerPort), Protocol: config.PortMappingProtocolTCP}
+	switch strings.ToUpper(protocol) {
+	case "", "TCP":
+	case "UDP":
+		m.Protocol = config.PortMappingProtocolUDP
+	case "SCTP":
+		m.Protocol = config.PortMappingProtocolSCTP
+	default:
+		return config.PortMapping{}, fmt.Errorf("invalid port mapping %q: protocol must be tcp, udp or sctp", value)
+	}
+	return m, nil
+}
+
+// ParseMount parses HOST_PATH:CONTAINER_PATH[:ro]. A relative host path is
+// taken from the working directory.
+func ParseMount(value string) (config.Mount, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return config.Mount{}, fmt.Errorf("invalid mount %q (expected HOST_PATH:CONTAINER_PATH[:ro])", value)
+	}
+	m := config.Mount{HostPath: parts[0], ContainerPath: parts[1]}
+	if len(parts) == 3 {
+		if parts[2] != "ro" {
+			return config.Mount{}, fmt.Errorf("invalid mount %q: the only option is ro", value)
+		}
+		m.Readonly = true
+	}
+	hostPath, err := filepath.Abs(m.HostPath)
+	if err != nil {
+		return config.Mount{}, fmt.Errorf("invalid mount %q: %w", value, err)
+	}
+	m.HostPath = hostPath
+	return m, nil
+}
+
+// ParseFeatureGate parses Name=true|false.
+func ParseFeatureGate(value string) (string, bool, error) {
+	name, enabled, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return "", false, fmt.Errorf("invalid feature gate %q (expected NAME=true|false)", value)
+	}
+	b, err := strconv.ParseBool(enabled)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid feature gate %q: %w", value, err)
+	}
+	return name, b, nil
+}
+
+// ParseNodeLabel parses [NODES:]KEY=VALUE, NODES being a NodeSelector.
+func ParseNodeLabel(value string) (NodeLabel, error) {
+	nodes, label := splitNodeSelector(value)
+	key, val, ok := strings.Cut(label, "=")
+	if !ok || key == "" {
+		return NodeLabel{}, fmt.Errorf("invalid node label %q (expected [NODES:]KEY=VALUE)", value)
+	}
+	return NodeLabel{Nodes: nodes, Key: key, Value: val}, nil
+}
+
+// ParseNodeTaint parses [NODES:]KEY[=VALUE]:EFFECT, NODES being a
+// NodeSelector.
+func ParseNodeTaint(value string) (NodeTaint, error) {
+	nodes, taint := splitNodeSelector(value)
+	i := strings.LastIndex(taint, ":")
+	if i <= 0 {
+		return NodeTaint{}, fmt.Errorf("invalid node taint %q (expected [NODES:]KEY[=VALUE]:EFFECT)", value)
+	}
+	t := NodeTaint{Nodes: nodes, Effect: taint[i+1:]}
+	t.Key, t.Value, _ = strings.Cut(taint[:i], "=")
+	if !slices.Contains([]string{"NoSchedule", "PreferNoSchedule", "NoExecute"}, t.Effect) {
+		return NodeTaint{}, fmt.Errorf("invalid node taint %q: effect must be NoSchedule, PreferNoSchedule or NoExecute", value)
+	}
+	return t, nil
+}
+
+// customize applies c to the generated config kindConfig: c.File is merged
+// first, and the other options then apply to the merged nodes, so a node the
+// file adds can be labelled, tainted or mounted like the generated ones.
+// Nodes still without an image get nodeImage.
+func (c ClusterConfig) customize(kindConfig *config.Cluster, nodeImage string) error {
+	if c.File != "" {
+		merged, err := mergeClusterConfigFile(*kindConfig, c.File)
+		if err != nil {
+			return err
+		}
+		*kindConfig = merged
+	}
+
+	workers := 0
+	for i := range kindConfig.Nodes {
+		// A node the file adds without a role is a control-plane node, as
+		// Kind defaults it.
+		if kindConfig.Nodes[i].Role == "" {
+			kindConfig.Nodes[i].Role = config.ControlPlaneRole
+		}
+		if kindConfig.Nodes[i].Role == config.WorkerRole {
+			workers++
+		}
+	}
+	for _, selector := range nodeSelectors(c) {
+		if n, err := strconv.Atoi(strings.TrimPrefix(string(selector), "worker-")); err == nil && n > workers {
+			return fmt.Errorf("node selector %s: the cluster has %d worker(s)", selector, workers)
+		}
+	}
+
+	// The extra port mappings go on the first control-plane node, where the
+	// ingress mappings are.
+	portMappingsAdded := false
+	worker := 0
+	for i := range kindConfig.Nodes {
+		node := &kindConfig.Nodes[i]
+		workerIndex := 0
+		if node.Role == config.WorkerRole {
+			worker++
+			workerIndex = worker
+		}
+		if node.Role == config.ControlPlaneRole && !portMappingsAdded {
+			node.ExtraPortMappings = append(node.ExtraPortMappings, c.ExtraPortMappings...)
+			portMappingsAdded = true
+		}
+		node.ExtraMounts = append(node.ExtraMounts, c.ExtraMounts...)
+		for _, l := range c.NodeLabels {
+			if l.Nodes.matches(*node, workerIndex) {
+				if node.Labels == nil {
+					node.Labels = map[string]string{}
+				}
+				node.Labels[l.Key] = l.Value
+			}
+		}
+		var taints []map[string]string
+		for _, t := range c.NodeTaints {
+			if t.Nodes.matches(*node, workerIndex) {
+				taint := map[string]string{"key": t.Key, "effect": t.Effect}
+				if t.Value != "" {
+					taint["value"] = t.Value
+				}
+				taints = append(taints, taint)
+			}
+		}
+		if len(taints) > 0 {
+			// kubeadm registers the node with its taints: the control plane
+			// from its InitConfiguration, workers from their JoinConfiguration.
+			configKind := "JoinConfiguration"
+			if node.Role == config.ControlPlaneRole {
+				configKind = "InitConfiguration"
+			}
+			patch, err := yaml.Marshal(map[string]any{"kind": configKind, "nodeRegistration": map[string]any{"taints": taints}})
+			if err != nil {
+				return fmt.Errorf("encode node taints: %w", err)
+			}
+			node.KubeadmConfigPatches = append(node.KubeadmConfigPatches, string(patch))
+		}
+		if node.Image == "" {
+			node.Image = nodeImage
+		}
+	}
+	if len(c.FeatureGates) > 0 {
+		if kindConfig.FeatureGates == nil {
+			kindConfig.FeatureGates = map[string]bool{}
+		}
+		for name, enabled := range c.FeatureGates {
+			kindConfig.FeatureGates[name] = enabled
+		}
+	}
+	if c.APIServerPort != 0 {
+		kindConfig.Networking.APIServerPort = c.APIServerPort
+	}
+	return nil
+}
+
+func nodeSelectors(c ClusterConfig) []NodeSelector {
+	var selectors []NodeSelector
+	for _, l := range c.NodeLabels {
+		selectors = append(selectors, l.Nodes)
+	}
+	for _, t := range c.NodeTaints {
+		selectors = append(selectors, t.Nodes)
+	}
+	return selectors
+}
+
+// mergeClusterConfigFile merges the Kind config in path over base, see
+// mergeClusterConfig.
+func mergeClusterConfigFile(base config.Cluster, path string) (config.Cluster, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return base, fmt.Errorf("failed to read kind config: %w", err)
+	}
+	var file config.Cluster
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return base, fmt.Errorf("failed to parse kind config %s: %w", path, err)
+	}
+	if file.Kind != "" && file.Kind != "Cluster" || file.APIVersion != "" && file.APIVersion != "kind.x-k8s.io/v1alpha4" {
+		return base, fmt.Errorf("kind config %s: expected kind: Cluster, apiVersion: kind.x-k8s.io/v1alpha4", path)
+	}
+	var over map[string]any
+	if err := yaml.Unmarshal(data, &over); err != nil {
+		return base, fmt.Errorf("failed to parse kind config %s: %w", path, err)
+	}
+	merged, err := mergeClusterConfig(base, over)
+	if err != nil {
+		return base, fmt.Errorf("kind config %s: %w", path, err)
+	}
+	return merged, nil
+}
+
+// mergeClusterConfig merges the Kind config over over base. Maps merge key by
+// key and scalars in over win. The nodes list merges node by node, over's
+// extra nodes being added; other lists (port mappings, mounts, kubeadm and
+// containerd patches...) are appended to base's.
+func mergeClusterConfig(base config.Cluster, over map[string]any) (config.Cluster, error) {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return base, err
+	}
+	var generated map[string]any
+	if err := json.Unmarshal(data, &generated); err != nil {
+		return base, err
+	}
+	merged, err := json.Marshal(mergeConfigValue("", generated, over))
+	if err != nil {
+		return base, err
+	}
+	var out config.Cluster
+	if err := json.Unmarshal(merged, &out); err != nil {
+		return base, err
+	}
+	return out, nil
+}
+
+func mergeConfigValue(key string, base, over any) any {
+	switch o := over.(type) {
+	case map[string]any:
+		b, ok := base.(map[string]any)
+		if !ok {
+			return o
+		}
+		for k, v := range o {
+			b[k] = mergeConfigValue(k, b[k], v)
+		}
+		return b
+	case []any:
+		b, ok := base.([]any)
+		if !ok {
+			return o
+		}
+		if key != "nodes" {
+			return append(b, o...)
+		}
+		for i, v := range o {
+			if i < len(b) {
+				b[i] = mergeConfigValue("", b[i], v)
+			} else {
+				b = append(b, v)
+			}
+		}
+		return b
+	}
+	return over
+}
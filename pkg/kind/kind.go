@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/mirror"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	config "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
@@ -22,9 +24,23 @@ import (
 // Pinned by digest so a mirrored copy must match this exact manifest.
 const DefaultNodeImage = "kindest/node:v1.35.1@sha256:05d7bcdefbda08b4e038f644c4df690cdac3fba8b06f8289f30e10026720a1ab"
 
+// The node ports the control-plane's HTTP and HTTPS host port mappings lead
+// to; the nginx gateway's service listens on them.
+const (
+	HTTPNodePort  = 31437
+	HTTPSNodePort = 30478
+)
+
+// MirroredNodeImage returns where `wsm registry mirror` places DefaultNodeImage
+// in the mirror at host, keeping its digest pin.
+func MirroredNodeImage(host string, layout *mirror.Layout) string {
+	return layout.Image(host, DefaultNodeImage)
+}
+
 // CreateCluster creates a Kind cluster with specified name and number of worker nodes.
-// If nodeImage is empty, DefaultNodeImage is used.
-func CreateCluster(ctx context.Context, name string, workers int, httpPort int32, httpsPort int32, nodeImage string, insecureRegistryHost string) error {
+// If nodeImage is empty, DefaultNodeImage is used. A non-zero registry is
+// configured on every node. custom is applied over the generated config.
+func CreateCluster(ctx context.Context, name string, workers int, httpPort int32, httpsPort int32, nodeImage string, registry Registry, custom ClusterConfig) error {
 	provider := cluster.NewProvider()
 
 	// Check if cluster already exists
@@ -35,21 +51,33 @@ func CreateCluster(ctx context.Context, name string, workers int, httpPort int32
 	if exists {
 		return fmt.Errorf("cluster '%s' already exists", name)
 	}
+	if err := registry.Validate(); err != nil {
+		return err
+	}
 
 	// Generate Kind cluster config
-	kindConfig := generateClusterConfig(workers, httpPort, httpsPort, nodeImage, insecureRegistryHost)
+	kindConfig := generateClusterConfig(workers, httpPort, httpsPort, nodeImage, !registry.IsZero())
+	if nodeImage == "" {
+		nodeImage = DefaultNodeImage
+	}
+	if err := custom.customize(&kindConfig, nodeImage); err != nil {
+		return err
+	}
 	// Create cluster using kind library
 	if err := provider.Create(
 		name,
 		cluster.CreateWithV1Alpha4Config(&kindConfig),
 		cluster.CreateWithDisplayUsage(true),
 		cluster.CreateWithDisplaySalutation(true),
+		// The new context goes into --kubeconfig when one is given, so the
+		// clients that follow find it.
+		cluster.CreateWithKubeconfigPath(kubectl.DefaultOptions().Kubeconfig),
 	); err != nil {
 		return fmt.Errorf("failed to create kind cluster: %w", err)
 	}
 
-	if insecureRegistryHost != "" {
-		if err := writeInsecureRegistryHostsConfig(ctx, name, insecureRegistryHost); err != nil {
+	if !registry.IsZero() {
+		if err := configureRegistry(ctx, name, registry); err != nil {
 			return err
 		}
 	}
@@ -71,7 +99,7 @@ func DeleteCluster(ctx context.Context, name string) error {
 	}
 
 	// Delete cluster using kind library
-	if err := provider.Delete(name, ""); err != nil {
+	if err := provider.Delete(name, kubectl.DefaultOptions().Kubeconfig); err != nil {
 		return fmt.Errorf("failed to delete kind cluster: %w", err)
 	}
 
@@ -136,12 +164,6 @@ func InstallMetricsServer(ctx context.Context) error {
 	return nil
 }
 
-// SetKubectlContext sets the kubectl context to the specified Kind cluster
-func SetKubectlContext(ctx context.Context, name string) {
-	contextName := fmt.Sprintf("kind-%s", name)
-	kubectl.SetContext(contextName)
-}
-
 // CleanupDistDirectory removes the dist/ directory if it exists
 func CleanupDistDirectory() error {
 	if _, err := os.Stat("dist"); err == nil {
@@ -176,10 +198,10 @@ func LoadImageToCluster(ctx context.Context, imageName, clusterName string) erro
 //   - extraPortMappings: host:httpPort → container:httpPort, host:httpsPort → container:httpsPort
 //   - ingress-ready node label so nginx-ingress can bind to those ports
 //
-// When insecureRegistryHost is non-empty, containerd is patched so kubelet pulls
-// from that host over plain HTTP. Pairs with `wsm registry mirror --to <host> --insecure`
-// and `wsm deploy-v2 operator --mirror-registry <host> --insecure-registry`.
-func generateClusterConfig(workers int, httpPort int32, httpsPort int32, nodeImage string, insecureRegistryHost string) config.Cluster {
+// When withRegistry is set, containerd is patched to read per-registry config,
+// which configureRegistry writes so kubelet pulls from the registry (see
+// Registry).
+func generateClusterConfig(workers int, httpPort int32, httpsPort int32, nodeImage string, withRegistry bool) config.Cluster {
 	if nodeImage == "" {
 		nodeImage = DefaultNodeImage
 	}
@@ -188,8 +210,8 @@ func generateClusterConfig(workers int, httpPort int32, httpsPort int32, nodeIma
 		Role:  config.ControlPlaneRole,
 		Image: nodeImage,
 		ExtraPortMappings: []config.PortMapping{
-			{ContainerPort: 31437, HostPort: httpPort, Protocol: config.PortMappingProtocolTCP},
-			{ContainerPort: 30478, HostPort: httpsPort, Protocol: config.PortMappingProtocolTCP},
+			{ContainerPort: HTTPNodePort, HostPort: httpPort, Protocol: config.PortMappingProtocolTCP},
+			{ContainerPort: HTTPSNodePort, HostPort: httpsPort, Protocol: config.PortMappingProtocolTCP},
 		},
 		KubeadmConfigPatches: []string{`kind: InitConfiguration
 nodeRegistration:
@@ -205,9 +227,9 @@ nodeRegistration:
 		kindConfig.Nodes[i+1] = config.Node{Role: config.WorkerRole, Image: nodeImage}
 	}
 
-	if insecureRegistryHost != "" {
+	if withRegistry {
 		// Tell containerd to read per-host config from /etc/containerd/certs.d.
-		// We write hosts.toml there in writeInsecureRegistryHostsConfig after
+		// We write hosts.toml there in configureRegistry after
 		// the cluster comes up.
 		//
 		// We can't put the registry endpoint config directly in the patch:
@@ -232,7 +254,9 @@ nodeRegistration:
 // refs with no override knob, so the only way to make them pull from the mirror
 // is a transparent containerd registry mirror: containerd requests the
 // host-stripped path (e.g. quay.io/strimzi/kafka → <mirror>/v2/strimzi/kafka),
-// which is exactly where `wsm registry mirror` pushes them.
+// which is exactly where `wsm registry mirror` pushes them under the default
+// preserve-path layout. Other layouts add a path prefix via override_path, or
+// skip hosts they rewrite non-uniformly (see mirror.Layout.HostPath).
 //
 // us-docker.pkg.dev is included only for the Bufstream Kafka image
 // (us-docker.pkg.dev/buf-images-1/...), a managed data-plane image the operator
@@ -247,37 +271,10 @@ var upstreamRegistryMirrors = map[string]string{
 	"us-docker.pkg.dev": "https://us-docker.pkg.dev",
 }
 
-func writeInsecureRegistryHostsConfig(ctx context.Context, clusterName, insecureRegistryHost string) error {
-	nodeName := clusterName + "-control-plane"
-
-	// 1. The mirror host itself, served over plain HTTP. Covers references that
-	// already point at <mirror>/... (W&B charts/operator/app images).
-	script := hostsTomlScript(insecureRegistryHost, fmt.Sprintf(`server = "http://%s"
-
-[host."http://%s"]
-  capabilities = ["pull", "resolve"]
-  skip_verify = true
-`, insecureRegistryHost, insecureRegistryHost))
-
-	// 2. Transparent mirrors for the public registries the managed-service images
-	// live on, so their hardcoded refs resolve to the mirror. The `server` line
-	// keeps online installs working: containerd falls back to upstream for any
-	// image not present in the mirror.
-	for host, server := range upstreamRegistryMirrors {
-		script += hostsTomlScript(host, fmt.Sprintf(`server = "%s"
-
-[host."http://%s"]
-  capabilities = ["pull", "resolve"]
-  skip_verify = true
-`, server, insecureRegistryHost))
-	}
-
-	cmd := exec.CommandContext(ctx, "docker", "exec", "-i", nodeName, "sh", "-c", script)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("configure insecure registry %s on %s: %w (%s)", insecureRegistryHost, nodeName, err, strings.TrimSpace(string(out)))
-	}
-	return nil
+// UpstreamRegistryMirrors returns the public registries containerd mirrors to
+// the node registry, with their canonical server URLs.
+func UpstreamRegistryMirrors() map[string]string {
+	return maps.Clone(upstreamRegistryMirrors)
 }
 
 // hostsTomlScript returns a shell snippet that writes hosts.toml for one
@@ -290,6 +287,19 @@ cat > /etc/containerd/certs.d/%s/hosts.toml <<'WSM_EOF'
 `, registryHost, registryHost, hostsToml)
 }
 
+// certsFileScript returns a shell snippet that writes a file, such as a CA
+// certificate, next to a registry host's hosts.toml.
+func certsFileScript(registryHost, name, content string) string {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return fmt.Sprintf(`set -e
+mkdir -p /etc/containerd/certs.d/%s
+cat > /etc/containerd/certs.d/%s/%s <<'WSM_EOF'
+%sWSM_EOF
+`, registryHost, registryHost, name, content)
+}
+
 // InstallIngressNGINX installs the nginx ingress controller for Kind clusters and waits
 // for it to be ready. This enables Ingress resources to work with the host port mappings
 // configured in generateClusterConfig (host 8080 → container 80).
@@ -336,7 +346,7 @@ func WaitForIngressNGINX(ctx context.Context, timeout time.Duration) error {
 }
 
 func ingressControllerReady(ctx context.Context) (bool, error) {
-	_, cs, err := kubectl.GetClientset()
+	cs, err := kubectl.FromContext(ctx).Clientset()
 	if err != nil {
 		return false, err
 	}
@@ -357,7 +367,7 @@ func ingressControllerReady(ctx context.Context) (bool, error) {
 }
 
 func metricsServerReady(ctx context.Context) (bool, error) {
-	_, cs, err := kubectl.GetClientset()
+	cs, err := kubectl.FromContext(ctx).Clientset()
 	if err != nil {
 		return false, err
 	}
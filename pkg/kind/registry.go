@@ -0,0 +1,259 @@
+package kind
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/registryserver"
+	"sigs.k8s.io/kind/pkg/cluster"
+)
+
+// Registry is a registry the nodes' containerd pulls from, standing in for
+// the upstream registries too.
+type Registry struct {
+	// Host is the registry as image references name it, e.g. harbor.corp:443.
+	Host string
+	// Endpoint is the URL the nodes reach Host at, when that differs from
+	// Host; empty means https://<Host>, or http://<Host> when Insecure.
+	Endpoint string
+	// Insecure makes the nodes pull over plain HTTP, or skip TLS verification.
+	Insecure bool
+	// CAFile is a PEM CA bundle the nodes trust for an HTTPS registry.
+	CAFile string
+	// Layout is the registry's mirror layout (nil means preserve-path); it
+	// decides where the upstream mirrors look for each public registry's
+	// images.
+	Layout *mirror.Layout
+}
+
+// IsZero reports whether r configures no registry.
+func (r Registry) IsZero() bool {
+	return r.Host == ""
+}
+
+// EndpointURL returns the URL the nodes reach the registry at.
+func (r Registry) EndpointURL() string {
+	switch {
+	case r.Endpoint != "":
+		return strings.TrimSuffix(r.Endpoint, "/")
+	case r.Insecure:
+		return "http://" + r.Host
+	default:
+		return "https://" + r.Host
+	}
+}
+
+// Validate checks that r can be written to the nodes.
+func (r Registry) Validate() error {
+	if r.Insecure && r.CAFile != "" {
+		return fmt.Errorf("registry %s: a CA file has no effect on an insecure registry", r.Host)
+	}
+	if r.CAFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.CAFile)
+	if err != nil {
+		return fmt.Errorf("read registry CA file: %w", err)
+	}
+	if !strings.Contains(string(data), "-----BEGIN CERTIFICATE-----") {
+		return fmt.Errorf("registry CA file %s holds no PEM certificates", r.CAFile)
+	}
+	return nil
+}
+
+// caPath is where HostsScript puts the CA on the node.
+func (r Registry) caPath() string {
+	return "/etc/containerd/certs.d/" + r.Host + "/ca.crt"
+}
+
+// HostsScript returns a shell script that makes a node's containerd pull from
+// r, both for images referenced by r.Host and, through transparent mirrors,
+// for those of the upstream registries.
+func (r Registry) HostsScript() (string, error) {
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	endpoint := r.EndpointURL()
+
+	// hostEntry is the [host] table pointing containerd at the registry, with
+	// path as the API root when non-empty.
+	hostEntry := func(path string) string {
+		url := endpoint
+		if path != "" {
+			url += path
+		}
+		entry := fmt.Sprintf("[host.%q]\n  capabilities = [\"pull\", \"resolve\"]\n", url)
+		if r.Insecure {
+			entry += "  skip_verify = true\n"
+		}
+		if r.CAFile != "" {
+			entry += fmt.Sprintf("  ca = %q\n", r.caPath())
+		}
+		if path != "" {
+			entry += "  override_path = true\n"
+		}
+		return entry
+	}
+
+	var script string
+	if r.CAFile != "" {
+		ca, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("read registry CA file: %w", err)
+		}
+		script += certsFileScript(r.Host, "ca.crt", string(ca))
+	}
+
+	// 1. The mirror host itself. Covers references that already point at
+	// <mirror>/... (W&B charts/operator/app images).
+	script += hostsTomlScript(r.Host, fmt.Sprintf("server = %q\n\n%s", endpoint, hostEntry("")))
+
+	// 2. Transparent mirrors for the public registries the managed-service images
+	// live on, so their hardcoded refs resolve to the mirror. The `server` line
+	// keeps online installs working: containerd falls back to upstream for any
+	// image not present in the mirror.
+	for host, server := range upstreamRegistryMirrors {
+		prefix, ok := r.Layout.HostPath(host)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: mirror layout %s can't be expressed as a containerd mirror for %s; images the operator hardcodes from %s will pull from upstream\n", r.Layout, host, host)
+			continue
+		}
+		// override_path makes containerd treat the URL path as the API root, so
+		// quay.io/x/y resolves to <mirror>/v2/<prefix>/x/y.
+		path := ""
+		if prefix != "" {
+			path = "/v2/" + prefix
+		}
+		script += hostsTomlScript(host, fmt.Sprintf("server = %q\n\n%s", server, hostEntry(path)))
+	}
+	return script, nil
+}
+
+// configureRegistry writes r's containerd config on every node of the cluster.
+func configureRegistry(ctx context.Context, clusterName string, r Registry) error {
+	script, err := r.HostsScript()
+	if err != nil {
+		return err
+	}
+	nodes, err := cluster.NewProvider().ListNodes(clusterName)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes of kind cluster %s: %w", clusterName, err)
+	}
+	for _, node := range nodes {
+		out, err := exec.CommandContext(ctx, "docker", "exec", "-i", node.String(), "sh", "-c", script).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("configure registry %s on %s: %w (%s)", r.Host, node, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// The local registry is a registry:2 container serving HTTPS, shared by the
+// Kind clusters on this machine. The nodes reach it by name over the Kind
+// network; this machine reaches it on a published localhost port.
+const (
+	LocalRegistryName  = "wsm-registry"
+	localRegistryImage = "registry:2"
+	localRegistryPort  = 5000
+)
+
+// LocalRegistry describes a running local registry.
+type LocalRegistry struct {
+	// Host is where this machine reaches the registry: localhost:<port>.
+	Host string
+	// Endpoint is where the Kind nodes reach it.
+	Endpoint string
+	// CAFile is the CA certificate its serving certificate chains to.
+	CAFile string
+}
+
+// StartLocalRegistry starts the local registry container, publishing it on
+// hostPort, or reuses the one already there. dir keeps its CA and serving
+// certificate, issued by the same code as `wsm registry serve --tls`.
+func StartLocalRegistry(ctx context.Context, hostPort int32, dir string) (LocalRegistry, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return LocalRegistry{}, err
+	}
+	hosts := []string{LocalRegistryName, "localhost", "127.0.0.1", "host.docker.internal"}
+	if _, _, _, err := registryserver.GeneratedCertFiles(dir, hosts); err != nil {
+		return LocalRegistry{}, fmt.Errorf("issue local registry certificate: %w", err)
+	}
+	reg := LocalRegistry{
+		Host:     net.JoinHostPort("localhost", strconv.Itoa(int(hostPort))),
+		Endpoint: fmt.Sprintf("https://%s:%d", LocalRegistryName, localRegistryPort),
+		CAFile:   filepath.Join(dir, registryserver.CACertFile),
+	}
+
+	state, err := dockerOutput(ctx, "inspect", "-f", `{{.State.Running}} {{range .Mounts}}{{if eq .Destination "/certs"}}{{.Source}}{{end}}{{end}}`, LocalRegistryName)
+	if err != nil {
+		// No such container yet.
+		_, err := dockerOutput(ctx, "run", "-d", "--restart=always",
+			"--name", LocalRegistryName,
+			"-p", fmt.Sprintf("127.0.0.1:%d:%d", hostPort, localRegistryPort),
+			"-v", dir+":/certs:ro",
+			"-e", "REGISTRY_HTTP_TLS_CERTIFICATE=/certs/"+registryserver.ServerCertFile,
+			"-e", "REGISTRY_HTTP_TLS_KEY=/certs/"+registryserver.ServerKeyFile,
+			"-e", "REGISTRY_STORAGE_DELETE_ENABLED=true",
+			localRegistryImage)
+		if err != nil {
+			return LocalRegistry{}, fmt.Errorf("start local registry: %w", err)
+		}
+		return reg, nil
+	}
+
+	running, certs, _ := strings.Cut(state, " ")
+	if certs != dir {
+		return LocalRegistry{}, fmt.Errorf("container %s already exists with its certificates in %q; pass that directory, or remove it with 'docker rm -f %s'", LocalRegistryName, certs, LocalRegistryName)
+	}
+	published, err := dockerOutput(ctx, "port", LocalRegistryName, fmt.Sprintf("%d/tcp", localRegistryPort))
+	if err == nil {
+		if _, port, err := net.SplitHostPort(strings.TrimSpace(strings.SplitN(published, "\n", 2)[0])); err == nil && port != strconv.Itoa(int(hostPort)) {
+			return LocalRegistry{}, fmt.Errorf("container %s already publishes port %s; pass that port, or remove it with 'docker rm -f %s'", LocalRegistryName, port, LocalRegistryName)
+		}
+	}
+	if running != "true" {
+		if _, err := dockerOutput(ctx, "start", LocalRegistryName); err != nil {
+			return LocalRegistry{}, fmt.Errorf("start local registry: %w", err)
+		}
+	}
+	return reg, nil
+}
+
+// ConnectLocalRegistry attaches the local registry container to the Kind
+// network, so the nodes of every Kind cluster reach it by name.
+func ConnectLocalRegistry(ctx context.Context) error {
+	network := "kind"
+	if n := os.Getenv("KIND_EXPERIMENTAL_DOCKER_NETWORK"); n != "" {
+		network = n
+	}
+	networks, err := dockerOutput(ctx, "inspect", "-f", `{{range $name, $_ := .NetworkSettings.Networks}}{{$name}} {{end}}`, LocalRegistryName)
+	if err != nil {
+		return fmt.Errorf("inspect local registry: %w", err)
+	}
+	for _, n := range strings.Fields(networks) {
+		if n == network {
+			return nil
+		}
+	}
+	if _, err := dockerOutput(ctx, "network", "connect", network, LocalRegistryName); err != nil {
+		return fmt.Errorf("connect local registry to the %s network: %w", network, err)
+	}
+	return nil
+}
+
+// dockerOutput runs the docker CLI and returns its trimmed output.
+func dockerOutput(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w (%s)", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
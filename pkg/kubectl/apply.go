@@ -0,0 +1,90 @@
+package kubectl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// FieldManager is the server-side apply field manager wsm applies objects as.
+// The fields it owns show up under this name in metadata.managedFields.
+const FieldManager = "wsm"
+
+// Conflict is a field wsm tried to set that another field manager owns.
+type Conflict struct {
+	Object  string // kind and name, e.g. StorageClass gp3 or Issuer wandb/wandb-ca
+	Field   string // e.g. .spec.provisioner
+	Manager string // e.g. argocd-controller
+}
+
+// ConflictError reports the fields a server-side apply left alone because
+// other field managers (GitOps controllers, kubectl edit, other operators)
+// own them. Applying with ConfigOptions.ForceConflicts takes them over.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "server-side apply conflict: %d field(s) are owned by another field manager:\n", len(e.Conflicts))
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "    OBJECT\tFIELD\tMANAGER")
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(w, "    %s\t%s\t%s\n", c.Object, c.Field, c.Manager)
+	}
+	w.Flush()
+	b.WriteString("Re-run with --force-conflicts to take these fields over from their managers, or change them through those managers instead.")
+	return b.String()
+}
+
+// AsConflictError returns the *ConflictError in err's chain, if any.
+func AsConflictError(err error) (*ConflictError, bool) {
+	var conflict *ConflictError
+	ok := errors.As(err, &conflict)
+	return conflict, ok
+}
+
+// conflictManager extracts the manager from an apiserver conflict message,
+// e.g. `conflict with "argocd-controller" using apps/v1`.
+var conflictManager = regexp.MustCompile(`conflict with "([^"]+)"`)
+
+// asConflictError turns the 409 a server-side apply of obj failed with into a
+// ConflictError; other errors are returned unchanged.
+func asConflictError(obj *unstructured.Unstructured, err error) error {
+	var status apierrors.APIStatus
+	if !apierrors.IsConflict(err) || !errors.As(err, &status) {
+		return err
+	}
+	details := status.Status().Details
+	if details == nil {
+		return err
+	}
+
+	object := obj.GetKind() + " " + obj.GetName()
+	if ns := obj.GetNamespace(); ns != "" {
+		object = obj.GetKind() + " " + ns + "/" + obj.GetName()
+	}
+	var conflicts []Conflict
+	for _, cause := range details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		manager := cause.Message
+		if m := conflictManager.FindStringSubmatch(cause.Message); m != nil {
+			manager = m[1]
+		}
+		conflicts = append(conflicts, Conflict{Object: object, Field: cause.Field, Manager: manager})
+	}
+	if len(conflicts) == 0 {
+		return err
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Field < conflicts[j].Field })
+	return &ConflictError{Conflicts: conflicts}
+}
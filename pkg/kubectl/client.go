@@ -0,0 +1,314 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// ConfigOptions select how a Client reaches its cluster and applies objects:
+// the kubectl-style --kubeconfig, --context, --namespace, --as, --as-group,
+// --request-timeout and --force-conflicts flags of the root command.
+type ConfigOptions struct {
+	// Kubeconfig is the kubeconfig file; empty uses $KUBECONFIG (which may
+	// list several files) or ~/.kube/config.
+	Kubeconfig string
+	// Context is the kubeconfig context; empty uses the current context.
+	// Only when both Kubeconfig and Context are empty does a client whose
+	// kubeconfig can't be loaded fall back to the in-cluster config.
+	Context string
+	// Namespace overrides the context's namespace.
+	Namespace string
+	// Impersonate and ImpersonateGroups are the user and groups to act as.
+	Impersonate       string
+	ImpersonateGroups []string
+	// RequestTimeout bounds each API request; zero means no timeout.
+	RequestTimeout time.Duration
+	// ForceConflicts makes server-side applies take over fields other field
+	// managers own instead of failing with a *ConflictError.
+	ForceConflicts bool
+}
+
+// Client talks to one cluster. Build one from a kubeconfig context with
+// NewClient or NewClientWithOptions, from a rest.Config with
+// NewClientForConfig, or from existing clients (such as the client-go fakes)
+// with NewClientFromInterfaces. The package-level functions use the client
+// their context carries (WithClient), or Default.
+type Client struct {
+	opts ConfigOptions
+
+	mu           sync.Mutex
+	loaded       bool
+	clientConfig clientcmd.ClientConfig
+	config       *rest.Config
+	clientset    kubernetes.Interface
+	dynamic      dynamic.Interface
+	mapper       meta.RESTMapper
+	initErr      error
+}
+
+// NewClient returns a client for kubeContext, or for the current context when
+// it is empty, with the rest of the default options (SetDefaultOptions).
+func NewClient(kubeContext string) *Client {
+	opts := DefaultOptions()
+	if kubeContext != "" {
+		opts.Context = kubeContext
+	}
+	return NewClientWithOptions(opts)
+}
+
+// NewClientWithOptions returns a client configured by opts. The kubeconfig is
+// read on first use, falling back to the in-cluster config when opts names
+// neither a kubeconfig nor a context.
+func NewClientWithOptions(opts ConfigOptions) *Client {
+	return &Client{opts: opts}
+}
+
+// NewClientForConfig returns a client for the cluster config points at.
+func NewClientForConfig(config *rest.Config) (*Client, error) {
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	return &Client{loaded: true, config: config, clientset: cs, dynamic: dyn}, nil
+}
+
+// NewClientFromInterfaces wraps existing clients, e.g. fake.NewSimpleClientset
+// and dynamicfake.NewSimpleDynamicClient in tests. mapper may be nil, in which
+// case it is discovered through clientset. The client has no rest.Config, so
+// port-forwarding and Helm are unavailable through it.
+func NewClientFromInterfaces(clientset kubernetes.Interface, dyn dynamic.Interface, mapper meta.RESTMapper) *Client {
+	return &Client{loaded: true, clientset: clientset, dynamic: dyn, mapper: mapper}
+}
+
+// Context returns the kubeconfig context the client was built for; empty for
+// the current context or a client built from a config or interfaces.
+func (c *Client) Context() string {
+	return c.opts.Context
+}
+
+// Options returns the options the client was built with.
+func (c *Client) Options() ConfigOptions {
+	return c.opts
+}
+
+// Namespace returns the namespace commands default to, as kubectl does: the
+// --namespace override, else the kubeconfig context's namespace, else
+// "default".
+func (c *Client) Namespace() string {
+	if c.opts.Namespace != "" {
+		return c.opts.Namespace
+	}
+	c.load()
+	if c.clientConfig != nil {
+		if ns, _, err := c.clientConfig.Namespace(); err == nil && ns != "" {
+			return ns
+		}
+	}
+	return metav1.NamespaceDefault
+}
+
+func (c *Client) load() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = c.opts.Kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{
+		CurrentContext: c.opts.Context,
+		Context:        clientcmdapi.Context{Namespace: c.opts.Namespace},
+		AuthInfo: clientcmdapi.AuthInfo{
+			Impersonate:       c.opts.Impersonate,
+			ImpersonateGroups: c.opts.ImpersonateGroups,
+		},
+	}
+	if c.opts.RequestTimeout > 0 {
+		configOverrides.Timeout = c.opts.RequestTimeout.String()
+	}
+
+	c.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+	config, err := c.clientConfig.ClientConfig()
+	if err != nil {
+		// fallback to in-cluster config; if that also fails, keep the kubeconfig error (more actionable).
+		// An explicit kubeconfig or context never falls back: a typo must not
+		// silently target the cluster wsm happens to run in.
+		var inClusterErr error
+		if c.opts.Kubeconfig != "" || c.opts.Context != "" {
+			inClusterErr = err
+		} else {
+			config, inClusterErr = rest.InClusterConfig()
+		}
+		if inClusterErr != nil {
+			if c.opts.Context != "" {
+				c.initErr = fmt.Errorf("failed to load kubeconfig for context %q: %w", c.opts.Context, err)
+			} else {
+				c.initErr = fmt.Errorf("failed to load kubeconfig: %w", err)
+			}
+			return
+		}
+		config.Impersonate = rest.ImpersonationConfig{UserName: c.opts.Impersonate, Groups: c.opts.ImpersonateGroups}
+		config.Timeout = c.opts.RequestTimeout
+	}
+
+	c.config = config
+	c.clientset, _ = kubernetes.NewForConfig(config)
+	c.dynamic, _ = dynamic.NewForConfig(config)
+}
+
+// err reports why a client is unavailable, preferring the captured kubeconfig error
+// over a bare os.ErrNotExist so callers surface something actionable (e.g. an unknown context).
+func (c *Client) err() error {
+	if c.initErr != nil {
+		return c.initErr
+	}
+	return os.ErrNotExist
+}
+
+// Config returns the client's rest.Config.
+func (c *Client) Config() (*rest.Config, error) {
+	c.load()
+	if c.config == nil {
+		if c.initErr == nil && c.clientset != nil {
+			return nil, errNoRESTConfig
+		}
+		return nil, c.err()
+	}
+	return c.config, nil
+}
+
+// Clientset returns the typed client.
+func (c *Client) Clientset() (kubernetes.Interface, error) {
+	c.load()
+	if c.clientset == nil {
+		return nil, c.err()
+	}
+	return c.clientset, nil
+}
+
+// Dynamic returns the dynamic client.
+func (c *Client) Dynamic() (dynamic.Interface, error) {
+	c.load()
+	if c.dynamic == nil {
+		return nil, c.err()
+	}
+	return c.dynamic, nil
+}
+
+// RESTMapper returns the client's REST mapper, discovering the cluster's API
+// resources on first use.
+func (c *Client) RESTMapper() (meta.RESTMapper, error) {
+	c.load()
+	c.mu.Lock()
+	mapper := c.mapper
+	c.mu.Unlock()
+	if mapper != nil {
+		return mapper, nil
+	}
+	return c.RefreshRESTMapper()
+}
+
+// RefreshRESTMapper rediscovers the cluster's API resources, e.g. after CRDs
+// were installed.
+func (c *Client) RefreshRESTMapper() (meta.RESTMapper, error) {
+	c.load()
+	if c.clientset == nil {
+		return nil, c.err()
+	}
+	gr, err := restmapper.GetAPIGroupResources(c.clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(gr)
+	c.mu.Lock()
+	c.mapper = mapper
+	c.mu.Unlock()
+	return mapper, nil
+}
+
+// IsConnected reports whether the client could be built.
+func (c *Client) IsConnected() bool {
+	_, err := c.Clientset()
+	return err == nil
+}
+
+var (
+	defaultMu      sync.Mutex
+	defaultOptions ConfigOptions
+	defaultClient  = NewClientWithOptions(ConfigOptions{})
+)
+
+// Default returns the process-wide client the package-level functions use
+// when their context carries none; SetContext selects its kubeconfig context.
+func Default() *Client {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultClient
+}
+
+// SetDefault replaces the process-wide client.
+func SetDefault(c *Client) {
+	if c == nil {
+		c = NewClient("")
+	}
+	defaultMu.Lock()
+	defaultClient = c
+	defaultMu.Unlock()
+}
+
+// DefaultOptions returns the options NewClient builds on.
+func DefaultOptions() ConfigOptions {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultOptions
+}
+
+// SetDefaultOptions sets the options NewClient builds on, and replaces the
+// process-wide client with one built from them.
+func SetDefaultOptions(opts ConfigOptions) {
+	defaultMu.Lock()
+	defaultOptions = opts
+	defaultClient = NewClientWithOptions(opts)
+	defaultMu.Unlock()
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx whose package-level kubectl calls, and the
+// pkg/kind functions given it, talk to c instead of Default.
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// FromContext returns the client ctx carries, or Default.
+func FromContext(ctx context.Context) *Client {
+	if ctx != nil {
+		if c, ok := ctx.Value(clientKey{}).(*Client); ok && c != nil {
+			return c
+		}
+	}
+	return Default()
+}
+
+// errNoRESTConfig is returned for operations that need a rest.Config by a
+// client built from interfaces.
+var errNoRESTConfig = errors.New("kubernetes client has no rest config")
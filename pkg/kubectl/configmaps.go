@@ -7,10 +7,27 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 )
 
 func GetConfigMap(ctx context.Context, name, namespace string) (*v1.ConfigMap, error) {
-	_, cs, err := GetClientset()
+	return FromContext(ctx).GetConfigMap(ctx, name, namespace)
+}
+
+func DeleteConfigMap(ctx context.Context, name, namespace string) error {
+	return FromContext(ctx).DeleteConfigMap(ctx, name, namespace)
+}
+
+func UpsertConfigMap(data map[string]string, name string, namespace string) error {
+	return Default().UpsertConfigMap(context.Background(), data, name, namespace)
+}
+
+func ListConfigMaps(ctx context.Context, name string) ([]v1.ConfigMap, error) {
+	return FromContext(ctx).ListConfigMaps(ctx, name)
+}
+
+func (c *Client) GetConfigMap(ctx context.Context, name, namespace string) (*v1.ConfigMap, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return nil, err
 	}
@@ -22,8 +39,8 @@ func GetConfigMap(ctx context.Context, name, namespace string) (*v1.ConfigMap, e
 	return cm, nil
 }
 
-func DeleteConfigMap(ctx context.Context, name, namespace string) error {
-	_, cs, err := GetClientset()
+func (c *Client) DeleteConfigMap(ctx context.Context, name, namespace string) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
@@ -38,49 +55,28 @@ func DeleteConfigMap(ctx context.Context, name, namespace string) error {
 	return nil
 }
 
-func UpsertConfigMap(data map[string]string, name string, namespace string) error {
-	ctx := context.Background()
-	_, cs, err := GetClientset() // Assuming GetClientset() returns the context and clientset
+// UpsertConfigMap server-side applies a wsm-owned ConfigMap holding exactly
+// data. wsm is the only writer of these ConfigMaps, so it always takes
+// ownership of their fields.
+func (c *Client) UpsertConfigMap(ctx context.Context, data map[string]string, name string, namespace string) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return fmt.Errorf("failed to get clientset: %w", err)
 	}
 
-	// Try to get the existing ConfigMap
-	existingConfigMap, err := cs.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
-	if err != nil {
-		// If the ConfigMap doesn't exist, create it
-		if errors.IsNotFound(err) {
-			configMap := &v1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      name,
-					Namespace: namespace,
-					Labels:    map[string]string{"app.kubernetes.io/managed-by": "wsm"},
-				},
-				Data: data,
-			}
-
-			_, err = cs.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to create ConfigMap: %w", err)
-			}
-			return nil
-		}
-		// If there was another error, return it
-		return fmt.Errorf("failed to get ConfigMap: %w", err)
-	}
-
-	// If the ConfigMap exists, update it
-	existingConfigMap.Data = data
-	_, err = cs.CoreV1().ConfigMaps(namespace).Update(ctx, existingConfigMap, metav1.UpdateOptions{})
+	configMap := corev1ac.ConfigMap(name, namespace).
+		WithLabels(map[string]string{"app.kubernetes.io/managed-by": "wsm"}).
+		WithData(data)
+	_, err = cs.CoreV1().ConfigMaps(namespace).Apply(ctx, configMap, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
 	if err != nil {
-		return fmt.Errorf("failed to update ConfigMap: %w", err)
+		return fmt.Errorf("failed to apply ConfigMap: %w", err)
 	}
 
 	return nil
 }
 
-func ListConfigMaps(ctx context.Context, name string) ([]v1.ConfigMap, error) {
-	_, cs, err := GetClientset()
+func (c *Client) ListConfigMaps(ctx context.Context, name string) ([]v1.ConfigMap, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,245 @@
+package kubectl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The label and annotations wsm stamps on every object it creates. Objects
+// Helm installs carry Helm's own labels instead and are tracked as releases.
+const (
+	ManagedByLabel    = "app.kubernetes.io/managed-by"
+	ManagedByValue    = "wsm"
+	StepAnnotation    = "wsm.wandb.com/step"
+	VersionAnnotation = "wsm.wandb.com/version"
+)
+
+// WSMVersion is the wsm version recorded on the objects it creates; main sets
+// it from its build stamp.
+var WSMVersion = "dev"
+
+// InventoryEntry is one object wsm created, as recorded in the deployment
+// marker's inventory.
+type InventoryEntry struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Step is the marker component that created the object, e.g. wandb-cr.
+	Step       string `json:"step,omitempty"`
+	WSMVersion string `json:"wsmVersion,omitempty"`
+}
+
+// GroupVersionKind returns the entry's GVK.
+func (e InventoryEntry) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(e.APIVersion, e.Kind)
+}
+
+// String returns the entry as Kind namespace/name.
+func (e InventoryEntry) String() string {
+	if e.Namespace == "" {
+		return e.Kind + " " + e.Name
+	}
+	return e.Kind + " " + e.Namespace + "/" + e.Name
+}
+
+// key identifies the object regardless of step and version.
+func (e InventoryEntry) key() string {
+	return e.GroupVersionKind().GroupKind().String() + "/" + e.Namespace + "/" + e.Name
+}
+
+// Inventory collects the objects created while it is carried in a context
+// (see WithInventory). It is safe for concurrent use.
+type Inventory struct {
+	mu      sync.Mutex
+	entries []InventoryEntry
+}
+
+// Entries returns the recorded objects in the order they were created.
+func (inv *Inventory) Entries() []InventoryEntry {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return append([]InventoryEntry(nil), inv.entries...)
+}
+
+func (inv *Inventory) add(e InventoryEntry) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.entries = mergeInventory(inv.entries, []InventoryEntry{e})
+}
+
+type inventoryKey struct{}
+
+type stepKey struct{}
+
+// WithInventory returns a copy of ctx in which the objects wsm creates are
+// recorded into inv. CreateDeploymentMarker writes them to the marker.
+func WithInventory(ctx context.Context, inv *Inventory) context.Context {
+	return context.WithValue(ctx, inventoryKey{}, inv)
+}
+
+// InventoryFromContext returns the inventory ctx carries, or nil.
+func InventoryFromContext(ctx context.Context) *Inventory {
+	inv, _ := ctx.Value(inventoryKey{}).(*Inventory)
+	return inv
+}
+
+// WithStep returns a copy of ctx whose created objects are attributed to step,
+// the marker component doing the work (operator, nginx-gateway, wandb-cr...).
+func WithStep(ctx context.Context, step string) context.Context {
+	return context.WithValue(ctx, stepKey{}, step)
+}
+
+func stepFromContext(ctx context.Context) string {
+	step, _ := ctx.Value(stepKey{}).(string)
+	return step
+}
+
+// IsManaged reports whether obj carries wsm's managed-by label.
+func IsManaged(obj metav1.Object) bool {
+	return obj.GetLabels()[ManagedByLabel] == ManagedByValue
+}
+
+// Stamp sets wsm's managed-by label and its step and version annotations on
+// obj. existing is the live object, if any: the step and version it was first
+// created with are kept rather than overwritten.
+func Stamp(ctx context.Context, obj, existing metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ManagedByLabel] = ManagedByValue
+	obj.SetLabels(labels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	var prior map[string]string
+	if existing != nil {
+		prior = existing.GetAnnotations()
+	}
+	if step := prior[StepAnnotation]; step != "" {
+		annotations[StepAnnotation] = step
+	} else if step := stepFromContext(ctx); step != "" {
+		annotations[StepAnnotation] = step
+	}
+	if version := prior[VersionAnnotation]; version != "" {
+		annotations[VersionAnnotation] = version
+	} else {
+		annotations[VersionAnnotation] = WSMVersion
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// Record adds an object wsm created to ctx's inventory, if it carries one.
+func Record(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string) {
+	inv := InventoryFromContext(ctx)
+	if inv == nil {
+		return
+	}
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	inv.add(InventoryEntry{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  namespace,
+		Name:       name,
+		Step:       stepFromContext(ctx),
+		WSMVersion: WSMVersion,
+	})
+}
+
+// mergeInventory appends the entries of add not already in base. An object
+// keeps the step and version it was first recorded with.
+func mergeInventory(base, add []InventoryEntry) []InventoryEntry {
+	seen := make(map[string]bool, len(base))
+	for _, e := range base {
+		seen[e.key()] = true
+	}
+	for _, e := range add {
+		if !seen[e.key()] {
+			seen[e.key()] = true
+			base = append(base, e)
+		}
+	}
+	return base
+}
+
+// removeInventory returns base without the objects in drop.
+func removeInventory(base, drop []InventoryEntry) []InventoryEntry {
+	dropped := make(map[string]bool, len(drop))
+	for _, e := range drop {
+		dropped[e.key()] = true
+	}
+	var kept []InventoryEntry
+	for _, e := range base {
+		if !dropped[e.key()] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+func parseInventory(data string) ([]InventoryEntry, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var entries []InventoryEntry
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("parse marker inventory: %w", err)
+	}
+	return entries, nil
+}
+
+func formatInventory(entries []InventoryEntry) (string, error) {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode marker inventory: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetInventoryObject returns the live object e refers to, or nil if it is gone,
+// including when its kind no longer exists.
+func (c *Client) GetInventoryObject(ctx context.Context, e InventoryEntry) (*unstructured.Unstructured, error) {
+	dr, err := c.resourceFor(e.GroupVersionKind(), e.Namespace)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	obj, err := dr.Get(ctx, e.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get %s: %w", e, err)
+	}
+	return obj, nil
+}
+
+// DeleteInventoryObject deletes the object e refers to, letting its dependents
+// go in the background. An object already gone is not an error.
+func (c *Client) DeleteInventoryObject(ctx context.Context, e InventoryEntry) error {
+	dr, err := c.resourceFor(e.GroupVersionKind(), e.Namespace)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+	propagation := metav1.DeletePropagationBackground
+	if err := dr.Delete(ctx, e.Name, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete %s: %w", e, err)
+	}
+	return nil
+}
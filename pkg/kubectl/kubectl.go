@@ -5,9 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
-	"sync"
 
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -23,158 +20,99 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
-var (
-	config      *rest.Config
-	clientset   *kubernetes.Clientset
-	dynamicHost *dynamic.DynamicClient
-	mapper      meta.RESTMapper
-	once        sync.Once
-	mapperOnce  sync.Once
-	kubeContext string
-	initErr     error
-)
-
-// clientInitErr reports why the k8s clients are unavailable, preferring the captured kubeconfig error
-// over a bare os.ErrNotExist so callers surface something actionable (e.g. an unknown context).
-func clientInitErr() error {
-	if initErr != nil {
-		return initErr
-	}
-	return os.ErrNotExist
-}
-
 func SetContext(ctx string) {
-	kubeContext = ctx
+	SetDefault(NewClient(ctx))
 }
 
 func GetContext() string {
-	return kubeContext
+	return Default().Context()
 }
 
-// ResetClients resets the cached k8s clients so the next call re-initializes
-// with the current kubeContext. Must be called after SetContext.
+// ResetClients drops the default client's cached connection so the next call
+// re-reads the kubeconfig for the current context.
 func ResetClients() {
-	config = nil
-	clientset = nil
-	dynamicHost = nil
-	mapper = nil
-	initErr = nil
-	once = sync.Once{}
-	mapperOnce = sync.Once{}
+	SetContext(GetContext())
 }
 
-func initMapper() {
-	mapperOnce.Do(func() {
-		if clientset != nil {
-			gr, err := restmapper.GetAPIGroupResources(clientset.Discovery())
-			if err != nil {
-				return
-			}
-			mapper = restmapper.NewDiscoveryRESTMapper(gr)
-		}
-	})
+func GetConfig() (*rest.Config, error) {
+	return Default().Config()
 }
 
-func initConfig() {
-	once.Do(func() {
-		kubeconfig := os.Getenv("KUBECONFIG")
-		if kubeconfig == "" {
-			home := homedir.HomeDir()
-			if home != "" {
-				kubeconfig = filepath.Join(home, ".kube", "config")
-			}
-		}
+func GetDynamicClientset() (*rest.Config, dynamic.Interface, error) {
+	c := Default()
+	dyn, err := c.Dynamic()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.config, dyn, nil
+}
 
-		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-		loadingRules.ExplicitPath = kubeconfig
-		configOverrides := &clientcmd.ConfigOverrides{}
-		if kubeContext != "" {
-			configOverrides.CurrentContext = kubeContext
-		}
+func GetClientset() (*rest.Config, kubernetes.Interface, error) {
+	c := Default()
+	cs, err := c.Clientset()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.config, cs, nil
+}
 
-		var err error
-		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides).ClientConfig()
-		if err != nil {
-			// fallback to in-cluster config; if that also fails, keep the kubeconfig error (more actionable).
-			var inClusterErr error
-			config, inClusterErr = rest.InClusterConfig()
-			if inClusterErr != nil {
-				if kubeContext != "" {
-					initErr = fmt.Errorf("failed to load kubeconfig for context %q: %w", kubeContext, err)
-				} else {
-					initErr = fmt.Errorf("failed to load kubeconfig: %w", err)
-				}
-				config = nil
-				return
-			}
-		}
+func GetRESTMapper() (meta.RESTMapper, error) {
+	return Default().RESTMapper()
+}
 
-		clientset, _ = kubernetes.NewForConfig(config)
-		dynamicHost, _ = dynamic.NewForConfig(config)
+func RefreshRESTMapper() (meta.RESTMapper, error) {
+	return Default().RefreshRESTMapper()
+}
 
-		initMapper()
-	})
+func IsConnectedToCluster() bool {
+	return Default().IsConnected()
 }
 
-func GetConfig() (*rest.Config, error) {
-	initConfig()
-	if config == nil {
-		return nil, clientInitErr()
-	}
-	return config, nil
+func ApplyYAML(ctx context.Context, yamlContent []byte) error {
+	return FromContext(ctx).ApplyYAML(ctx, yamlContent)
 }
 
-func GetDynamicClientset() (*rest.Config, *dynamic.DynamicClient, error) {
-	initConfig()
-	if config == nil || dynamicHost == nil {
-		return nil, nil, clientInitErr()
-	}
-	return config, dynamicHost, nil
+func PatchDeployment(ctx context.Context, name, namespace string, patchType types.PatchType, patch []byte) error {
+	return FromContext(ctx).PatchDeployment(ctx, name, namespace, patchType, patch)
 }
 
-func GetClientset() (*rest.Config, *kubernetes.Clientset, error) {
-	initConfig()
-	if config == nil || clientset == nil {
-		return nil, nil, clientInitErr()
-	}
-	return config, clientset, nil
+func DeleteYAML(ctx context.Context, yamlContent []byte) error {
+	return FromContext(ctx).DeleteYAML(ctx, yamlContent)
 }
 
-func GetRESTMapper() (meta.RESTMapper, error) {
-	initConfig()
-	initMapper()
-	if mapper == nil {
-		return nil, os.ErrNotExist
-	}
-	return mapper, nil
+func DeleteCR(ctx context.Context, name, namespace string) error {
+	return FromContext(ctx).DeleteCR(ctx, name, namespace)
 }
 
-func RefreshRESTMapper() (meta.RESTMapper, error) {
-	initConfig()
-	if clientset != nil {
-		gr, err := restmapper.GetAPIGroupResources(clientset.Discovery())
-		if err != nil {
-			return nil, err
-		}
-		mapper = restmapper.NewDiscoveryRESTMapper(gr)
-	}
-	if mapper == nil {
-		return nil, os.ErrNotExist
-	}
-	return mapper, nil
+func ApplyUnstructured(ctx context.Context, obj *unstructured.Unstructured) error {
+	return FromContext(ctx).ApplyUnstructured(ctx, obj)
 }
 
-func IsConnectedToCluster() bool {
-	_, _, err := GetClientset()
-	return err == nil
+func ApplyCertificate(ctx context.Context, cert *certmanagerv1.Certificate) error {
+	return FromContext(ctx).ApplyCertificate(ctx, cert)
 }
 
-func ApplyYAML(ctx context.Context, yamlContent []byte) error {
+func ApplyIssuer(ctx context.Context, issuer *certmanagerv1.Issuer) error {
+	return FromContext(ctx).ApplyIssuer(ctx, issuer)
+}
+
+func ApplyClusterIssuer(ctx context.Context, issuer *certmanagerv1.ClusterIssuer) error {
+	return FromContext(ctx).ApplyClusterIssuer(ctx, issuer)
+}
+
+func ApplyIngressClass(ctx context.Context, ingressClass networkingv1.IngressClass) error {
+	return FromContext(ctx).ApplyIngressClass(ctx, ingressClass)
+}
+
+func ApplyStorageClass(ctx context.Context, storageClass *storagev1.StorageClass) error {
+	return FromContext(ctx).ApplyStorageClass(ctx, storageClass)
+}
+
+// ApplyYAML server-side applies every object in a multi-document manifest.
+func (c *Client) ApplyYAML(ctx context.Context, yamlContent []byte) error {
+	var conflicts []Conflict
 	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(yamlContent), 4096)
 	for {
 		obj := &unstructured.Unstructured{}
@@ -189,34 +127,43 @@ func ApplyYAML(ctx context.Context, yamlContent []byte) error {
 			continue
 		}
 
-		if err := ApplyUnstructured(ctx, obj); err != nil {
-			return err
+		// Conflicts are collected so one report covers every object; the
+		// objects without any are still applied.
+		if err := c.ApplyUnstructured(ctx, obj); err != nil {
+			conflict, ok := AsConflictError(err)
+			if !ok {
+				return err
+			}
+			conflicts = append(conflicts, conflict.Conflicts...)
 		}
 	}
 
+	if len(conflicts) > 0 {
+		return &ConflictError{Conflicts: conflicts}
+	}
 	return nil
 }
 
-func PatchDeployment(ctx context.Context, name, namespace string, patchType types.PatchType, patch []byte) error {
-	_, cs, err := GetClientset()
+func (c *Client) PatchDeployment(ctx context.Context, name, namespace string, patchType types.PatchType, patch []byte) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
 
-	_, err = cs.AppsV1().Deployments(namespace).Patch(ctx, name, patchType, patch, metav1.PatchOptions{})
+	_, err = cs.AppsV1().Deployments(namespace).Patch(ctx, name, patchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
 	if err != nil {
 		return fmt.Errorf("failed to patch deployment %s/%s: %w", namespace, name, err)
 	}
 	return nil
 }
 
-func DeleteYAML(ctx context.Context, yamlContent []byte) error {
-	_, dyn, err := GetDynamicClientset()
+func (c *Client) DeleteYAML(ctx context.Context, yamlContent []byte) error {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return err
 	}
 
-	mapper, err := GetRESTMapper()
+	mapper, err := c.RESTMapper()
 	if err != nil {
 		return err
 	}
@@ -264,18 +211,18 @@ func DeleteYAML(ctx context.Context, yamlContent []byte) error {
 	return nil
 }
 
-func DeleteCR(ctx context.Context, name, namespace string) error {
+func (c *Client) DeleteCR(ctx context.Context, name, namespace string) error {
 	gvk := schema.GroupVersionKind{
 		Group:   "apps.wandb.com",
 		Version: "v2",
 		Kind:    "WeightsAndBiases",
 	}
-	_, dyn, err := GetDynamicClientset()
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return err
 	}
 
-	mapper, err := GetRESTMapper()
+	mapper, err := c.RESTMapper()
 	if err != nil {
 		return err
 	}
@@ -303,53 +250,80 @@ func DeleteCR(ctx context.Context, name, namespace string) error {
 	return nil
 }
 
-func ApplyUnstructured(ctx context.Context, obj *unstructured.Unstructured) error {
-	_, dyn, err := GetDynamicClientset()
+// ApplyUnstructured server-side applies obj as FieldManager. Fields another
+// manager owns come back as a *ConflictError unless the client was built with
+// ForceConflicts.
+func (c *Client) ApplyUnstructured(ctx context.Context, obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	dr, err := c.resourceFor(gvk, obj.GetNamespace())
 	if err != nil {
 		return err
 	}
 
-	mapper, err := GetRESTMapper()
-	if err != nil {
-		return err
+	// Only objects wsm creates, or created on an earlier run, are stamped and
+	// recorded; one that already exists under another owner is just applied.
+	existing, err := dr.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get object %s %s/%s: %w", gvk, obj.GetNamespace(), obj.GetName(), err)
+	}
+	owned := errors.IsNotFound(err) || IsManaged(existing)
+	if owned {
+		if existing != nil {
+			Stamp(ctx, obj, existing)
+		} else {
+			Stamp(ctx, obj, nil)
+		}
 	}
 
-	gvk := obj.GroupVersionKind()
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	_, err = dr.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        c.opts.ForceConflicts,
+	})
 	if err != nil {
-		// If mapping fails, try refreshing the mapper as CRDs might have been just installed
-		if refreshedMapper, refreshErr := RefreshRESTMapper(); refreshErr == nil {
-			mapping, err = refreshedMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if conflict, ok := AsConflictError(asConflictError(obj, err)); ok {
+			return conflict
 		}
+		return fmt.Errorf("failed to apply object %s %s/%s: %w", gvk, obj.GetNamespace(), obj.GetName(), err)
+	}
 
-		if err != nil {
-			return fmt.Errorf("failed to get mapping for %s: %w", gvk, err)
-		}
+	if owned {
+		Record(ctx, gvk, obj.GetNamespace(), obj.GetName())
 	}
+	return nil
+}
 
-	var dr dynamic.ResourceInterface
-	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-		dr = dyn.Resource(mapping.Resource).Namespace(obj.GetNamespace())
-	} else {
-		dr = dyn.Resource(mapping.Resource)
+// resourceFor returns the dynamic client for gvk, scoped to namespace when the
+// kind is namespaced. The REST mapper is refreshed once on a miss, as the kind
+// may come from a CRD installed moments ago.
+func (c *Client) resourceFor(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	dyn, err := c.Dynamic()
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := obj.MarshalJSON()
+	mapper, err := c.RESTMapper()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = dr.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
-		FieldManager: "wsm",
-	})
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
-		return fmt.Errorf("failed to apply object %s %s/%s: %w", gvk, obj.GetNamespace(), obj.GetName(), err)
+		if refreshedMapper, refreshErr := c.RefreshRESTMapper(); refreshErr == nil {
+			mapping, err = refreshedMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get mapping for %s: %w", gvk, err)
+		}
 	}
 
-	return nil
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return dyn.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+	return dyn.Resource(mapping.Resource), nil
 }
 
-func ApplyCertificate(ctx context.Context, cert *certmanagerv1.Certificate) error {
+func (c *Client) ApplyCertificate(ctx context.Context, cert *certmanagerv1.Certificate) error {
 	cert.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   "cert-manager.io",
 		Version: "v1",
@@ -359,10 +333,10 @@ func ApplyCertificate(ctx context.Context, cert *certmanagerv1.Certificate) erro
 	if err != nil {
 		return err
 	}
-	return ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
+	return c.ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
 }
 
-func ApplyIssuer(ctx context.Context, issuer *certmanagerv1.Issuer) error {
+func (c *Client) ApplyIssuer(ctx context.Context, issuer *certmanagerv1.Issuer) error {
 	issuer.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   "cert-manager.io",
 		Version: "v1",
@@ -372,10 +346,10 @@ func ApplyIssuer(ctx context.Context, issuer *certmanagerv1.Issuer) error {
 	if err != nil {
 		return err
 	}
-	return ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
+	return c.ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
 }
 
-func ApplyClusterIssuer(ctx context.Context, issuer *certmanagerv1.ClusterIssuer) error {
+func (c *Client) ApplyClusterIssuer(ctx context.Context, issuer *certmanagerv1.ClusterIssuer) error {
 	issuer.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   "cert-manager.io",
 		Version: "v1",
@@ -385,10 +359,10 @@ func ApplyClusterIssuer(ctx context.Context, issuer *certmanagerv1.ClusterIssuer
 	if err != nil {
 		return err
 	}
-	return ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
+	return c.ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
 }
 
-func ApplyIngressClass(ctx context.Context, ingressClass networkingv1.IngressClass) error {
+func (c *Client) ApplyIngressClass(ctx context.Context, ingressClass networkingv1.IngressClass) error {
 	ingressClass.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   "networking.k8s.io",
 		Version: "v1",
@@ -398,10 +372,10 @@ func ApplyIngressClass(ctx context.Context, ingressClass networkingv1.IngressCla
 	if err != nil {
 		return err
 	}
-	return ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
+	return c.ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
 }
 
-func ApplyStorageClass(ctx context.Context, storageClass *storagev1.StorageClass) error {
+func (c *Client) ApplyStorageClass(ctx context.Context, storageClass *storagev1.StorageClass) error {
 	storageClass.SetGroupVersionKind(schema.GroupVersionKind{
 		Group:   "storage.k8s.io",
 		Version: "v1",
@@ -411,5 +385,5 @@ func ApplyStorageClass(ctx context.Context, storageClass *storagev1.StorageClass
 	if err != nil {
 		return err
 	}
-	return ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
+	return c.ApplyUnstructured(ctx, &unstructured.Unstructured{Object: data})
 }
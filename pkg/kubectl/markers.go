@@ -2,25 +2,111 @@ package kubectl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-// CreateDeploymentMarker creates a ConfigMap marker to track wsm-managed deployments
-// Note: Assumes the namespace already exists (created by operator manifest)
+// markerName is the ConfigMap wsm records its deployments in.
+const markerName = "wsm-deployment-marker"
+
+// CreateDeploymentMarker is Client.CreateDeploymentMarker on the context's client.
 func CreateDeploymentMarker(ctx context.Context, clusterName, namespace string, components string) error {
-	data := map[string]string{
-		"created-by": "wsm",
-		"components": components,
+	return FromContext(ctx).CreateDeploymentMarker(ctx, clusterName, namespace, components)
+}
+
+// HasDeploymentMarker is Client.HasDeploymentMarker on the context's client.
+func HasDeploymentMarker(ctx context.Context, namespace string, component string) (bool, error) {
+	return FromContext(ctx).HasDeploymentMarker(ctx, namespace, component)
+}
+
+// DeleteDeploymentMarker is Client.DeleteDeploymentMarker on the context's client.
+func DeleteDeploymentMarker(ctx context.Context, namespace string, component string) error {
+	return FromContext(ctx).DeleteDeploymentMarker(ctx, namespace, component)
+}
+
+// ListDeploymentMarkers is Client.ListDeploymentMarkers on the context's client.
+func ListDeploymentMarkers(ctx context.Context) ([]Marker, error) {
+	return FromContext(ctx).ListDeploymentMarkers(ctx)
+}
+
+// ForgetInventory is Client.ForgetInventory on the context's client.
+func ForgetInventory(ctx context.Context, namespace string, entries []InventoryEntry) error {
+	return FromContext(ctx).ForgetInventory(ctx, namespace, entries)
+}
+
+// RecordDetached is Client.RecordDetached on the context's client.
+func RecordDetached(ctx context.Context, namespace string, objects []DetachedObject) error {
+	return FromContext(ctx).RecordDetached(ctx, namespace, objects)
+}
+
+// ForgetDetached is Client.ForgetDetached on the context's client.
+func ForgetDetached(ctx context.Context, namespace string, entries []InventoryEntry) error {
+	return FromContext(ctx).ForgetDetached(ctx, namespace, entries)
+}
+
+// GetInventoryObject is Client.GetInventoryObject on the context's client.
+func GetInventoryObject(ctx context.Context, e InventoryEntry) (*unstructured.Unstructured, error) {
+	return FromContext(ctx).GetInventoryObject(ctx, e)
+}
+
+// DeleteInventoryObject is Client.DeleteInventoryObject on the context's client.
+func DeleteInventoryObject(ctx context.Context, e InventoryEntry) error {
+	return FromContext(ctx).DeleteInventoryObject(ctx, e)
+}
+
+// FindNamespacesWithMarker is Client.FindNamespacesWithMarker on the context's client.
+func FindNamespacesWithMarker(ctx context.Context, component string) ([]string, error) {
+	return FromContext(ctx).FindNamespacesWithMarker(ctx, component)
+}
+
+// CreateDeploymentMarker creates a ConfigMap marker to track wsm-managed deployments,
+// or adds components to the one already in namespace. The objects recorded in
+// ctx's inventory (see WithInventory) are added to the marker's inventory.
+// Note: Assumes the namespace already exists (created by operator manifest)
+func (c *Client) CreateDeploymentMarker(ctx context.Context, clusterName, namespace string, components string) error {
+	data := map[string]string{}
+	existing, err := c.GetConfigMap(ctx, markerName, namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to read deployment marker: %w", err)
 	}
+	var marker Marker
+	if err == nil {
+		if marker, err = parseMarker(existing); err != nil {
+			return err
+		}
+		maps.Copy(data, existing.Data)
+	}
+	data["created-by"] = "wsm"
+
+	all := marker.Components
+	for component := range strings.SplitSeq(components, ",") {
+		if component = strings.TrimSpace(component); component != "" && !slices.Contains(all, component) {
+			all = append(all, component)
+		}
+	}
+	data["components"] = strings.Join(all, ",")
 
 	if clusterName != "" {
 		data["cluster-name"] = clusterName
 	}
 
-	if err := UpsertConfigMap(data, "wsm-deployment-marker", namespace); err != nil {
+	if inv := InventoryFromContext(ctx); inv != nil && len(inv.Entries()) > 0 {
+		inventory, err := formatInventory(mergeInventory(marker.Inventory, inv.Entries()))
+		if err != nil {
+			return err
+		}
+		data["inventory"] = inventory
+	}
+
+	if err := c.UpsertConfigMap(ctx, data, markerName, namespace); err != nil {
 		return fmt.Errorf("failed to create deployment marker: %w", err)
 	}
 
@@ -28,8 +114,8 @@ func CreateDeploymentMarker(ctx context.Context, clusterName, namespace string,
 }
 
 // HasDeploymentMarker checks if a deployment marker exists
-func HasDeploymentMarker(ctx context.Context, namespace string, component string) (bool, error) {
-	configMap, err := GetConfigMap(ctx, "wsm-deployment-marker", namespace)
+func (c *Client) HasDeploymentMarker(ctx context.Context, namespace string, component string) (bool, error) {
+	configMap, err := c.GetConfigMap(ctx, markerName, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return false, nil
@@ -51,15 +137,15 @@ func HasDeploymentMarker(ctx context.Context, namespace string, component string
 // DeleteDeploymentMarker removes the specified component from the deployment marker ConfigMap
 // if component is empty, it removes the entire deployment marker.
 // if after removing the component, no components remain, it deletes the marker.
-func DeleteDeploymentMarker(ctx context.Context, namespace string, component string) error {
+func (c *Client) DeleteDeploymentMarker(ctx context.Context, namespace string, component string) error {
 	if component == "" {
-		if err := DeleteConfigMap(ctx, "wsm-deployment-marker", namespace); err != nil {
+		if err := c.DeleteConfigMap(ctx, markerName, namespace); err != nil {
 			return fmt.Errorf("failed to delete deployment marker: %w", err)
 		}
 		return nil
 	}
 
-	cm, err := GetConfigMap(ctx, "wsm-deployment-marker", namespace)
+	cm, err := c.GetConfigMap(ctx, markerName, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil
@@ -69,7 +155,7 @@ func DeleteDeploymentMarker(ctx context.Context, namespace string, component str
 
 	componentsStr, ok := cm.Data["components"]
 	if !ok {
-		return DeleteConfigMap(ctx, "wsm-deployment-marker", namespace)
+		return c.DeleteConfigMap(ctx, markerName, namespace)
 	}
 
 	var newComponents []string
@@ -80,15 +166,18 @@ func DeleteDeploymentMarker(ctx context.Context, namespace string, component str
 		}
 	}
 
-	if len(newComponents) == 0 {
-		if err := DeleteConfigMap(ctx, "wsm-deployment-marker", namespace); err != nil {
+	// The marker outlives its components while it still lists objects wsm
+	// created, so `wsm cluster cleanup` can find and remove them, or data a
+	// destroyed instance left behind, so `wsm deploy-v2 wandb orphans` can.
+	if len(newComponents) == 0 && cm.Data["inventory"] == "" && cm.Data["detached"] == "" {
+		if err := c.DeleteConfigMap(ctx, markerName, namespace); err != nil {
 			return fmt.Errorf("failed to delete deployment marker: %w", err)
 		}
 		return nil
 	}
 
 	cm.Data["components"] = strings.Join(newComponents, ",")
-	if err := UpsertConfigMap(cm.Data, "wsm-deployment-marker", namespace); err != nil {
+	if err := c.UpsertConfigMap(ctx, cm.Data, markerName, namespace); err != nil {
 		return fmt.Errorf("failed to update deployment marker: %w", err)
 	}
 
@@ -96,8 +185,8 @@ func DeleteDeploymentMarker(ctx context.Context, namespace string, component str
 }
 
 // FindNamespacesWithMarker finds all namespaces containing the wsm-deployment-marker for a specific component
-func FindNamespacesWithMarker(ctx context.Context, component string) ([]string, error) {
-	cms, err := ListConfigMaps(ctx, "wsm-deployment-marker")
+func (c *Client) FindNamespacesWithMarker(ctx context.Context, component string) ([]string, error) {
+	cms, err := c.ListConfigMaps(ctx, markerName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployment markers: %w", err)
 	}
@@ -116,3 +205,175 @@ func FindNamespacesWithMarker(ctx context.Context, component string) ([]string,
 
 	return namespaces, nil
 }
+
+// Marker is a parsed wsm deployment marker.
+type Marker struct {
+	Namespace   string
+	ClusterName string
+	Components  []string
+	// Inventory lists the objects wsm created, in creation order. Markers
+	// written by older wsm versions have none.
+	Inventory []InventoryEntry
+	// Detached lists the data W&B instances destroyed with the detach
+	// retention policy left behind in the namespace.
+	Detached []DetachedObject
+}
+
+// DetachedObject is a PVC or Secret a W&B instance left behind when it was
+// destroyed with the detach retention policy.
+type DetachedObject struct {
+	InventoryEntry
+	// Instance is the name of the destroyed WeightsAndBiases CR.
+	Instance   string    `json:"instance"`
+	DetachedAt time.Time `json:"detachedAt"`
+}
+
+func parseMarker(cm *v1.ConfigMap) (Marker, error) {
+	m := Marker{Namespace: cm.Namespace, ClusterName: cm.Data["cluster-name"]}
+	for component := range strings.SplitSeq(cm.Data["components"], ",") {
+		if component = strings.TrimSpace(component); component != "" {
+			m.Components = append(m.Components, component)
+		}
+	}
+	inventory, err := parseInventory(cm.Data["inventory"])
+	if err != nil {
+		return m, fmt.Errorf("deployment marker in %s: %w", cm.Namespace, err)
+	}
+	m.Inventory = inventory
+	if data := cm.Data["detached"]; data != "" {
+		if err := json.Unmarshal([]byte(data), &m.Detached); err != nil {
+			return m, fmt.Errorf("deployment marker in %s: parse detached objects: %w", cm.Namespace, err)
+		}
+	}
+	return m, nil
+}
+
+// ListDeploymentMarkers returns every wsm deployment marker in the cluster.
+func (c *Client) ListDeploymentMarkers(ctx context.Context) ([]Marker, error) {
+	cms, err := c.ListConfigMaps(ctx, markerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployment markers: %w", err)
+	}
+
+	markers := make([]Marker, 0, len(cms))
+	for i := range cms {
+		m, err := parseMarker(&cms[i])
+		if err != nil {
+			return nil, err
+		}
+		markers = append(markers, m)
+	}
+	return markers, nil
+}
+
+// ForgetInventory drops entries from the inventory of the marker in namespace,
+// once the objects are gone. A marker left with no components, no inventory
+// and no detached objects is deleted.
+func (c *Client) ForgetInventory(ctx context.Context, namespace string, entries []InventoryEntry) error {
+	cm, err := c.GetConfigMap(ctx, markerName, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read deployment marker: %w", err)
+	}
+	marker, err := parseMarker(cm)
+	if err != nil {
+		return err
+	}
+
+	marker.Inventory = removeInventory(marker.Inventory, entries)
+	return c.updateMarker(ctx, cm, marker)
+}
+
+// RecordDetached adds objects a destroyed W&B instance left behind to the
+// marker in namespace, creating the marker if there is none.
+func (c *Client) RecordDetached(ctx context.Context, namespace string, objects []DetachedObject) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	cm, err := c.GetConfigMap(ctx, markerName, namespace)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to read deployment marker: %w", err)
+		}
+		cm = &v1.ConfigMap{Data: map[string]string{"created-by": "wsm"}}
+		cm.Namespace = namespace
+	}
+	marker, err := parseMarker(cm)
+	if err != nil {
+		return err
+	}
+
+	recorded := map[string]int{}
+	for i, o := range marker.Detached {
+		recorded[o.key()] = i
+	}
+	for _, o := range objects {
+		if i, ok := recorded[o.key()]; ok {
+			marker.Detached[i] = o
+			continue
+		}
+		recorded[o.key()] = len(marker.Detached)
+		marker.Detached = append(marker.Detached, o)
+	}
+	return c.updateMarker(ctx, cm, marker)
+}
+
+// ForgetDetached drops entries from the detached objects of the marker in
+// namespace, once they are gone. A marker left with no components, no
+// inventory and no detached objects is deleted.
+func (c *Client) ForgetDetached(ctx context.Context, namespace string, entries []InventoryEntry) error {
+	cm, err := c.GetConfigMap(ctx, markerName, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read deployment marker: %w", err)
+	}
+	marker, err := parseMarker(cm)
+	if err != nil {
+		return err
+	}
+
+	dropped := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		dropped[e.key()] = true
+	}
+	marker.Detached = slices.DeleteFunc(marker.Detached, func(o DetachedObject) bool { return dropped[o.key()] })
+	return c.updateMarker(ctx, cm, marker)
+}
+
+// updateMarker writes marker's inventory and detached objects back to cm, or
+// deletes the marker once it records nothing.
+func (c *Client) updateMarker(ctx context.Context, cm *v1.ConfigMap, marker Marker) error {
+	if len(marker.Components) == 0 && len(marker.Inventory) == 0 && len(marker.Detached) == 0 {
+		if err := c.DeleteConfigMap(ctx, markerName, cm.Namespace); err != nil {
+			return fmt.Errorf("failed to delete deployment marker: %w", err)
+		}
+		return nil
+	}
+
+	if len(marker.Inventory) == 0 {
+		delete(cm.Data, "inventory")
+	} else {
+		inventory, err := formatInventory(marker.Inventory)
+		if err != nil {
+			return err
+		}
+		cm.Data["inventory"] = inventory
+	}
+	if len(marker.Detached) == 0 {
+		delete(cm.Data, "detached")
+	} else {
+		detached, err := json.MarshalIndent(marker.Detached, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode marker detached objects: %w", err)
+		}
+		cm.Data["detached"] = string(detached)
+	}
+	if err := c.UpsertConfigMap(ctx, cm.Data, markerName, cm.Namespace); err != nil {
+		return fmt.Errorf("failed to update deployment marker: %w", err)
+	}
+	return nil
+}
@@ -49,7 +49,7 @@ func (s *PortForwardSession) Close() error {
 // and establishes a native (client-go) port-forward. It blocks until the forward is ready, then returns
 // a live session. localPort 0 lets the OS choose. Callers pass an explicit config/clientset (e.g. from
 // GetClientset) so this stays a pure library function rather than depending on the package singleton.
-func PortForward(ctx context.Context, cfg *rest.Config, cs *kubernetes.Clientset, namespace, service string, remotePort, localPort int) (*PortForwardSession, error) {
+func PortForward(ctx context.Context, cfg *rest.Config, cs kubernetes.Interface, namespace, service string, remotePort, localPort int) (*PortForwardSession, error) {
 	if remotePort < 1 || remotePort > 65535 {
 		return nil, fmt.Errorf("invalid remote port %d (must be 1-65535)", remotePort)
 	}
@@ -96,6 +96,20 @@ func PortForward(ctx context.Context, cfg *rest.Config, cs *kubernetes.Clientset
 	return &PortForwardSession{LocalPort: int(ports[0].Local), stopCh: stopCh, errCh: errCh}, nil
 }
 
+// PortForward forwards localPort to remotePort of a ready pod behind the Service, as the
+// package-level PortForward does, over c's connection.
+func (c *Client) PortForward(ctx context.Context, namespace, service string, remotePort, localPort int) (*PortForwardSession, error) {
+	cfg, err := c.Config()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := c.Clientset()
+	if err != nil {
+		return nil, err
+	}
+	return PortForward(ctx, cfg, cs, namespace, service, remotePort, localPort)
+}
+
 // portForwardDialer prefers WebSocket (SPDY-over-WebSocket) and falls back to plain SPDY on upgrade
 // failure, matching kubectl — so it works against both classic and WebSocket-only API servers.
 func portForwardDialer(cfg *rest.Config, reqURL *url.URL) (httpstream.Dialer, error) {
@@ -113,7 +127,7 @@ func portForwardDialer(cfg *rest.Config, reqURL *url.URL) (httpstream.Dialer, er
 	}), nil
 }
 
-func pickPodAndPort(ctx context.Context, cs *kubernetes.Clientset, namespace, service string, remotePort int32) (string, int32, error) {
+func pickPodAndPort(ctx context.Context, cs kubernetes.Interface, namespace, service string, remotePort int32) (string, int32, error) {
 	svc, err := cs.CoreV1().Services(namespace).Get(ctx, service, metav1.GetOptions{})
 	if err != nil {
 		return "", 0, fmt.Errorf("service %q not found in namespace %q: %w", service, namespace, err)
@@ -26,12 +26,16 @@ func GetSecretData(name string, namespace string, key string) ([]byte, error) {
 }
 
 func GetSecretDataMap(name string, namespace string) (map[string][]byte, error) {
-	_, cs, err := GetClientset()
+	return Default().GetSecretDataMap(context.Background(), name, namespace)
+}
+
+func (c *Client) GetSecretDataMap(ctx context.Context, name string, namespace string) (map[string][]byte, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return nil, err
 	}
 
-	secret, err := cs.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	secret, err := cs.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,209 @@
+package localcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/mirror"
+	"sigs.k8s.io/yaml"
+)
+
+// k3dProvider creates k3s clusters in Docker with the k3d CLI.
+type k3dProvider struct{}
+
+func (k3dProvider) Name() string { return "k3d" }
+
+func (k3dProvider) Installed() bool { return requireCLI("k3d", k3dInstallURL) == nil }
+
+const k3dInstallURL = "https://k3d.io"
+
+func (p k3dProvider) Create(ctx context.Context, opts CreateOptions) error {
+	if err := rejectKindConfig(p.Name(), opts); err != nil {
+		return err
+	}
+	if err := requireCLI("k3d", k3dInstallURL); err != nil {
+		return err
+	}
+	exists, err := Exists(ctx, p, opts.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("cluster '%s' already exists", opts.Name)
+	}
+
+	// Traefik is left out: W&B is exposed through the nginx gateway, on the
+	// same node ports as with Kind.
+	args := []string{"cluster", "create", opts.Name,
+		"--agents", strconv.Itoa(opts.Workers),
+		"--port", fmt.Sprintf("%d:%d@server:0", opts.HTTPPort, kind.HTTPNodePort),
+		"--port", fmt.Sprintf("%d:%d@server:0", opts.HTTPSPort, kind.HTTPSNodePort),
+		"--k3s-arg", "--disable=traefik@server:*",
+		"--kubeconfig-update-default",
+		"--kubeconfig-switch-context",
+		"--wait",
+	}
+	if opts.NodeImage != "" {
+		args = append(args, "--image", opts.NodeImage)
+	}
+	if !opts.Registry.IsZero() {
+		if err := opts.Registry.Validate(); err != nil {
+			return err
+		}
+		registries, err := k3sRegistriesConfig(opts.Registry)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp("", "wsm-k3d-registries-*.yaml")
+		if err != nil {
+			return fmt.Errorf("failed to write k3s registries config: %w", err)
+		}
+		defer func() { _ = os.Remove(f.Name()) }()
+		if _, err := f.Write(registries); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write k3s registries config: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write k3s registries config: %w", err)
+		}
+		args = append(args, "--registry-config", f.Name())
+		if opts.Registry.CAFile != "" {
+			caFile, err := filepath.Abs(opts.Registry.CAFile)
+			if err != nil {
+				return err
+			}
+			args = append(args, "--volume", caFile+":"+k3sRegistryCAFile+"@all")
+		}
+	}
+
+	if err := run(ctx, "k3d", args...); err != nil {
+		return fmt.Errorf("failed to create k3d cluster: %w", err)
+	}
+	return nil
+}
+
+// k3sRegistries is k3s's registries.yaml, from which it writes containerd's
+// hosts.toml on every node.
+type k3sRegistries struct {
+	Mirrors map[string]k3sMirror `json:"mirrors"`
+	// Configs holds the TLS settings of each endpoint host.
+	Configs map[string]k3sRegistryConfig `json:"configs,omitempty"`
+}
+
+type k3sMirror struct {
+	Endpoint []string `json:"endpoint"`
+	// Rewrite maps repository regexps to their path in the endpoint.
+	Rewrite map[string]string `json:"rewrite,omitempty"`
+}
+
+type k3sRegistryConfig struct {
+	TLS k3sRegistryTLS `json:"tls"`
+}
+
+type k3sRegistryTLS struct {
+	CAFile string `json:"ca_file,omitempty"`
+}
+
+// k3sRegistryCAFile is where the registry's CA is mounted on every node.
+const k3sRegistryCAFile = "/etc/wsm/registry-ca.crt"
+
+// k3sRegistriesConfig returns the registries.yaml making k3s pull from
+// registry, also for the upstream registries mirrored to it: the counterpart
+// of kind.Registry.HostsScript. An HTTPS registry's CA is read from
+// k3sRegistryCAFile. k3s falls back to the upstream registry for images the
+// mirror lacks.
+func k3sRegistriesConfig(registry kind.Registry) ([]byte, error) {
+	endpointURL := registry.EndpointURL()
+	endpoint := []string{endpointURL}
+	registries := k3sRegistries{Mirrors: map[string]k3sMirror{
+		registry.Host: {Endpoint: endpoint},
+	}}
+	if registry.CAFile != "" {
+		u, err := url.Parse(endpointURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse registry endpoint %s: %w", endpointURL, err)
+		}
+		registries.Configs = map[string]k3sRegistryConfig{
+			u.Host: {TLS: k3sRegistryTLS{CAFile: k3sRegistryCAFile}},
+		}
+	}
+	for host := range kind.UpstreamRegistryMirrors() {
+		prefix, ok := registry.Layout.HostPath(host)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: mirror layout %s can't be expressed as a k3s mirror for %s; images the operator hardcodes from %s will pull from upstream\n", registry.Layout, host, host)
+			continue
+		}
+		m := k3sMirror{Endpoint: endpoint}
+		if prefix != "" {
+			m.Rewrite = map[string]string{"^(.*)$": prefix + "/$1"}
+		}
+		registries.Mirrors[host] = m
+	}
+	data, err := yaml.Marshal(registries)
+	if err != nil {
+		return nil, fmt.Errorf("encode k3s registries config: %w", err)
+	}
+	return data, nil
+}
+
+func (p k3dProvider) Delete(ctx context.Context, name string) error {
+	if err := requireCLI("k3d", k3dInstallURL); err != nil {
+		return err
+	}
+	exists, err := Exists(ctx, p, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cluster '%s' does not exist", name)
+	}
+	if err := run(ctx, "k3d", "cluster", "delete", name); err != nil {
+		return fmt.Errorf("failed to delete k3d cluster: %w", err)
+	}
+	return nil
+}
+
+func (k3dProvider) List(ctx context.Context) ([]string, error) {
+	if err := requireCLI("k3d", k3dInstallURL); err != nil {
+		return nil, err
+	}
+	out, err := output(ctx, "k3d", "cluster", "list", "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var clusters []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(out, &clusters); err != nil {
+		return nil, fmt.Errorf("parse k3d cluster list: %w", err)
+	}
+	names := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		names = append(names, c.Name)
+	}
+	return names, nil
+}
+
+func (k3dProvider) KubeContext(name string) string {
+	return "k3d-" + name
+}
+
+// MirroredNodeImage returns "": `wsm registry mirror` does not carry k3s images.
+func (k3dProvider) MirroredNodeImage(host string, layout *mirror.Layout) string {
+	return ""
+}
+
+// InstallMetricsServer waits for the metrics-server k3s ships with.
+func (k3dProvider) InstallMetricsServer(ctx context.Context, name string) error {
+	if err := kind.WaitForMetricsServer(ctx, 2*time.Minute); err != nil {
+		return fmt.Errorf("metrics-server did not become ready: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package localcluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/mirror"
+)
+
+// kindProvider creates clusters with the Kind library, see package kind.
+type kindProvider struct{}
+
+func (kindProvider) Name() string { return "kind" }
+
+// Installed is always true: Kind is built in, it only needs Docker.
+func (kindProvider) Installed() bool { return true }
+
+func (kindProvider) Create(ctx context.Context, opts CreateOptions) error {
+	return kind.CreateCluster(ctx, opts.Name, opts.Workers, opts.HTTPPort, opts.HTTPSPort, opts.NodeImage, opts.Registry, opts.Kind)
+}
+
+func (kindProvider) Delete(ctx context.Context, name string) error {
+	return kind.DeleteCluster(ctx, name)
+}
+
+func (kindProvider) List(ctx context.Context) ([]string, error) {
+	return kind.ListClusters()
+}
+
+func (kindProvider) KubeContext(name string) string {
+	return fmt.Sprintf("kind-%s", name)
+}
+
+func (kindProvider) MirroredNodeImage(host string, layout *mirror.Layout) string {
+	return kind.MirroredNodeImage(host, layout)
+}
+
+func (kindProvider) InstallMetricsServer(ctx context.Context, name string) error {
+	return kind.InstallMetricsServer(ctx)
+}
@@ -0,0 +1,168 @@
+// Package localcluster creates the local Kubernetes clusters `wsm cluster`
+// and --setup-k8s-cluster deploy to, with Kind, k3d or minikube.
+package localcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/mirror"
+)
+
+// DefaultProvider is the provider used when none is chosen.
+const DefaultProvider = "kind"
+
+// CreateOptions describes the cluster to create.
+type CreateOptions struct {
+	Name    string
+	Workers int
+	// HTTPPort and HTTPSPort are the host ports mapped to the nginx gateway's
+	// node ports (kind.HTTPNodePort, kind.HTTPSNodePort).
+	HTTPPort  int32
+	HTTPSPort int32
+	// NodeImage overrides the provider's node image; empty uses its default.
+	NodeImage string
+	// Registry, when set, is a registry the nodes pull from, also standing in
+	// for the upstream registries: over plain HTTP (Insecure) or HTTPS,
+	// trusting its CAFile.
+	Registry kind.Registry
+	// Kind customizes the generated Kind config; only the kind provider
+	// supports it.
+	Kind kind.ClusterConfig
+}
+
+// rejectKindConfig fails when opts customizes the Kind config, which provider
+// can't honour.
+func rejectKindConfig(provider string, opts CreateOptions) error {
+	if !opts.Kind.IsZero() {
+		return fmt.Errorf("the %s provider does not support the Kind config options (--config, --port-mapping, --mount, --feature-gates, --api-server-port, --node-label, --node-taint)", provider)
+	}
+	return nil
+}
+
+// Provider creates and deletes local clusters with one tool.
+type Provider interface {
+	// Name is the value of --provider selecting the provider.
+	Name() string
+	// Installed reports whether the provider's tooling is available.
+	Installed() bool
+	Create(ctx context.Context, opts CreateOptions) error
+	Delete(ctx context.Context, name string) error
+	// List returns the names of the provider's clusters.
+	List(ctx context.Context) ([]string, error)
+	// KubeContext is the kubeconfig context the provider writes for the
+	// cluster name.
+	KubeContext(name string) string
+	// MirroredNodeImage returns where `wsm registry mirror` places the
+	// provider's node image in the mirror at host, or "" if it does not.
+	MirroredNodeImage(host string, layout *mirror.Layout) string
+	// InstallMetricsServer makes sure the cluster name runs a ready
+	// metrics-server.
+	InstallMetricsServer(ctx context.Context, name string) error
+}
+
+var providers = []Provider{kindProvider{}, k3dProvider{}, minikubeProvider{}}
+
+// Names returns the names of the providers, for flag help and validation.
+func Names() []string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Providers returns every provider.
+func Providers() []Provider {
+	return slices.Clone(providers)
+}
+
+// Get returns the provider called name.
+func Get(name string) (Provider, error) {
+	for _, p := range providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown cluster provider %q (expected: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Exists reports whether p has a cluster called name.
+func Exists(ctx context.Context, p Provider, name string) (bool, error) {
+	clusters, err := p.List(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to list %s clusters: %w", p.Name(), err)
+	}
+	return slices.Contains(clusters, name), nil
+}
+
+// MarkerComponent is the deployment marker component recording a cluster
+// created with provider.
+func MarkerComponent(provider string) string {
+	return provider + "-cluster"
+}
+
+// Created reports whether the cluster c talks to was created by `wsm cluster
+// create` with any provider, from its deployment marker. Such a cluster maps
+// the host's HTTP and HTTPS ports to the gateway's node ports.
+func Created(ctx context.Context, c *kubectl.Client) (bool, error) {
+	for _, p := range providers {
+		ok, err := c.HasDeploymentMarker(ctx, "default", MarkerComponent(p.Name()))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// IsMarkerComponent reports whether component records a local cluster.
+func IsMarkerComponent(component string) bool {
+	return slices.ContainsFunc(providers, func(p Provider) bool { return MarkerComponent(p.Name()) == component })
+}
+
+// run runs a provider's CLI, showing its output. Clusters are written to
+// --kubeconfig when one is given.
+func run(ctx context.Context, name string, args ...string) error {
+	cmd := command(ctx, name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// output runs a provider's CLI and returns its standard output.
+func output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := command(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%s %s: %w (%s)", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if kubeconfig := kubectl.DefaultOptions().Kubeconfig; kubeconfig != "" {
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfig)
+	}
+	return cmd
+}
+
+// requireCLI fails with an install hint when name is not on PATH.
+func requireCLI(name, installURL string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s not found in PATH; install it from %s", name, installURL)
+	}
+	return nil
+}
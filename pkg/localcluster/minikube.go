@@ -0,0 +1,162 @@
+package localcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wandb/wsm/pkg/kind"
+	"github.com/wandb/wsm/pkg/mirror"
+)
+
+// minikubeProvider creates clusters with the minikube CLI. It uses the docker
+// driver, whose nodes are containers like Kind's, and containerd, whose
+// per-host registry config wsm writes as it does for Kind.
+type minikubeProvider struct{}
+
+func (minikubeProvider) Name() string { return "minikube" }
+
+func (minikubeProvider) Installed() bool { return requireCLI("minikube", minikubeInstallURL) == nil }
+
+const minikubeInstallURL = "https://minikube.sigs.k8s.io"
+
+func (p minikubeProvider) Create(ctx context.Context, opts CreateOptions) error {
+	if err := rejectKindConfig(p.Name(), opts); err != nil {
+		return err
+	}
+	if err := requireCLI("minikube", minikubeInstallURL); err != nil {
+		return err
+	}
+	if err := opts.Registry.Validate(); err != nil {
+		return err
+	}
+	exists, err := Exists(ctx, p, opts.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("cluster '%s' already exists", opts.Name)
+	}
+
+	args := []string{"start",
+		"--profile", opts.Name,
+		"--driver", "docker",
+		"--container-runtime", "containerd",
+		"--nodes", strconv.Itoa(opts.Workers + 1),
+		"--ports", fmt.Sprintf("%d:%d", opts.HTTPPort, kind.HTTPNodePort),
+		"--ports", fmt.Sprintf("%d:%d", opts.HTTPSPort, kind.HTTPSNodePort),
+	}
+	if opts.NodeImage != "" {
+		args = append(args, "--base-image", opts.NodeImage)
+	}
+	if opts.Registry.Insecure {
+		args = append(args, "--insecure-registry", opts.Registry.Host)
+	}
+	if err := run(ctx, "minikube", args...); err != nil {
+		return fmt.Errorf("failed to create minikube cluster: %w", err)
+	}
+
+	if !opts.Registry.IsZero() {
+		script, err := opts.Registry.HostsScript()
+		if err != nil {
+			return err
+		}
+		nodes, err := minikubeNodes(ctx, opts.Name)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			out, err := exec.CommandContext(ctx, "docker", "exec", "-i", node, "sh", "-c", script).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("configure registry %s on %s: %w (%s)", opts.Registry.Host, node, err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	return nil
+}
+
+// minikubeNodes returns the node names of profile, which with the docker
+// driver are also their container names.
+func minikubeNodes(ctx context.Context, profile string) ([]string, error) {
+	out, err := output(ctx, "minikube", "node", "list", "--profile", profile)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for line := range strings.Lines(string(out)) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			nodes = append(nodes, fields[0])
+		}
+	}
+	return nodes, nil
+}
+
+func (p minikubeProvider) Delete(ctx context.Context, name string) error {
+	if err := requireCLI("minikube", minikubeInstallURL); err != nil {
+		return err
+	}
+	exists, err := Exists(ctx, p, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cluster '%s' does not exist", name)
+	}
+	if err := run(ctx, "minikube", "delete", "--profile", name); err != nil {
+		return fmt.Errorf("failed to delete minikube cluster: %w", err)
+	}
+	return nil
+}
+
+func (minikubeProvider) List(ctx context.Context) ([]string, error) {
+	if err := requireCLI("minikube", minikubeInstallURL); err != nil {
+		return nil, err
+	}
+	// minikube exits non-zero when there are no profiles, still printing the
+	// (empty) JSON list.
+	out, runErr := output(ctx, "minikube", "profile", "list", "--output", "json")
+	var profiles struct {
+		Valid []struct {
+			Name string
+		} `json:"valid"`
+		Invalid []struct {
+			Name string
+		} `json:"invalid"`
+	}
+	if err := json.Unmarshal(out, &profiles); err != nil {
+		if runErr != nil {
+			return nil, runErr
+		}
+		return nil, fmt.Errorf("parse minikube profile list: %w", err)
+	}
+	var names []string
+	for _, p := range append(profiles.Valid, profiles.Invalid...) {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+func (minikubeProvider) KubeContext(name string) string {
+	return name
+}
+
+// MirroredNodeImage returns "": `wsm registry mirror` does not carry the
+// minikube base image.
+func (minikubeProvider) MirroredNodeImage(host string, layout *mirror.Layout) string {
+	return ""
+}
+
+// InstallMetricsServer enables minikube's metrics-server addon.
+func (minikubeProvider) InstallMetricsServer(ctx context.Context, name string) error {
+	if err := run(ctx, "minikube", "addons", "enable", "metrics-server", "--profile", name); err != nil {
+		return fmt.Errorf("failed to install metrics-server: %w", err)
+	}
+	if err := kind.WaitForMetricsServer(ctx, 2*time.Minute); err != nil {
+		return fmt.Errorf("metrics-server did not become ready: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ecrCreator creates ECR repositories through the CreateRepository API.
+// ECR has no implicit creation: every repository path must exist before the
+// first push. Credentials come from the AWS SDK's default chain (environment,
+// shared config and credentials files, SSO, instance and pod roles), so no
+// AWS CLI is needed.
+type ecrCreator struct{ opts CreatorOptions }
+
+// parseECRHost splits <account>.dkr.ecr.<region>.<domain>, where domain is
+// amazonaws.com, or amazonaws.com.cn in the China partition.
+func parseECRHost(host string) (account, region, domain string, err error) {
+	account, rest, ok := strings.Cut(host, ".dkr.ecr.")
+	if !ok {
+		return "", "", "", fmt.Errorf("%q is not an ECR registry host (<account>.dkr.ecr.<region>.amazonaws.com)", host)
+	}
+	region, domain, ok = strings.Cut(rest, ".")
+	if !ok || region == "" || (domain != "amazonaws.com" && domain != "amazonaws.com.cn") {
+		return "", "", "", fmt.Errorf("%q is not an ECR registry host (<account>.dkr.ecr.<region>.amazonaws.com)", host)
+	}
+	return account, region, domain, nil
+}
+
+func (e *ecrCreator) EnsureRepository(ctx context.Context, repository string) error {
+	host, path, err := splitRepository(repository)
+	if err != nil {
+		return err
+	}
+	account, region, domain, err := parseECRHost(host)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("no AWS credentials: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("https://api.ecr.%s.%s", region, domain)
+	if e.opts.Endpoint != "" {
+		endpoint = strings.TrimRight(e.opts.Endpoint, "/")
+	}
+	body, err := json.Marshal(map[string]string{
+		"registryId":     account,
+		"repositoryName": path,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.CreateRepository")
+	payloadHash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), "ecr", region, time.Now()); err != nil {
+		return fmt.Errorf("sign ECR request: %w", err)
+	}
+
+	resp, err := e.opts.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("create ECR repository %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	var apiErr struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(respBody, &apiErr)
+	if strings.HasSuffix(apiErr.Type, "RepositoryAlreadyExistsException") {
+		return nil
+	}
+	return fmt.Errorf("create ECR repository %s: %s: %s %s", path, resp.Status, apiErr.Type, strings.TrimSpace(apiErr.Message))
+}
@@ -0,0 +1,113 @@
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Extras are user-specified artifacts mirrored alongside the ones wsm itself
+// needs: sidecars, custom CA init images, in-house Helm charts. They go
+// through the same Layout as everything else.
+type Extras struct {
+	Images []string `json:"images,omitempty"`
+	// Charts are OCI chart references (oci://host/path:version; the scheme is
+	// optional). Classic HTTP chart repositories have no registry reference to
+	// mirror to, so push those charts to an OCI registry first.
+	Charts []string `json:"charts,omitempty"`
+}
+
+// Config is the mirror config file read by --config:
+//
+//	extra:
+//	  images:
+//	    - ghcr.io/acme/ca-init:1.4.0
+//	  charts:
+//	    - oci://ghcr.io/acme/charts/wandb-addons:0.3.1
+type Config struct {
+	Extra Extras `json:"extra"`
+}
+
+// LoadConfig reads a mirror config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mirror config: %w", err)
+	}
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("parse mirror config %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// LoadList reads a file with one reference per line. Blank lines and '#'
+// comments are ignored.
+func LoadList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var refs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			refs = append(refs, line)
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// Merge appends other's artifacts to e.
+func (e *Extras) Merge(other Extras) {
+	e.Images = append(e.Images, other.Images...)
+	e.Charts = append(e.Charts, other.Charts...)
+}
+
+// Normalize strips oci:// from chart references, drops duplicates and rejects
+// references without a tag or digest, which would otherwise resolve to
+// "latest" and drift between mirror and check runs.
+func (e *Extras) Normalize() error {
+	images, err := normalizeRefs(e.Images, "image")
+	if err != nil {
+		return err
+	}
+	charts, err := normalizeRefs(e.Charts, "chart")
+	if err != nil {
+		return err
+	}
+	e.Images, e.Charts = images, charts
+	return nil
+}
+
+// Sources returns every extra reference, images first.
+func (e Extras) Sources() []string {
+	return append(append([]string{}, e.Images...), e.Charts...)
+}
+
+// Empty reports whether there are no extra artifacts.
+func (e Extras) Empty() bool {
+	return len(e.Images) == 0 && len(e.Charts) == 0
+}
+
+func normalizeRefs(refs []string, kind string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, ref := range refs {
+		ref = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ref), "oci://"))
+		if ref == "" || seen[ref] {
+			continue
+		}
+		if _, suffix := SplitReference(ref); suffix == "" {
+			return nil, fmt.Errorf("extra %s %q needs a tag or digest", kind, ref)
+		}
+		seen[ref] = true
+		out = append(out, ref)
+	}
+	return out, nil
+}
@@ -0,0 +1,245 @@
+// Package mirror decides where each upstream chart and image lives inside a
+// customer's mirror registry. `wsm registry mirror` pushes with it, `wsm
+// registry check` / `wsm registry values` compute the same destinations, and
+// the install side (pkg/operator Helm values, the Kind containerd mirror
+// config) reads from the same locations.
+package mirror
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Layout strategies accepted by ParseLayout.
+const (
+	// LayoutPreservePath keeps the upstream path and drops only the registry
+	// host: quay.io/jetstack/cert-manager-controller →
+	// <mirror>/jetstack/cert-manager-controller. This is the default.
+	LayoutPreservePath = "preserve-path"
+	// LayoutFlatten puts every repository directly under a single project,
+	// joining the upstream path segments with '-': quay.io/jetstack/cert-manager-controller →
+	// <mirror>/<prefix>/jetstack-cert-manager-controller. For registries
+	// (Harbor with one project, ECR) that don't allow arbitrary nesting.
+	LayoutFlatten = "flatten"
+	// LayoutMapping rewrites upstream repository prefixes according to a
+	// mapping file; anything not matched falls back to preserve-path.
+	LayoutMapping = "mapping"
+)
+
+// LayoutNames lists the accepted strategies, for flag help and errors.
+var LayoutNames = []string{LayoutPreservePath, LayoutFlatten, LayoutMapping}
+
+// wandbPublicPrefix is stripped from W&B references so they land under
+// <mirror>/wandb/* rather than <mirror>/wandb-production/public/wandb/*.
+const wandbPublicPrefix = "us-docker.pkg.dev/wandb-production/public/"
+
+// Mapping rewrites every upstream repository starting with Source (a registry
+// host, or host plus path) to start with Target (a path inside the mirror).
+type Mapping struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// mappingFile is the on-disk format read by --layout-mapping:
+//
+//	mappings:
+//	  - source: quay.io/jetstack
+//	    target: infra/jetstack
+//	  - source: ghcr.io
+//	    target: ghcr
+type mappingFile struct {
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Layout maps upstream repositories to paths inside the mirror. A nil *Layout
+// behaves as LayoutPreservePath, so callers that never set one keep wsm's
+// original conventions.
+type Layout struct {
+	Strategy string
+	Prefix   string    // project the flatten strategy places every repository under
+	Mappings []Mapping // mapping strategy rules, longest Source wins
+}
+
+// ParseLayout builds a Layout from the --layout / --layout-prefix /
+// --layout-mapping flag values. An empty strategy means preserve-path.
+func ParseLayout(strategy, prefix, mappingPath string) (*Layout, error) {
+	l := &Layout{Strategy: strategy, Prefix: strings.Trim(prefix, "/")}
+	switch strategy {
+	case "", LayoutPreservePath:
+		l.Strategy = LayoutPreservePath
+	case LayoutFlatten:
+		if l.Prefix == "" {
+			return nil, fmt.Errorf("layout %q requires a prefix (the single project to place every repository under)", LayoutFlatten)
+		}
+	case LayoutMapping:
+		if mappingPath == "" {
+			return nil, fmt.Errorf("layout %q requires a mapping file", LayoutMapping)
+		}
+		mappings, err := LoadMappings(mappingPath)
+		if err != nil {
+			return nil, err
+		}
+		l.Mappings = mappings
+	default:
+		return nil, fmt.Errorf("unknown layout %q (valid: %s)", strategy, strings.Join(LayoutNames, ", "))
+	}
+	if mappingPath != "" && l.Strategy != LayoutMapping {
+		return nil, fmt.Errorf("a mapping file is only used with layout %q", LayoutMapping)
+	}
+	return l, nil
+}
+
+// LoadMappings reads a mapping file. Sources and targets are normalised
+// (no scheme, no leading/trailing '/').
+func LoadMappings(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read layout mapping file: %w", err)
+	}
+	var f mappingFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse layout mapping file %s: %w", path, err)
+	}
+	if len(f.Mappings) == 0 {
+		return nil, fmt.Errorf("layout mapping file %s has no mappings", path)
+	}
+	for i, m := range f.Mappings {
+		m.Source = strings.Trim(strings.TrimPrefix(m.Source, "oci://"), "/")
+		m.Target = strings.Trim(m.Target, "/")
+		if m.Source == "" || m.Target == "" {
+			return nil, fmt.Errorf("layout mapping file %s: entry %d needs both source and target", path, i+1)
+		}
+		f.Mappings[i] = m
+	}
+	// Longest source first so the most specific rule wins.
+	sort.SliceStable(f.Mappings, func(i, j int) bool {
+		return len(f.Mappings[i].Source) > len(f.Mappings[j].Source)
+	})
+	return f.Mappings, nil
+}
+
+// String describes the layout for plan output.
+func (l *Layout) String() string {
+	switch {
+	case l == nil || l.Strategy == LayoutPreservePath || l.Strategy == "":
+		return LayoutPreservePath
+	case l.Strategy == LayoutFlatten:
+		return fmt.Sprintf("%s (prefix %s)", LayoutFlatten, l.Prefix)
+	default:
+		return fmt.Sprintf("%s (%d rules)", l.Strategy, len(l.Mappings))
+	}
+}
+
+// Path returns where upstreamRepo (a repository without tag or digest, e.g.
+// quay.io/jetstack/cert-manager-controller) lives inside the mirror, without
+// the mirror host.
+func (l *Layout) Path(upstreamRepo string) string {
+	upstreamRepo = strings.TrimPrefix(upstreamRepo, "oci://")
+	if l != nil {
+		switch l.Strategy {
+		case LayoutFlatten:
+			return l.Prefix + "/" + strings.ReplaceAll(stripHost(upstreamRepo), "/", "-")
+		case LayoutMapping:
+			full := qualify(upstreamRepo)
+			for _, m := range l.Mappings {
+				if rest, ok := cutPathPrefix(full, m.Source); ok {
+					return strings.Trim(m.Target+"/"+rest, "/")
+				}
+			}
+		}
+	}
+	return stripHost(upstreamRepo)
+}
+
+// Repository returns the full mirror repository for upstreamRepo on host.
+func (l *Layout) Repository(host, upstreamRepo string) string {
+	return strings.TrimRight(host, "/") + "/" + l.Path(upstreamRepo)
+}
+
+// Image returns the full mirror reference (repository plus the original tag
+// and/or digest) for an upstream image or OCI chart reference.
+func (l *Layout) Image(host, upstreamImage string) string {
+	repo, suffix := SplitReference(strings.TrimPrefix(upstreamImage, "oci://"))
+	return l.Repository(host, repo) + suffix
+}
+
+// HostPath reports the mirror path every repository from upstreamHost lands
+// under, so a containerd transparent mirror (which only swaps the host, plus an
+// optional path prefix via override_path) can redirect pulls of references the
+// operator hardcodes. ok is false when the layout rewrites repositories of that
+// host non-uniformly and a transparent mirror can't express it.
+func (l *Layout) HostPath(upstreamHost string) (prefix string, ok bool) {
+	if l == nil {
+		return "", true
+	}
+	switch l.Strategy {
+	case LayoutFlatten:
+		return "", false
+	case LayoutMapping:
+		for _, m := range l.Mappings {
+			if m.Source == upstreamHost {
+				return m.Target, true
+			}
+			if strings.HasPrefix(m.Source, upstreamHost+"/") {
+				return "", false
+			}
+		}
+	}
+	return "", true
+}
+
+// SplitReference splits an image reference into its repository and the
+// ":tag", "@digest" or ":tag@digest" suffix (empty when there is neither).
+func SplitReference(ref string) (repo, suffix string) {
+	if i := strings.IndexByte(ref, '@'); i >= 0 {
+		repo, suffix = ref[:i], ref[i:]
+	} else {
+		repo = ref
+	}
+	// A ':' after the last '/' is a tag; one before it is a host port.
+	if i := strings.LastIndexByte(repo, ':'); i > strings.LastIndexByte(repo, '/') {
+		repo, suffix = repo[:i], repo[i:]+suffix
+	}
+	return repo, suffix
+}
+
+// stripHost drops the registry host from repo (and the W&B public project
+// path), leaving the path the preserve-path layout uses.
+func stripHost(repo string) string {
+	if strings.HasPrefix(repo, wandbPublicPrefix) {
+		return strings.TrimPrefix(repo, wandbPublicPrefix)
+	}
+	if host, rest, ok := strings.Cut(repo, "/"); ok && isHost(host) {
+		return rest
+	}
+	return repo
+}
+
+// qualify prefixes Docker Hub short names with docker.io so mapping rules can
+// match them by host.
+func qualify(repo string) string {
+	if host, _, ok := strings.Cut(repo, "/"); ok && isHost(host) {
+		return repo
+	}
+	return "docker.io/" + repo
+}
+
+func isHost(s string) bool {
+	return strings.ContainsAny(s, ".:") || s == "localhost"
+}
+
+// cutPathPrefix is strings.CutPrefix restricted to whole path segments, so a
+// source of "quay.io/jetstack" doesn't match "quay.io/jetstack-extras/x".
+func cutPathPrefix(s, prefix string) (string, bool) {
+	if s == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(s, prefix+"/") {
+		return s[len(prefix)+1:], true
+	}
+	return "", false
+}
@@ -0,0 +1,387 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Registry flavours understood by NewRepositoryCreator.
+const (
+	RegistryTypeGeneric     = "generic"
+	RegistryTypeECR         = "ecr"
+	RegistryTypeHarbor      = "harbor"
+	RegistryTypeArtifactory = "artifactory"
+	RegistryTypeGAR         = "gar"
+	RegistryTypeACR         = "acr"
+)
+
+// RegistryTypes lists the flavours accepted by --registry-type.
+var RegistryTypes = []string{RegistryTypeECR, RegistryTypeHarbor, RegistryTypeArtifactory, RegistryTypeGAR, RegistryTypeACR, RegistryTypeGeneric}
+
+// RepositoryCreator makes sure a repository exists in the mirror before
+// anything is pushed to it. Registries that create repositories on push
+// (registry:2, ACR) get a creator that does nothing.
+type RepositoryCreator interface {
+	// EnsureRepository creates repository (host/path, no tag) — or the
+	// project that holds it — if it doesn't already exist. It is safe to call
+	// repeatedly for the same repository.
+	EnsureRepository(ctx context.Context, repository string) error
+}
+
+// CreatorOptions configures the registry API clients. Every field is optional.
+type CreatorOptions struct {
+	// HTTPClient is used for every API call; defaults to http.DefaultClient
+	// (or a TLS-skipping client when Insecure).
+	HTTPClient *http.Client
+	// Insecure skips TLS verification on the registry's own API
+	// (Harbor/Artifactory), matching --insecure on the push side.
+	Insecure bool
+	// Endpoint overrides the API base URL, e.g. a VPC endpoint for ECR or a
+	// local fake server in tests. Defaults to the flavour's public endpoint.
+	Endpoint string
+	// Credential supplies basic-auth credentials for Harbor and Artifactory.
+	Credential auth.CredentialFunc
+	// Root is the mirror the repositories are created under: the registry
+	// host plus any path every repository shares. Artifact Registry needs it
+	// to name <location>-docker.pkg.dev/<project>/<repository>, since the
+	// layout decides everything after it.
+	Root string
+}
+
+func (o CreatorOptions) httpClient() *http.Client {
+	if o.HTTPClient != nil {
+		return o.HTTPClient
+	}
+	if o.Insecure {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // user explicitly opted into --insecure
+		return &http.Client{Transport: t}
+	}
+	return http.DefaultClient
+}
+
+func (o CreatorOptions) baseURL(host string) string {
+	if o.Endpoint != "" {
+		return strings.TrimRight(o.Endpoint, "/")
+	}
+	return "https://" + host
+}
+
+func (o CreatorOptions) basicAuth(ctx context.Context, req *http.Request, host string) error {
+	if o.Credential == nil {
+		return nil
+	}
+	cred, err := o.Credential(ctx, host)
+	if err != nil {
+		return fmt.Errorf("read credentials for %s: %w", host, err)
+	}
+	if cred.Username != "" || cred.Password != "" {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+	return nil
+}
+
+// DetectRegistryType guesses a registry's flavour from its hostname, falling
+// back to probing the Harbor and Artifactory APIs. Anything unrecognised is
+// RegistryTypeGeneric.
+func DetectRegistryType(ctx context.Context, host string, opts CreatorOptions) string {
+	host, _, _ = strings.Cut(host, "/")
+	switch {
+	case strings.Contains(host, ".dkr.ecr.") && strings.Contains(host, ".amazonaws.com"):
+		return RegistryTypeECR
+	case strings.HasSuffix(host, "-docker.pkg.dev"):
+		return RegistryTypeGAR
+	case strings.HasSuffix(host, ".azurecr.io"):
+		return RegistryTypeACR
+	}
+
+	probe := func(path string) (int, []byte) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.baseURL(host)+path, nil)
+		if err != nil {
+			return 0, nil
+		}
+		resp, err := opts.httpClient().Do(req)
+		if err != nil {
+			return 0, nil
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, body
+	}
+	if code, body := probe("/api/v2.0/systeminfo"); code == http.StatusOK && bytes.Contains(body, []byte("harbor_version")) {
+		return RegistryTypeHarbor
+	}
+	if code, body := probe("/artifactory/api/system/ping"); code == http.StatusOK && strings.TrimSpace(string(body)) == "OK" {
+		return RegistryTypeArtifactory
+	}
+	return RegistryTypeGeneric
+}
+
+// NewRepositoryCreator returns the creator for registryType. Results are
+// cached, so each repository or project is created at most once per creator.
+func NewRepositoryCreator(registryType string, opts CreatorOptions) (RepositoryCreator, error) {
+	var c RepositoryCreator
+	switch registryType {
+	case RegistryTypeECR:
+		c = &ecrCreator{opts: opts}
+	case RegistryTypeHarbor:
+		c = &harborCreator{opts: opts}
+	case RegistryTypeArtifactory:
+		c = &artifactoryCreator{opts: opts}
+	case RegistryTypeGAR:
+		host, project, repo, err := parseGAR(opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		c = &garCreator{opts: opts, host: host, project: project, repo: repo}
+	case RegistryTypeACR, RegistryTypeGeneric:
+		// ACR and plain registries create repositories on first push.
+		c = noopCreator{}
+	default:
+		return nil, fmt.Errorf("unknown registry type %q (valid: %s)", registryType, strings.Join(RegistryTypes, ", "))
+	}
+	return &cachingCreator{next: c, done: map[string]bool{}}, nil
+}
+
+type noopCreator struct{}
+
+func (noopCreator) EnsureRepository(context.Context, string) error { return nil }
+
+// cachingCreator remembers which keys have already been ensured.
+type cachingCreator struct {
+	next RepositoryCreator
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+func (c *cachingCreator) EnsureRepository(ctx context.Context, repository string) error {
+	key := repository
+	if k, ok := c.next.(interface{ cacheKey(string) string }); ok {
+		key = k.cacheKey(repository)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done[key] {
+		return nil
+	}
+	if err := c.next.EnsureRepository(ctx, repository); err != nil {
+		return err
+	}
+	c.done[key] = true
+	return nil
+}
+
+// splitRepository splits host/path into its host and path.
+func splitRepository(repository string) (host, path string, err error) {
+	host, path, ok := strings.Cut(strings.TrimPrefix(repository, "oci://"), "/")
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("repository %q has no path", repository)
+	}
+	return host, path, nil
+}
+
+// firstSegment is the project (Harbor) or repository key (Artifactory) a
+// repository lives in.
+func firstSegment(repository string) (host, segment string, err error) {
+	host, path, err := splitRepository(repository)
+	if err != nil {
+		return "", "", err
+	}
+	segment, _, _ = strings.Cut(path, "/")
+	return host, segment, nil
+}
+
+// apiError renders a failed registry API call.
+func apiError(what string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return fmt.Errorf("%s: %s: %s", what, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// doJSON sends body (if non-nil) as JSON and returns the response; the caller
+// closes it.
+func doJSON(ctx context.Context, client *http.Client, method, url string, body interface{}, prepare func(*http.Request) error) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if prepare != nil {
+		if err := prepare(req); err != nil {
+			return nil, err
+		}
+	}
+	return client.Do(req)
+}
+
+// ---------------- Harbor ----------------
+
+// harborCreator creates the project a repository lives in. Harbor creates the
+// repository itself on push, but rejects pushes into a missing project.
+type harborCreator struct{ opts CreatorOptions }
+
+func (h *harborCreator) cacheKey(repository string) string {
+	host, project, _ := firstSegment(repository)
+	return host + "/" + project
+}
+
+func (h *harborCreator) EnsureRepository(ctx context.Context, repository string) error {
+	host, project, err := firstSegment(repository)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"project_name": project,
+		"metadata":     map[string]string{"public": "false"},
+	}
+	resp, err := doJSON(ctx, h.opts.httpClient(), http.MethodPost, h.opts.baseURL(host)+"/api/v2.0/projects", body,
+		func(req *http.Request) error { return h.opts.basicAuth(ctx, req, host) })
+	if err != nil {
+		return fmt.Errorf("create Harbor project %s: %w", project, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK, http.StatusConflict:
+		return nil
+	}
+	return apiError("create Harbor project "+project, resp)
+}
+
+// ---------------- Artifactory ----------------
+
+// artifactoryCreator creates the local Docker repository a path lives in
+// (the "repository path" access method: <host>/<repo-key>/<image>).
+// Artifactory creates the image paths inside it on push.
+type artifactoryCreator struct{ opts CreatorOptions }
+
+func (a *artifactoryCreator) cacheKey(repository string) string {
+	host, key, _ := firstSegment(repository)
+	return host + "/" + key
+}
+
+func (a *artifactoryCreator) EnsureRepository(ctx context.Context, repository string) error {
+	host, key, err := firstSegment(repository)
+	if err != nil {
+		return err
+	}
+	url := a.opts.baseURL(host) + "/artifactory/api/repositories/" + key
+	authorize := func(req *http.Request) error { return a.opts.basicAuth(ctx, req, host) }
+
+	resp, err := doJSON(ctx, a.opts.httpClient(), http.MethodGet, url, nil, authorize)
+	if err != nil {
+		return fmt.Errorf("look up Artifactory repository %s: %w", key, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body := map[string]string{
+		"key":              key,
+		"rclass":           "local",
+		"packageType":      "docker",
+		"dockerApiVersion": "V2",
+	}
+	resp, err = doJSON(ctx, a.opts.httpClient(), http.MethodPut, url, body, authorize)
+	if err != nil {
+		return fmt.Errorf("create Artifactory repository %s: %w", key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		return nil
+	}
+	return apiError("create Artifactory repository "+key, resp)
+}
+
+// ---------------- Google Artifact Registry ----------------
+
+// garCreator creates the Artifact Registry repository a mirror lives in:
+// <location>-docker.pkg.dev/<project>/<repository>, taken from the mirror
+// root rather than from each image path, which the layout shapes. Image paths
+// inside the repository are created on push.
+type garCreator struct {
+	opts                CreatorOptions
+	host, project, repo string
+}
+
+// cacheKey is the <host>/<project>/<repository> repository lives under.
+func (g *garCreator) cacheKey(repository string) string {
+	parts := strings.SplitN(strings.TrimPrefix(repository, "oci://"), "/", 4)
+	return strings.Join(parts[:min(len(parts), 3)], "/")
+}
+
+// parseGAR splits a mirror root into its host, project and repository.
+func parseGAR(root string) (host, project, repo string, err error) {
+	host, path, _ := strings.Cut(strings.Trim(strings.TrimPrefix(root, "oci://"), "/"), "/")
+	project, repo, _ = strings.Cut(path, "/")
+	repo, _, _ = strings.Cut(repo, "/")
+	if !strings.HasSuffix(host, "-docker.pkg.dev") || project == "" || repo == "" {
+		return "", "", "", fmt.Errorf("an Artifact Registry mirror must be given as <location>-docker.pkg.dev/<project>/<repository>, got %q", root)
+	}
+	return host, project, repo, nil
+}
+
+func (g *garCreator) EnsureRepository(ctx context.Context, repository string) error {
+	host, project, repo := g.host, g.project, g.repo
+	if g.cacheKey(repository) != host+"/"+project+"/"+repo {
+		return fmt.Errorf("repository %q is outside the Artifact Registry repository %s/%s/%s", repository, host, project, repo)
+	}
+	location := strings.TrimSuffix(host, "-docker.pkg.dev")
+	base := "https://artifactregistry.googleapis.com"
+	if g.opts.Endpoint != "" {
+		base = strings.TrimRight(g.opts.Endpoint, "/")
+	}
+	url := fmt.Sprintf("%s/v1/projects/%s/locations/%s/repositories?repositoryId=%s", base, project, location, repo)
+
+	token, err := googleAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := doJSON(ctx, g.opts.httpClient(), http.MethodPost, url, map[string]string{"format": "DOCKER"},
+		func(req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("create Artifact Registry repository %s: %w", repo, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
+		return nil
+	}
+	return apiError("create Artifact Registry repository "+repo, resp)
+}
+
+// googleAccessToken reads GOOGLE_OAUTH_ACCESS_TOKEN, falling back to
+// `gcloud auth print-access-token`.
+func googleAccessToken(ctx context.Context) (string, error) {
+	if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+		return t, nil
+	}
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("no Google access token: set GOOGLE_OAUTH_ACCESS_TOKEN or log in with gcloud (%w)", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,244 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestParseECRHost(t *testing.T) {
+	tests := []struct {
+		host                    string
+		account, region, domain string
+		wantErr                 bool
+	}{
+		{host: "123456789012.dkr.ecr.us-east-1.amazonaws.com", account: "123456789012", region: "us-east-1", domain: "amazonaws.com"},
+		{host: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", account: "123456789012", region: "cn-north-1", domain: "amazonaws.com.cn"},
+		{host: "harbor.example.com", wantErr: true},
+		{host: "123456789012.dkr.ecr.us-east-1.example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		account, region, domain, err := parseECRHost(tt.host)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseECRHost(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if account != tt.account || region != tt.region || domain != tt.domain {
+			t.Errorf("parseECRHost(%q) = %q, %q, %q, want %q, %q, %q", tt.host, account, region, domain, tt.account, tt.region, tt.domain)
+		}
+	}
+}
+
+func TestECRCreator(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var created []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "AmazonEC2ContainerRegistry_V20150921.CreateRepository" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if got := r.Header.Get("Authorization"); !strings.Contains(got, "Credential=AKIDEXAMPLE/") || !strings.Contains(got, "/cn-north-1/ecr/aws4_request") {
+			t.Errorf("Authorization = %q, want a SigV4 signature for cn-north-1/ecr", got)
+		}
+		var body struct {
+			RegistryID     string `json:"registryId"`
+			RepositoryName string `json:"repositoryName"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.RegistryID != "123456789012" {
+			t.Errorf("registryId = %q", body.RegistryID)
+		}
+		if len(created) > 0 && created[len(created)-1] == body.RepositoryName {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"RepositoryAlreadyExistsException","message":"exists"}`))
+			return
+		}
+		created = append(created, body.RepositoryName)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c := &ecrCreator{opts: CreatorOptions{Endpoint: srv.URL}}
+	ctx := context.Background()
+	repo := "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn/jetstack/cert-manager-controller"
+	for i := 0; i < 2; i++ {
+		if err := c.EnsureRepository(ctx, repo); err != nil {
+			t.Fatalf("EnsureRepository() #%d = %v", i+1, err)
+		}
+	}
+	if len(created) != 1 || created[0] != "jetstack/cert-manager-controller" {
+		t.Errorf("created %v, want [jetstack/cert-manager-controller]", created)
+	}
+}
+
+func TestParseGAR(t *testing.T) {
+	tests := []struct {
+		root                string
+		host, project, repo string
+		wantErr             bool
+	}{
+		{root: "us-docker.pkg.dev/acme/mirror", host: "us-docker.pkg.dev", project: "acme", repo: "mirror"},
+		{root: "oci://europe-west4-docker.pkg.dev/acme/mirror/sub/", host: "europe-west4-docker.pkg.dev", project: "acme", repo: "mirror"},
+		// Under preserve-path the image path follows the host directly, so a
+		// bare host can't name a repository.
+		{root: "us-docker.pkg.dev", wantErr: true},
+		{root: "us-docker.pkg.dev/acme", wantErr: true},
+		{root: "harbor.example.com/acme/mirror", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, project, repo, err := parseGAR(tt.root)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGAR(%q) error = %v, wantErr %v", tt.root, err, tt.wantErr)
+			continue
+		}
+		if host != tt.host || project != tt.project || repo != tt.repo {
+			t.Errorf("parseGAR(%q) = %q, %q, %q, want %q, %q, %q", tt.root, host, project, repo, tt.host, tt.project, tt.repo)
+		}
+	}
+}
+
+func TestGARCreator(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "ya29.token")
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/projects/acme/locations/us/repositories" || r.URL.Query().Get("repositoryId") != "mirror" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer ya29.token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	c, err := NewRepositoryCreator(RegistryTypeGAR, CreatorOptions{Endpoint: srv.URL, Root: "us-docker.pkg.dev/acme/mirror"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, repo := range []string{
+		"us-docker.pkg.dev/acme/mirror/jetstack/cert-manager-controller",
+		"us-docker.pkg.dev/acme/mirror/nginx",
+	} {
+		if err := c.EnsureRepository(ctx, repo); err != nil {
+			t.Errorf("EnsureRepository(%q) = %v", repo, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("API called %d times, want 1", calls)
+	}
+	if err := c.EnsureRepository(ctx, "us-docker.pkg.dev/other/repo/nginx"); err == nil {
+		t.Error("EnsureRepository() outside the mirror root succeeded")
+	}
+
+	if _, err := NewRepositoryCreator(RegistryTypeGAR, CreatorOptions{Root: "us-docker.pkg.dev"}); err == nil {
+		t.Error("NewRepositoryCreator() accepted a root without project and repository")
+	}
+}
+
+func staticCredential(username, password string) auth.CredentialFunc {
+	return func(context.Context, string) (auth.Credential, error) {
+		return auth.Credential{Username: username, Password: password}, nil
+	}
+}
+
+func TestHarborCreator(t *testing.T) {
+	projects := map[string]bool{"existing": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2.0/projects" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			ProjectName string `json:"project_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if projects[body.ProjectName] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		projects[body.ProjectName] = true
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c, err := NewRepositoryCreator(RegistryTypeHarbor, CreatorOptions{Endpoint: srv.URL, Credential: staticCredential("admin", "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, repo := range []string{"harbor.example.com/jetstack/cert-manager-controller", "harbor.example.com/existing/nginx"} {
+		if err := c.EnsureRepository(ctx, repo); err != nil {
+			t.Errorf("EnsureRepository(%q) = %v", repo, err)
+		}
+	}
+	if !projects["jetstack"] {
+		t.Error("project jetstack was not created")
+	}
+
+	c, err = NewRepositoryCreator(RegistryTypeHarbor, CreatorOptions{Endpoint: srv.URL, Credential: staticCredential("admin", "wrong")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnsureRepository(ctx, "harbor.example.com/other/nginx"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("EnsureRepository() with bad credentials = %v, want a 401 error", err)
+	}
+}
+
+func TestArtifactoryCreator(t *testing.T) {
+	repos := map[string]bool{"existing": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/artifactory/api/repositories/")
+		switch r.Method {
+		case http.MethodGet:
+			if !repos[key] {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case http.MethodPut:
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["key"] != key || body["rclass"] != "local" || body["packageType"] != "docker" {
+				t.Errorf("create body = %v", body)
+			}
+			repos[key] = true
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewRepositoryCreator(RegistryTypeArtifactory, CreatorOptions{Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, repo := range []string{"artifactory.example.com/docker-local/nginx", "artifactory.example.com/existing/nginx"} {
+		if err := c.EnsureRepository(context.Background(), repo); err != nil {
+			t.Errorf("EnsureRepository(%q) = %v", repo, err)
+		}
+	}
+	if !repos["docker-local"] {
+		t.Error("repository docker-local was not created")
+	}
+}
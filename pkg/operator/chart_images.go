@@ -0,0 +1,184 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wandb/wsm/pkg/helm"
+	"helm.sh/helm/v4/pkg/action"
+	"helm.sh/helm/v4/pkg/chart/common/util"
+	"helm.sh/helm/v4/pkg/chart/loader"
+	chart "helm.sh/helm/v4/pkg/chart/v2"
+	"helm.sh/helm/v4/pkg/cli"
+	v1 "helm.sh/helm/v4/pkg/release/v1"
+)
+
+// ManagedServiceCharts are the operator chart's managed-service operator
+// subcharts (MySQL, Redis, object store, ClickHouse). Installs against
+// external databases don't run them, so their images can be left out of a
+// mirror.
+var ManagedServiceCharts = []string{"moco", "redis-operator", "seaweedfs-operator", "altinity-clickhouse-operator"}
+
+// StackImage is an image one of the releases wsm installs references.
+type StackImage struct {
+	Reference string
+	Release   string // e.g. wandb-operator
+	Chart     string // chart or subchart path, e.g. operator/charts/moco
+	// Rendered is set when the rendered manifests reference the image; the
+	// others only appear in the chart's values.
+	Rendered bool
+}
+
+// Managed reports whether the image comes from one of ManagedServiceCharts.
+func (i StackImage) Managed() bool {
+	parts := strings.Split(i.Chart, "/charts/")
+	for _, sub := range parts[1:] {
+		for _, name := range ManagedServiceCharts {
+			if sub == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StackRenderOptions selects how StackImages renders the releases.
+type StackRenderOptions struct {
+	OperatorChartVersion string
+	Telemetry            TelemetryConfig
+	// Mirror is applied to the values as `wsm deploy-v2 --mirror-registry`
+	// does, so the images come out as the install will pull them. nil renders
+	// the upstream values.
+	Mirror *MirrorConfig
+	// ChartSource is the registry the charts are pulled from, laid out like a
+	// mirror; nil pulls them from upstream. Defaults to Mirror.
+	ChartSource *MirrorConfig
+}
+
+// StackCharts returns the upstream references (no oci:// prefix) of the
+// cert-manager, nginx-gateway-fabric and operator charts at the versions wsm
+// installs.
+func StackCharts(operatorChartVersion string) []string {
+	return []string{
+		strings.TrimPrefix(certManagerChartRef, "oci://") + ":" + CertManagerVersion,
+		strings.TrimPrefix(nginxGatewayChartRef, "oci://") + ":" + NginxGatewayVersion,
+		strings.TrimPrefix(operatorChartRef, "oci://") + ":" + operatorChartVersion,
+	}
+}
+
+// StackImages renders the cert-manager, nginx-gateway-fabric and operator
+// releases with the values DeployOperator and its prerequisites install them
+// with, and returns every image the rendered manifests (hooks included) and
+// the charts' values reference. The set follows whatever the charts pin, so a
+// subchart bump needs no change in wsm.
+func StackImages(ctx context.Context, opts StackRenderOptions) ([]StackImage, error) {
+	source := opts.ChartSource
+	if source == nil {
+		source = opts.Mirror
+	}
+	releases := []struct {
+		spec     ReleaseSpec
+		upstream string
+	}{
+		{CertManagerRelease(true, opts.Mirror), certManagerChartRef},
+		{NginxGatewayRelease(false, opts.Mirror), nginxGatewayChartRef},
+		// The namespaces only reach metadata, never an image.
+		{OperatorRelease("wandb-operators", opts.OperatorChartVersion, opts.Mirror, opts.Telemetry, "wandb", false), operatorChartRef},
+	}
+
+	var images []StackImage
+	for _, r := range releases {
+		spec := r.spec
+		spec.Chart = chartRef(r.upstream, source)
+		found, err := ReleaseImages(ctx, spec, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, img := range found {
+			images = append(images, StackImage{Reference: img.Reference, Release: spec.Name, Chart: img.Chart, Rendered: img.Rendered})
+		}
+	}
+	return images, nil
+}
+
+// ReleaseImages pulls spec's chart, from source when it is a mirror, renders
+// it client-side with spec.Values, and returns the images in the rendered
+// manifests and hooks and in the values of the chart and its enabled
+// subcharts.
+func ReleaseImages(ctx context.Context, spec ReleaseSpec, source *MirrorConfig) ([]helm.ChartImage, error) {
+	settings := cli.New()
+	settings.SetNamespace(spec.Namespace)
+
+	plainHTTP := source != nil && source.Insecure
+	username, password, err := mirrorCredential(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(source), plainHTTP, plainHTTP, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	install := action.NewInstall(action.NewConfiguration())
+	install.SetRegistryClient(registryClient)
+	install.DryRunStrategy = action.DryRunClient
+	install.Replace = true
+	install.ReleaseName = spec.Name
+	install.Namespace = spec.Namespace
+	install.Version = spec.Version
+
+	cp, err := install.LocateChart(spec.Chart, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate chart %s:%s: %w", spec.Chart, spec.Version, err)
+	}
+	charter, err := loader.Load(cp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", spec.Chart, err)
+	}
+	ch, ok := charter.(*chart.Chart)
+	if !ok {
+		return nil, fmt.Errorf("chart %s: unsupported chart API version", spec.Chart)
+	}
+	// Every dependency the chart declares; rendering drops the disabled ones
+	// from ch.
+	subcharts := map[string]bool{}
+	for _, dep := range ch.Metadata.Dependencies {
+		subcharts[dependencyName(dep)] = false
+	}
+
+	rel, err := install.RunWithContext(ctx, ch, spec.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s: %w", spec.Chart, err)
+	}
+	release, ok := rel.(*v1.Release)
+	if !ok {
+		return nil, fmt.Errorf("chart %s: unexpected release type %T", spec.Chart, rel)
+	}
+
+	manifest := release.Manifest
+	for _, hook := range release.Hooks {
+		manifest += "\n---\n# Source: " + hook.Path + "\n" + hook.Manifest
+	}
+	rendered, err := helm.ManifestImages(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", spec.Chart, err)
+	}
+
+	for _, dep := range ch.Dependencies() {
+		subcharts[dep.Name()] = true
+	}
+	values, err := util.CoalesceValues(ch, spec.Values)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: merge values: %w", spec.Chart, err)
+	}
+	return helm.MergeImages(rendered, helm.ValuesImages(ch.Name(), values, subcharts)), nil
+}
+
+// dependencyName is the key a dependency's values live under.
+func dependencyName(dep *chart.Dependency) string {
+	if dep.Alias != "" {
+		return dep.Alias
+	}
+	return dep.Name
+}
@@ -0,0 +1,157 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/registryauth"
+)
+
+const (
+	// GatewayAPIVersion is the Gateway API release whose standard CRDs wsm
+	// installs ahead of nginx-gateway-fabric.
+	GatewayAPIVersion = "v1.4.0"
+
+	// GatewayAPICRDURL is where the CRD manifest is fetched from without a mirror.
+	GatewayAPICRDURL = "https://github.com/kubernetes-sigs/gateway-api/releases/download/" + GatewayAPIVersion + "/standard-install.yaml"
+
+	// GatewayAPICRDRepository is the upstream-style name the CRD artifact is
+	// placed under in a mirror. Nothing is published there: `wsm registry
+	// mirror` packages the release manifest itself, and the mirror layout maps
+	// this name like any other (<mirror>/wandb/gateway-api-crds under
+	// preserve-path).
+	GatewayAPICRDRepository = "us-docker.pkg.dev/wandb-production/public/wandb/gateway-api-crds"
+
+	// GatewayAPICRDArtifactType is the config media type of the CRD artifact.
+	GatewayAPICRDArtifactType = "application/vnd.wandb.gateway-api-crds.v1+json"
+
+	gatewayAPICRDLayerMediaType = "application/yaml"
+	gatewayAPICRDFileName       = "standard-install.yaml"
+)
+
+// GatewayAPICRDReference is the upstream-style reference of the pinned CRD
+// artifact; place it in a mirror with the mirror's layout.
+func GatewayAPICRDReference() string {
+	return GatewayAPICRDRepository + ":" + GatewayAPIVersion
+}
+
+// FetchGatewayAPICRDs downloads the Gateway API CRD manifest from crdURL, or
+// from GatewayAPICRDURL when crdURL is empty.
+func FetchGatewayAPICRDs(ctx context.Context, crdURL string) ([]byte, error) {
+	if crdURL == "" {
+		crdURL = GatewayAPICRDURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, crdURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Gateway API CRDs: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Gateway API CRDs: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch Gateway API CRDs: status %s", resp.Status)
+	}
+
+	yamlContent, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Gateway API CRDs: %w", err)
+	}
+	return yamlContent, nil
+}
+
+// GatewayAPICRDImage packs a CRD manifest into the single-layer OCI artifact
+// `wsm registry mirror` pushes and PullGatewayAPICRDs reads back.
+func GatewayAPICRDImage(crds []byte, sourceURL string) (v1.Image, error) {
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, GatewayAPICRDArtifactType)
+	img, err := mutate.Append(img, mutate.Addendum{
+		Layer:       static.NewLayer(crds, gatewayAPICRDLayerMediaType),
+		Annotations: map[string]string{"org.opencontainers.image.title": gatewayAPICRDFileName},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mutate.Annotations(img, map[string]string{
+		"org.opencontainers.image.version": GatewayAPIVersion,
+		"org.opencontainers.image.source":  sourceURL,
+	}).(v1.Image), nil
+}
+
+// PullGatewayAPICRDs reads the CRD manifest out of the artifact `wsm registry
+// mirror` placed in mirror.
+func PullGatewayAPICRDs(ctx context.Context, mirror *MirrorConfig) ([]byte, error) {
+	refStr := mirror.Layout.Image(mirror.Host, GatewayAPICRDReference())
+	var nameOpts []name.Option
+	if mirror.Insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	ref, err := name.ParseReference(refStr, nameOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", refStr, err)
+	}
+
+	creds := mirror.Credentials
+	if creds == nil {
+		creds = registryauth.Default()
+	}
+	opts := []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(registryauth.Keychain(creds)),
+	}
+	if mirror.Insecure || mirror.CAFile != "" {
+		tlsConf, err := tlsconfig.Client(tlsconfig.Options{
+			InsecureSkipVerify: mirror.Insecure,
+			CAFile:             mirror.CAFile,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client TLS certs: %w", err)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConf
+		opts = append(opts, remote.WithTransport(transport))
+	}
+
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pull %s (mirror it with 'wsm registry mirror'): %w", refStr, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", refStr, err)
+	}
+	for _, layer := range layers {
+		mt, err := layer.MediaType()
+		if err != nil || mt != gatewayAPICRDLayerMediaType {
+			continue
+		}
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", refStr, err)
+		}
+		defer func() { _ = rc.Close() }()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("%s has no %s layer", refStr, gatewayAPICRDLayerMediaType)
+}
+
+// installGatewayApiCRDs applies a Gateway API CRD manifest.
+func installGatewayApiCRDs(ctx context.Context, c *kubectl.Client, crds []byte) error {
+	if err := c.ApplyYAML(ctx, crds); err != nil {
+		return fmt.Errorf("failed to apply Gateway API CRDs: %w", err)
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -14,6 +13,8 @@ import (
 	appsv1 "github.com/wandb/operator/api/v1"
 	v2 "github.com/wandb/operator/api/v2"
 	"github.com/wandb/wsm/pkg/kubectl"
+	"github.com/wandb/wsm/pkg/mirror"
+	"github.com/wandb/wsm/pkg/registryauth"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v4/pkg/action"
 	"helm.sh/helm/v4/pkg/chart/loader"
@@ -28,12 +29,14 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // CreateNamespace creates a namespace if it doesn't exist
-func CreateNamespace(ctx context.Context, namespace string) error {
-	_, cs, err := kubectl.GetClientset()
+func CreateNamespace(ctx context.Context, c *kubectl.Client, namespace string) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
@@ -43,15 +46,21 @@ func CreateNamespace(ctx context.Context, namespace string) error {
 			Name: namespace,
 		},
 	}
+	kubectl.Stamp(ctx, ns, nil)
 
-	_, err = cs.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	_, err = cs.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{FieldManager: kubectl.FieldManager})
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
+		if !strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("failed to create namespace: %w", err)
+		}
+		// Re-record a namespace an earlier run created; leave anyone else's alone.
+		existing, err := cs.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if err != nil || !kubectl.IsManaged(existing) {
 			return nil
 		}
-		return fmt.Errorf("failed to create namespace: %w", err)
 	}
 
+	kubectl.Record(ctx, corev1.SchemeGroupVersion.WithKind("Namespace"), "", namespace)
 	return nil
 }
 
@@ -73,7 +82,6 @@ const (
 	// installs. Exported for the same reason as CertManagerVersion.
 	NginxGatewayVersion = "2.5.1"
 
-	gatewayApiCRDURL                     = "https://github.com/kubernetes-sigs/gateway-api/releases/download/v1.4.0/standard-install.yaml"
 	completeServerAPIsDiscoveryErrSubstr = "unable to retrieve the complete list of server APIs"
 )
 
@@ -102,20 +110,36 @@ func setNested(obj map[string]any, value any, path ...string) {
 // MirrorConfig points the install functions at a customer-controlled registry
 // instead of the upstream defaults baked into wsm. When non-nil, every OCI
 // chart reference and container image repository is rewritten to live under
-// Host (using wsm's path conventions; see registry_mirror.go for the matching
-// push targets). Populate the mirror with `wsm registry mirror --to <Host>`
+// Host, placed by Layout (see pkg/mirror; registry_mirror.go pushes to the
+// same locations). Populate the mirror with `wsm registry mirror --to <Host>`
 // before installing.
 type MirrorConfig struct {
-	Host     string // hostname[:port] of the mirror, e.g. "local-registry:5000"
-	Insecure bool   // plain HTTP / skip TLS verify; passed to Helm's OCI client
-	CAFile   string // path to a PEM CA bundle to trust for an HTTPS mirror (self-signed / internal CA)
+	Host     string         // hostname[:port] of the mirror, e.g. "local-registry:5000"
+	Insecure bool           // plain HTTP / skip TLS verify; passed to Helm's OCI client
+	CAFile   string         // path to a PEM CA bundle to trust for an HTTPS mirror (self-signed / internal CA)
+	Layout   *mirror.Layout // repository layout inside the mirror; nil means preserve-path
+	// Credentials authenticates Helm's OCI chart pulls from Host. nil leaves
+	// Helm on its own registry config and the Docker config.
+	Credentials registryauth.Provider
+}
+
+// Repository returns the mirror repository for upstreamRepo (no tag), e.g.
+// quay.io/jetstack/cert-manager-controller → <Host>/jetstack/cert-manager-controller.
+func (m *MirrorConfig) Repository(upstreamRepo string) string {
+	return m.Layout.Repository(m.Host, upstreamRepo)
+}
+
+// Path is Repository without the mirror host, for charts that take the
+// registry and repository as separate values.
+func (m *MirrorConfig) Path(upstreamRepo string) string {
+	return m.Layout.Path(upstreamRepo)
 }
 
 // InstallCertManager installs cert-manager.
 // When skipIfPresent is true, installation is skipped if the cert-manager deployment already exists.
-func InstallCertManager(ctx context.Context, enableGatewayAPI bool, skipIfPresent bool, mirror *MirrorConfig) error {
+func InstallCertManager(ctx context.Context, c *kubectl.Client, enableGatewayAPI bool, skipIfPresent bool, mirror *MirrorConfig) error {
 	if skipIfPresent {
-		deploymentExists, err := certManagerDeploymentExists(ctx)
+		deploymentExists, err := certManagerDeploymentExists(ctx, c)
 		if err != nil {
 			return err
 		}
@@ -124,14 +148,12 @@ func InstallCertManager(ctx context.Context, enableGatewayAPI bool, skipIfPresen
 		}
 	}
 
-	if err := CreateNamespace(ctx, certManagerNamespace); err != nil {
+	if err := CreateNamespace(ctx, c, certManagerNamespace); err != nil {
 		return err
 	}
 
 	// Initialize Helm settings
-	settings := cli.New()
-	settings.SetNamespace(certManagerNamespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, certManagerNamespace)
 
 	// Initialize action configuration
 	actionConfig, err := initActionConfig(settings)
@@ -142,7 +164,11 @@ func InstallCertManager(ctx context.Context, enableGatewayAPI bool, skipIfPresen
 	// Create registry client. Plain-HTTP / TLS-skip needed for self-hosted
 	// mirrors that don't have a real cert (e.g. a local registry:2).
 	plainHTTP := mirror != nil && mirror.Insecure
-	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP)
+	username, password, err := mirrorCredential(ctx, mirror)
+	if err != nil {
+		return err
+	}
+	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to create registry client: %w", err)
 	}
@@ -154,30 +180,8 @@ func InstallCertManager(ctx context.Context, enableGatewayAPI bool, skipIfPresen
 		return fmt.Errorf("failed to check if release exists: %w", err)
 	}
 
-	chartRef := certManagerChartRef
-	if mirror != nil {
-		chartRef = "oci://" + mirror.Host + "/jetstack/charts/cert-manager"
-	}
-
-	releaseValues := map[string]interface{}{
-		"crds": map[string]interface{}{
-			"enabled": true,
-		},
-		"config": map[string]interface{}{
-			"enableGatewayAPI": enableGatewayAPI,
-		},
-		"startupapicheck": map[string]interface{}{
-			"enabled": false,
-		},
-	}
-
-	if mirror != nil {
-		// cert-manager v1.20 composes per-component image refs as
-		// <imageRegistry>/<imageNamespace>/cert-manager-<component>:<tag>.
-		// Setting both makes all 5 component images resolve to the mirror.
-		releaseValues["imageRegistry"] = mirror.Host
-		releaseValues["imageNamespace"] = "jetstack"
-	}
+	release := CertManagerRelease(enableGatewayAPI, mirror)
+	chartRef, releaseValues := release.Chart, release.Values
 
 	if releaseExists {
 		// Create upgrade action
@@ -236,8 +240,8 @@ func InstallCertManager(ctx context.Context, enableGatewayAPI bool, skipIfPresen
 }
 
 // WaitForCertManager waits for cert-manager to be ready
-func WaitForCertManager(ctx context.Context, timeout time.Duration) error {
-	_, cs, err := kubectl.GetClientset()
+func WaitForCertManager(ctx context.Context, c *kubectl.Client, timeout time.Duration) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
@@ -259,8 +263,8 @@ func WaitForCertManager(ctx context.Context, timeout time.Duration) error {
 	})
 }
 
-func certManagerDeploymentExists(ctx context.Context) (bool, error) {
-	_, cs, err := kubectl.GetClientset()
+func certManagerDeploymentExists(ctx context.Context, c *kubectl.Client) (bool, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return false, err
 	}
@@ -278,10 +282,8 @@ func certManagerDeploymentExists(ctx context.Context) (bool, error) {
 // DeleteCertManager deletes the cert-manager resources
 // DeleteCertManager uninstalls the cert-manager Helm release. removed is false when
 // there was no release to uninstall.
-func DeleteCertManager(ctx context.Context) (removed bool, err error) {
-	settings := cli.New()
-	settings.SetNamespace(certManagerNamespace)
-	settings.KubeContext = kubectl.GetContext()
+func DeleteCertManager(ctx context.Context, c *kubectl.Client) (removed bool, err error) {
+	settings := helmSettings(c, certManagerNamespace)
 
 	actionConfig, err := initActionConfig(settings)
 	if err != nil {
@@ -305,9 +307,10 @@ func DeleteCertManager(ctx context.Context) (removed bool, err error) {
 
 // InstallNginxGateway installs nginx-gateway-fabric.
 // When skipIfPresent is true, installation is skipped if the nginx-gateway-fabric deployment already exists.
-func InstallNginxGateway(ctx context.Context, skipIfPresent bool, mirror *MirrorConfig, gatewayCRDURL string, skipGatewayCRDs bool) error {
+// nodePorts exposes it on the NodePorts a `wsm cluster create` cluster maps to the host.
+func InstallNginxGateway(ctx context.Context, c *kubectl.Client, skipIfPresent, nodePorts bool, mirror *MirrorConfig, gatewayCRDURL string, skipGatewayCRDs bool) error {
 	if skipIfPresent {
-		deploymentExists, err := nginxGatewayDeploymentExists(ctx)
+		deploymentExists, err := nginxGatewayDeploymentExists(ctx, c)
 		if err != nil {
 			return err
 		}
@@ -316,34 +319,39 @@ func InstallNginxGateway(ctx context.Context, skipIfPresent bool, mirror *Mirror
 		}
 	}
 
-	exists, err := gatewayApiCRDsExist(ctx)
+	exists, err := gatewayApiCRDsExist(ctx, c)
 	if err != nil {
 		return fmt.Errorf("failed to check if gateway api crds exist: %w", err)
 	}
 	if !exists {
-		// In an air-gapped network the default URL is unreachable. --skip-gateway-api-crds
-		// lets the customer pre-apply the CRDs (e.g. from a mirrored copy) and have wsm
-		// assume they're present; --gateway-api-crd-url points the fetch at an internal host.
+		// In an air-gapped network the default URL is unreachable. With a mirror the
+		// CRDs come from the artifact `wsm registry mirror` packaged there;
+		// --gateway-api-crd-url points the fetch at an internal host instead, and
+		// --skip-gateway-api-crds lets the customer pre-apply them and have wsm
+		// assume they're present.
 		if skipGatewayCRDs {
 			return fmt.Errorf("gateway API CRDs are not installed and --skip-gateway-api-crds was set; apply them first (kubectl apply -f <gateway-api standard-install.yaml>)")
 		}
-		crdURL := gatewayCRDURL
-		if crdURL == "" {
-			crdURL = gatewayApiCRDURL
+		var crds []byte
+		if gatewayCRDURL == "" && mirror != nil {
+			crds, err = PullGatewayAPICRDs(ctx, mirror)
+		} else {
+			crds, err = FetchGatewayAPICRDs(ctx, gatewayCRDURL)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to install gateway api crds: %w", err)
 		}
-		if err := installGatewayApiCRDs(ctx, crdURL); err != nil {
+		if err := installGatewayApiCRDs(ctx, c, crds); err != nil {
 			return fmt.Errorf("failed to install gateway api crds: %w", err)
 		}
 	}
 
-	if err := CreateNamespace(ctx, nginxGatewayNamespace); err != nil {
+	if err := CreateNamespace(ctx, c, nginxGatewayNamespace); err != nil {
 		return err
 	}
 
 	// Initialize Helm settings
-	settings := cli.New()
-	settings.SetNamespace(nginxGatewayNamespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, nginxGatewayNamespace)
 
 	// Initialize action configuration
 	actionConfig, err := initActionConfig(settings)
@@ -354,7 +362,11 @@ func InstallNginxGateway(ctx context.Context, skipIfPresent bool, mirror *Mirror
 	// Create registry client. Plain-HTTP / TLS-skip needed for self-hosted
 	// mirrors that don't have a real cert (e.g. a local registry:2).
 	plainHTTP := mirror != nil && mirror.Insecure
-	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP)
+	username, password, err := mirrorCredential(ctx, mirror)
+	if err != nil {
+		return err
+	}
+	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to create registry client: %w", err)
 	}
@@ -366,32 +378,8 @@ func InstallNginxGateway(ctx context.Context, skipIfPresent bool, mirror *Mirror
 		return fmt.Errorf("failed to check if release exists: %w", err)
 	}
 
-	chartRef := nginxGatewayChartRef
-	if mirror != nil {
-		chartRef = "oci://" + mirror.Host + "/nginx/charts/nginx-gateway-fabric"
-	}
-
-	releaseValues := map[string]any{}
-
-	if strings.HasPrefix(kubectl.GetContext(), "kind-") {
-		releaseValues["nginx"] = map[string]any{
-			"service": map[string]any{
-				"type": "NodePort",
-				"nodePorts": []map[string]any{
-					{"port": 31437, "listenerPort": 8080},
-					{"port": 30478, "listenerPort": 8443},
-				},
-			},
-		}
-	}
-
-	if mirror != nil {
-		// nginx-gateway-fabric has no global imageRegistry — each component
-		// repository is set independently. Merge with any existing nginx.*
-		// values (the Kind NodePort block above).
-		setNested(releaseValues, mirror.Host+"/nginx/nginx-gateway-fabric", "nginxGateway", "image", "repository")
-		setNested(releaseValues, mirror.Host+"/nginx/nginx-gateway-fabric/nginx", "nginx", "image", "repository")
-	}
+	release := NginxGatewayRelease(nodePorts, mirror)
+	chartRef, releaseValues := release.Chart, release.Values
 
 	if releaseExists {
 		// Create upgrade action
@@ -451,8 +439,8 @@ func InstallNginxGateway(ctx context.Context, skipIfPresent bool, mirror *Mirror
 }
 
 // WaitForNginxGateway waits for nginx-gateway-fabric to be ready
-func WaitForNginxGateway(ctx context.Context, timeout time.Duration) error {
-	_, cs, err := kubectl.GetClientset()
+func WaitForNginxGateway(ctx context.Context, c *kubectl.Client, timeout time.Duration) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
@@ -474,8 +462,8 @@ func WaitForNginxGateway(ctx context.Context, timeout time.Duration) error {
 	})
 }
 
-func nginxGatewayDeploymentExists(ctx context.Context) (bool, error) {
-	_, cs, err := kubectl.GetClientset()
+func nginxGatewayDeploymentExists(ctx context.Context, c *kubectl.Client) (bool, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return false, err
 	}
@@ -492,10 +480,8 @@ func nginxGatewayDeploymentExists(ctx context.Context) (bool, error) {
 
 // DeleteNginxGateway uninstalls the nginx-gateway-fabric Helm release. removed is false
 // when there was no release to uninstall.
-func DeleteNginxGateway(ctx context.Context) (removed bool, err error) {
-	settings := cli.New()
-	settings.SetNamespace(nginxGatewayNamespace)
-	settings.KubeContext = kubectl.GetContext()
+func DeleteNginxGateway(ctx context.Context, c *kubectl.Client) (removed bool, err error) {
+	settings := helmSettings(c, nginxGatewayNamespace)
 
 	actionConfig, err := initActionConfig(settings)
 	if err != nil {
@@ -518,8 +504,8 @@ func DeleteNginxGateway(ctx context.Context) (removed bool, err error) {
 }
 
 // gatewayApiCRDsExist checks if Gateway API CRDs exist in the cluster
-func gatewayApiCRDsExist(ctx context.Context) (bool, error) {
-	_, cs, err := kubectl.GetClientset()
+func gatewayApiCRDsExist(ctx context.Context, c *kubectl.Client) (bool, error) {
+	cs, err := c.Clientset()
 	if err != nil {
 		return false, err
 	}
@@ -589,30 +575,6 @@ func isRetryableServerAPIDiscoveryError(err error) bool {
 	return strings.Contains(err.Error(), completeServerAPIsDiscoveryErrSubstr)
 }
 
-// installGatewayApiCRDs installs Gateway API CRDs from the given YAML URL.
-func installGatewayApiCRDs(ctx context.Context, crdURL string) error {
-	resp, err := http.Get(crdURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch Gateway API CRDs: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to fetch Gateway API CRDs: status %s", resp.Status)
-	}
-
-	yamlContent, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read Gateway API CRDs: %w", err)
-	}
-
-	if err := kubectl.ApplyYAML(ctx, yamlContent); err != nil {
-		return fmt.Errorf("failed to apply Gateway API CRDs: %w", err)
-	}
-
-	return nil
-}
-
 // mergeValues shallow-merges add into releaseValues[key], creating it if absent.
 func mergeValues(releaseValues map[string]interface{}, key string, add map[string]interface{}) {
 	existing, ok := releaseValues[key].(map[string]interface{})
@@ -665,12 +627,10 @@ type OpenShiftConfig struct {
 
 // GetOperatorOpenShiftConfig reads the installed operator release and reports its
 // OpenShift settings. Returns nil when the operator is not installed in namespace.
-func GetOperatorOpenShiftConfig(namespace string) (*OpenShiftConfig, error) {
+func GetOperatorOpenShiftConfig(ctx context.Context, c *kubectl.Client, namespace string) (*OpenShiftConfig, error) {
 	const releaseName = "wandb-operator"
 
-	settings := cli.New()
-	settings.SetNamespace(namespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, namespace)
 
 	actionConfig, err := initActionConfig(settings)
 	if err != nil {
@@ -764,6 +724,7 @@ func nestedValue(values map[string]interface{}, keys ...string) (interface{}, bo
 // DeployOperator deploys the W&B operator chart version specified.  The chart is called operator and is available in oci://us-docker.pkg.dev/wandb-production/public/wandb/charts
 func DeployOperator(
 	ctx context.Context,
+	c *kubectl.Client,
 	namespace string,
 	chartVersion string,
 	mirror *MirrorConfig,
@@ -771,19 +732,11 @@ func DeployOperator(
 	wandbNamespace string,
 	openshift bool,
 ) error {
-	const chartName = "operator"
-	const releaseName = "wandb-operator"
-
-	repositoryURL := "oci://us-docker.pkg.dev/wandb-production/public/wandb/charts"
-	if mirror != nil {
-		repositoryURL = "oci://" + mirror.Host + "/wandb/charts"
-	}
-	chartRef := repositoryURL + "/" + chartName
+	release := OperatorRelease(namespace, chartVersion, mirror, telemetry, wandbNamespace, openshift)
+	releaseName, chartRef, releaseValues := release.Name, release.Chart, release.Values
 
 	// Initialize Helm settings
-	settings := cli.New()
-	settings.SetNamespace(namespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, namespace)
 
 	// Initialize action configuration
 	actionConfig, err := initActionConfig(settings)
@@ -794,7 +747,11 @@ func DeployOperator(
 	// Create registry client. Plain-HTTP / TLS-skip needed for self-hosted
 	// mirrors that don't have a real cert (e.g. a local registry:2).
 	plainHTTP := mirror != nil && mirror.Insecure
-	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP)
+	username, password, err := mirrorCredential(ctx, mirror)
+	if err != nil {
+		return err
+	}
+	registryClient, err := newRegistryClient(settings, "", "", mirrorCAFile(mirror), plainHTTP, plainHTTP, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to create registry client: %w", err)
 	}
@@ -806,96 +763,13 @@ func DeployOperator(
 		return fmt.Errorf("failed to check if release exists: %w", err)
 	}
 
-	operatorImage := map[string]interface{}{
-		"pullPolicy": "Always",
-	}
-	if mirror != nil {
-		operatorImage["repository"] = mirror.Host + "/wandb/operator"
-	}
-
-	telemetryValues := buildTelemetryValues(telemetry)
-	releaseValues := map[string]interface{}{
-		"wandb": map[string]interface{}{
-			"install": false,
-		},
-		"wandb-operator": map[string]interface{}{
-			"image": operatorImage,
-		},
-		"telemetry": telemetryValues,
-	}
-
-	// Point the bundled managed-service operator subcharts at the mirror. Each
-	// third-party subchart exposes images differently (no single global knob), so
-	// we set each chart's own registry/repository key — all resolving to
-	// <mirror>/<host-stripped path>, matching where `wsm registry mirror` pushes
-	// them (translate() in registry_mirror.go). Helm deep-merges these over the
-	// chart's values.yaml, so image tags and unrelated keys are preserved.
-	// Kafka (Bufstream) has no subchart operator here and no Helm image knob; the
-	// operator emits its data-plane images with upstream refs, so they reach the
-	// mirror via each node's container-runtime registry mirror — not a Helm value
-	// and not spec.global.imageRegistry (which --mirror-registry does not set).
-	if mirror != nil {
-		// moco injects three sidecar images into every MySQLCluster via the
-		// controller's --agent-image / --fluent-bit-image / mysqld_exporter args
-		// (driven by these chart values), so all four must be retargeted — not
-		// just the controller image. Verified by `helm template`.
-		releaseValues["moco"] = map[string]interface{}{
-			"image":          map[string]interface{}{"repository": mirror.Host + "/cybozu-go/moco"},
-			"agent":          map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Host + "/cybozu-go/moco-agent"}},
-			"fluentbit":      map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Host + "/cybozu-go/moco/fluent-bit"}},
-			"mysqldExporter": map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Host + "/cybozu-go/moco/mysqld_exporter"}},
-		}
-		releaseValues["redis-operator"] = map[string]interface{}{
-			"redisOperator": map[string]interface{}{"imageName": mirror.Host + "/opstree/redis-operator"},
-		}
-		releaseValues["seaweedfs-operator"] = map[string]interface{}{
-			"image": map[string]interface{}{
-				"registry":   mirror.Host,
-				"repository": "chrislusf/seaweedfs-operator",
-			},
-		}
-		releaseValues["altinity-clickhouse-operator"] = map[string]interface{}{
-			"operator": map[string]interface{}{
-				"image": map[string]interface{}{"registry": mirror.Host},
-			},
-			"metrics": map[string]interface{}{
-				"image": map[string]interface{}{"registry": mirror.Host},
-			},
-			"crdHook": map[string]interface{}{
-				"image": map[string]interface{}{"repository": mirror.Host + "/alpine/k8s"},
-			},
-		}
-	}
-
-	// The operator chart's telemetry-validation requires the caller to opt the
-	// victoria-metrics-operator and grafana-operator dependencies in when
-	// telemetry is enabled — their chart defaults are false (helm dependency
-	// conditions are boolean-only). "full" runs the in-cluster Victoria stack
-	// plus local Grafana; "forward" runs the Victoria stack and forwards OTLP
-	// data to telemetry.forwarding.otlp.endpoint.
-	if telemetry.Mode == TelemetryModeFull || telemetry.Mode == TelemetryModeForward {
-		// The telemetry subchart deploys into the telemetry namespace (the W&B
-		// namespace), not the operator's release namespace. It must already
-		// exist — the chart does not create it — so ensure it here and pin
-		// telemetry.namespace to match the CR's namespace.
-		telemetryValues["namespace"] = wandbNamespace
-		if err := CreateNamespace(ctx, wandbNamespace); err != nil {
+	if telemetryNeedsNamespace(telemetry) {
+		// The telemetry subchart deploys into the W&B namespace but does not
+		// create it.
+		if err := CreateNamespace(ctx, c, wandbNamespace); err != nil {
 			return fmt.Errorf("failed to ensure telemetry namespace %q: %w", wandbNamespace, err)
 		}
 	}
-	// Enable the telemetry subchart dependencies (chart defaults are false). The
-	// forwarding.otlp.* values for "forward" are already set by buildTelemetryValues.
-	switch telemetry.Mode {
-	case TelemetryModeFull:
-		releaseValues["victoria-metrics-operator"] = map[string]interface{}{"enabled": true}
-		releaseValues["grafana-operator"] = map[string]interface{}{"enabled": true}
-	case TelemetryModeForward:
-		releaseValues["victoria-metrics-operator"] = map[string]interface{}{"enabled": true}
-	}
-
-	if openshift {
-		applyOpenShiftValues(releaseValues)
-	}
 
 	if releaseExists {
 		// Create upgrade action
@@ -953,8 +827,8 @@ func DeployOperator(
 }
 
 // WaitForOperator waits for operator to be ready by checking webhook CA bundle injection and deployment
-func WaitForOperator(ctx context.Context, namespace string, timeout time.Duration) error {
-	_, cs, err := kubectl.GetClientset()
+func WaitForOperator(ctx context.Context, c *kubectl.Client, namespace string, timeout time.Duration) error {
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
@@ -1028,12 +902,10 @@ func WaitForOperator(ctx context.Context, namespace string, timeout time.Duratio
 
 // DeleteOperator uninstalls the W&B operator Helm release. removed is false when there
 // was no release to uninstall.
-func DeleteOperator(ctx context.Context, namespace string) (removed bool, err error) {
+func DeleteOperator(ctx context.Context, c *kubectl.Client, namespace string) (removed bool, err error) {
 	const releaseName = "wandb-operator"
 
-	settings := cli.New()
-	settings.SetNamespace(namespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, namespace)
 
 	actionConfig, err := initActionConfig(settings)
 	if err != nil {
@@ -1055,6 +927,59 @@ func DeleteOperator(ctx context.Context, namespace string) (removed bool, err er
 	return true, nil
 }
 
+// GetOperatorChartVersion returns the chart version of the installed operator
+// release, or "" when the operator is not installed in namespace.
+func GetOperatorChartVersion(ctx context.Context, c *kubectl.Client, namespace string) (string, error) {
+	const releaseName = "wandb-operator"
+
+	settings := helmSettings(c, namespace)
+
+	actionConfig, err := initActionConfig(settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize action config: %w", err)
+	}
+
+	exists, err := checkReleaseExists(actionConfig, releaseName)
+	if err != nil {
+		return "", fmt.Errorf("failed to check operator release: %w", err)
+	}
+	if !exists {
+		return "", nil
+	}
+
+	rel, err := action.NewGet(actionConfig).Run(releaseName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read operator release %q: %w", releaseName, err)
+	}
+	release, ok := rel.(*v1.Release)
+	if !ok || release.Chart == nil || release.Chart.Metadata == nil {
+		return "", fmt.Errorf("unexpected release type for %q", releaseName)
+	}
+	return release.Chart.Metadata.Version, nil
+}
+
+// CertManagerInstalled reports whether the cert-manager release is installed.
+func CertManagerInstalled(ctx context.Context, c *kubectl.Client) (bool, error) {
+	return releaseInstalled(ctx, c, certManagerNamespace, certManagerReleaseName)
+}
+
+// NginxGatewayInstalled reports whether the nginx-gateway release is installed.
+func NginxGatewayInstalled(ctx context.Context, c *kubectl.Client) (bool, error) {
+	return releaseInstalled(ctx, c, nginxGatewayNamespace, nginxGatewayReleaseName)
+}
+
+func releaseInstalled(ctx context.Context, c *kubectl.Client, namespace, releaseName string) (bool, error) {
+	actionConfig, err := initActionConfig(helmSettings(c, namespace))
+	if err != nil {
+		return false, fmt.Errorf("failed to initialize action config: %w", err)
+	}
+	exists, err := checkReleaseExists(actionConfig, releaseName)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s release: %w", releaseName, err)
+	}
+	return exists, nil
+}
+
 // checkReleaseExists checks if a Helm release exists
 func checkReleaseExists(actionConfig *action.Configuration, releaseName string) (bool, error) {
 	listClient := action.NewList(actionConfig)
@@ -1076,6 +1001,31 @@ func checkReleaseExists(actionConfig *action.Configuration, releaseName string)
 	return false, nil
 }
 
+// helmSettings returns the Helm settings for namespace, reaching the cluster
+// the way c does: same kubeconfig, context, impersonation and request timeout.
+func helmSettings(c *kubectl.Client, namespace string) *cli.EnvSettings {
+	opts := c.Options()
+	settings := cli.New()
+	settings.SetNamespace(namespace)
+	settings.KubeContext = opts.Context
+	if opts.Kubeconfig != "" {
+		settings.KubeConfig = opts.Kubeconfig
+	}
+	if opts.Impersonate != "" {
+		settings.KubeAsUser = opts.Impersonate
+	}
+	if len(opts.ImpersonateGroups) > 0 {
+		settings.KubeAsGroups = opts.ImpersonateGroups
+	}
+	if opts.RequestTimeout > 0 {
+		if flags, ok := settings.RESTClientGetter().(*genericclioptions.ConfigFlags); ok {
+			timeout := opts.RequestTimeout.String()
+			flags.Timeout = &timeout
+		}
+	}
+	return settings
+}
+
 // TODO refactor these into the helm pkg
 
 var helmDriver string = os.Getenv("HELM_DRIVER")
@@ -1116,35 +1066,48 @@ func mirrorCAFile(mirror *MirrorConfig) string {
 	return mirror.CAFile
 }
 
+// mirrorCredential resolves the mirror's username and password for Helm's OCI
+// client. Every chart comes from the mirror when one is configured, so Helm's
+// client-wide basic auth is scoped correctly.
+func mirrorCredential(ctx context.Context, mirror *MirrorConfig) (username, password string, err error) {
+	if mirror == nil || mirror.Credentials == nil {
+		return "", "", nil
+	}
+	cred, err := mirror.Credentials.Credential(ctx, mirror.Host)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve credentials for %s: %w", mirror.Host, err)
+	}
+	return cred.Username, cred.Password, nil
+}
+
 // InjectRegistryCAIntoOperator makes the wandb-operator pod trust an HTTPS
 // mirror's CA. The operator fetches the server manifest from inside the cluster
 // with its own TLS-verifying client (not containerd), so a self-signed / internal
 // CA must be present in the pod's trust store. We mount the CA as a secret and
 // point Go's SSL_CERT_FILE at it — no operator image or chart change needed.
-func InjectRegistryCAIntoOperator(ctx context.Context, namespace, caFile string) error {
+func InjectRegistryCAIntoOperator(ctx context.Context, c *kubectl.Client, namespace, caFile string) error {
 	caData, err := os.ReadFile(caFile)
 	if err != nil {
 		return fmt.Errorf("read registry CA file %q: %w", caFile, err)
 	}
 
-	_, cs, err := kubectl.GetClientset()
+	cs, err := c.Clientset()
 	if err != nil {
 		return err
 	}
 
 	const secretName = "wsm-registry-ca"
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: namespace},
-		Data:       map[string][]byte{"ca.crt": caData},
-	}
-	if _, err := cs.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
-		if !errors.IsAlreadyExists(err) {
-			return fmt.Errorf("create registry CA secret: %w", err)
-		}
-		if _, err := cs.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
-			return fmt.Errorf("update registry CA secret: %w", err)
-		}
-	}
+	objMeta := &metav1.ObjectMeta{}
+	kubectl.Stamp(ctx, objMeta, nil)
+	secret := corev1ac.Secret(secretName, namespace).
+		WithLabels(objMeta.Labels).
+		WithAnnotations(objMeta.Annotations).
+		WithData(map[string][]byte{"ca.crt": caData})
+	// The secret is wsm's own, so take its fields over unconditionally.
+	if _, err := cs.CoreV1().Secrets(namespace).Apply(ctx, secret, metav1.ApplyOptions{FieldManager: kubectl.FieldManager, Force: true}); err != nil {
+		return fmt.Errorf("apply registry CA secret: %w", err)
+	}
+	kubectl.Record(ctx, corev1.SchemeGroupVersion.WithKind("Secret"), namespace, secretName)
 
 	const deployName = "wandb-operator"
 	dep, err := cs.AppsV1().Deployments(namespace).Get(ctx, deployName, metav1.GetOptions{})
@@ -1165,16 +1128,17 @@ func InjectRegistryCAIntoOperator(ctx context.Context, namespace, caFile string)
 		`"env":[{"name":"SSL_CERT_FILE","value":"/etc/wsm-ca/ca.crt"}]}]}}}}`,
 		secretName, secretName, containerName, secretName)
 
-	return kubectl.PatchDeployment(ctx, deployName, namespace, types.StrategicMergePatchType, []byte(patch))
+	return c.PatchDeployment(ctx, deployName, namespace, types.StrategicMergePatchType, []byte(patch))
 }
 
-func newRegistryClient(settings *cli.EnvSettings, certFile, keyFile, caFile string, insecureSkipTLSVerify, plainHTTP bool) (*registry.Client, error) {
+func newRegistryClient(settings *cli.EnvSettings, certFile, keyFile, caFile string, insecureSkipTLSVerify, plainHTTP bool, username, password string) (*registry.Client, error) {
 
 	opts := []registry.ClientOption{
 		registry.ClientOptDebug(settings.Debug),
 		registry.ClientOptEnableCache(true),
 		registry.ClientOptWriter(os.Stderr),
 		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptBasicAuth(username, password),
 	}
 
 	if plainHTTP {
@@ -1209,7 +1173,7 @@ func newRegistryClient(settings *cli.EnvSettings, certFile, keyFile, caFile stri
 	return registryClient, nil
 }
 
-func ApplyCR(ctx context.Context, wandbCR *v2.WeightsAndBiases, overrides []CROverride) error {
+func ApplyCR(ctx context.Context, c *kubectl.Client, wandbCR *v2.WeightsAndBiases, overrides []CROverride) error {
 	gvk := wandbCR.GroupVersionKind()
 	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(wandbCR)
 	if err != nil {
@@ -1242,7 +1206,7 @@ func ApplyCR(ctx context.Context, wandbCR *v2.WeightsAndBiases, overrides []CROv
 		}
 	}
 
-	if err := kubectl.ApplyUnstructured(ctx, obj); err != nil {
+	if err := c.ApplyUnstructured(ctx, obj); err != nil {
 		return fmt.Errorf("failed to apply CR: %w", err)
 	}
 
@@ -1426,8 +1390,8 @@ type CRRef struct {
 }
 
 // DeleteCR deletes a WeightsAndBiases CR from the cluster
-func DeleteCR(ctx context.Context, name, namespace string) error {
-	_, dyn, err := kubectl.GetDynamicClientset()
+func DeleteCR(ctx context.Context, c *kubectl.Client, name, namespace string) error {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return err
 	}
@@ -1445,8 +1409,8 @@ func DeleteCR(ctx context.Context, name, namespace string) error {
 
 // ListCRs lists the names of WeightsAndBiases CRs in a namespace. Pass "" to
 // list across all namespaces; use ListAllCRs when you also need the namespace.
-func ListCRs(ctx context.Context, namespace string) ([]string, error) {
-	refs, err := listCRRefs(ctx, namespace)
+func ListCRs(ctx context.Context, c *kubectl.Client, namespace string) ([]string, error) {
+	refs, err := listCRRefs(ctx, c, namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -1460,12 +1424,12 @@ func ListCRs(ctx context.Context, namespace string) ([]string, error) {
 
 // ListAllCRs lists every WeightsAndBiases CR across all namespaces as
 // namespace+name pairs.
-func ListAllCRs(ctx context.Context) ([]CRRef, error) {
-	return listCRRefs(ctx, metav1.NamespaceAll)
+func ListAllCRs(ctx context.Context, c *kubectl.Client) ([]CRRef, error) {
+	return listCRRefs(ctx, c, metav1.NamespaceAll)
 }
 
-func listCRRefs(ctx context.Context, namespace string) ([]CRRef, error) {
-	_, dyn, err := kubectl.GetDynamicClientset()
+func listCRRefs(ctx context.Context, c *kubectl.Client, namespace string) ([]CRRef, error) {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return nil, err
 	}
@@ -1485,10 +1449,32 @@ func listCRRefs(ctx context.Context, namespace string) ([]CRRef, error) {
 	return refs, nil
 }
 
+// ListWandbVersions returns spec.wandb.version for every WeightsAndBiases CR
+// in the cluster. CRs without a version are omitted.
+func ListWandbVersions(ctx context.Context, c *kubectl.Client) (map[CRRef]string, error) {
+	dyn, err := c.Dynamic()
+	if err != nil {
+		return nil, err
+	}
+	list, err := dyn.Resource(weightsAndBiasesV2GVR).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WeightsAndBiases CRs: %w", err)
+	}
+
+	versions := map[CRRef]string{}
+	for _, item := range list.Items {
+		version, _, _ := unstructured.NestedString(item.Object, "spec", "wandb", "version")
+		if version != "" {
+			versions[CRRef{Namespace: item.GetNamespace(), Name: item.GetName()}] = version
+		}
+	}
+	return versions, nil
+}
+
 // ReconcileCR forces the operator to re-reconcile a CR by bumping the
 // reconcile-requested-at annotation to the current time.
-func ReconcileCR(ctx context.Context, name, namespace string) error {
-	_, dyn, err := kubectl.GetDynamicClientset()
+func ReconcileCR(ctx context.Context, c *kubectl.Client, name, namespace string) error {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return err
 	}
@@ -1517,8 +1503,8 @@ func ReconcileCR(ctx context.Context, name, namespace string) error {
 	return nil
 }
 
-func GetCR(ctx context.Context, name, namespace string) (*v2.WeightsAndBiases, error) {
-	_, dyn, err := kubectl.GetDynamicClientset()
+func GetCR(ctx context.Context, c *kubectl.Client, name, namespace string) (*v2.WeightsAndBiases, error) {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return nil, err
 	}
@@ -1538,8 +1524,45 @@ func GetCR(ctx context.Context, name, namespace string) (*v2.WeightsAndBiases, e
 	return cr, nil
 }
 
-func ConvertV1CRToV2(ctx context.Context, name, namespace string) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
-	restConfig, dynamicClient, err := kubectl.GetDynamicClientset()
+// CRStatus is the version and readiness of a WeightsAndBiases CR.
+type CRStatus struct {
+	Version string // spec.wandb.version
+	Ready   bool
+}
+
+// GetCRStatus reads a CR's version and readiness. It returns nil when the CR
+// does not exist.
+func GetCRStatus(ctx context.Context, c *kubectl.Client, name, namespace string) (*CRStatus, error) {
+	dyn, err := c.Dynamic()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := dyn.Resource(weightsAndBiasesV2GVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get WeightsAndBiases %s/%s: %w", namespace, name, err)
+	}
+
+	version, _, _ := unstructured.NestedString(obj.Object, "spec", "wandb", "version")
+	return &CRStatus{Version: version, Ready: crReady(obj)}, nil
+}
+
+// crReady reports whether the operator marked a CR ready.
+func crReady(obj *unstructured.Unstructured) bool {
+	ready, found, err := unstructured.NestedString(obj.Object, "status", "ready")
+	return err == nil && found && ready == "true"
+}
+
+func ConvertV1CRToV2(ctx context.Context, c *kubectl.Client, name, namespace string) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	client := c
+	restConfig, err := client.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+	dynamicClient, err := client.Dynamic()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1606,8 +1629,8 @@ func stripServerManagedMetadata(obj *unstructured.Unstructured) {
 }
 
 // WaitForCR waits for WeightsAndBiases CR to be ready
-func WaitForCR(ctx context.Context, name, namespace string, timeout time.Duration) error {
-	_, dyn, err := kubectl.GetDynamicClientset()
+func WaitForCR(ctx context.Context, c *kubectl.Client, name, namespace string, timeout time.Duration) error {
+	dyn, err := c.Dynamic()
 	if err != nil {
 		return err
 	}
@@ -1618,15 +1641,11 @@ func WaitForCR(ctx context.Context, name, namespace string, timeout time.Duratio
 			return false, nil
 		}
 
-		ready, found, err := unstructured.NestedString(cr.Object, "status", "ready")
-		if err == nil && found && ready == "true" {
-			return true, nil
-		}
-		return false, nil
+		return crReady(cr), nil
 	})
 }
 
 // WaitForCRReady waits for a WeightsAndBiases CR to reach ready state
-func WaitForCRReady(ctx context.Context, namespace, crName string, timeout time.Duration) error {
-	return WaitForCR(ctx, crName, namespace, timeout)
+func WaitForCRReady(ctx context.Context, c *kubectl.Client, namespace, crName string, timeout time.Duration) error {
+	return WaitForCR(ctx, c, crName, namespace, timeout)
 }
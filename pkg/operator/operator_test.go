@@ -0,0 +1,81 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wandb/wsm/pkg/kubectl"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func wandbCR(namespace, name, version string) *unstructured.Unstructured {
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("apps.wandb.com/v2")
+	cr.SetKind("WeightsAndBiases")
+	cr.SetNamespace(namespace)
+	cr.SetName(name)
+	if version != "" {
+		_ = unstructured.SetNestedField(cr.Object, version, "spec", "wandb", "version")
+	}
+	return cr
+}
+
+func TestListWandbVersions(t *testing.T) {
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{weightsAndBiasesV2GVR: "WeightsAndBiasesList"})
+	// The tracker can't guess the plural of WeightsAndBiases; seed it by resource.
+	for _, cr := range []*unstructured.Unstructured{
+		wandbCR("wandb", "wandb", "0.70.1"),
+		wandbCR("team-b", "wandb", "v0.71.0"),
+		wandbCR("team-c", "pending", ""),
+	} {
+		if err := dyn.Tracker().Create(weightsAndBiasesV2GVR, cr, cr.GetNamespace()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := kubectl.NewClientFromInterfaces(fake.NewClientset(), dyn, nil)
+
+	got, err := ListWandbVersions(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[CRRef]string{
+		{Namespace: "wandb", Name: "wandb"}:  "0.70.1",
+		{Namespace: "team-b", Name: "wandb"}: "v0.71.0",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ListWandbVersions() = %v, want %v", got, want)
+	}
+	for ref, version := range want {
+		if got[ref] != version {
+			t.Errorf("ListWandbVersions()[%v] = %q, want %q", ref, got[ref], version)
+		}
+	}
+}
+
+func TestCreateNamespace(t *testing.T) {
+	cs := fake.NewClientset()
+	c := kubectl.NewClientFromInterfaces(cs, nil, nil)
+	ctx := context.Background()
+
+	if err := CreateNamespace(ctx, c, "wandb"); err != nil {
+		t.Fatal(err)
+	}
+	ns, err := cs.CoreV1().Namespaces().Get(ctx, "wandb", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !kubectl.IsManaged(ns) {
+		t.Errorf("namespace labels = %v, want the %s label", ns.Labels, kubectl.ManagedByLabel)
+	}
+
+	// A second run finds it in place.
+	if err := CreateNamespace(ctx, c, "wandb"); err != nil {
+		t.Errorf("CreateNamespace() on an existing namespace = %v", err)
+	}
+}
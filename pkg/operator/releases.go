@@ -0,0 +1,208 @@
+package operator
+
+import "github.com/wandb/wsm/pkg/kind"
+
+// operatorChartRef is the upstream OCI chart DeployOperator installs.
+const operatorChartRef = "oci://us-docker.pkg.dev/wandb-production/public/wandb/charts/operator"
+
+// operatorReleaseName is the Helm release DeployOperator installs.
+const operatorReleaseName = "wandb-operator"
+
+// ReleaseSpec is one Helm release wsm installs: where the chart comes from
+// and the exact values wsm passes. The install functions are built on these,
+// and `wsm registry values` prints them for teams that install the same
+// charts through their own GitOps tooling.
+type ReleaseSpec struct {
+	Name      string
+	Namespace string
+	Chart     string // oci:// chart reference, retargeted to the mirror when one is set
+	Version   string
+	Values    map[string]interface{}
+}
+
+// chartRef retargets an upstream oci:// chart reference to the mirror.
+func chartRef(upstream string, mirror *MirrorConfig) string {
+	if mirror == nil {
+		return upstream
+	}
+	return "oci://" + mirror.Repository(upstream)
+}
+
+// CertManagerRelease returns the cert-manager release InstallCertManager
+// installs.
+func CertManagerRelease(enableGatewayAPI bool, mirror *MirrorConfig) ReleaseSpec {
+	values := map[string]interface{}{
+		"crds": map[string]interface{}{
+			"enabled": true,
+		},
+		"config": map[string]interface{}{
+			"enableGatewayAPI": enableGatewayAPI,
+		},
+		"startupapicheck": map[string]interface{}{
+			"enabled": false,
+		},
+	}
+
+	if mirror != nil {
+		// cert-manager v1.20 composes per-component image refs as
+		// <imageRegistry>/<imageNamespace>/cert-manager-<component>:<tag>
+		// unless <component>.image.repository is set, which it uses verbatim.
+		// Setting the full repository per component lets every mirror layout
+		// (not just preserve-path) resolve all 5 images to the mirror.
+		for _, comp := range []string{"controller", "webhook", "cainjector", "acmesolver", "startupapicheck"} {
+			repo := mirror.Repository("quay.io/jetstack/cert-manager-" + comp)
+			if comp == "controller" {
+				setNested(values, repo, "image", "repository")
+				continue
+			}
+			setNested(values, repo, comp, "image", "repository")
+		}
+	}
+
+	return ReleaseSpec{
+		Name:      certManagerReleaseName,
+		Namespace: certManagerNamespace,
+		Chart:     chartRef(certManagerChartRef, mirror),
+		Version:   CertManagerVersion,
+		Values:    values,
+	}
+}
+
+// NginxGatewayRelease returns the nginx-gateway-fabric release
+// InstallNginxGateway installs. nodePorts exposes the gateway on the fixed
+// NodePorts every `wsm cluster create` provider maps to the host.
+func NginxGatewayRelease(nodePorts bool, mirror *MirrorConfig) ReleaseSpec {
+	values := map[string]interface{}{}
+
+	if nodePorts {
+		values["nginx"] = map[string]interface{}{
+			"service": map[string]interface{}{
+				"type": "NodePort",
+				"nodePorts": []map[string]interface{}{
+					{"port": kind.HTTPNodePort, "listenerPort": 8080},
+					{"port": kind.HTTPSNodePort, "listenerPort": 8443},
+				},
+			},
+		}
+	}
+
+	if mirror != nil {
+		// nginx-gateway-fabric has no global imageRegistry — each component
+		// repository is set independently. Merge with any existing nginx.*
+		// values (the NodePort block above).
+		setNested(values, mirror.Repository("ghcr.io/nginx/nginx-gateway-fabric"), "nginxGateway", "image", "repository")
+		setNested(values, mirror.Repository("ghcr.io/nginx/nginx-gateway-fabric/nginx"), "nginx", "image", "repository")
+	}
+
+	return ReleaseSpec{
+		Name:      nginxGatewayReleaseName,
+		Namespace: nginxGatewayNamespace,
+		Chart:     chartRef(nginxGatewayChartRef, mirror),
+		Version:   NginxGatewayVersion,
+		Values:    values,
+	}
+}
+
+// OperatorRelease returns the wandb-operator release DeployOperator installs.
+func OperatorRelease(namespace, chartVersion string, mirror *MirrorConfig, telemetry TelemetryConfig, wandbNamespace string, openshift bool) ReleaseSpec {
+	operatorImage := map[string]interface{}{
+		"pullPolicy": "Always",
+	}
+	if mirror != nil {
+		operatorImage["repository"] = mirror.Repository("us-docker.pkg.dev/wandb-production/public/wandb/operator")
+	}
+
+	telemetryValues := buildTelemetryValues(telemetry)
+	values := map[string]interface{}{
+		"wandb": map[string]interface{}{
+			"install": false,
+		},
+		"wandb-operator": map[string]interface{}{
+			"image": operatorImage,
+		},
+		"telemetry": telemetryValues,
+	}
+
+	// Point the bundled managed-service operator subcharts at the mirror. Each
+	// third-party subchart exposes images differently (no single global knob), so
+	// we set each chart's own registry/repository key — all resolving through
+	// mirror.Repository, matching where `wsm registry mirror` pushes the images
+	// StackImages finds in the chart. Helm deep-merges these over the
+	// chart's values.yaml, so image tags and unrelated keys are preserved.
+	// Kafka (Bufstream) has no subchart operator here and no Helm image knob; the
+	// operator emits its data-plane images with upstream refs, so they reach the
+	// mirror via each node's container-runtime registry mirror — not a Helm value
+	// and not spec.global.imageRegistry (which --mirror-registry does not set).
+	if mirror != nil {
+		// moco injects three sidecar images into every MySQLCluster via the
+		// controller's --agent-image / --fluent-bit-image / mysqld_exporter args
+		// (driven by these chart values), so all four must be retargeted — not
+		// just the controller image. Verified by `helm template`.
+		values["moco"] = map[string]interface{}{
+			"image":          map[string]interface{}{"repository": mirror.Repository("ghcr.io/cybozu-go/moco")},
+			"agent":          map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Repository("ghcr.io/cybozu-go/moco-agent")}},
+			"fluentbit":      map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Repository("ghcr.io/cybozu-go/moco/fluent-bit")}},
+			"mysqldExporter": map[string]interface{}{"image": map[string]interface{}{"repository": mirror.Repository("ghcr.io/cybozu-go/moco/mysqld_exporter")}},
+		}
+		values["redis-operator"] = map[string]interface{}{
+			"redisOperator": map[string]interface{}{"imageName": mirror.Repository("quay.io/opstree/redis-operator")},
+		}
+		values["seaweedfs-operator"] = map[string]interface{}{
+			"image": map[string]interface{}{
+				"registry":   mirror.Host,
+				"repository": mirror.Path("chrislusf/seaweedfs-operator"),
+			},
+		}
+		values["altinity-clickhouse-operator"] = map[string]interface{}{
+			"operator": map[string]interface{}{
+				"image": map[string]interface{}{"registry": mirror.Host, "repository": mirror.Path("altinity/clickhouse-operator")},
+			},
+			"metrics": map[string]interface{}{
+				"image": map[string]interface{}{"registry": mirror.Host, "repository": mirror.Path("altinity/metrics-exporter")},
+			},
+			"crdHook": map[string]interface{}{
+				"image": map[string]interface{}{"repository": mirror.Repository("alpine/k8s")},
+			},
+		}
+	}
+
+	// The operator chart's telemetry-validation requires the caller to opt the
+	// victoria-metrics-operator and grafana-operator dependencies in when
+	// telemetry is enabled — their chart defaults are false (helm dependency
+	// conditions are boolean-only). "full" runs the in-cluster Victoria stack
+	// plus local Grafana; "forward" runs the Victoria stack and forwards OTLP
+	// data to telemetry.forwarding.otlp.endpoint.
+	if telemetryNeedsNamespace(telemetry) {
+		// The telemetry subchart deploys into the telemetry namespace (the W&B
+		// namespace), not the operator's release namespace; pin
+		// telemetry.namespace to match the CR's namespace.
+		telemetryValues["namespace"] = wandbNamespace
+	}
+	// Enable the telemetry subchart dependencies (chart defaults are false). The
+	// forwarding.otlp.* values for "forward" are already set by buildTelemetryValues.
+	switch telemetry.Mode {
+	case TelemetryModeFull:
+		values["victoria-metrics-operator"] = map[string]interface{}{"enabled": true}
+		values["grafana-operator"] = map[string]interface{}{"enabled": true}
+	case TelemetryModeForward:
+		values["victoria-metrics-operator"] = map[string]interface{}{"enabled": true}
+	}
+
+	if openshift {
+		applyOpenShiftValues(values)
+	}
+
+	return ReleaseSpec{
+		Name:      operatorReleaseName,
+		Namespace: namespace,
+		Chart:     chartRef(operatorChartRef, mirror),
+		Version:   chartVersion,
+		Values:    values,
+	}
+}
+
+// telemetryNeedsNamespace reports whether the telemetry subchart is enabled
+// and so deploys into the W&B namespace, which must exist beforehand.
+func telemetryNeedsNamespace(telemetry TelemetryConfig) bool {
+	return telemetry.Mode == TelemetryModeFull || telemetry.Mode == TelemetryModeForward
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/wandb/wsm/pkg/kubectl"
 	"helm.sh/helm/v4/pkg/action"
-	"helm.sh/helm/v4/pkg/cli"
 	v1 "helm.sh/helm/v4/pkg/release/v1"
 )
 
@@ -117,12 +116,10 @@ func parseTelemetryValues(values map[string]interface{}) TelemetryConfig {
 // GetOperatorTelemetryConfig reads back the telemetry configuration wsm installed into the operator's
 // Helm release, returning wsm's domain type. It round-trips against DeployOperator: what you install
 // is what you read back. Callers get the mode without embedding a Helm client or knowing the values layout.
-func GetOperatorTelemetryConfig(ctx context.Context, namespace string) (TelemetryConfig, error) {
+func GetOperatorTelemetryConfig(ctx context.Context, c *kubectl.Client, namespace string) (TelemetryConfig, error) {
 	const releaseName = "wandb-operator"
 
-	settings := cli.New()
-	settings.SetNamespace(namespace)
-	settings.KubeContext = kubectl.GetContext()
+	settings := helmSettings(c, namespace)
 
 	actionConfig, err := initActionConfig(settings)
 	if err != nil {
@@ -0,0 +1,207 @@
+// Package registryauth resolves credentials for container registries. Every
+// registry client wsm uses (containers/image copies and checks,
+// go-containerregistry pushes, oras artifact pulls/pushes and Helm OCI chart
+// pulls) asks the same Provider, so explicit flags, environment variables and
+// the Docker config (including credential helpers) behave identically
+// everywhere.
+package registryauth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+)
+
+// Credential is a username/password pair, or an identity (refresh) token as
+// returned by some credential helpers.
+type Credential struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// Empty reports whether c carries no credentials (anonymous access).
+func (c Credential) Empty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// Provider returns the credential to use for a registry host (hostname[:port]).
+// A provider with nothing for host returns an empty Credential and no error, so
+// providers can be chained.
+type Provider interface {
+	Credential(ctx context.Context, host string) (Credential, error)
+}
+
+// Static serves one username/password pair for one registry host.
+type Static struct {
+	Host     string
+	Username string
+	Password string
+}
+
+func (s Static) Credential(_ context.Context, host string) (Credential, error) {
+	if normalizeHost(host) != normalizeHost(s.Host) {
+		return Credential{}, nil
+	}
+	return Credential{Username: s.Username, Password: s.Password}, nil
+}
+
+// FromEnv reads <prefix>USERNAME and <prefix>PASSWORD for host. When
+// <prefix>HOST is set it overrides host, which lets CI name the registry the
+// variables belong to. It returns nil when the variables are unset.
+func FromEnv(prefix, host string) Provider {
+	username, password := os.Getenv(prefix+"USERNAME"), os.Getenv(prefix+"PASSWORD")
+	if username == "" && password == "" {
+		return nil
+	}
+	if h := os.Getenv(prefix + "HOST"); h != "" {
+		host = h
+	}
+	if host == "" {
+		return nil
+	}
+	return Static{Host: host, Username: username, Password: password}
+}
+
+// DockerConfig reads ~/.docker/config.json (or $DOCKER_CONFIG), including
+// credsStore/credHelpers credential helpers. A missing config means anonymous.
+type DockerConfig struct {
+	once  sync.Once
+	store credentials.Store
+	err   error
+}
+
+func (d *DockerConfig) Credential(ctx context.Context, host string) (Credential, error) {
+	d.once.Do(func() {
+		d.store, d.err = credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	})
+	if d.err != nil {
+		return Credential{}, nil
+	}
+	// The Docker config keys Docker Hub as https://index.docker.io/v1/, which
+	// oras only derives from the registry-1.docker.io API host.
+	if normalizeHost(host) == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	cred, err := credentials.Credential(d.store)(ctx, host)
+	if err != nil {
+		return Credential{}, fmt.Errorf("read Docker credentials for %s: %w", host, err)
+	}
+	return Credential{Username: cred.Username, Password: cred.Password, IdentityToken: cred.RefreshToken}, nil
+}
+
+// Chain asks each provider in order and returns the first non-empty
+// credential. nil entries are skipped.
+type Chain []Provider
+
+func (c Chain) Credential(ctx context.Context, host string) (Credential, error) {
+	for _, p := range c {
+		if p == nil {
+			continue
+		}
+		cred, err := p.Credential(ctx, host)
+		if err != nil {
+			return Credential{}, err
+		}
+		if !cred.Empty() {
+			return cred, nil
+		}
+	}
+	return Credential{}, nil
+}
+
+// Default is the provider used when the caller supplies no explicit
+// credentials: the Docker config only, matching `docker pull`/`docker push`.
+func Default() Provider {
+	return &DockerConfig{}
+}
+
+// CredentialFunc adapts p for oras-go clients.
+func CredentialFunc(p Provider) auth.CredentialFunc {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		cred, err := p.Credential(ctx, hostport)
+		if err != nil {
+			return auth.EmptyCredential, err
+		}
+		return auth.Credential{Username: cred.Username, Password: cred.Password, RefreshToken: cred.IdentityToken}, nil
+	}
+}
+
+// Keychain adapts p for go-containerregistry clients.
+func Keychain(p Provider) authn.Keychain {
+	return keychain{p}
+}
+
+type keychain struct{ p Provider }
+
+func (k keychain) Resolve(r authn.Resource) (authn.Authenticator, error) {
+	return k.ResolveContext(context.Background(), r)
+}
+
+func (k keychain) ResolveContext(ctx context.Context, r authn.Resource) (authn.Authenticator, error) {
+	cred, err := k.p.Credential(ctx, r.RegistryStr())
+	if err != nil {
+		return nil, err
+	}
+	if cred.Empty() {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cred.Username,
+		Password:      cred.Password,
+		IdentityToken: cred.IdentityToken,
+	}), nil
+}
+
+// SystemContext returns a copy of base whose DockerAuthConfig carries p's
+// credential for host, for one containers/image operation against that host.
+// With no credential the copy is left alone, so containers/image falls back to
+// its own auth.json / Docker config lookup.
+func SystemContext(ctx context.Context, base *types.SystemContext, p Provider, host string) (*types.SystemContext, error) {
+	sys := &types.SystemContext{}
+	if base != nil {
+		copied := *base
+		sys = &copied
+	}
+	cred, err := p.Credential(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if !cred.Empty() {
+		sys.DockerAuthConfig = &types.DockerAuthConfig{
+			Username:      cred.Username,
+			Password:      cred.Password,
+			IdentityToken: cred.IdentityToken,
+		}
+	}
+	return sys, nil
+}
+
+// Host returns the registry host of an image or repository reference, with
+// Docker Hub short names resolved to docker.io.
+func Host(ref string) string {
+	ref = strings.TrimPrefix(ref, "oci://")
+	if host, _, ok := strings.Cut(ref, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		return host
+	}
+	return "docker.io"
+}
+
+// normalizeHost folds Docker Hub's aliases together, since each client library
+// spells it differently.
+func normalizeHost(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.TrimRight(host, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return host
+}
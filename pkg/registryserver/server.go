@@ -0,0 +1,134 @@
+// Package registryserver is a small OCI distribution registry that keeps its
+// content on local disk. It backs `wsm registry serve`, so the air-gapped flow
+// (mirror, check, cluster create, deploy-v2 --mirror-registry) can be exercised
+// on one machine without running a registry:2 container.
+//
+// Request handling comes from go-containerregistry's pkg/registry. Blobs are
+// stored by its disk blob handler; manifests, which it only keeps in memory,
+// are journaled to disk and replayed on start.
+package registryserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+)
+
+// Options configures a Server.
+type Options struct {
+	// Storage is the directory blobs, manifests and generated TLS material
+	// live in. It is created if missing.
+	Storage string
+	// Username and Password, when both are set, require HTTP basic auth on
+	// every request.
+	Username string
+	Password string
+	// Logger receives one line per request. nil discards request logs.
+	Logger *log.Logger
+}
+
+// Server is an OCI registry backed by a storage directory.
+type Server struct {
+	opts    Options
+	handler http.Handler
+}
+
+// New opens (or initialises) the storage directory and replays the manifests
+// stored there.
+func New(opts Options) (*Server, error) {
+	if opts.Storage == "" {
+		return nil, errors.New("storage directory is required")
+	}
+	if (opts.Username == "") != (opts.Password == "") {
+		return nil, errors.New("basic auth needs both a username and a password")
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	blobDir := filepath.Join(opts.Storage, "blobs")
+	if err := os.MkdirAll(blobDir, 0755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", blobDir, err)
+	}
+	inner := ggcrregistry.New(
+		ggcrregistry.WithBlobHandler(ggcrregistry.NewDiskBlobHandler(blobDir)),
+		ggcrregistry.Logger(logger),
+	)
+
+	store := &manifestStore{dir: filepath.Join(opts.Storage, "repositories"), inner: inner}
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+
+	var handler http.Handler = store
+	if opts.Username != "" {
+		handler = basicAuth(handler, opts.Username, opts.Password)
+	}
+	return &Server{opts: opts, handler: handler}, nil
+}
+
+// Handler returns the registry's HTTP handler, to be mounted at the site root.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Serve listens on addr until ctx is cancelled. A non-nil tlsConfig serves
+// HTTPS; otherwise the registry speaks plain HTTP.
+func (s *Server) Serve(ctx context.Context, addr string, tlsConfig *tls.Config) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	srv := &http.Server{
+		Handler:           s.handler,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// basicAuth rejects requests that don't carry the configured credentials.
+// The challenge on /v2/ is what makes docker, containerd, oras and
+// containers/image send them.
+func basicAuth(next http.Handler, username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="wsm registry"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,357 @@
+package registryserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// On-disk layout under <storage>/repositories, one directory per repository:
+//
+//	<repo>/_manifests/<algorithm>/<hex>  stored manifest (JSON: media type + bytes)
+//	<repo>/_tags/<tag>                   digest the tag points at
+//
+// Repository path components can't start with '_', so the two marker
+// directories never collide with a nested repository.
+const (
+	manifestsDir = "_manifests"
+	tagsDir      = "_tags"
+)
+
+var (
+	repoComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+)
+
+// storedManifest is the on-disk form of one manifest.
+type storedManifest struct {
+	MediaType string `json:"mediaType"`
+	Content   []byte `json:"content"`
+}
+
+// manifestStore journals manifest writes and deletes to disk before handing
+// the request to the in-memory registry, and replays the journal on start.
+// Deleting a manifest by digest also deletes the tags pointing at it, as
+// registry:2 does (the in-memory registry leaves them dangling).
+type manifestStore struct {
+	dir   string
+	inner http.Handler
+	mu    sync.Mutex
+}
+
+func (s *manifestStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repo, ref, ok := manifestTarget(r.URL.Path)
+	if !ok {
+		s.inner.ServeHTTP(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, repo, ref)
+	case http.MethodDelete:
+		s.delete(w, r, repo, ref)
+	default:
+		s.inner.ServeHTTP(w, r)
+	}
+}
+
+func (s *manifestStore) put(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	if !validRepo(repo) || !(isDigest(ref) || tagPattern.MatchString(ref)) {
+		writeError(w, http.StatusBadRequest, "NAME_INVALID", fmt.Sprintf("invalid repository or reference %s:%s", repo, ref))
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := &statusRecorder{ResponseWriter: w}
+	s.inner.ServeHTTP(rec, r)
+	if rec.status != http.StatusCreated {
+		return
+	}
+	digest, _, err := v1.SHA256(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	if err := s.writeManifest(repo, digest, storedManifest{MediaType: r.Header.Get("Content-Type"), Content: body}); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠ persist manifest %s@%s: %v\n", repo, digest, err)
+		return
+	}
+	if !isDigest(ref) {
+		if err := writeFileAtomic(s.tagPath(repo, ref), []byte(digest.String())); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠ persist tag %s:%s: %v\n", repo, ref, err)
+		}
+	}
+}
+
+func (s *manifestStore) delete(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	if !validRepo(repo) {
+		s.inner.ServeHTTP(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tags []string
+	if isDigest(ref) {
+		tags = s.tagsFor(repo, ref)
+	}
+	rec := &statusRecorder{ResponseWriter: w}
+	s.inner.ServeHTTP(rec, r)
+	if rec.status != http.StatusAccepted {
+		return
+	}
+
+	if !isDigest(ref) {
+		_ = os.Remove(s.tagPath(repo, ref))
+		return
+	}
+	if h, err := v1.NewHash(ref); err == nil {
+		_ = os.Remove(s.manifestPath(repo, h))
+	}
+	for _, tag := range tags {
+		req := httptest.NewRequest(http.MethodDelete, "/v2/"+repo+"/manifests/"+tag, nil)
+		s.inner.ServeHTTP(httptest.NewRecorder(), req)
+		_ = os.Remove(s.tagPath(repo, tag))
+	}
+}
+
+// replay loads every stored manifest and tag back into the in-memory
+// registry. Image manifests go first so index manifests find their children.
+func (s *manifestStore) replay() error {
+	repos, err := s.repositories()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		manifests, err := s.readManifests(repo)
+		if err != nil {
+			return err
+		}
+		pending := manifests
+		for len(pending) > 0 {
+			var retry []storedManifest
+			for _, m := range pending {
+				digest, _, _ := v1.SHA256(bytes.NewReader(m.Content))
+				if s.replayPut(repo, digest.String(), m) != http.StatusCreated {
+					retry = append(retry, m)
+				}
+			}
+			if len(retry) == len(pending) {
+				return fmt.Errorf("replay %s: %d manifest(s) reference content that is missing from %s", repo, len(retry), s.dir)
+			}
+			pending = retry
+		}
+
+		entries, err := os.ReadDir(filepath.Join(s.repoDir(repo), tagsDir))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, e := range entries {
+			data, err := os.ReadFile(filepath.Join(s.repoDir(repo), tagsDir, e.Name()))
+			if err != nil {
+				return err
+			}
+			h, err := v1.NewHash(strings.TrimSpace(string(data)))
+			if err != nil {
+				return fmt.Errorf("replay tag %s:%s: %w", repo, e.Name(), err)
+			}
+			m, err := readManifest(s.manifestPath(repo, h))
+			if err != nil {
+				return fmt.Errorf("replay tag %s:%s: %w", repo, e.Name(), err)
+			}
+			if status := s.replayPut(repo, e.Name(), m); status != http.StatusCreated {
+				return fmt.Errorf("replay tag %s:%s: registry returned %d", repo, e.Name(), status)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *manifestStore) replayPut(repo, ref string, m storedManifest) int {
+	req := httptest.NewRequest(http.MethodPut, "/v2/"+repo+"/manifests/"+ref, bytes.NewReader(m.Content))
+	req.Header.Set("Content-Type", m.MediaType)
+	rec := httptest.NewRecorder()
+	s.inner.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// repositories returns every repository with stored manifests.
+func (s *manifestStore) repositories() ([]string, error) {
+	var repos []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || d.Name() != manifestsDir {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		repos = append(repos, filepath.ToSlash(rel))
+		return filepath.SkipDir
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return repos, err
+}
+
+// readManifests returns repo's stored manifests, image manifests before
+// indexes.
+func (s *manifestStore) readManifests(repo string) ([]storedManifest, error) {
+	var out []storedManifest
+	root := filepath.Join(s.repoDir(repo), manifestsDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		m, err := readManifest(path)
+		if err != nil {
+			return err
+		}
+		out = append(out, m)
+		return nil
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		return !types.MediaType(out[i].MediaType).IsIndex() && types.MediaType(out[j].MediaType).IsIndex()
+	})
+	return out, err
+}
+
+// tagsFor returns the stored tags of repo that point at digest.
+func (s *manifestStore) tagsFor(repo, digest string) []string {
+	entries, err := os.ReadDir(filepath.Join(s.repoDir(repo), tagsDir))
+	if err != nil {
+		return nil
+	}
+	var tags []string
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(s.repoDir(repo), tagsDir, e.Name()))
+		if err == nil && strings.TrimSpace(string(data)) == digest {
+			tags = append(tags, e.Name())
+		}
+	}
+	return tags
+}
+
+func (s *manifestStore) writeManifest(repo string, digest v1.Hash, m storedManifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.manifestPath(repo, digest), data)
+}
+
+func (s *manifestStore) repoDir(repo string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(repo))
+}
+
+func (s *manifestStore) manifestPath(repo string, digest v1.Hash) string {
+	return filepath.Join(s.repoDir(repo), manifestsDir, digest.Algorithm, digest.Hex)
+}
+
+func (s *manifestStore) tagPath(repo, tag string) string {
+	return filepath.Join(s.repoDir(repo), tagsDir, tag)
+}
+
+func readManifest(path string) (storedManifest, error) {
+	var m storedManifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// writeFileAtomic writes data to path via a temp file in the same directory,
+// so a crash never leaves a half-written manifest behind.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// manifestTarget splits /v2/<repo>/manifests/<ref>.
+func manifestTarget(path string) (repo, ref string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "/v2/")
+	if !ok {
+		return "", "", false
+	}
+	elems := strings.Split(rest, "/")
+	if len(elems) < 3 || elems[len(elems)-2] != "manifests" {
+		return "", "", false
+	}
+	return strings.Join(elems[:len(elems)-2], "/"), elems[len(elems)-1], true
+}
+
+func validRepo(repo string) bool {
+	for _, c := range strings.Split(repo, "/") {
+		if !repoComponent.MatchString(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigest(ref string) bool {
+	_, err := v1.NewHash(ref)
+	return err == nil
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,190 @@
+package registryserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CA file names under the TLS directory. The CA is created once and kept, so
+// clusters and Docker daemons that trust it keep working across restarts; the
+// serving certificate is re-issued on every start for the current host list.
+const (
+	CACertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+)
+
+// GeneratedTLS loads the CA in dir (creating it on first use) and issues a
+// serving certificate for hosts, which may be DNS names or IP addresses. It
+// returns the TLS config and the path of the CA certificate clients must
+// trust.
+func GeneratedTLS(dir string, hosts []string) (*tls.Config, string, error) {
+	der, key, caCert, err := issueCertificate(dir, hosts)
+	if err != nil {
+		return nil, "", err
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{der, caCert.Raw},
+		PrivateKey:  key,
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, filepath.Join(dir, CACertFile), nil
+}
+
+// Serving certificate file names GeneratedCertFiles writes under the TLS
+// directory.
+const (
+	ServerCertFile = "server.crt"
+	ServerKeyFile  = "server.key"
+)
+
+// GeneratedCertFiles is GeneratedTLS for registries that read their
+// certificate from disk, such as a registry:2 container: it writes the serving
+// certificate chain and key to dir. They are only issued when missing, so a
+// running registry keeps serving the files on disk. It returns the paths of
+// the certificate, the key and the CA certificate.
+func GeneratedCertFiles(dir string, hosts []string) (certFile, keyFile, caFile string, err error) {
+	certFile, keyFile, caFile = filepath.Join(dir, ServerCertFile), filepath.Join(dir, ServerKeyFile), filepath.Join(dir, CACertFile)
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	_, caErr := os.Stat(caFile)
+	if certErr == nil && keyErr == nil && caErr == nil {
+		return certFile, keyFile, caFile, nil
+	}
+
+	der, key, caCert, err := issueCertificate(dir, hosts)
+	if err != nil {
+		return "", "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", "", err
+	}
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})...)
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", "", err
+	}
+	if err := os.WriteFile(certFile, chain, 0644); err != nil {
+		return "", "", "", err
+	}
+	return certFile, keyFile, caFile, nil
+}
+
+// issueCertificate loads the CA in dir, creating it on first use, and issues
+// a serving certificate for hosts.
+func issueCertificate(dir string, hosts []string) ([]byte, *ecdsa.PrivateKey, *x509.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, nil, nil, errors.New("at least one TLS host is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("issue serving certificate: %w", err)
+	}
+	return der, key, caCert, nil
+}
+
+// FileTLS loads a serving certificate and key from PEM files.
+func FileTLS(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath, keyPath := filepath.Join(dir, CACertFile), filepath.Join(dir, caKeyFile)
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		certBlock, _ := pem.Decode(certPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+		if certBlock == nil || keyBlock == nil {
+			return nil, nil, fmt.Errorf("%s or %s is not PEM", certPath, keyPath)
+		}
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", certPath, err)
+		}
+		key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", keyPath, err)
+		}
+		return cert, key, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "wsm registry CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create CA: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func randomSerial() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	return n
+}
@@ -0,0 +1,319 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Output formats.
+const (
+	FormatCycloneDX = "cyclonedx"
+	FormatSPDX      = "spdx"
+)
+
+// Formats lists the formats Write accepts.
+var Formats = []string{FormatCycloneDX, FormatSPDX}
+
+// Document is an SBOM covering one or more images.
+type Document struct {
+	Name        string // what the document describes, e.g. an image reference or "wandb 0.82.2"
+	ToolVersion string // wsm version recorded as the generating tool
+	Created     time.Time
+	Images      []*Inventory
+}
+
+// Write encodes doc as CycloneDX 1.5 or SPDX 2.3 JSON. Each image becomes a
+// container component (SPDX package) that contains its cataloged packages.
+func Write(w io.Writer, format string, doc Document) error {
+	if doc.Created.IsZero() {
+		doc.Created = time.Now()
+	}
+	var v interface{}
+	switch format {
+	case FormatCycloneDX:
+		v = cycloneDX(doc)
+	case FormatSPDX:
+		v = spdx(doc)
+	default:
+		return fmt.Errorf("unknown SBOM format %q (want one of: %s)", format, strings.Join(Formats, ", "))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false) // purl qualifiers are joined with '&'
+	return enc.Encode(v)
+}
+
+// FileExtension returns the conventional file suffix for format.
+func FileExtension(format string) string {
+	if format == FormatSPDX {
+		return ".spdx.json"
+	}
+	return ".cdx.json"
+}
+
+// ---------------- CycloneDX ----------------
+
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     cdxTools      `json:"tools"`
+	Component *cdxComponent `json:"component,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef     string         `json:"bom-ref,omitempty"`
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	PURL       string         `json:"purl,omitempty"`
+	Hashes     []cdxHash      `json:"hashes,omitempty"`
+	Licenses   []cdxLicense   `json:"licenses,omitempty"`
+	Properties []cdxProperty  `json:"properties,omitempty"`
+	Components []cdxComponent `json:"components,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License *cdxLicenseChoice `json:"license,omitempty"`
+}
+
+type cdxLicenseChoice struct {
+	Name string `json:"name"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func cycloneDX(doc Document) cdxBOM {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: doc.Created.UTC().Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{{
+				Type: "application", Name: "wsm", Version: doc.ToolVersion,
+			}}},
+		},
+		Components: []cdxComponent{},
+	}
+	if doc.Name != "" {
+		bom.Metadata.Component = &cdxComponent{Type: "application", Name: doc.Name}
+	}
+	for _, inv := range doc.Images {
+		image := cdxComponent{
+			BOMRef: inv.ref(),
+			Type:   "container",
+			Name:   inv.Image,
+		}
+		if alg, hex, ok := strings.Cut(inv.Digest, ":"); ok && alg == "sha256" {
+			image.Hashes = []cdxHash{{Alg: "SHA-256", Content: hex}}
+			image.Version = inv.Digest
+		}
+		if inv.Artifact != "" {
+			image.Type = "file"
+			image.Properties = append(image.Properties, cdxProperty{Name: "wsm:mediaType", Value: inv.Artifact})
+		}
+		if inv.Distro.ID != "" {
+			image.Properties = append(image.Properties, cdxProperty{Name: "wsm:distro", Value: inv.Distro.ID + "-" + inv.Distro.Version})
+		}
+		if inv.Origin != "" {
+			image.Properties = append(image.Properties, cdxProperty{Name: "wsm:sbomSource", Value: inv.Origin})
+		}
+		for _, p := range inv.Packages {
+			c := cdxComponent{
+				BOMRef:  inv.ref() + "#" + p.PURL(inv.Distro),
+				Type:    "library",
+				Name:    p.Name,
+				Version: p.Version,
+				PURL:    p.PURL(inv.Distro),
+			}
+			for _, l := range p.Licenses {
+				c.Licenses = append(c.Licenses, cdxLicense{License: &cdxLicenseChoice{Name: l}})
+			}
+			if p.Location != "" {
+				c.Properties = append(c.Properties, cdxProperty{Name: "wsm:location", Value: p.Location})
+			}
+			image.Components = append(image.Components, c)
+		}
+		bom.Components = append(bom.Components, image)
+	}
+	return bom
+}
+
+// ---------------- SPDX ----------------
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+func spdx(doc Document) spdxDocument {
+	name := doc.Name
+	if name == "" && len(doc.Images) == 1 {
+		name = doc.Images[0].Image
+	}
+	out := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: "https://wandb.ai/spdxdocs/wsm/" + newUUID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  doc.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: wsm-" + doc.ToolVersion},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	n := 0
+	nextID := func() string {
+		n++
+		return fmt.Sprintf("SPDXRef-Package-%d", n)
+	}
+	for _, inv := range doc.Images {
+		imageID := nextID()
+		image := spdxPackage{
+			SPDXID:           imageID,
+			Name:             inv.Image,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			PrimaryPurpose:   "CONTAINER",
+		}
+		if inv.Artifact != "" {
+			image.PrimaryPurpose = "FILE"
+		}
+		if alg, hex, ok := strings.Cut(inv.Digest, ":"); ok && alg == "sha256" {
+			image.VersionInfo = inv.Digest
+			image.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: hex}}
+		}
+		out.Packages = append(out.Packages, image)
+		out.Relationships = append(out.Relationships, spdxRelationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: imageID})
+
+		for _, p := range inv.Packages {
+			id := nextID()
+			pkg := spdxPackage{
+				SPDXID:           id,
+				Name:             p.Name,
+				VersionInfo:      p.Version,
+				DownloadLocation: "NOASSERTION",
+				LicenseConcluded: "NOASSERTION",
+				LicenseDeclared:  "NOASSERTION",
+				ExternalRefs: []spdxExternalRef{{
+					Category: "PACKAGE-MANAGER", Type: "purl", Locator: p.PURL(inv.Distro),
+				}},
+			}
+			// Only single identifiers are passed through: free-text license
+			// names aren't valid SPDX expressions.
+			if len(p.Licenses) == 1 && spdxIdentifier(p.Licenses[0]) {
+				pkg.LicenseDeclared = p.Licenses[0]
+			}
+			if p.Location != "" {
+				pkg.SourceInfo = "found at " + p.Location
+			}
+			out.Packages = append(out.Packages, pkg)
+			out.Relationships = append(out.Relationships, spdxRelationship{Element: imageID, Type: "CONTAINS", Related: id})
+		}
+	}
+	return out
+}
+
+// spdxIdentifier reports whether s looks like a single SPDX license ID.
+func spdxIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '+') {
+			return false
+		}
+	}
+	return true
+}
+
+// ref is the identifier an image is referenced by inside a document.
+func (inv *Inventory) ref() string {
+	if inv.Digest == "" {
+		return inv.Image
+	}
+	name := inv.Image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	return name + "@" + inv.Digest
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,69 @@
+package sbom
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// inMemoryBinarySize is the largest executable read into memory; bigger ones
+// are spilled to a temp file, since reading build info needs random access.
+const inMemoryBinarySize = 64 << 20
+
+// goModules returns the main module, its dependencies and the Go toolchain
+// recorded in a Go executable's build info. Executables that aren't Go, or
+// were built without module info, yield nothing.
+func goModules(r io.Reader, size int64, location string) ([]Package, error) {
+	var ra io.ReaderAt
+	if size <= inMemoryBinarySize {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		ra = bytes.NewReader(data)
+	} else {
+		f, err := os.CreateTemp("", "wsm-sbom-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			return nil, err
+		}
+		ra = f
+	}
+
+	info, err := buildinfo.Read(ra)
+	if err != nil {
+		return nil, nil
+	}
+	var pkgs []Package
+	add := func(m *debug.Module) {
+		if m == nil || m.Path == "" {
+			return
+		}
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		// Local replacements and "(devel)" main modules carry no version
+		// anything can be matched against.
+		if m.Version == "" || m.Version == "(devel)" {
+			return
+		}
+		pkgs = append(pkgs, Package{Type: TypeGolang, Name: m.Path, Version: m.Version, Location: location})
+	}
+	add(&info.Main)
+	for _, dep := range info.Deps {
+		add(dep)
+	}
+	if v, ok := strings.CutPrefix(info.GoVersion, "go"); ok {
+		// Strip experiment suffixes such as "go1.22.1 X:boringcrypto".
+		v, _, _ = strings.Cut(v, " ")
+		pkgs = append(pkgs, Package{Type: TypeGolang, Name: "stdlib", Version: "v" + v, Location: location})
+	}
+	return pkgs, nil
+}
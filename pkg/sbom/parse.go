@@ -0,0 +1,227 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/textproto"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// parseOSRelease reads ID and VERSION_ID from an os-release file.
+func parseOSRelease(data []byte) Distro {
+	var d Distro
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			d.ID = value
+		case "VERSION_ID":
+			d.Version = value
+		}
+	}
+	return d
+}
+
+// parseDpkgStatus reads the installed packages of a dpkg status file (or a
+// distroless status.d entry, which uses the same format).
+func parseDpkgStatus(data []byte, location string) []Package {
+	var pkgs []Package
+	for _, stanza := range bytes.Split(data, []byte("\n\n")) {
+		fields := controlFields(stanza)
+		name, version := fields["Package"], fields["Version"]
+		if name == "" || version == "" {
+			continue
+		}
+		// Entries dpkg keeps for removed packages whose config files remain.
+		if status := fields["Status"]; status != "" && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		source := name
+		if s := fields["Source"]; s != "" {
+			// "Source: openssl (3.0.11-1)" names a source version that differs
+			// from the binary's; advisories are matched on the binary version.
+			source, _, _ = strings.Cut(s, " ")
+		}
+		pkgs = append(pkgs, Package{
+			Type:     TypeDeb,
+			Name:     name,
+			Version:  version,
+			Source:   source,
+			Location: location,
+		})
+	}
+	return pkgs
+}
+
+// controlFields parses one Debian control stanza. Continuation lines are
+// dropped; none of the fields read here span lines.
+func controlFields(stanza []byte) map[string]string {
+	fields := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(stanza))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// parseDebianCopyright returns the distinct License: values of a
+// machine-readable debian/copyright file.
+func parseDebianCopyright(data []byte) []string {
+	var licenses []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		value, ok := strings.CutPrefix(sc.Text(), "License:")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			licenses = append(licenses, value)
+		}
+	}
+	return licenses
+}
+
+// parseAPKInstalled reads an Alpine installed database: blank-line separated
+// records of single-letter fields.
+func parseAPKInstalled(data []byte, location string) []Package {
+	var pkgs []Package
+	var cur Package
+	flush := func() {
+		if cur.Name != "" && cur.Version != "" {
+			if cur.Source == "" {
+				cur.Source = cur.Name
+			}
+			cur.Type, cur.Location = TypeAPK, location
+			pkgs = append(pkgs, cur)
+		}
+		cur = Package{}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'P':
+			cur.Name = value
+		case 'V':
+			cur.Version = value
+		case 'o':
+			cur.Source = value
+		case 'L':
+			if value != "" {
+				cur.Licenses = []string{value}
+			}
+		}
+	}
+	flush()
+	return pkgs
+}
+
+// isPythonMetadata reports whether name is the metadata file of an installed
+// Python distribution.
+func isPythonMetadata(name string) bool {
+	dir, base := path.Base(path.Dir(name)), path.Base(name)
+	return (base == "METADATA" && strings.HasSuffix(dir, ".dist-info")) ||
+		(base == "PKG-INFO" && strings.HasSuffix(dir, ".egg-info"))
+}
+
+// parsePythonMetadata reads Name, Version and License from a core metadata
+// file, which is RFC 822 headers followed by the long description.
+func parsePythonMetadata(data []byte, location string) (Package, bool) {
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(header, '\n', '\n'))))
+	h, err := r.ReadMIMEHeader()
+	if err != nil && len(h) == 0 {
+		return Package{}, false
+	}
+	p := Package{
+		Type:     TypePyPI,
+		Name:     h.Get("Name"),
+		Version:  h.Get("Version"),
+		Location: location,
+	}
+	if p.Name == "" || p.Version == "" {
+		return Package{}, false
+	}
+	if l := h.Get("License-Expression"); l != "" {
+		p.Licenses = []string{l}
+	} else if l := h.Get("License"); l != "" && l != "UNKNOWN" && !strings.Contains(l, "\n") && len(l) < 100 {
+		p.Licenses = []string{l}
+	}
+	return p, true
+}
+
+var pythonNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizePythonName applies the PEP 503 name normalization PyPI and OSV use.
+func normalizePythonName(name string) string {
+	return strings.ToLower(pythonNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// isNPMPackageJSON reports whether name is the manifest of a package installed
+// under node_modules (not a nested file of one).
+func isNPMPackageJSON(name string) bool {
+	if path.Base(name) != "package.json" {
+		return false
+	}
+	dir := path.Dir(name)
+	parent := path.Dir(dir)
+	if strings.HasPrefix(path.Base(parent), "@") {
+		parent = path.Dir(parent)
+	}
+	return path.Base(parent) == "node_modules"
+}
+
+// parseNPMPackageJSON reads name, version and license from a package.json.
+func parseNPMPackageJSON(data []byte, location string) (Package, bool) {
+	var pj struct {
+		Name    string          `json:"name"`
+		Version string          `json:"version"`
+		License json.RawMessage `json:"license"`
+	}
+	if err := json.Unmarshal(data, &pj); err != nil || pj.Name == "" || pj.Version == "" {
+		return Package{}, false
+	}
+	p := Package{Type: TypeNPM, Name: pj.Name, Version: pj.Version, Location: location}
+	// license is an SPDX expression string, or the legacy {"type": ...} form.
+	var license string
+	if json.Unmarshal(pj.License, &license) != nil {
+		var legacy struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(pj.License, &legacy) == nil {
+			license = legacy.Type
+		}
+	}
+	if license != "" {
+		p.Licenses = []string{license}
+	}
+	return p, true
+}
@@ -0,0 +1,224 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Media types SBOMs are attached to images under, as OCI referrer artifact
+// types or in-toto predicate types.
+const (
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+	MediaTypeSPDX      = "application/spdx+json"
+	PredicateSPDX      = "https://spdx.dev/Document"
+	PredicateCycloneDX = "https://cyclonedx.org/bom"
+)
+
+// Parse reads an SBOM someone else produced: CycloneDX or SPDX JSON, bare or
+// wrapped in an in-toto statement (BuildKit attestations) or a DSSE envelope
+// (cosign attest). Components without a package URL, such as the image
+// itself or files, are left out.
+func Parse(data []byte) (*Inventory, error) {
+	var probe struct {
+		BOMFormat   string          `json:"bomFormat"`
+		SPDXVersion string          `json:"spdxVersion"`
+		Predicate   json.RawMessage `json:"predicate"`
+		Payload     string          `json:"payload"`
+	}
+	// Some tools write a UTF-8 byte order mark.
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	switch {
+	case probe.BOMFormat == "CycloneDX":
+		return parseCycloneDX(data)
+	case probe.SPDXVersion != "":
+		return parseSPDX(data)
+	case len(probe.Predicate) > 0:
+		return Parse(probe.Predicate)
+	case probe.Payload != "":
+		payload, err := base64.StdEncoding.DecodeString(probe.Payload)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(payload)
+	}
+	return nil, errors.New("not a CycloneDX or SPDX document")
+}
+
+func parseCycloneDX(data []byte) (*Inventory, error) {
+	type component struct {
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		PURL     string `json:"purl"`
+		Licenses []struct {
+			License struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"license"`
+			Expression string `json:"expression"`
+		} `json:"licenses"`
+		Components json.RawMessage `json:"components"`
+	}
+	var bom struct {
+		Components json.RawMessage `json:"components"`
+	}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		return nil, err
+	}
+	inv := &Inventory{}
+	var walk func(raw json.RawMessage) error
+	walk = func(raw json.RawMessage) error {
+		if len(raw) == 0 {
+			return nil
+		}
+		var components []component
+		if err := json.Unmarshal(raw, &components); err != nil {
+			return err
+		}
+		for _, c := range components {
+			if p, distro, ok := packageFromPURL(c.PURL); ok {
+				for _, l := range c.Licenses {
+					switch {
+					case l.Expression != "":
+						p.Licenses = append(p.Licenses, l.Expression)
+					case l.License.ID != "":
+						p.Licenses = append(p.Licenses, l.License.ID)
+					case l.License.Name != "":
+						p.Licenses = append(p.Licenses, l.License.Name)
+					}
+				}
+				inv.Packages = append(inv.Packages, p)
+				if inv.Distro.ID == "" {
+					inv.Distro = distro
+				}
+			}
+			if err := walk(c.Components); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(bom.Components); err != nil {
+		return nil, err
+	}
+	inv.Packages = dedupe(inv.Packages)
+	return inv, nil
+}
+
+func parseSPDX(data []byte) (*Inventory, error) {
+	var doc struct {
+		Packages []struct {
+			Name             string `json:"name"`
+			LicenseDeclared  string `json:"licenseDeclared"`
+			LicenseConcluded string `json:"licenseConcluded"`
+			ExternalRefs     []struct {
+				Type    string `json:"referenceType"`
+				Locator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	inv := &Inventory{}
+	for _, pkg := range doc.Packages {
+		for _, ref := range pkg.ExternalRefs {
+			if ref.Type != "purl" {
+				continue
+			}
+			p, distro, ok := packageFromPURL(ref.Locator)
+			if !ok {
+				continue
+			}
+			for _, l := range []string{pkg.LicenseDeclared, pkg.LicenseConcluded} {
+				if l != "" && l != "NOASSERTION" && l != "NONE" {
+					p.Licenses = []string{l}
+					break
+				}
+			}
+			inv.Packages = append(inv.Packages, p)
+			if inv.Distro.ID == "" {
+				inv.Distro = distro
+			}
+			break
+		}
+	}
+	inv.Packages = dedupe(inv.Packages)
+	return inv, nil
+}
+
+// packageFromPURL turns a package URL back into a Package, plus the distro its
+// "distro" qualifier names. Image and file purls (oci, docker) are not
+// packages and report false.
+func packageFromPURL(purl string) (Package, Distro, bool) {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return Package{}, Distro{}, false
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	rest, version, _ := strings.Cut(rest, "@")
+	typ, path, ok := strings.Cut(rest, "/")
+	if !ok || version == "" {
+		return Package{}, Distro{}, false
+	}
+	typ = strings.ToLower(typ)
+	if typ == "oci" || typ == "docker" {
+		return Package{}, Distro{}, false
+	}
+	unescape := func(s string) string {
+		if u, err := url.PathUnescape(s); err == nil {
+			return u
+		}
+		return s
+	}
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		segments[i] = unescape(segments[i])
+	}
+	p := Package{Type: typ, Version: unescape(version)}
+	switch typ {
+	case TypeDeb, TypeAPK:
+		// The namespace is the vendor (debian, ubuntu, alpine), not part of
+		// the name.
+		p.Name = segments[len(segments)-1]
+	default:
+		p.Name = strings.Join(segments, "/")
+	}
+
+	var distro Distro
+	query, _ := url.ParseQuery(rawQuery)
+	for _, key := range []string{"upstream", "source", "origin"} {
+		if v := query.Get(key); v != "" {
+			// syft writes "upstream=openssl@3.0.11-1"; only the name matters.
+			p.Source, _, _ = strings.Cut(v, "@")
+			break
+		}
+	}
+	if (typ == TypeDeb || typ == TypeAPK) && p.Source == "" {
+		p.Source = p.Name
+	}
+	if d := query.Get("distro"); d != "" {
+		if i := strings.LastIndex(d, "-"); i > 0 {
+			distro = Distro{ID: d[:i], Version: d[i+1:]}
+		}
+	}
+	return p, distro, p.Name != ""
+}
+
+// IsSBOMMediaType reports whether mediaType (a referrer's artifact type or an
+// in-toto predicate type) carries an SBOM Parse understands.
+func IsSBOMMediaType(mediaType string) bool {
+	switch mediaType {
+	case MediaTypeCycloneDX, MediaTypeSPDX, PredicateSPDX, PredicateCycloneDX:
+		return true
+	}
+	return strings.HasPrefix(mediaType, PredicateCycloneDX) ||
+		strings.HasPrefix(mediaType, "https://spdx.dev/Document/")
+}
@@ -0,0 +1,234 @@
+// Package sbom builds software bills of materials for container images without
+// running them. It reads the flattened image filesystem and catalogs OS
+// packages (dpkg, apk), Go modules embedded in binaries, Python distributions
+// and npm packages, then writes the result as CycloneDX or SPDX JSON.
+package sbom
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Package types, matching the purl type each maps to.
+const (
+	TypeDeb    = "deb"
+	TypeAPK    = "apk"
+	TypeGolang = "golang"
+	TypePyPI   = "pypi"
+	TypeNPM    = "npm"
+)
+
+// Package is one cataloged component.
+type Package struct {
+	Type    string
+	Name    string
+	Version string
+	// Source is the source package an OS binary package was built from
+	// (dpkg Source:, apk origin). Vulnerability databases for Debian, Ubuntu
+	// and Alpine key advisories by it.
+	Source   string
+	Licenses []string
+	// Location is the path inside the image the package was found at.
+	Location string
+}
+
+// Distro identifies the image's OS from /etc/os-release.
+type Distro struct {
+	ID      string // e.g. debian, ubuntu, alpine
+	Version string // VERSION_ID, e.g. 12, 22.04, 3.19.1
+}
+
+// Inventory is everything cataloged in one image.
+type Inventory struct {
+	Image    string // reference the image was read from
+	Digest   string // manifest digest
+	Distro   Distro
+	Packages []Package
+	// Origin says where the packages came from: "generated" when Catalog
+	// read the image, or the attached SBOM they were parsed from.
+	Origin string
+	// Artifact is the config media type of a non-image artifact (a Helm
+	// chart, the server manifest), which is listed without packages.
+	Artifact string
+}
+
+// maxBinarySize bounds how much of one executable is buffered to read Go build
+// info from it. Larger files are skipped.
+const maxBinarySize = 1 << 30
+
+// Catalog reads img's flattened filesystem once and returns its packages,
+// sorted by type, name and version.
+func Catalog(img v1.Image) (*Inventory, error) {
+	rc := mutate.Extract(img)
+	defer rc.Close()
+
+	inv := &Inventory{Origin: "generated"}
+	var copyrights = map[string][]string{} // dpkg package → licenses from its copyright file
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read image filesystem: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean("/" + hdr.Name)
+
+		switch {
+		case name == "/etc/os-release" || name == "/usr/lib/os-release":
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if d := parseOSRelease(data); d.ID != "" && (inv.Distro.ID == "" || name == "/etc/os-release") {
+				inv.Distro = d
+			}
+		case name == "/var/lib/dpkg/status" || (path.Dir(name) == "/var/lib/dpkg/status.d" && !strings.HasSuffix(name, ".md5sums")):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			inv.Packages = append(inv.Packages, parseDpkgStatus(data, name)...)
+		case strings.HasPrefix(name, "/usr/share/doc/") && path.Base(name) == "copyright":
+			data, err := io.ReadAll(io.LimitReader(tr, 1<<20))
+			if err != nil {
+				return nil, err
+			}
+			pkg := path.Base(path.Dir(name))
+			copyrights[pkg] = parseDebianCopyright(data)
+		case name == "/lib/apk/db/installed":
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			inv.Packages = append(inv.Packages, parseAPKInstalled(data, name)...)
+		case isPythonMetadata(name):
+			data, err := io.ReadAll(io.LimitReader(tr, 1<<20))
+			if err != nil {
+				return nil, err
+			}
+			if p, ok := parsePythonMetadata(data, name); ok {
+				inv.Packages = append(inv.Packages, p)
+			}
+		case isNPMPackageJSON(name):
+			data, err := io.ReadAll(io.LimitReader(tr, 1<<20))
+			if err != nil {
+				return nil, err
+			}
+			if p, ok := parseNPMPackageJSON(data, name); ok {
+				inv.Packages = append(inv.Packages, p)
+			}
+		case hdr.Mode&0o111 != 0 && hdr.Size > 4 && hdr.Size <= maxBinarySize:
+			br := bufio.NewReader(tr)
+			magic, err := br.Peek(4)
+			if err != nil || !bytes.Equal(magic, []byte("\x7fELF")) {
+				continue
+			}
+			pkgs, err := goModules(br, hdr.Size, name)
+			if err != nil {
+				return nil, err
+			}
+			inv.Packages = append(inv.Packages, pkgs...)
+		}
+	}
+
+	for i, p := range inv.Packages {
+		if p.Type == TypeDeb && len(p.Licenses) == 0 {
+			inv.Packages[i].Licenses = copyrights[p.Name]
+		}
+	}
+	inv.Packages = dedupe(inv.Packages)
+	return inv, nil
+}
+
+// dedupe drops repeated packages (the same module in several binaries keeps
+// its first location) and sorts the rest.
+func dedupe(pkgs []Package) []Package {
+	seen := map[string]bool{}
+	out := pkgs[:0]
+	for _, p := range pkgs {
+		key := p.Type + "\x00" + p.Name + "\x00" + p.Version
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return out
+}
+
+// PURL returns the package URL for p. distro qualifies OS packages.
+func (p Package) PURL(distro Distro) string {
+	switch p.Type {
+	case TypeDeb, TypeAPK:
+		ns := distro.ID
+		if ns == "" {
+			ns = map[string]string{TypeDeb: "debian", TypeAPK: "alpine"}[p.Type]
+		}
+		purl := fmt.Sprintf("pkg:%s/%s/%s@%s", p.Type, ns, purlEscape(p.Name), purlEscape(p.Version))
+		var q []string
+		if distro.ID != "" {
+			q = append(q, "distro="+purlEscape(distro.ID+"-"+distro.Version))
+		}
+		if p.Source != "" && p.Source != p.Name {
+			key := "source"
+			if p.Type == TypeAPK {
+				key = "origin"
+			}
+			q = append(q, key+"="+purlEscape(p.Source))
+		}
+		if len(q) > 0 {
+			purl += "?" + strings.Join(q, "&")
+		}
+		return purl
+	case TypePyPI:
+		return "pkg:pypi/" + purlEscape(normalizePythonName(p.Name)) + "@" + purlEscape(p.Version)
+	case TypeNPM:
+		if scope, name, ok := strings.Cut(p.Name, "/"); ok {
+			return "pkg:npm/" + purlEscape(scope) + "/" + purlEscape(name) + "@" + purlEscape(p.Version)
+		}
+		return "pkg:npm/" + purlEscape(p.Name) + "@" + purlEscape(p.Version)
+	default:
+		return "pkg:" + p.Type + "/" + p.Name + "@" + purlEscape(p.Version)
+	}
+}
+
+// purlEscape percent-encodes the characters a purl component can't carry.
+func purlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '.', c == '-', c == '_', c == '~', c == '+':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -43,7 +43,7 @@ func Lookup(name string) (UI, bool) {
 // ResolveService confirms ui's well-known Service exists in namespace and returns its name. The Victoria
 // stack and grafana services are named deterministically by their operators (see Catalog), so a plain
 // get-by-name is the reliable lookup; if the operator ever renames one, pass --service explicitly.
-func ResolveService(ctx context.Context, cs *kubernetes.Clientset, namespace string, ui UI) (string, error) {
+func ResolveService(ctx context.Context, cs kubernetes.Interface, namespace string, ui UI) (string, error) {
 	if _, err := cs.CoreV1().Services(namespace).Get(ctx, ui.Service, metav1.GetOptions{}); err != nil {
 		return "", fmt.Errorf("telemetry service %q not found in namespace %q (is the operator installed with --observability-mode=%s?); pass --service to override: %w", ui.Service, namespace, ui.ModeHint, err)
 	}
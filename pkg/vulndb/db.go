@@ -0,0 +1,455 @@
+// Package vulndb matches SBOM inventories against an offline copy of OSV
+// advisories (https://ossf.github.io/osv-schema/), the format the Debian,
+// Ubuntu, Alpine, Go, PyPI and npm advisory feeds are all published in. The
+// database is whatever the caller downloaded ahead of time, e.g. the per-
+// ecosystem all.zip archives from https://osv-vulnerabilities.storage.googleapis.com,
+// so scanning needs no network access.
+package vulndb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/wandb/wsm/pkg/sbom"
+)
+
+// advisory is the subset of an OSV record matching needs.
+type advisory struct {
+	ID               string          `json:"id"`
+	Summary          string          `json:"summary"`
+	Aliases          []string        `json:"aliases"`
+	Withdrawn        string          `json:"withdrawn"`
+	Severity         []osvSeverity   `json:"severity"`
+	Affected         []affected      `json:"affected"`
+	DatabaseSpecific json.RawMessage `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string  `json:"type"`
+		Events []event `json:"events"`
+	} `json:"ranges"`
+	Versions          []string        `json:"versions"`
+	EcosystemSpecific json.RawMessage `json:"ecosystem_specific"`
+	DatabaseSpecific  json.RawMessage `json:"database_specific"`
+}
+
+type event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+func (e event) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	}
+	return e.Limit
+}
+
+// packageKey is an ecosystem (with its release, e.g. "Debian:12") and a
+// package name within it.
+type packageKey struct {
+	ecosystem string
+	name      string
+}
+
+type entry struct {
+	adv      *advisory
+	affected *affected
+}
+
+// DB is an in-memory index of advisories by affected package.
+type DB struct {
+	entries    map[packageKey][]entry
+	advisories int
+}
+
+// Finding is one advisory affecting one package.
+type Finding struct {
+	ID       string
+	Aliases  []string
+	Summary  string
+	Severity Severity
+	Package  sbom.Package
+	// FixedVersion is the first version that resolves the advisory, or ""
+	// when no fix has been released.
+	FixedVersion string
+}
+
+// Unevaluated is an advisory for one of the inventory's packages that Match
+// could not decide either way, so it is neither a finding nor ruled out.
+type Unevaluated struct {
+	ID      string
+	Package sbom.Package
+	Reason  string
+}
+
+// Load reads advisories from path, which is an OSV JSON file (one record or
+// an array of them), a .zip of JSON records, or a directory of either.
+func Load(path string) (*DB, error) {
+	db := &DB{entries: map[packageKey][]entry{}}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("open vulnerability database: %w", err)
+	}
+	if !info.IsDir() {
+		return db, db.loadFile(path)
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(p)); ext != ".json" && ext != ".zip" {
+			return nil
+		}
+		return db.loadFile(p)
+	})
+	return db, err
+}
+
+// Len returns the number of advisories loaded.
+func (db *DB) Len() int { return db.advisories }
+
+func (db *DB) loadFile(path string) error {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", path, err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", path, f.Name, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", path, f.Name, err)
+			}
+			if err := db.add(data); err != nil {
+				return fmt.Errorf("%s: %s: %w", path, f.Name, err)
+			}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := db.add(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// add indexes one OSV record, or a JSON array of them.
+func (db *DB) add(data []byte) error {
+	data = bytes.TrimSpace(data)
+	var records []*advisory
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &records); err != nil {
+			return err
+		}
+	} else {
+		var adv advisory
+		if err := json.Unmarshal(data, &adv); err != nil {
+			return err
+		}
+		records = []*advisory{&adv}
+	}
+	for _, adv := range records {
+		if adv.ID == "" || adv.Withdrawn != "" {
+			continue
+		}
+		db.advisories++
+		for i := range adv.Affected {
+			a := &adv.Affected[i]
+			eco, ok := normalizeEcosystem(a.Package.Ecosystem)
+			if !ok {
+				continue
+			}
+			key := packageKey{eco, normalizeName(eco, a.Package.Name)}
+			db.entries[key] = append(db.entries[key], entry{adv: adv, affected: a})
+		}
+	}
+	return nil
+}
+
+// normalizeEcosystem reduces an OSV ecosystem to "<name>[:<release>]".
+// Ubuntu qualifies releases ("Ubuntu:22.04:LTS") and publishes Pro-only
+// advisories ("Ubuntu:Pro:…") that don't apply to the public archive.
+func normalizeEcosystem(eco string) (string, bool) {
+	parts := strings.Split(eco, ":")
+	if parts[0] == "Ubuntu" {
+		if len(parts) < 2 || parts[1] == "Pro" {
+			return "", false
+		}
+		return parts[0] + ":" + parts[1], true
+	}
+	return eco, eco != ""
+}
+
+func normalizeName(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+	}
+	return name
+}
+
+// ecosystemOf returns the OSV ecosystem and package name p is published
+// under, or "" when the database can't cover it (e.g. an unknown distro).
+func ecosystemOf(p sbom.Package, distro sbom.Distro) (eco, name string) {
+	source := p.Source
+	if source == "" {
+		source = p.Name
+	}
+	switch p.Type {
+	case sbom.TypeDeb:
+		switch distro.ID {
+		case "debian":
+			major, _, _ := strings.Cut(distro.Version, ".")
+			if major == "" {
+				return "", ""
+			}
+			return "Debian:" + major, source
+		case "ubuntu":
+			return "Ubuntu:" + distro.Version, source
+		}
+	case sbom.TypeAPK:
+		switch distro.ID {
+		case "alpine":
+			parts := strings.SplitN(distro.Version, ".", 3)
+			if len(parts) < 2 {
+				return "", ""
+			}
+			return "Alpine:v" + parts[0] + "." + parts[1], source
+		case "wolfi":
+			return "Wolfi", source
+		case "chainguard":
+			return "Chainguard", source
+		}
+	case sbom.TypeGolang:
+		return "Go", p.Name
+	case sbom.TypePyPI:
+		return "PyPI", normalizeName("PyPI", p.Name)
+	case sbom.TypeNPM:
+		return "npm", p.Name
+	}
+	return "", ""
+}
+
+// Match returns the advisories affecting inv's packages, most severe first,
+// and the ones it could not evaluate: those of an ecosystem without a version
+// ordering, and those that only give a GIT commit range.
+func (db *DB) Match(inv *sbom.Inventory) ([]Finding, []Unevaluated) {
+	var findings []Finding
+	var unevaluated []Unevaluated
+	for _, p := range inv.Packages {
+		eco, name := ecosystemOf(p, inv.Distro)
+		if eco == "" {
+			continue
+		}
+		base, _, _ := strings.Cut(eco, ":")
+		cmp, ok := comparatorFor(base)
+		seen := map[string]bool{}
+		for _, e := range db.entries[packageKey{eco, name}] {
+			if seen[e.adv.ID] {
+				continue
+			}
+			if !ok {
+				seen[e.adv.ID] = true
+				unevaluated = append(unevaluated, Unevaluated{ID: e.adv.ID, Package: p, Reason: "unsupported ecosystem " + base})
+				continue
+			}
+			hit, fixed, err := affects(e.affected, p.Version, cmp)
+			if err != nil {
+				seen[e.adv.ID] = true
+				unevaluated = append(unevaluated, Unevaluated{ID: e.adv.ID, Package: p, Reason: err.Error()})
+				continue
+			}
+			if !hit {
+				continue
+			}
+			seen[e.adv.ID] = true
+			findings = append(findings, Finding{
+				ID:           e.adv.ID,
+				Aliases:      e.adv.Aliases,
+				Summary:      e.adv.Summary,
+				Severity:     severityOf(e.adv, e.affected),
+				Package:      p,
+				FixedVersion: fixed,
+			})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if a.Package.Name != b.Package.Name {
+			return a.Package.Name < b.Package.Name
+		}
+		return a.ID < b.ID
+	})
+	return findings, unevaluated
+}
+
+// errGitRange is returned by affects for an entry that only bounds the
+// affected code by commits, which can't be placed among package versions.
+var errGitRange = errors.New("only a GIT commit range, without affected versions")
+
+// affects evaluates an OSV affected entry against version, returning whether
+// it is affected and the fix to upgrade to. GIT ranges are covered by the
+// entry's versions list, which OSV feeds enumerate alongside them; an entry
+// with neither that list nor a version range can't be evaluated.
+func affects(a *affected, version string, cmp compareFunc) (bool, string, error) {
+	for _, v := range a.Versions {
+		if cmp(v, version) == 0 {
+			return true, firstFixAbove(a, version, cmp), nil
+		}
+	}
+	git := false
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			git = true
+		}
+		if r.Type != "ECOSYSTEM" && r.Type != "SEMVER" {
+			continue
+		}
+		if inRange(r.Events, version, cmp) {
+			return true, firstFixAbove(a, version, cmp), nil
+		}
+	}
+	if git && len(a.Versions) == 0 && !hasVersionRange(a) {
+		return false, "", errGitRange
+	}
+	return false, "", nil
+}
+
+func hasVersionRange(a *affected) bool {
+	for _, r := range a.Ranges {
+		if r.Type == "ECOSYSTEM" || r.Type == "SEMVER" {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange applies the OSV range algorithm: walk the events in version order,
+// switching to affected at each introduced <= version and back at each fixed
+// <= version or last_affected < version.
+func inRange(events []event, version string, cmp compareFunc) bool {
+	sorted := append([]event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].version(), sorted[j].version()
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return cmp(a, b) < 0
+	})
+	affected := false
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || cmp(version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if cmp(version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if cmp(version, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// firstFixAbove returns the lowest fixed version greater than version. The
+// fixed events of GIT ranges are commits, not versions, and are skipped.
+func firstFixAbove(a *affected, version string, cmp compareFunc) string {
+	var best string
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, e := range r.Events {
+			if e.Fixed == "" || cmp(e.Fixed, version) <= 0 {
+				continue
+			}
+			if best == "" || cmp(e.Fixed, best) < 0 {
+				best = e.Fixed
+			}
+		}
+	}
+	return best
+}
+
+// severityOf picks the most specific rating an advisory carries: the
+// affected entry's own, the record's database_specific one, then a rating
+// derived from its severity scores.
+func severityOf(adv *advisory, a *affected) Severity {
+	for _, raw := range []json.RawMessage{a.EcosystemSpecific, a.DatabaseSpecific, adv.DatabaseSpecific} {
+		var ds struct {
+			Severity string `json:"severity"`
+			Urgency  string `json:"urgency"`
+		}
+		if len(raw) == 0 || json.Unmarshal(raw, &ds) != nil {
+			continue
+		}
+		for _, s := range []string{ds.Severity, ds.Urgency} {
+			if sev, err := ParseSeverity(s); err == nil && sev != Unknown {
+				return sev
+			}
+		}
+	}
+	best := Unknown
+	for _, s := range adv.Severity {
+		var sev Severity
+		switch s.Type {
+		case "CVSS_V3":
+			score, ok := cvss3BaseScore(s.Score)
+			if !ok {
+				continue
+			}
+			sev = severityFromScore(score)
+		case "Ubuntu":
+			sev, _ = ParseSeverity(s.Score)
+		}
+		if sev > best {
+			best = sev
+		}
+	}
+	return best
+}
@@ -0,0 +1,134 @@
+package vulndb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wandb/wsm/pkg/sbom"
+)
+
+func TestInRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []event
+		version string
+		want    bool
+	}{
+		{"introduced zero, fixed above", []event{{Introduced: "0"}, {Fixed: "1.2.0"}}, "1.1.9", true},
+		{"at the fix", []event{{Introduced: "0"}, {Fixed: "1.2.0"}}, "1.2.0", false},
+		{"below introduced", []event{{Introduced: "1.0.0"}, {Fixed: "1.2.0"}}, "0.9.0", false},
+		{"at introduced", []event{{Introduced: "1.0.0"}, {Fixed: "1.2.0"}}, "1.0.0", true},
+		{"no fix", []event{{Introduced: "1.0.0"}}, "9.0.0", true},
+		{"at last_affected", []event{{Introduced: "0"}, {LastAffected: "1.1.0"}}, "1.1.0", true},
+		{"above last_affected", []event{{Introduced: "0"}, {LastAffected: "1.1.0"}}, "1.1.1", false},
+		{"second range, between", []event{{Introduced: "1.0.0"}, {Fixed: "1.2.0"}, {Introduced: "2.0.0"}, {Fixed: "2.1.0"}}, "1.5.0", false},
+		{"second range, inside", []event{{Introduced: "1.0.0"}, {Fixed: "1.2.0"}, {Introduced: "2.0.0"}, {Fixed: "2.1.0"}}, "2.0.5", true},
+		{"events out of order", []event{{Fixed: "2.1.0"}, {Introduced: "2.0.0"}, {Fixed: "1.2.0"}, {Introduced: "1.0.0"}}, "1.1.0", true},
+	}
+	for _, tt := range tests {
+		if got := inRange(tt.events, tt.version, compareSemver); got != tt.want {
+			t.Errorf("%s: inRange(%s) = %v, want %v", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestAffects(t *testing.T) {
+	type rng = struct {
+		Type   string  `json:"type"`
+		Events []event `json:"events"`
+	}
+	gitRange := rng{Type: "GIT", Events: []event{{Introduced: "0"}, {Fixed: "a1b2c3d4"}}}
+	tests := []struct {
+		name      string
+		affected  affected
+		version   string
+		want      bool
+		wantFixed string
+		wantErr   error
+	}{
+		{
+			name:      "ecosystem range",
+			affected:  affected{Ranges: []rng{{Type: "ECOSYSTEM", Events: []event{{Introduced: "0"}, {Fixed: "1.2.0"}}}}},
+			version:   "1.1.0",
+			want:      true,
+			wantFixed: "1.2.0",
+		},
+		{
+			name:     "listed version",
+			affected: affected{Versions: []string{"1.0.0", "1.0.1"}},
+			version:  "1.0.1",
+			want:     true,
+		},
+		{
+			name:     "GIT range covered by the versions list",
+			affected: affected{Ranges: []rng{gitRange}, Versions: []string{"1.0.0"}},
+			version:  "1.0.1",
+		},
+		{
+			name:      "GIT range next to a SEMVER range, whose fix is used",
+			affected:  affected{Ranges: []rng{gitRange, {Type: "SEMVER", Events: []event{{Introduced: "0"}, {Fixed: "1.2.0"}}}}},
+			version:   "1.1.0",
+			want:      true,
+			wantFixed: "1.2.0",
+		},
+		{
+			name:     "GIT range alone",
+			affected: affected{Ranges: []rng{gitRange}},
+			version:  "1.0.0",
+			wantErr:  errGitRange,
+		},
+	}
+	for _, tt := range tests {
+		got, fixed, err := affects(&tt.affected, tt.version, compareSemver)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: affects() error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || fixed != tt.wantFixed {
+			t.Errorf("%s: affects() = %v, %q, want %v, %q", tt.name, got, fixed, tt.want, tt.wantFixed)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	db := &DB{entries: map[packageKey][]entry{}}
+	for _, record := range []string{
+		`{"id": "DSA-1", "affected": [{"package": {"ecosystem": "Debian:12", "name": "openssl"},
+			"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.15-1~deb12u1"}]}]}]}`,
+		`{"id": "GO-1", "affected": [{"package": {"ecosystem": "Go", "name": "golang.org/x/net"},
+			"ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "0.23.0"}]}]}]}`,
+		`{"id": "GHSA-git", "affected": [{"package": {"ecosystem": "Go", "name": "golang.org/x/net"},
+			"ranges": [{"type": "GIT", "repo": "https://go.googlesource.com/net", "events": [{"introduced": "0"}, {"fixed": "abc123"}]}]}]}`,
+		`{"id": "DSA-old", "withdrawn": "2024-01-01T00:00:00Z", "affected": [{"package": {"ecosystem": "Debian:12", "name": "openssl"},
+			"ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}]}]}]}`,
+	} {
+		if err := db.add([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inv := &sbom.Inventory{
+		Distro: sbom.Distro{ID: "debian", Version: "12.7"},
+		Packages: []sbom.Package{
+			{Type: sbom.TypeDeb, Name: "libssl3", Source: "openssl", Version: "3.0.14-1~deb12u2"},
+			{Type: sbom.TypeGolang, Name: "golang.org/x/net", Version: "v0.22.0"},
+		},
+	}
+
+	findings, unevaluated := db.Match(inv)
+	got := map[string]string{}
+	for _, f := range findings {
+		got[f.ID] = f.FixedVersion
+	}
+	want := map[string]string{"DSA-1": "3.0.15-1~deb12u1", "GO-1": "0.23.0"}
+	if len(got) != len(want) {
+		t.Fatalf("Match() findings = %v, want %v", got, want)
+	}
+	for id, fixed := range want {
+		if got[id] != fixed {
+			t.Errorf("Match() %s fixed in %q, want %q", id, got[id], fixed)
+		}
+	}
+	if len(unevaluated) != 1 || unevaluated[0].ID != "GHSA-git" {
+		t.Errorf("Match() unevaluated = %v, want GHSA-git", unevaluated)
+	}
+}
@@ -0,0 +1,141 @@
+package vulndb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Severity ranks a finding. The zero value is Unknown, which sorts lowest.
+type Severity int
+
+const (
+	Unknown Severity = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+// Severities lists the named levels in ascending order.
+var Severities = []Severity{Unknown, Low, Medium, High, Critical}
+
+func (s Severity) String() string {
+	switch s {
+	case Low:
+		return "LOW"
+	case Medium:
+		return "MEDIUM"
+	case High:
+		return "HIGH"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseSeverity accepts the level names, case-insensitively, plus the
+// synonyms advisory sources use (GitHub's "moderate", Debian's urgencies).
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "unknown", "unimportant", "negligible", "not yet assigned", "":
+		return Unknown, nil
+	case "low":
+		return Low, nil
+	case "medium", "moderate":
+		return Medium, nil
+	case "high", "important":
+		return High, nil
+	case "critical":
+		return Critical, nil
+	}
+	return Unknown, fmt.Errorf("unknown severity %q (want low, medium, high or critical)", s)
+}
+
+// severityFromScore maps a CVSS base score to its qualitative rating.
+func severityFromScore(score float64) Severity {
+	switch {
+	case score >= 9:
+		return Critical
+	case score >= 7:
+		return High
+	case score >= 4:
+		return Medium
+	case score > 0:
+		return Low
+	default:
+		return Unknown
+	}
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.x vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func cvss3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+	m := map[string]string{}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, ":"); ok {
+			m[k] = v
+		}
+	}
+	changed := m["S"] == "C"
+	weights := []struct {
+		metric string
+		values map[string]float64
+	}{
+		{"AV", map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}},
+		{"AC", map[string]float64{"L": 0.77, "H": 0.44}},
+		{"PR", map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}},
+		{"UI", map[string]float64{"N": 0.85, "R": 0.62}},
+		{"C", map[string]float64{"H": 0.56, "L": 0.22, "N": 0}},
+		{"I", map[string]float64{"H": 0.56, "L": 0.22, "N": 0}},
+		{"A", map[string]float64{"H": 0.56, "L": 0.22, "N": 0}},
+	}
+	w := map[string]float64{}
+	for _, metric := range weights {
+		v, ok := metric.values[m[metric.metric]]
+		if !ok {
+			return 0, false
+		}
+		w[metric.metric] = v
+	}
+	if changed {
+		// Privileges weigh more when the impact crosses a scope boundary.
+		switch m["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp1(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp1(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp1 is the CVSS v3.1 Roundup function: the smallest number with one
+// decimal place that is >= x, computed without floating-point drift.
+func roundUp1(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
@@ -0,0 +1,316 @@
+package vulndb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// compareFunc orders two versions of one ecosystem: negative if a < b, zero
+// if equal, positive if a > b.
+type compareFunc func(a, b string) int
+
+// comparatorFor returns the version ordering of an OSV ecosystem (its name
+// without the ":release" suffix). ok is false for an ecosystem whose ordering
+// isn't implemented; its versions can't be matched against ranges.
+func comparatorFor(ecosystem string) (cmp compareFunc, ok bool) {
+	switch ecosystem {
+	case "Debian", "Ubuntu":
+		return compareDpkg, true
+	case "Alpine", "Wolfi", "Chainguard":
+		return compareAPK, true
+	case "PyPI":
+		return comparePEP440, true
+	case "Go", "npm":
+		return compareSemver, true
+	default:
+		return nil, false
+	}
+}
+
+// ---------------- dpkg ----------------
+
+// compareDpkg implements dpkg's [epoch:]upstream[-revision] ordering, where
+// '~' sorts before everything, even the end of the string.
+func compareDpkg(a, b string) int {
+	ae, au, ar := splitDpkg(a)
+	be, bu, br := splitDpkg(b)
+	if ae != be {
+		if ae < be {
+			return -1
+		}
+		return 1
+	}
+	if c := verrevcmp(au, bu); c != 0 {
+		return c
+	}
+	return verrevcmp(ar, br)
+}
+
+func splitDpkg(v string) (epoch int, upstream, revision string) {
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		if n, err := strconv.Atoi(e); err == nil {
+			epoch, v = n, rest
+		}
+	}
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+func dpkgOrder(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	case c == 0:
+		return 0
+	default:
+		return int(c) + 256
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func verrevcmp(a, b string) int {
+	i, j := 0, 0
+	at := func(s string, k int) byte {
+		if k < len(s) {
+			return s[k]
+		}
+		return 0
+	}
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := dpkgOrder(at(a, i)), dpkgOrder(at(b, j))
+			if ac != bc {
+				return ac - bc
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
+
+// ---------------- apk ----------------
+
+// apk suffixes in ascending order; a version without a suffix sits between
+// the pre-release ones and the post-release ones.
+var apkSuffixRank = map[string]int{
+	"alpha": -4, "beta": -3, "pre": -2, "rc": -1,
+	"cvs": 1, "svn": 2, "git": 3, "hg": 4, "p": 5,
+}
+
+type apkVersion struct {
+	numbers  []int
+	letter   byte
+	suffixes [][2]int // rank, number
+	revision int
+}
+
+var apkPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)*)([a-z]?)((?:_[a-z]+[0-9]*)*)(?:-r([0-9]+))?$`)
+var apkSuffix = regexp.MustCompile(`_([a-z]+)([0-9]*)`)
+
+func parseAPK(v string) (apkVersion, bool) {
+	m := apkPattern.FindStringSubmatch(v)
+	if m == nil {
+		return apkVersion{}, false
+	}
+	var out apkVersion
+	for _, n := range strings.Split(m[1], ".") {
+		i, _ := strconv.Atoi(n)
+		out.numbers = append(out.numbers, i)
+	}
+	if m[2] != "" {
+		out.letter = m[2][0]
+	}
+	for _, s := range apkSuffix.FindAllStringSubmatch(m[3], -1) {
+		rank, ok := apkSuffixRank[s[1]]
+		if !ok {
+			return apkVersion{}, false
+		}
+		n, _ := strconv.Atoi(s[2])
+		out.suffixes = append(out.suffixes, [2]int{rank, n})
+	}
+	out.revision, _ = strconv.Atoi(m[4])
+	return out, true
+}
+
+// compareAPK orders Alpine package versions. Versions it can't parse fall
+// back to dpkg ordering, which agrees on the common cases.
+func compareAPK(a, b string) int {
+	av, aok := parseAPK(a)
+	bv, bok := parseAPK(b)
+	if !aok || !bok {
+		return compareDpkg(a, b)
+	}
+	for k := 0; k < len(av.numbers) || k < len(bv.numbers); k++ {
+		if k >= len(av.numbers) {
+			return -1
+		}
+		if k >= len(bv.numbers) {
+			return 1
+		}
+		if c := av.numbers[k] - bv.numbers[k]; c != 0 {
+			return c
+		}
+	}
+	if c := int(av.letter) - int(bv.letter); c != 0 {
+		return c
+	}
+	for k := 0; k < len(av.suffixes) || k < len(bv.suffixes); k++ {
+		var as, bs [2]int
+		if k < len(av.suffixes) {
+			as = av.suffixes[k]
+		}
+		if k < len(bv.suffixes) {
+			bs = bv.suffixes[k]
+		}
+		if as[0] != bs[0] {
+			return as[0] - bs[0]
+		}
+		if as[1] != bs[1] {
+			return as[1] - bs[1]
+		}
+	}
+	return av.revision - bv.revision
+}
+
+// ---------------- semver ----------------
+
+// compareSemver orders Go module and npm versions. Go's toolchain versions
+// ("1.22rc1") and anything else that isn't semver fall back to dpkg ordering.
+func compareSemver(a, b string) int {
+	av, aerr := semver.NewVersion(strings.TrimPrefix(a, "v"))
+	bv, berr := semver.NewVersion(strings.TrimPrefix(b, "v"))
+	if aerr != nil || berr != nil {
+		return compareDpkg(strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v"))
+	}
+	return av.Compare(bv)
+}
+
+// ---------------- PEP 440 ----------------
+
+var pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?(\d*))?` +
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d*))?` +
+	`(?:[-_.]?(dev)[-_.]?(\d*))?` +
+	`(?:\+[a-z0-9._-]*)?$`)
+
+// pep440Key is a parsed version in sortable form. Missing phases take the
+// sentinel values PEP 440 orders them by.
+type pep440Key struct {
+	epoch   int
+	release []int
+	pre     [2]int // phase rank (a 0, b 1, rc 2), number
+	post    int
+	dev     int
+}
+
+const (
+	pepMin = -1 << 30
+	pepMax = 1 << 30
+)
+
+func parsePEP440(v string) (pep440Key, bool) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+	if m == nil {
+		return pep440Key{}, false
+	}
+	var k pep440Key
+	k.epoch, _ = strconv.Atoi(m[1])
+	for _, n := range strings.Split(m[2], ".") {
+		i, _ := strconv.Atoi(n)
+		k.release = append(k.release, i)
+	}
+	num := func(s string) int { n, _ := strconv.Atoi(s); return n }
+
+	hasPre, hasPost, hasDev := m[3] != "", m[5] != "" || m[6] != "", m[8] != ""
+	switch {
+	case hasPre:
+		rank := map[string]int{"a": 0, "alpha": 0, "b": 1, "beta": 1, "c": 2, "rc": 2, "pre": 2, "preview": 2}[m[3]]
+		k.pre = [2]int{rank, num(m[4])}
+	case hasDev && !hasPost:
+		// 1.0.dev1 sorts before 1.0a1.
+		k.pre = [2]int{pepMin, 0}
+	default:
+		k.pre = [2]int{pepMax, 0}
+	}
+	switch {
+	case m[5] != "":
+		k.post = num(m[5])
+	case m[6] != "":
+		k.post = num(m[7])
+	default:
+		k.post = pepMin
+	}
+	if hasDev {
+		k.dev = num(m[9])
+	} else {
+		k.dev = pepMax
+	}
+	return k, true
+}
+
+// comparePEP440 orders Python package versions.
+func comparePEP440(a, b string) int {
+	ak, aok := parsePEP440(a)
+	bk, bok := parsePEP440(b)
+	if !aok || !bok {
+		return compareDpkg(a, b)
+	}
+	if ak.epoch != bk.epoch {
+		return ak.epoch - bk.epoch
+	}
+	for i := 0; i < len(ak.release) || i < len(bk.release); i++ {
+		var x, y int
+		if i < len(ak.release) {
+			x = ak.release[i]
+		}
+		if i < len(bk.release) {
+			y = bk.release[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	for _, pair := range [][2]int{{ak.pre[0], bk.pre[0]}, {ak.pre[1], bk.pre[1]}, {ak.post, bk.post}, {ak.dev, bk.dev}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
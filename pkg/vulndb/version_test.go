@@ -0,0 +1,68 @@
+package vulndb
+
+import "testing"
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestComparators(t *testing.T) {
+	tests := []struct {
+		ecosystem string
+		a, b      string
+		want      int
+	}{
+		// dpkg
+		{"Debian", "1.0", "1.0", 0},
+		{"Debian", "1.0", "1.1", -1},
+		{"Debian", "1:1.0", "2.0", 1},
+		{"Debian", "1.0~rc1", "1.0", -1},
+		{"Debian", "1.0-1", "1.0-1+deb12u1", -1},
+		{"Debian", "2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"Ubuntu", "1.2.3-0ubuntu1", "1.2.3-0ubuntu1.1", -1},
+		// apk
+		{"Alpine", "1.2.3-r0", "1.2.3-r1", -1},
+		{"Alpine", "1.2.3_rc1-r0", "1.2.3-r0", -1},
+		{"Alpine", "1.2.3_p1-r0", "1.2.3-r0", 1},
+		{"Alpine", "1.2.3a-r0", "1.2.3-r0", 1},
+		{"Wolfi", "3.10-r0", "3.9-r5", 1},
+		// PEP 440
+		{"PyPI", "1.0", "1.0.0", 0},
+		{"PyPI", "1.0.dev1", "1.0a1", -1},
+		{"PyPI", "1.0a1", "1.0b1", -1},
+		{"PyPI", "1.0rc1", "1.0", -1},
+		{"PyPI", "1.0", "1.0.post1", -1},
+		{"PyPI", "1!0.1", "2.0", 1},
+		// semver
+		{"Go", "v1.2.3", "1.2.3", 0},
+		{"Go", "v1.2.3", "v1.10.0", -1},
+		{"Go", "v1.0.0-rc.1", "v1.0.0", -1},
+		{"npm", "4.17.21", "4.17.20", 1},
+	}
+	for _, tt := range tests {
+		cmp, ok := comparatorFor(tt.ecosystem)
+		if !ok {
+			t.Fatalf("comparatorFor(%q) is unsupported", tt.ecosystem)
+		}
+		if got := sign(cmp(tt.a, tt.b)); got != tt.want {
+			t.Errorf("%s: compare(%q, %q) = %d, want %d", tt.ecosystem, tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(cmp(tt.b, tt.a)); got != -tt.want {
+			t.Errorf("%s: compare(%q, %q) = %d, want %d", tt.ecosystem, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestComparatorForUnsupported(t *testing.T) {
+	for _, eco := range []string{"Maven", "crates.io", "RubyGems", ""} {
+		if cmp, ok := comparatorFor(eco); ok || cmp != nil {
+			t.Errorf("comparatorFor(%q) = supported, want unsupported", eco)
+		}
+	}
+}